@@ -1,11 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/fs"
 	"math/big"
 	"os"
@@ -15,6 +17,8 @@ import (
 	"github.com/joho/godotenv"
 	whisper "github.com/rotationalio/whisper/pkg"
 	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/archive"
+	"github.com/rotationalio/whisper/pkg/audit"
 	"github.com/rotationalio/whisper/pkg/config"
 	"github.com/urfave/cli/v2"
 )
@@ -32,10 +36,25 @@ func main() {
 		&cli.StringFlag{
 			Name:    "endpoint",
 			Aliases: []string{"e", "url", "u"},
-			Usage:   "endpoint to connect to the whisper service on",
+			Usage:   "endpoint to connect to the whisper service on (url or unix:///path/to.sock)",
 			EnvVars: []string{"WHISPER_ENDPOINT", "WHISPER_URL"},
 			Value:   "https://api.whisper.rotational.dev",
 		},
+		&cli.StringFlag{
+			Name:    "cert",
+			Usage:   "client certificate for servers that require mutual tls",
+			EnvVars: []string{"WHISPER_CLIENT_CERT"},
+		},
+		&cli.StringFlag{
+			Name:    "key",
+			Usage:   "private key of the client certificate",
+			EnvVars: []string{"WHISPER_CLIENT_KEY"},
+		},
+		&cli.StringFlag{
+			Name:    "ca",
+			Usage:   "ca bundle to verify the server certificate with instead of the system roots",
+			EnvVars: []string{"WHISPER_CA_FILE"},
+		},
 	}
 
 	app.Commands = []*cli.Command{
@@ -48,9 +67,131 @@ func main() {
 				&cli.StringFlag{
 					Name:    "addr",
 					Aliases: []string{"a"},
-					Usage:   "address to bind the whisper server on",
+					Usage:   "address to bind the whisper server on (host:port, unix:///path/to.sock, or systemd)",
 					EnvVars: []string{"WHISPER_BIND_ADDR"},
 				},
+				&cli.StringFlag{
+					Name:    "config",
+					Aliases: []string{"c"},
+					Usage:   "path to a yaml config file; environment variables take precedence",
+					EnvVars: []string{config.FileEnv},
+				},
+			},
+		},
+		{
+			Name:     "audit",
+			Usage:    "manage the whisper security audit log",
+			Category: "server",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "verify",
+					Usage:     "check the audit log hash chain for gaps or tampering",
+					ArgsUsage: "path [path ...]",
+					Action:    auditVerify,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "path",
+							Aliases: []string{"p"},
+							Usage:   "path of the audit log if not specified as an argument",
+							EnvVars: []string{"WHISPER_AUDIT_PATH"},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:     "admin",
+			Usage:    "inspect and destroy the secrets stored by the whisper server",
+			Category: "admin",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "token",
+					Aliases: []string{"t"},
+					Usage:   "admin token to authenticate with the whisper server",
+					EnvVars: []string{"WHISPER_ADMIN_TOKEN"},
+				},
+			},
+			Subcommands: []*cli.Command{
+				{
+					Name:   "status",
+					Usage:  "show the status of the server and the health of its dependencies",
+					Before: initAdminClient,
+					Action: adminStatus,
+				},
+				{
+					Name:   "stats",
+					Usage:  "count the stored secrets by age and time until expiration",
+					Before: initAdminClient,
+					Action: adminStats,
+				},
+				{
+					Name:   "list",
+					Usage:  "list the metadata of the stored secrets",
+					Before: initAdminClient,
+					Action: adminList,
+					Flags: []cli.Flag{
+						&cli.IntFlag{
+							Name:    "page-size",
+							Aliases: []string{"n"},
+							Usage:   "the number of secrets to return per page",
+						},
+						&cli.StringFlag{
+							Name:    "page-token",
+							Aliases: []string{"p"},
+							Usage:   "next page token returned by a previous list",
+						},
+					},
+				},
+				{
+					Name:      "destroy",
+					Usage:     "destroy a secret without its password",
+					ArgsUsage: "token|hash",
+					Before:    initAdminClient,
+					Action:    adminDestroy,
+				},
+				{
+					Name:   "purge",
+					Usage:  "destroy all stored secrets; first run without --confirm to get a confirmation token",
+					Before: initAdminClient,
+					Action: adminPurge,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "confirm",
+							Aliases: []string{"c"},
+							Usage:   "confirmation token returned by a previous purge",
+						},
+					},
+				},
+				{
+					Name:   "maintenance",
+					Usage:  "show or change the maintenance mode of the server without a restart",
+					Before: initAdminClient,
+					Action: adminMaintenance,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  "enable",
+							Usage: "put the server into maintenance mode",
+						},
+						&cli.BoolFlag{
+							Name:  "disable",
+							Usage: "take the server out of maintenance mode",
+						},
+						&cli.BoolFlag{
+							Name:    "read-only",
+							Aliases: []string{"r"},
+							Usage:   "allow secrets to be fetched and destroyed during maintenance",
+						},
+						&cli.StringFlag{
+							Name:    "message",
+							Aliases: []string{"m"},
+							Usage:   "message shown to users during maintenance",
+						},
+						&cli.DurationFlag{
+							Name:  "eta",
+							Usage: "how long maintenance is expected to take",
+						},
+					},
+				},
 			},
 		},
 		{
@@ -70,10 +211,10 @@ func main() {
 					Aliases: []string{"G", "gs"},
 					Usage:   "generate a random secret of the specified length",
 				},
-				&cli.StringFlag{
+				&cli.StringSliceFlag{
 					Name:    "in",
 					Aliases: []string{"i", "u", "upload"},
-					Usage:   "upload a file as the secret contents",
+					Usage:   "upload a file as the secret contents; several files or a directory are archived",
 				},
 				&cli.StringFlag{
 					Name:    "password",
@@ -100,6 +241,35 @@ func main() {
 					Aliases: []string{"b", "b64"},
 					Usage:   "specify if the secret is base64 encoded (true if uploading a file, false if generated)",
 				},
+				&cli.StringFlag{
+					Name:    "callback",
+					Aliases: []string{"webhook"},
+					Usage:   "a webhook url to notify when the secret is fetched or destroyed",
+				},
+				&cli.StringFlag{
+					Name:    "email",
+					Aliases: []string{"notify"},
+					Usage:   "an email address to notify when the secret is fetched or destroyed",
+				},
+			},
+		},
+		{
+			Name:      "batch",
+			Usage:     "create several whisper secrets at once from a manifest file",
+			ArgsUsage: "manifest.yaml",
+			Category:  "client",
+			Before:    initClient,
+			Action:    batch,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "bundle",
+					Usage: "reveal all of the secrets with a single token",
+				},
+				&cli.IntFlag{
+					Name:    "generate-password",
+					Aliases: []string{"g", "gp"},
+					Usage:   "generate a random password of the specified length for secrets without a password",
+				},
 			},
 		},
 		{
@@ -120,6 +290,59 @@ func main() {
 					Aliases: []string{"o", "d", "download"},
 					Usage:   "download the secret to a file or to a directory",
 				},
+				&cli.StringFlag{
+					Name:  "owner",
+					Usage: "fetch the response to a secret request using its owner token",
+				},
+			},
+		},
+		{
+			Name:     "request",
+			Usage:    "request that someone else send you a whisper secret",
+			Category: "client",
+			Before:   initClient,
+			Action:   request,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:    "accesses",
+					Aliases: []string{"a"},
+					Usage:   "set number of allowed accesses of the response; default 1, -1 for unlimited until expiration",
+				},
+				&cli.DurationFlag{
+					Name:    "lifetime",
+					Aliases: []string{"l", "e", "expires", "expires-after"},
+					Usage:   "specify the lifetime of the request and response before it is deleted",
+				},
+			},
+		},
+		{
+			Name:      "respond",
+			Usage:     "respond to a whisper secret request by its token",
+			ArgsUsage: "token",
+			Category:  "client",
+			Before:    initClient,
+			Action:    respond,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "secret",
+					Aliases: []string{"s"},
+					Usage:   "input the secret as a string on the command line",
+				},
+				&cli.IntFlag{
+					Name:    "generate-secret",
+					Aliases: []string{"G", "gs"},
+					Usage:   "generate a random secret of the specified length",
+				},
+				&cli.StringSliceFlag{
+					Name:    "in",
+					Aliases: []string{"i", "u", "upload"},
+					Usage:   "upload a file as the secret contents; several files or a directory are archived",
+				},
+				&cli.BoolFlag{
+					Name:    "b64encoded",
+					Aliases: []string{"b", "b64"},
+					Usage:   "specify if the secret is base64 encoded (true if uploading a file, false if generated)",
+				},
 			},
 		},
 		{
@@ -144,6 +367,13 @@ func main() {
 			Before:   initClient,
 			Action:   status,
 		},
+		{
+			Name:     "policy",
+			Usage:    "get the limits enforced by the server when secrets are created",
+			Category: "client",
+			Before:   initClient,
+			Action:   policy,
+		},
 	}
 
 	app.Run(os.Args)
@@ -156,7 +386,7 @@ func main() {
 func serve(c *cli.Context) (err error) {
 	// Create server configuration
 	var conf config.Config
-	if conf, err = config.New(); err != nil {
+	if conf, err = config.Load(c.String("config")); err != nil {
 		return cli.Exit(err, 1)
 	}
 
@@ -177,6 +407,37 @@ func serve(c *cli.Context) (err error) {
 	return nil
 }
 
+// Verify the audit log; rotated or archived logs can be verified by specifying all of
+// the files in order from oldest to newest.
+func auditVerify(c *cli.Context) (err error) {
+	paths := c.Args().Slice()
+	if len(paths) == 0 {
+		if path := c.String("path"); path != "" {
+			paths = append(paths, path)
+		} else {
+			return cli.Exit("specify the path to the audit log", 1)
+		}
+	}
+
+	readers := make([]io.Reader, 0, len(paths))
+	for _, path := range paths {
+		var f *os.File
+		if f, err = os.Open(path); err != nil {
+			return cli.Exit(err, 1)
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+
+	var n int
+	if n, err = audit.Verify(io.MultiReader(readers...)); err != nil {
+		return cli.Exit(fmt.Errorf("audit log verification failed after %d records: %w", n, err), 1)
+	}
+
+	fmt.Printf("verified %d audit records\n", n)
+	return nil
+}
+
 //===========================================================================
 // Client Actions
 //===========================================================================
@@ -189,44 +450,13 @@ func create(c *cli.Context) (err error) {
 		Password: c.String("password"),
 		Accesses: c.Int("accesses"),
 		Lifetime: v1.Duration(c.Duration("lifetime")),
+		Callback: c.String("callback"),
+		Email:    c.String("email"),
 	}
 
 	// Add the secret to the request via one of the command line options
-	switch {
-	case c.String("secret") != "":
-		if c.Int("generate-secret") != 0 || c.String("in") != "" {
-			return cli.Exit("specify only one of secret, generate-secret, or in path", 1)
-		}
-
-		// Basic secret provided via the CLI
-		req.Secret = c.String("secret")
-		req.IsBase64 = c.Bool("b64encoded")
-
-	case c.String("in") != "":
-		if c.Int("generate-secret") != 0 {
-			// The check for secret has already been done
-			return cli.Exit("specify only one of secret, generate-secret, or in path", 1)
-		}
-
-		// Load the secret as base64 encoded data from a file
-		var data []byte
-		if data, err = os.ReadFile(c.String("in")); err != nil {
-			return cli.Exit(err, 1)
-		}
-		req.Filename = filepath.Base(c.String("in"))
-		req.Secret = base64.StdEncoding.EncodeToString(data)
-		req.IsBase64 = true
-
-	case c.Int("generate-secret") != 0:
-		// Generate a random secret of the specified length
-		if req.Secret, err = generateRandomSecret(c.Int("generate-secret")); err != nil {
-			return cli.Exit(err, 1)
-		}
-		req.IsBase64 = false
-
-	default:
-		// No secret was specified at all?
-		return cli.Exit("specify at least one of secret, generate-secret, or in path", 1)
+	if req.Secret, req.Filename, req.IsBase64, req.Archive, err = secretFromFlags(c); err != nil {
+		return err
 	}
 
 	// Handle password generation if requested
@@ -252,6 +482,47 @@ func create(c *cli.Context) (err error) {
 	return printJSON(rep)
 }
 
+func batch(c *cli.Context) (err error) {
+	if c.NArg() != 1 {
+		return cli.Exit("specify the path to one manifest file", 1)
+	}
+
+	var manifest *Manifest
+	if manifest, err = LoadManifest(c.Args().First()); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	if c.Bool("bundle") {
+		manifest.Bundle = true
+	}
+
+	// Handle password generation if requested
+	if gp := c.Int("generate-password"); gp > 0 {
+		if manifest.Password != "" {
+			return cli.Exit("the manifest already specifies a password", 1)
+		}
+		if manifest.Password, err = generateRandomSecret(gp); err != nil {
+			return cli.Exit(err, 1)
+		}
+		// Print the password so that it can be used to retrieve the secrets later
+		fmt.Printf("Password for retrieval: %s\n", manifest.Password)
+	}
+
+	var req *v1.CreateBatchRequest
+	if req, err = manifest.Request(filepath.Dir(c.Args().First())); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var rep *v1.CreateBatchReply
+	if rep, err = client.CreateBatch(ctx, req); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return printJSON(rep)
+}
+
 func fetch(c *cli.Context) (err error) {
 	if c.NArg() != 1 {
 		return cli.Exit("specify one token to fetch the secret for", 1)
@@ -263,11 +534,41 @@ func fetch(c *cli.Context) (err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// If an owner token is specified, fetch the response to a secret request instead
 	var rep *v1.FetchSecretReply
-	if rep, err = client.FetchSecret(ctx, token, password); err != nil {
+	if owner := c.String("owner"); owner != "" {
+		if password != "" {
+			return cli.Exit("specify either password or owner token, not both", 1)
+		}
+
+		if rep, err = client.FetchResponse(ctx, token, owner); err != nil {
+			return cli.Exit(err, 1)
+		}
+	} else {
+		if rep, err = client.FetchSecret(ctx, token, password); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+
+	// The secrets of a bundle are written to a directory if one is specified
+	if len(rep.Bundle) > 0 {
+		return writeBundle(c.String("out"), rep)
+	}
+
+	// Decode the secret and verify it against the digest computed by the server so that
+	// a secret that was corrupted is never written or printed.
+	var data []byte
+	if data, err = rep.Decode(); err != nil {
 		return cli.Exit(err, 1)
 	}
 
+	// Archives are unpacked if the secret is downloaded to a directory
+	if rep.Archive != "" {
+		if isDir, _ := isDirectory(c.String("out")); isDir {
+			return extractArchive(c.String("out"), data)
+		}
+	}
+
 	// Figure out where to write the file to; if out is a directory, write the
 	var path string
 
@@ -289,18 +590,9 @@ func fetch(c *cli.Context) (err error) {
 		path = rep.Filename
 	}
 
-	// If we've discovered a path to write the file to, write it there, decoding the
-	// data as necessary from base64. Otherwise print the json to stdout and exit.
+	// If we've discovered a path to write the file to, write the decoded data there.
+	// Otherwise print the json to stdout and exit.
 	if path != "" {
-		var data []byte
-		if rep.IsBase64 {
-			if data, err = base64.StdEncoding.DecodeString(rep.Secret); err != nil {
-				return cli.Exit(err, 1)
-			}
-		} else {
-			data = []byte(rep.Secret)
-		}
-
 		if err = os.WriteFile(path, data, 0644); err != nil {
 			return cli.Exit(err, 1)
 		}
@@ -314,6 +606,86 @@ func fetch(c *cli.Context) (err error) {
 	return printJSON(rep)
 }
 
+// Write the secrets of a bundle to the out directory, naming secrets that are not files
+// by their position in the bundle. The JSON response is printed if out is not specified.
+// Every secret in the bundle is verified against its digest before anything is written.
+func writeBundle(out string, rep *v1.FetchSecretReply) (err error) {
+	secrets := make([][]byte, 0, len(rep.Bundle))
+	for i, item := range rep.Bundle {
+		var data []byte
+		if data, err = item.Decode(); err != nil {
+			return cli.Exit(fmt.Errorf("secret %d of the bundle: %w", i+1, err), 1)
+		}
+		secrets = append(secrets, data)
+	}
+
+	if out == "" {
+		return printJSON(rep)
+	}
+
+	var isDir bool
+	if isDir, err = isDirectory(out); err != nil || !isDir {
+		return cli.Exit("a bundle can only be downloaded to a directory", 1)
+	}
+
+	for i, item := range rep.Bundle {
+		if item.Archive != "" {
+			if err = extractArchive(out, secrets[i]); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := filepath.Base(item.Filename)
+		if item.Filename == "" {
+			name = fmt.Sprintf("secret-%d.dat", i+1)
+		}
+
+		path := filepath.Join(out, name)
+		if err = os.WriteFile(path, secrets[i], 0644); err != nil {
+			return cli.Exit(err, 1)
+		}
+		fmt.Printf("secret written to %s\n", path)
+	}
+	return nil
+}
+
+func request(c *cli.Context) (err error) {
+	req := &v1.RequestSecretRequest{
+		Accesses: c.Int("accesses"),
+		Lifetime: v1.Duration(c.Duration("lifetime")),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var rep *v1.RequestSecretReply
+	if rep, err = client.RequestSecret(ctx, req); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return printJSON(rep)
+}
+
+func respond(c *cli.Context) (err error) {
+	if c.NArg() != 1 {
+		return cli.Exit("specify one token to respond to the secret request for", 1)
+	}
+
+	req := &v1.RespondSecretRequest{}
+	if req.Secret, req.Filename, req.IsBase64, req.Archive, err = secretFromFlags(c); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var rep *v1.RespondSecretReply
+	if rep, err = client.RespondSecret(ctx, c.Args().First(), req); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return printJSON(rep)
+}
+
 func destroy(c *cli.Context) (err error) {
 	if c.NArg() != 1 {
 		return cli.Exit("specify one token to fetch the secret for", 1)
@@ -343,17 +715,259 @@ func status(c *cli.Context) (err error) {
 	return printJSON(rep)
 }
 
+func policy(c *cli.Context) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var rep *v1.PolicyReply
+	if rep, err = client.Policy(ctx); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return printJSON(rep)
+}
+
+//===========================================================================
+// Admin Actions
+//===========================================================================
+
+var adminClient v1.AdminService
+
+func adminStatus(c *cli.Context) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var rep *v1.StatusReply
+	if rep, err = adminClient.AdminStatus(ctx); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return printJSON(rep)
+}
+
+func adminStats(c *cli.Context) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var rep *v1.AdminStatsReply
+	if rep, err = adminClient.AdminStats(ctx); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return printJSON(rep)
+}
+
+func adminList(c *cli.Context) (err error) {
+	req := &v1.AdminListRequest{
+		PageSize:  c.Int("page-size"),
+		PageToken: c.String("page-token"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var rep *v1.AdminListReply
+	if rep, err = adminClient.AdminListSecrets(ctx, req); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return printJSON(rep)
+}
+
+func adminDestroy(c *cli.Context) (err error) {
+	if c.NArg() != 1 {
+		return cli.Exit("specify one token or token hash of the secret to destroy", 1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var rep *v1.DestroySecretReply
+	if rep, err = adminClient.AdminDestroySecret(ctx, c.Args().First()); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return printJSON(rep)
+}
+
+func adminPurge(c *cli.Context) (err error) {
+	// Purging every secret may take longer than a normal request
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var rep *v1.AdminPurgeReply
+	if rep, err = adminClient.AdminPurge(ctx, &v1.AdminPurgeRequest{Confirm: c.String("confirm")}); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	if rep.Confirm != "" {
+		fmt.Fprintf(os.Stderr, "this will destroy %d secrets; to continue run:\n\n\twhisper admin purge --confirm %s\n\n", rep.Secrets, rep.Confirm)
+	}
+	return printJSON(rep)
+}
+
+func adminMaintenance(c *cli.Context) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var rep *v1.MaintenanceMode
+	switch {
+	case c.Bool("enable") && c.Bool("disable"):
+		return cli.Exit("specify only one of enable or disable", 1)
+	case c.Bool("enable"):
+		req := &v1.MaintenanceMode{
+			Enabled:  true,
+			ReadOnly: c.Bool("read-only"),
+			Message:  c.String("message"),
+		}
+
+		if eta := c.Duration("eta"); eta > 0 {
+			ts := time.Now().Add(eta)
+			req.ETA = &ts
+		}
+
+		if rep, err = adminClient.AdminSetMaintenance(ctx, req); err != nil {
+			return cli.Exit(err, 1)
+		}
+	case c.Bool("disable"):
+		if rep, err = adminClient.AdminSetMaintenance(ctx, &v1.MaintenanceMode{Enabled: false}); err != nil {
+			return cli.Exit(err, 1)
+		}
+	default:
+		if rep, err = adminClient.AdminMaintenance(ctx); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+	return printJSON(rep)
+}
+
 //===========================================================================
 // Helper Functions
 //===========================================================================
 
+func initAdminClient(c *cli.Context) (err error) {
+	if c.String("token") == "" {
+		return cli.Exit("specify the admin token with --token or $WHISPER_ADMIN_TOKEN", 1)
+	}
+
+	var opts []v1.ClientOption
+	if opts, err = clientOptions(c); err != nil {
+		return err
+	}
+
+	if adminClient, err = v1.NewAdmin(c.String("endpoint"), c.String("token"), opts...); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return nil
+}
+
 func initClient(c *cli.Context) (err error) {
-	if client, err = v1.New(c.String("endpoint")); err != nil {
+	var opts []v1.ClientOption
+	if opts, err = clientOptions(c); err != nil {
+		return err
+	}
+
+	if client, err = v1.New(c.String("endpoint"), opts...); err != nil {
 		return cli.Exit(err, 1)
 	}
 	return nil
 }
 
+// Configure the client certificate and the CA bundle from the global flags.
+func clientOptions(c *cli.Context) (opts []v1.ClientOption, err error) {
+	switch {
+	case c.String("cert") != "" && c.String("key") != "":
+		opts = append(opts, v1.WithClientCertificate(c.String("cert"), c.String("key")))
+	case c.String("cert") != "" || c.String("key") != "":
+		return nil, cli.Exit("specify both the client certificate and key with --cert and --key", 1)
+	}
+
+	if c.String("ca") != "" {
+		opts = append(opts, v1.WithCertificateAuthority(c.String("ca")))
+	}
+	return opts, nil
+}
+
+// Load the secret from one of the secret, in, or generate-secret command line flags.
+func secretFromFlags(c *cli.Context) (secret, filename string, isBase64 bool, archiveType string, err error) {
+	paths := c.StringSlice("in")
+	switch {
+	case c.String("secret") != "":
+		if c.Int("generate-secret") != 0 || len(paths) > 0 {
+			return "", "", false, "", cli.Exit("specify only one of secret, generate-secret, or in path", 1)
+		}
+
+		// Basic secret provided via the CLI
+		return c.String("secret"), "", c.Bool("b64encoded"), "", nil
+
+	case len(paths) > 0:
+		if c.Int("generate-secret") != 0 {
+			// The check for secret has already been done
+			return "", "", false, "", cli.Exit("specify only one of secret, generate-secret, or in path", 1)
+		}
+
+		// Load the secret as base64 encoded data from the files
+		if secret, filename, archiveType, err = loadFiles(paths...); err != nil {
+			return "", "", false, "", cli.Exit(err, 1)
+		}
+		return secret, filename, true, archiveType, nil
+
+	case c.Int("generate-secret") != 0:
+		// Generate a random secret of the specified length
+		if secret, err = generateRandomSecret(c.Int("generate-secret")); err != nil {
+			return "", "", false, "", cli.Exit(err, 1)
+		}
+		return secret, "", false, "", nil
+
+	default:
+		// No secret was specified at all?
+		return "", "", false, "", cli.Exit("specify at least one of secret, generate-secret, or in path", 1)
+	}
+}
+
+// Load the files as base64 encoded data. A single file is uploaded as is, while several
+// files or a directory are uploaded as an archive that is unpacked when it is fetched.
+func loadFiles(paths ...string) (secret, filename, archiveType string, err error) {
+	if len(paths) == 1 {
+		var isDir bool
+		if isDir, err = isDirectory(paths[0]); err != nil {
+			return "", "", "", err
+		}
+
+		if !isDir {
+			var data []byte
+			if data, err = os.ReadFile(paths[0]); err != nil {
+				return "", "", "", err
+			}
+			return base64.StdEncoding.EncodeToString(data), filepath.Base(paths[0]), "", nil
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err = archive.Create(buf, paths...); err != nil {
+		return "", "", "", err
+	}
+
+	// Name the archive after the directory if only one directory is uploaded
+	filename = "archive.tar.gz"
+	if len(paths) == 1 {
+		var abs string
+		if abs, err = filepath.Abs(paths[0]); err != nil {
+			return "", "", "", err
+		}
+		filename = filepath.Base(abs) + ".tar.gz"
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), filename, archive.TarGzip, nil
+}
+
+// Unpack the decoded archive of a secret into the out directory.
+func extractArchive(out string, data []byte) (err error) {
+	var files []string
+	if files, err = archive.Extract(bytes.NewReader(data), out); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	for _, file := range files {
+		fmt.Printf("secret written to %s\n", filepath.Join(out, filepath.FromSlash(file)))
+	}
+	return nil
+}
+
 func generateRandomSecret(n int) (s string, err error) {
 	const letters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-_%=+"
 	ret := make([]byte, n)
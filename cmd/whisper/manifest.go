@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest describes the secrets created by the batch command in a YAML or JSON file,
+// e.g. to share the VPN configuration, SSH key, and passwords of a new contractor.
+// The options of the manifest are used for secrets that do not specify their own.
+//
+//	password: supersecretsquirrel
+//	lifetime: 72h
+//	bundle: true
+//	secrets:
+//	  - in: ./vpn.ovpn
+//	  - in: ./id_ed25519
+//	  - secret: correct horse battery staple
+//	  - generate: 24
+type Manifest struct {
+	Password string            `yaml:"password"`
+	Accesses int               `yaml:"accesses"`
+	Lifetime time.Duration     `yaml:"lifetime"`
+	Callback string            `yaml:"callback"`
+	Email    string            `yaml:"email"`
+	Bundle   bool              `yaml:"bundle"`
+	Secrets  []*ManifestSecret `yaml:"secrets"`
+}
+
+// ManifestSecret is a secret in the manifest, which is specified in the same manner as
+// the secret, in, and generate-secret flags of the create command. Files are relative to
+// the manifest and are uploaded as base64 encoded data with their filename; directories
+// are uploaded as archives.
+type ManifestSecret struct {
+	Secret     string        `yaml:"secret"`
+	In         string        `yaml:"in"`
+	Generate   int           `yaml:"generate"`
+	B64Encoded bool          `yaml:"b64encoded"`
+	Password   string        `yaml:"password"`
+	Accesses   int           `yaml:"accesses"`
+	Lifetime   time.Duration `yaml:"lifetime"`
+	Callback   string        `yaml:"callback"`
+	Email      string        `yaml:"email"`
+}
+
+// LoadManifest reads the manifest from a YAML or JSON file.
+func LoadManifest(path string) (m *Manifest, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return nil, err
+	}
+
+	m = &Manifest{}
+	if err = yaml.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("could not parse manifest: %w", err)
+	}
+
+	if len(m.Secrets) == 0 {
+		return nil, fmt.Errorf("manifest %s does not contain any secrets", path)
+	}
+	return m, nil
+}
+
+// Request creates the batch request, loading and generating secrets as necessary. The
+// dir is the directory of the manifest that relative file paths are loaded from.
+func (m *Manifest) Request(dir string) (req *v1.CreateBatchRequest, err error) {
+	req = &v1.CreateBatchRequest{
+		Secrets:  make([]*v1.CreateSecretRequest, 0, len(m.Secrets)),
+		Password: m.Password,
+		Accesses: m.Accesses,
+		Lifetime: v1.Duration(m.Lifetime),
+		Callback: m.Callback,
+		Email:    m.Email,
+		Bundle:   m.Bundle,
+	}
+
+	for i, item := range m.Secrets {
+		secret := &v1.CreateSecretRequest{
+			Password: item.Password,
+			Accesses: item.Accesses,
+			Lifetime: v1.Duration(item.Lifetime),
+			Callback: item.Callback,
+			Email:    item.Email,
+		}
+
+		if secret.Secret, secret.Filename, secret.IsBase64, secret.Archive, err = item.load(dir); err != nil {
+			return nil, fmt.Errorf("secret %d: %w", i+1, err)
+		}
+		req.Secrets = append(req.Secrets, secret)
+	}
+	return req, nil
+}
+
+func (s *ManifestSecret) load(dir string) (secret, filename string, isBase64 bool, archiveType string, err error) {
+	specified := 0
+	for _, ok := range []bool{s.Secret != "", s.In != "", s.Generate != 0} {
+		if ok {
+			specified++
+		}
+	}
+
+	if specified != 1 {
+		return "", "", false, "", errors.New("specify exactly one of secret, in, or generate")
+	}
+
+	switch {
+	case s.Secret != "":
+		return s.Secret, "", s.B64Encoded, "", nil
+
+	case s.In != "":
+		path := s.In
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		if secret, filename, archiveType, err = loadFiles(path); err != nil {
+			return "", "", false, "", err
+		}
+		return secret, filename, true, archiveType, nil
+
+	default:
+		if secret, err = generateRandomSecret(s.Generate); err != nil {
+			return "", "", false, "", err
+		}
+		return secret, "", false, "", nil
+	}
+}
@@ -0,0 +1,328 @@
+package whisper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rotationalio/whisper/pkg/admin"
+	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/audit"
+	"github.com/rotationalio/whisper/pkg/logger"
+	"github.com/rotationalio/whisper/pkg/metrics"
+	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rotationalio/whisper/pkg/vault"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	adminDefaultPageSize = 50
+	adminMaximumPageSize = 500
+)
+
+// Secret statuses reported by the admin API.
+const (
+	statusActive     = "active"
+	statusPending    = "pending"
+	statusIncomplete = "incomplete"
+	statusInvalid    = "invalid"
+)
+
+var (
+	errAdminUnauthorized = errors.New("invalid admin token")
+	errInvalidPageSize   = errors.New("page size must be between 1 and 500")
+)
+
+// AdminAuthenticate is middleware that requires the admin token in the Authorization
+// header. Unlike secret passwords, the admin token is not base64 encoded.
+func (s *Server) AdminAuthenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !admin.Verify(s.conf.Admin.Token, c.GetHeader("Authorization")) {
+			s.record(c, audit.AuthFailed, "", errAdminUnauthorized.Error())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorReply(c, errAdminUnauthorized))
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminStats counts the secrets in the vault by their age and time until expiration.
+func (s *Server) AdminStats(c *gin.Context) {
+	entries, err := s.vault.List(c.Request.Context())
+	if err != nil {
+		sentry.Error(c).Err(err).Msg("could not list secrets")
+		c.JSON(http.StatusInternalServerError, ErrorReply(c, "could not list secrets"))
+		return
+	}
+
+	now := time.Now()
+	out := &v1.AdminStatsReply{
+		Secrets:   len(entries),
+		Age:       make(map[string]int),
+		Expires:   make(map[string]int),
+		Generated: now,
+	}
+
+	for _, entry := range entries {
+		if !entry.Secret || !entry.Metadata {
+			out.Incomplete++
+		}
+
+		out.Age[bucket(now.Sub(entry.Created))]++
+		switch {
+		case entry.Expires.IsZero():
+			out.Expires["never"]++
+		case !entry.Expires.After(now):
+			out.Expires["expired"]++
+		default:
+			out.Expires[bucket(entry.Expires.Sub(now))]++
+		}
+	}
+
+	c.JSON(http.StatusOK, out)
+}
+
+// AdminListSecrets returns a page of the metadata of the secrets in the vault ordered
+// by the keyed hash of their tokens. Neither the secrets nor their tokens are returned.
+func (s *Server) AdminListSecrets(c *gin.Context) {
+	in := &v1.AdminListRequest{}
+	if err := c.ShouldBindQuery(in); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorReply(c, BadRequest(err)))
+		return
+	}
+
+	switch {
+	case in.PageSize == 0:
+		in.PageSize = adminDefaultPageSize
+	case in.PageSize < 0 || in.PageSize > adminMaximumPageSize:
+		c.JSON(http.StatusBadRequest, ErrorReply(c, BadRequest(errInvalidPageSize)))
+		return
+	}
+
+	ctx := c.Request.Context()
+	entries, err := s.vault.List(ctx)
+	if err != nil {
+		sentry.Error(c).Err(err).Msg("could not list secrets")
+		c.JSON(http.StatusInternalServerError, ErrorReply(c, "could not list secrets"))
+		return
+	}
+
+	// The page token is the hash of the last secret on the previous page, which is stable
+	// when secrets are created or destroyed between requests.
+	hashed := hashEntries(entries)
+	start := sort.Search(len(hashed), func(i int) bool { return hashed[i].hash > in.PageToken })
+
+	out := &v1.AdminListReply{Secrets: make([]*v1.SecretMetadata, 0, in.PageSize)}
+	for _, item := range hashed[start:] {
+		if len(out.Secrets) == in.PageSize {
+			out.NextPageToken = out.Secrets[len(out.Secrets)-1].Secret
+			break
+		}
+
+		var secret *v1.SecretMetadata
+		if secret, err = s.secretMetadata(ctx, item); err != nil {
+			sentry.Error(c).Err(err).Str("secret", item.hash).Msg("could not load secret metadata")
+			c.JSON(http.StatusInternalServerError, ErrorReply(c, "could not load secret metadata"))
+			return
+		}
+		out.Secrets = append(out.Secrets, secret)
+	}
+
+	c.JSON(http.StatusOK, out)
+}
+
+// AdminDestroySecret destroys a secret without its password. The secret is identified by
+// its token or by the keyed hash of its token from the logs, audit log, or admin API; the
+// vault is only listed to find the secret by its hash.
+func (s *Server) AdminDestroySecret(c *gin.Context) {
+	ctx := c.Request.Context()
+	entry, err := s.adminEntry(ctx, c.Param("token"))
+	if err != nil {
+		sentry.Error(c).Err(err).Msg("could not find secret")
+		c.JSON(http.StatusInternalServerError, ErrorReply(c, "could not find secret"))
+		return
+	}
+
+	if entry == nil {
+		c.JSON(http.StatusNotFound, ErrorReply(c, vault.ErrSecretNotFound))
+		return
+	}
+
+	if err = s.vault.Purge(ctx, entry); err != nil {
+		sentry.Error(c).Err(err).Str("secret", logger.HashToken(entry.Token)).Msg("could not destroy secret")
+		c.JSON(http.StatusInternalServerError, ErrorReply(c, "could not destroy secret"))
+		return
+	}
+
+	metrics.Secret(metrics.Destroyed)
+	s.record(c, audit.SecretDestroyed, entry.Token, "destroyed by admin")
+	log.Ctx(ctx).Warn().Str("secret", logger.HashToken(entry.Token)).Msg("secret destroyed by admin")
+	c.JSON(http.StatusOK, &v1.DestroySecretReply{Destroyed: true})
+}
+
+// AdminPurge destroys every secret in the vault. The first request returns a short-lived
+// confirmation token along with the number of secrets that would be destroyed; the purge
+// only happens when the confirmation token is sent back in a second request.
+func (s *Server) AdminPurge(c *gin.Context) {
+	in := &v1.AdminPurgeRequest{}
+
+	// An empty body requests a confirmation token
+	if err := c.ShouldBindJSON(in); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, ErrorReply(c, BadRequest(err)))
+		return
+	}
+
+	ctx := c.Request.Context()
+	entries, err := s.vault.List(ctx)
+	if err != nil {
+		sentry.Error(c).Err(err).Msg("could not list secrets")
+		c.JSON(http.StatusInternalServerError, ErrorReply(c, "could not list secrets"))
+		return
+	}
+
+	out := &v1.AdminPurgeReply{Secrets: len(entries)}
+	if in.Confirm == "" {
+		if out.Confirm, out.Expires, err = s.confirms.Issue(); err != nil {
+			sentry.Error(c).Err(err).Msg("could not issue purge confirmation")
+			c.JSON(http.StatusInternalServerError, ErrorReply(c, "could not issue purge confirmation"))
+			return
+		}
+		c.JSON(http.StatusAccepted, out)
+		return
+	}
+
+	if !s.confirms.Confirm(in.Confirm) {
+		c.JSON(http.StatusBadRequest, ErrorReply(c, BadRequest("invalid or expired confirmation token")))
+		return
+	}
+
+	log.Ctx(ctx).Warn().Int("secrets", len(entries)).Msg("purging all secrets by admin request")
+	for _, entry := range entries {
+		if err = s.vault.Purge(ctx, entry); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("secret", logger.HashToken(entry.Token)).Msg("could not purge secret")
+			out.Failed++
+			continue
+		}
+
+		out.Purged++
+		metrics.Secret(metrics.Destroyed)
+		s.record(c, audit.SecretDestroyed, entry.Token, "purged by admin")
+	}
+
+	if out.Failed > 0 {
+		sentry.Error(c).Int("failed", out.Failed).Msg("could not purge all secrets")
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// Find the secret identified by its token or the keyed hash of its token, returning nil
+// if the secret does not exist. Secrets identified by their token are checked directly.
+func (s *Server) adminEntry(ctx context.Context, param string) (_ *vault.Entry, err error) {
+	if vault.IsToken(param) {
+		var exists bool
+		if exists, err = s.vault.Check(ctx, param); err != nil || !exists {
+			return nil, err
+		}
+		return &vault.Entry{Token: param, Secret: true, Metadata: true}, nil
+	}
+
+	var entries []*vault.Entry
+	if entries, err = s.vault.List(ctx); err != nil {
+		return nil, err
+	}
+
+	for _, item := range hashEntries(entries) {
+		if item.hash == param {
+			return item.Entry, nil
+		}
+	}
+	return nil, nil
+}
+
+// hashedEntry pairs a vault entry with the keyed hash of its token.
+type hashedEntry struct {
+	*vault.Entry
+	hash string
+}
+
+// Sort the entries by the keyed hash of their tokens for stable pagination.
+func hashEntries(entries []*vault.Entry) []hashedEntry {
+	hashed := make([]hashedEntry, 0, len(entries))
+	for _, entry := range entries {
+		hashed = append(hashed, hashedEntry{Entry: entry, hash: logger.HashToken(entry.Token)})
+	}
+	sort.Slice(hashed, func(i, j int) bool { return hashed[i].hash < hashed[j].hash })
+	return hashed
+}
+
+// Load the metadata of a secret, leaving out the password hash, filename, and any
+// notification addresses which are not needed by operators. The files of an archive are
+// listed so that operators can see what was shared without the contents of the files.
+func (s *Server) secretMetadata(ctx context.Context, item hashedEntry) (_ *v1.SecretMetadata, err error) {
+	out := &v1.SecretMetadata{
+		Secret:  item.hash,
+		Status:  statusIncomplete,
+		Created: item.Created,
+		Expires: item.Expires,
+	}
+
+	if !item.Metadata {
+		return out, nil
+	}
+
+	meta := s.vault.With(item.Token)
+	if err = meta.Load(ctx, false); err != nil {
+		if errors.Is(err, vault.ErrSecretNotFound) {
+			return out, nil
+		}
+		return nil, err
+	}
+
+	out.Created = meta.Created
+	out.Expires = meta.Expires
+	out.LastAccessed = meta.LastAccessed
+	out.Accesses = meta.Accesses
+	out.Retrievals = meta.Retrievals
+	out.Failures = meta.Failures
+	out.Password = meta.Password != ""
+	out.File = meta.Filename != ""
+	out.Request = meta.Request
+	out.Notify = meta.Callback != "" || meta.Email != ""
+	out.Archive = meta.Archive
+	out.Files = meta.Files
+	out.Size = meta.Size
+
+	switch {
+	case !meta.Valid():
+		out.Status = statusInvalid
+	case meta.Pending():
+		out.Status = statusPending
+	case item.Secret:
+		out.Status = statusActive
+	}
+	return out, nil
+}
+
+// Label a duration with the bucket it falls into for the admin stats.
+func bucket(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return "<1h"
+	case d < 24*time.Hour:
+		return "1h-24h"
+	case d < 7*24*time.Hour:
+		return "1d-7d"
+	default:
+		return ">7d"
+	}
+}
+
+// isAdminRoute returns true if the request is to the admin API.
+func isAdminRoute(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, "/admin/")
+}
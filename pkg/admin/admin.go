@@ -0,0 +1,91 @@
+/*
+Package admin implements the credential checks and the purge confirmation for the
+operator API, which allows operators to inspect the metadata of the secrets stored by
+whisper and to destroy them during an incident. Secret payloads are never available.
+*/
+package admin
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MinTokenLength is the shortest admin token that is accepted by the configuration.
+const MinTokenLength = 32
+
+// ConfirmationLifetime is how long a purge confirmation token can be used.
+const ConfirmationLifetime = 5 * time.Minute
+
+// Config enables the operator API when an admin token is configured.
+type Config struct {
+	Token string `required:"false" yaml:"token"` // bearer token required by the operator API
+}
+
+// Enabled returns true if operator requests can be authenticated.
+func (c Config) Enabled() bool {
+	return c.Token != ""
+}
+
+func (c Config) Validate() error {
+	if c.Enabled() && len(c.Token) < MinTokenLength {
+		return errors.New("invalid configuration: the admin token must be at least 32 characters")
+	}
+	return nil
+}
+
+var bearer = regexp.MustCompile(`^(?i)Bearer\s+(\S+)$`)
+
+// Verify returns true if the Authorization header contains the admin token. The tokens
+// are hashed before comparison so that the comparison is constant time regardless of
+// the length of the supplied token.
+func Verify(token, header string) bool {
+	groups := bearer.FindStringSubmatch(strings.TrimSpace(header))
+	if token == "" || len(groups) != 2 {
+		return false
+	}
+
+	expected := sha256.Sum256([]byte(token))
+	actual := sha256.Sum256([]byte(groups[1]))
+	return subtle.ConstantTimeCompare(expected[:], actual[:]) == 1
+}
+
+// Confirmations issues single-use tokens that must be supplied to confirm a purge so
+// that everything cannot be destroyed by a single mistaken request. Only the most
+// recently issued token is valid.
+type Confirmations struct {
+	sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Issue a new confirmation token, invalidating any previously issued token.
+func (c *Confirmations) Issue() (token string, expires time.Time, err error) {
+	buf := make([]byte, 16)
+	if _, err = rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.token = base64.RawURLEncoding.EncodeToString(buf)
+	c.expires = time.Now().Add(ConfirmationLifetime)
+	return c.token, c.expires, nil
+}
+
+// Confirm returns true if the token is the most recently issued token and it has not
+// expired. The token is consumed whether or not it is valid to prevent guessing.
+func (c *Confirmations) Confirm(token string) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	valid := c.token != "" && time.Now().Before(c.expires) && subtle.ConstantTimeCompare([]byte(c.token), []byte(token)) == 1
+	c.token = ""
+	return valid
+}
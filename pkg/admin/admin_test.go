@@ -0,0 +1,77 @@
+package admin_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/admin"
+	"github.com/stretchr/testify/require"
+)
+
+const token = "Wd8QaqkQ0pRL4hHvRAu5fiR79h3RU0W1XGrXH3qT"
+
+func TestConfig(t *testing.T) {
+	conf := admin.Config{}
+	require.False(t, conf.Enabled())
+	require.NoError(t, conf.Validate())
+
+	conf.Token = "tooshort"
+	require.True(t, conf.Enabled())
+	require.Error(t, conf.Validate())
+
+	conf.Token = token
+	require.NoError(t, conf.Validate())
+}
+
+func TestVerify(t *testing.T) {
+	testCases := []struct {
+		token    string
+		header   string
+		expected bool
+	}{
+		{token, "Bearer " + token, true},
+		{token, "bearer " + token, true},
+		{token, "  Bearer   " + token + " ", true},
+		{token, "", false},
+		{token, token, false},
+		{token, "Basic " + token, false},
+		{token, "Bearer " + token[:len(token)-1], false},
+		{token, "Bearer " + token + "a", false},
+		{token, "Bearer " + strings.ToLower(token), false},
+		{"", "Bearer ", false},
+		{"", "Bearer " + token, false},
+	}
+
+	for i, tc := range testCases {
+		require.Equal(t, tc.expected, admin.Verify(tc.token, tc.header), "test case %d failed", i)
+	}
+}
+
+func TestConfirmations(t *testing.T) {
+	var confirms admin.Confirmations
+	require.False(t, confirms.Confirm(""), "no token has been issued")
+
+	first, expires, err := confirms.Issue()
+	require.NoError(t, err)
+	require.NotEmpty(t, first)
+	require.WithinDuration(t, time.Now().Add(admin.ConfirmationLifetime), expires, time.Second)
+
+	// Issuing a new token invalidates the previous token
+	second, _, err := confirms.Issue()
+	require.NoError(t, err)
+	require.NotEqual(t, first, second)
+	require.False(t, confirms.Confirm(first))
+
+	// The token is consumed by an incorrect confirmation
+	third, _, err := confirms.Issue()
+	require.NoError(t, err)
+	require.False(t, confirms.Confirm("incorrect"))
+	require.False(t, confirms.Confirm(third))
+
+	// A token can only be used once
+	fourth, _, err := confirms.Issue()
+	require.NoError(t, err)
+	require.True(t, confirms.Confirm(fourth))
+	require.False(t, confirms.Confirm(fourth))
+}
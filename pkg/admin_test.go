@@ -0,0 +1,174 @@
+package whisper_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/rotationalio/whisper/pkg"
+	"github.com/rotationalio/whisper/pkg/admin"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/logger"
+	"github.com/rotationalio/whisper/pkg/vault"
+)
+
+func (s *WhisperTestSuite) TestAdmin() {
+	const token = "Wd8QaqkQ0pRL4hHvRAu5fiR79h3RU0W1XGrXH3qT"
+
+	// The admin API is not available unless an admin token is configured
+	req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	// Use a separate server so that the secrets of the other tests are not purged
+	conf := s.conf
+	conf.Admin = admin.Config{Token: token}
+	srv, err := New(conf)
+	s.NoError(err)
+	srv.SetStatus(true, true)
+
+	prev := s.router
+	s.router = srv.Routes()
+	defer func() { s.router = prev }()
+
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := api.NewAdmin(server.URL, token)
+	s.NoError(err)
+
+	// Requests without the admin token are rejected
+	unauthorized, err := api.NewAdmin(server.URL, token[:len(token)-1])
+	s.NoError(err)
+	_, err = unauthorized.AdminStats(ctx)
+	s.ErrorContains(err, "401")
+
+	// Create a secret with a password, a file, and a secret request
+	secret := s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight", Password: "supersecretpassword", Accesses: 2}, http.StatusCreated)
+	file := s.sendCreateSecret(&api.CreateSecretRequest{Secret: "dGhlIGVhZ2xlIGZsaWVzIGF0IG1pZG5pZ2h0", Filename: "eagle.txt", IsBase64: true}, http.StatusCreated)
+	request := &api.RequestSecretReply{}
+	s.sendJSON(http.MethodPost, "/v1/requests", "", &api.RequestSecretRequest{}, http.StatusCreated, request)
+
+	stats, err := client.AdminStats(ctx)
+	s.NoError(err)
+	s.Equal(3, stats.Secrets)
+	s.Equal(1, stats.Incomplete, "the pending request has no secret")
+	s.Equal(map[string]int{"<1h": 3}, stats.Age)
+	s.Equal(3, stats.Expires["1d-7d"])
+
+	// Page through the metadata of the secrets
+	page, err := client.AdminListSecrets(ctx, &api.AdminListRequest{PageSize: 2})
+	s.NoError(err)
+	s.Len(page.Secrets, 2)
+	s.NotEmpty(page.NextPageToken)
+
+	last, err := client.AdminListSecrets(ctx, &api.AdminListRequest{PageSize: 2, PageToken: page.NextPageToken})
+	s.NoError(err)
+	s.Len(last.Secrets, 1)
+	s.Empty(last.NextPageToken)
+
+	listed := make(map[string]*api.SecretMetadata)
+	for _, meta := range append(page.Secrets, last.Secrets...) {
+		listed[meta.Secret] = meta
+	}
+	s.Len(listed, 3)
+
+	meta := listed[logger.HashToken(secret.Token)]
+	s.Require().NotNil(meta)
+	s.Equal("active", meta.Status)
+	s.True(meta.Password)
+	s.False(meta.File)
+	s.Equal(2, meta.Accesses)
+
+	meta = listed[logger.HashToken(file.Token)]
+	s.Require().NotNil(meta)
+	s.True(meta.File)
+	s.Equal(int64(27), meta.Size)
+	s.False(meta.Password)
+
+	meta = listed[logger.HashToken(request.Token)]
+	s.Require().NotNil(meta)
+	s.Equal("pending", meta.Status)
+	s.True(meta.Request)
+
+	// The admin API never returns secrets, tokens, or filenames
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/admin/secrets", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+	for _, sensitive := range []string{secret.Token, file.Token, request.Token, "eagle", "dGhlIGVhZ2xl"} {
+		s.NotContains(w.Body.String(), sensitive)
+	}
+
+	_, err = client.AdminListSecrets(ctx, &api.AdminListRequest{PageSize: 1000})
+	s.ErrorContains(err, "400")
+
+	// Destroy a secret by the hash of its token without its password
+	rep, err := client.AdminDestroySecret(ctx, logger.HashToken(secret.Token))
+	s.NoError(err)
+	s.True(rep.Destroyed)
+	s.sendFetchRequest(secret.Token, "", http.StatusNotFound)
+
+	_, err = client.AdminDestroySecret(ctx, logger.HashToken(secret.Token))
+	s.ErrorContains(err, "404")
+
+	// Destroy a secret by its token
+	other := s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the owl hoots at dawn"}, http.StatusCreated)
+	rep, err = client.AdminDestroySecret(ctx, other.Token)
+	s.NoError(err)
+	s.True(rep.Destroyed)
+	s.sendFetchRequest(other.Token, "", http.StatusNotFound)
+
+	_, err = client.AdminDestroySecret(ctx, other.Token)
+	s.ErrorContains(err, "404")
+
+	// The admin API is available in maintenance mode
+	conf.Maintenance = true
+	maintenance, err := New(conf)
+	s.NoError(err)
+	maintenance.SetStatus(true, true)
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	maintenance.Routes().ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+
+	// Secrets in the project that were not created by whisper are not purged
+	unrelated := srv.Vault().With("database-password")
+	unrelated.Expires = time.Now().Add(time.Hour)
+	s.NoError(unrelated.Create(ctx, vault.SuffixSecret))
+	s.NoError(unrelated.AddVersion(ctx, vault.SuffixSecret, []byte("not a whisper secret")))
+
+	// Purging requires a confirmation token
+	purge, err := client.AdminPurge(ctx, &api.AdminPurgeRequest{})
+	s.NoError(err)
+	s.Equal(2, purge.Secrets)
+	s.Zero(purge.Purged)
+	s.NotEmpty(purge.Confirm)
+
+	_, err = client.AdminPurge(ctx, &api.AdminPurgeRequest{Confirm: "incorrect"})
+	s.ErrorContains(err, "400")
+
+	// The incorrect confirmation consumed the confirmation token
+	_, err = client.AdminPurge(ctx, &api.AdminPurgeRequest{Confirm: purge.Confirm})
+	s.ErrorContains(err, "400")
+
+	purge, err = client.AdminPurge(ctx, nil)
+	s.NoError(err)
+	purge, err = client.AdminPurge(ctx, &api.AdminPurgeRequest{Confirm: purge.Confirm})
+	s.NoError(err)
+	s.Equal(2, purge.Secrets)
+	s.Equal(2, purge.Purged)
+	s.Zero(purge.Failed)
+
+	stats, err = client.AdminStats(ctx)
+	s.NoError(err)
+	s.Zero(stats.Secrets)
+
+	s.ErrorIs(unrelated.Create(ctx, vault.SuffixSecret), vault.ErrAlreadyExists, "the unrelated secret was purged")
+}
@@ -17,9 +17,25 @@ import (
 // Service defines the API, which is implemented by the v1 client.
 type Service interface {
 	Status(ctx context.Context) (out *StatusReply, err error)
+	Policy(ctx context.Context) (out *PolicyReply, err error)
 	CreateSecret(ctx context.Context, in *CreateSecretRequest) (out *CreateSecretReply, err error)
+	CreateBatch(ctx context.Context, in *CreateBatchRequest) (out *CreateBatchReply, err error)
 	FetchSecret(ctx context.Context, token, password string) (out *FetchSecretReply, err error)
 	DestroySecret(ctx context.Context, token, password string) (out *DestroySecretReply, err error)
+	RequestSecret(ctx context.Context, in *RequestSecretRequest) (out *RequestSecretReply, err error)
+	RespondSecret(ctx context.Context, token string, in *RespondSecretRequest) (out *RespondSecretReply, err error)
+	FetchResponse(ctx context.Context, token, owner string) (out *FetchSecretReply, err error)
+}
+
+// AdminService describes the operator API, which requires the admin token.
+type AdminService interface {
+	AdminStats(ctx context.Context) (out *AdminStatsReply, err error)
+	AdminListSecrets(ctx context.Context, in *AdminListRequest) (out *AdminListReply, err error)
+	AdminDestroySecret(ctx context.Context, secret string) (out *DestroySecretReply, err error)
+	AdminPurge(ctx context.Context, in *AdminPurgeRequest) (out *AdminPurgeReply, err error)
+	AdminStatus(ctx context.Context) (out *StatusReply, err error)
+	AdminMaintenance(ctx context.Context) (out *MaintenanceMode, err error)
+	AdminSetMaintenance(ctx context.Context, in *MaintenanceMode) (out *MaintenanceMode, err error)
 }
 
 //===========================================================================
@@ -28,16 +44,49 @@ type Service interface {
 
 // Reply contains standard fields that are embedded in most API responses
 type Reply struct {
-	Success bool   `json:"success"`
-	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty" yaml:"error,omitempty"`
+	Code      ErrorCode `json:"code,omitempty" yaml:"code,omitempty"`             // identifies the error for clients
+	RequestID string    `json:"request_id,omitempty" yaml:"request_id,omitempty"` // correlates the error with server logs
 }
 
 // StatusReply is returned on status requests. Note that no request is needed.
 type StatusReply struct {
-	Status  string `json:"status"`
-	Uptime  string `json:"uptime,omitempty"`
-	Version string `json:"version,omitempty"`
-	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+	Status   string     `json:"status"`
+	Uptime   string     `json:"uptime,omitempty"`
+	Version  string     `json:"version,omitempty"`
+	Error    string     `json:"error,omitempty" yaml:"error,omitempty"`
+	Message  string     `json:"message,omitempty"`   // explains why the server is in maintenance mode
+	ETA      *time.Time `json:"eta,omitempty"`       // when maintenance is expected to be over
+	ReadOnly bool       `json:"read_only,omitempty"` // secrets can be fetched and destroyed but not created
+
+	// The state of the dependencies of the server, only included in verbose replies
+	Components []*ComponentStatus `json:"components,omitempty"`
+}
+
+// ComponentStatus describes the most recent health checks of a server dependency.
+type ComponentStatus struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`                  // ok, unhealthy, or unknown if it has not been checked
+	Checked     *time.Time `json:"checked,omitempty"`       // when the component was last checked
+	Latency     Duration   `json:"latency,omitempty"`       // how long the last check took
+	Failures    int        `json:"failures,omitempty"`      // the number of consecutive failed checks
+	LastError   string     `json:"last_error,omitempty"`    // the most recent error, even if the component recovered
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"` // when the most recent error occurred
+}
+
+// PolicyReply describes the limits that the server enforces when secrets are created
+// so that clients can validate secrets before they are submitted.
+type PolicyReply struct {
+	DefaultLifetime     Duration `json:"default_lifetime"`      // the lifetime of secrets that do not specify one
+	DefaultAccesses     int      `json:"default_accesses"`      // the accesses of secrets that do not specify them
+	MinLifetime         Duration `json:"min_lifetime"`          // the shortest lifetime a secret can have
+	MaxLifetime         Duration `json:"max_lifetime"`          // the longest lifetime a secret can have; 0 for no limit
+	MaxAccesses         int      `json:"max_accesses"`          // the maximum number of accesses; 0 for no limit
+	AllowUnlimited      bool     `json:"allow_unlimited"`       // if secrets can have unlimited (negative) accesses
+	MaxSize             int      `json:"max_size"`              // the maximum size of a secret in bytes
+	RequirePassword     bool     `json:"require_password"`      // if secrets must have a password
+	MinPasswordStrength int      `json:"min_password_strength"` // the minimum password strength from 0 to 4
 }
 
 //===========================================================================
@@ -51,6 +100,9 @@ type CreateSecretRequest struct {
 	Lifetime Duration `json:"lifetime,omitempty"`        // how long the secret will last before being deleted
 	Filename string   `json:"filename,omitempty"`        // if the secret is a filename, the name of the file
 	IsBase64 bool     `json:"is_base64"`                 // if the secret is base64 encoded or not
+	Callback string   `json:"callback,omitempty"`        // a webhook URL that is notified when the secret is fetched or destroyed
+	Email    string   `json:"email,omitempty"`           // an email address that is notified when the secret is fetched or destroyed
+	Archive  string   `json:"archive,omitempty"`         // if the secret is several files packaged by the client, the type of archive, e.g. tar+gzip
 }
 
 type CreateSecretReply struct {
@@ -59,14 +111,150 @@ type CreateSecretReply struct {
 }
 
 type FetchSecretReply struct {
-	Secret    string    `json:"secret"`             // the secret retrieved by the database, which is now deleted
-	Filename  string    `json:"filename,omitempty"` // the name of the file used to create the secret to save as a file
-	IsBase64  bool      `json:"is_base64"`          // if the secret is base64 encoded data
-	Created   time.Time `json:"created"`            // the timestamp the secret was created
-	Accesses  int       `json:"accesses"`           // the number of times the secret has been accessed
-	Destroyed bool      `json:"destroyed"`          // if the secret was destroyed after the fetch
+	Secret      string    `json:"secret"`                 // the secret retrieved by the database, which is now deleted
+	Filename    string    `json:"filename,omitempty"`     // the name of the file used to create the secret to save as a file
+	IsBase64    bool      `json:"is_base64"`              // if the secret is base64 encoded data
+	Created     time.Time `json:"created"`                // the timestamp the secret was created
+	Accesses    int       `json:"accesses"`               // the number of times the secret has been accessed
+	Destroyed   bool      `json:"destroyed"`              // if the secret was destroyed after the fetch
+	Archive     string    `json:"archive,omitempty"`      // if the secret is an archive of several files, the type of archive
+	Files       []string  `json:"files,omitempty"`        // the files in the archive
+	ContentType string    `json:"content_type,omitempty"` // the media type of the secret, detected when it was created
+	Size        int64     `json:"size,omitempty"`         // the size of the secret in bytes after it is base64 decoded
+	SHA256      string    `json:"sha256,omitempty"`       // the hex encoded SHA-256 digest of the decoded secret
+
+	// The secrets of a bundle created by a batch request; the secret is empty if set
+	Bundle []*BundleItem `json:"bundle,omitempty"`
 }
 
 type DestroySecretReply struct {
 	Destroyed bool `json:"destroyed"` // if the secret was destroyed or not
 }
+
+// MaxBatchSize is the maximum number of secrets that can be created by a batch request.
+const MaxBatchSize = 25
+
+// CreateBatchRequest creates several secrets at once: either all of the secrets are
+// created or none of them are. The password, accesses, lifetime, callback, and email of
+// the request are used for the secrets that do not specify their own. If bundle is set
+// the secrets are stored together and revealed by a single token; secrets in a bundle
+// may only specify their contents since the bundle is fetched with one set of options.
+type CreateBatchRequest struct {
+	Secrets  []*CreateSecretRequest `json:"secrets" binding:"required,min=1,dive,required"` // the secrets to create, at most MaxBatchSize
+	Password string                 `json:"password,omitempty"`                             // the password of secrets that do not specify one
+	Accesses int                    `json:"accesses,omitempty"`                             // the accesses of secrets that do not specify them
+	Lifetime Duration               `json:"lifetime,omitempty"`                             // the lifetime of secrets that do not specify one
+	Callback string                 `json:"callback,omitempty"`                             // the webhook of secrets that do not specify one
+	Email    string                 `json:"email,omitempty"`                                // the email address of secrets that do not specify one
+	Bundle   bool                   `json:"bundle,omitempty"`                               // reveal all of the secrets with a single token
+}
+
+type CreateBatchReply struct {
+	Secrets []*CreateSecretReply `json:"secrets,omitempty"` // the tokens of the secrets in the order of the request
+	Bundle  *CreateSecretReply   `json:"bundle,omitempty"`  // the token of the bundle if the secrets were bundled
+}
+
+// BundleItem is one of the secrets of a bundle.
+type BundleItem struct {
+	Secret      string `json:"secret"`                 // the secret can be a string of any length or base64 encoded data
+	Filename    string `json:"filename,omitempty"`     // if the secret is a file, the name of the file
+	IsBase64    bool   `json:"is_base64"`              // if the secret is base64 encoded or not
+	Archive     string `json:"archive,omitempty"`      // if the secret is several files packaged by the client, the type of archive
+	ContentType string `json:"content_type,omitempty"` // the media type of the secret, detected when it was created
+	Size        int64  `json:"size,omitempty"`         // the size of the secret in bytes after it is base64 decoded
+	SHA256      string `json:"sha256,omitempty"`       // the hex encoded SHA-256 digest of the decoded secret
+}
+
+//===========================================================================
+// Secret Request REST API
+//===========================================================================
+
+type RequestSecretRequest struct {
+	Accesses int      `json:"accesses,omitempty"` // the number of times the requester can access the response; default is 1
+	Lifetime Duration `json:"lifetime,omitempty"` // how long the request and its response will last before being deleted
+}
+
+type RequestSecretReply struct {
+	Token   string    `json:"token"`   // the token that is shared with the responder so they can submit a secret
+	Owner   string    `json:"owner"`   // the token that the requester must keep to fetch the response; it is never shared
+	Expires time.Time `json:"expires"` // the timestamp when the request and any response will have expired
+}
+
+type RespondSecretRequest struct {
+	Secret   string `json:"secret" binding:"required"` // the secret can be a string of any length or base64 encoded data
+	Filename string `json:"filename,omitempty"`        // if the secret is a filename, the name of the file
+	IsBase64 bool   `json:"is_base64"`                 // if the secret is base64 encoded or not
+	Archive  string `json:"archive,omitempty"`         // if the secret is several files packaged by the client, the type of archive
+}
+
+type RespondSecretReply struct {
+	Fulfilled bool      `json:"fulfilled"` // if the secret request has been fulfilled by the response
+	Expires   time.Time `json:"expires"`   // the timestamp when the response will have expired
+}
+
+//===========================================================================
+// Admin REST API
+//===========================================================================
+
+// AdminStatsReply counts the secrets stored in the vault by how long ago they were
+// created and how long until they expire. Buckets are keyed by labels such as "<1h".
+type AdminStatsReply struct {
+	Secrets    int            `json:"secrets"`    // the number of secrets stored in the vault
+	Incomplete int            `json:"incomplete"` // secrets missing their metadata or payload, including pending requests
+	Age        map[string]int `json:"age"`        // the number of secrets by time since they were created
+	Expires    map[string]int `json:"expires"`    // the number of secrets by time until they expire
+	Generated  time.Time      `json:"generated"`  // the time the stats were computed
+}
+
+type AdminListRequest struct {
+	PageSize  int    `json:"page_size,omitempty" form:"page_size"`   // the maximum number of secrets to return; default 50
+	PageToken string `json:"page_token,omitempty" form:"page_token"` // the next page token of the previous page
+}
+
+type AdminListReply struct {
+	Secrets       []*SecretMetadata `json:"secrets"`
+	NextPageToken string            `json:"next_page_token,omitempty"` // empty if this is the last page
+}
+
+// SecretMetadata describes a stored secret without the secret or its token. The secret
+// is identified by the keyed hash of its token, which matches the logs and audit log.
+type SecretMetadata struct {
+	Secret       string    `json:"secret"`                  // keyed hash of the secret token
+	Status       string    `json:"status"`                  // active, pending, incomplete, or invalid
+	Created      time.Time `json:"created"`                 // the timestamp the secret was created
+	Expires      time.Time `json:"expires"`                 // the timestamp when the secret will have expired
+	LastAccessed time.Time `json:"last_accessed,omitempty"` // the timestamp the secret was last fetched
+	Accesses     int       `json:"accesses"`                // the number of allowed accesses
+	Retrievals   int       `json:"retrievals"`              // the number of times the secret has been fetched
+	Failures     int       `json:"failures,omitempty"`      // the number of incorrect password attempts
+	Password     bool      `json:"password"`                // if a password is required to fetch the secret
+	File         bool      `json:"file"`                    // if the secret is a file
+	Request      bool      `json:"request"`                 // if the secret is a secret request
+	Notify       bool      `json:"notify"`                  // if a webhook or email is notified of fetches
+	Archive      string    `json:"archive,omitempty"`       // the type of archive if the secret is several files
+	Files        []string  `json:"files,omitempty"`         // the files in the archive
+	Size         int64     `json:"size,omitempty"`          // the size of the secret in bytes after it is base64 decoded
+}
+
+// AdminPurgeRequest destroys all secrets. The first request without a confirmation
+// token returns the confirmation token that must be sent in a second request.
+type AdminPurgeRequest struct {
+	Confirm string `json:"confirm,omitempty"`
+}
+
+type AdminPurgeReply struct {
+	Secrets int       `json:"secrets"`           // the number of secrets stored before the purge
+	Confirm string    `json:"confirm,omitempty"` // the token to confirm the purge with if not purged
+	Expires time.Time `json:"expires,omitempty"` // when the confirmation token expires
+	Purged  int       `json:"purged"`            // the number of secrets that were destroyed
+	Failed  int       `json:"failed,omitempty"`  // the number of secrets that could not be destroyed
+}
+
+// MaintenanceMode describes whether the server is in maintenance mode. In read-only
+// maintenance, secrets can still be fetched and destroyed but cannot be created.
+type MaintenanceMode struct {
+	Enabled  bool       `json:"enabled"`
+	ReadOnly bool       `json:"read_only,omitempty"` // allow fetches and destroys during maintenance
+	Message  string     `json:"message,omitempty"`   // shown to users in the status reply
+	ETA      *time.Time `json:"eta,omitempty"`       // when maintenance is expected to be over
+}
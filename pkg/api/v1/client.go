@@ -5,14 +5,19 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
+
+	"go.opentelemetry.io/otel/propagation"
 )
 
-func New(endpoint string) (_ Service, err error) {
+func New(endpoint string, opts ...ClientOption) (_ Service, err error) {
 	c := &APIv1{
 		client: &http.Client{
 			Transport:     nil,
@@ -24,18 +29,57 @@ func New(endpoint string) (_ Service, err error) {
 	if c.endpoint, err = url.Parse(endpoint); err != nil {
 		return nil, fmt.Errorf("could not parse endpoint: %s", err)
 	}
+
+	// Connect to a local server on a unix socket, e.g. unix:///run/whisper/whisper.sock
+	if c.endpoint.Scheme == "unix" {
+		if c.endpoint.Path == "" {
+			return nil, errors.New("could not parse endpoint: the path of the unix socket is required")
+		}
+
+		var dialer net.Dialer
+		socket := c.endpoint.Path
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+
+		c.client.Transport = transport
+		c.endpoint = &url.URL{Scheme: "http", Host: "localhost"}
+	}
+
+	for _, opt := range opts {
+		if err = opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// NewAdmin creates a client for the operator API that authenticates with the admin token.
+func NewAdmin(endpoint, token string, opts ...ClientOption) (_ AdminService, err error) {
+	var svc Service
+	if svc, err = New(endpoint, opts...); err != nil {
+		return nil, err
+	}
+
+	c := svc.(*APIv1)
+	c.adminToken = token
 	return c, nil
 }
 
 // APIv1 implements the Service interface.
 // TODO: add redirect check that ensures the client only accesses v1 routes.
 type APIv1 struct {
-	endpoint *url.URL
-	client   *http.Client
+	endpoint   *url.URL
+	client     *http.Client
+	adminToken string
 }
 
-// Ensure that the api implements the Service interface
-var _ Service = &APIv1{}
+// Ensure that the api implements the Service and AdminService interfaces
+var (
+	_ Service      = &APIv1{}
+	_ AdminService = &APIv1{}
+)
 
 // NewRequest creates an http.Request with the specified context and method, resolving
 // the path to the root endpoint of the API (e.g. /v1) and serializes the data to JSON.
@@ -66,6 +110,9 @@ func (s APIv1) NewRequest(ctx context.Context, method, path string, data interfa
 	req.Header.Add("Accept-Encoding", "gzip, deflate, br")
 	req.Header.Add("Content-Type", "application/json")
 
+	// Propagate the W3C trace context so that client spans are linked to server spans
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
 	return req, nil
 }
 
@@ -80,7 +127,7 @@ func (s APIv1) Do(req *http.Request, data interface{}, checkStatus bool) (rep *h
 	// Detect errors if they've occurred
 	if checkStatus {
 		if rep.StatusCode < 200 || rep.StatusCode >= 300 {
-			return rep, fmt.Errorf("[%d] %s", rep.StatusCode, rep.Status)
+			return rep, newStatusError(rep)
 		}
 	}
 
@@ -105,7 +152,11 @@ func (s APIv1) Status(ctx context.Context) (out *StatusReply, err error) {
 	if req, err = s.NewRequest(ctx, http.MethodGet, "/v1/status", nil); err != nil {
 		return nil, err
 	}
+	return s.status(req)
+}
 
+// Execute a status request, parsing the reply if the server is unavailable.
+func (s APIv1) status(req *http.Request) (out *StatusReply, err error) {
 	// Execute the request and get a response
 	// NOTE: cannot use s.Do because we want to parse 503 Unavailable errors
 	var rep *http.Response
@@ -116,7 +167,7 @@ func (s APIv1) Status(ctx context.Context) (out *StatusReply, err error) {
 
 	// Detect other errors
 	if rep.StatusCode != http.StatusOK && rep.StatusCode != http.StatusServiceUnavailable {
-		return nil, fmt.Errorf("[%d] %s", rep.StatusCode, rep.Status)
+		return nil, newStatusError(rep)
 	}
 
 	// Deserialize the JSON data from the response
@@ -127,6 +178,22 @@ func (s APIv1) Status(ctx context.Context) (out *StatusReply, err error) {
 	return out, nil
 }
 
+func (s APIv1) Policy(ctx context.Context) (out *PolicyReply, err error) {
+	//  Make the HTTP request
+	var req *http.Request
+	if req, err = s.NewRequest(ctx, http.MethodGet, "/v1/policy", nil); err != nil {
+		return nil, err
+	}
+
+	// Execute the request and get a response
+	out = &PolicyReply{}
+	if _, err = s.Do(req, out, true); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
 func (s APIv1) CreateSecret(ctx context.Context, in *CreateSecretRequest) (out *CreateSecretReply, err error) {
 	//  Make the HTTP request
 	var req *http.Request
@@ -143,6 +210,22 @@ func (s APIv1) CreateSecret(ctx context.Context, in *CreateSecretRequest) (out *
 	return out, nil
 }
 
+func (s APIv1) CreateBatch(ctx context.Context, in *CreateBatchRequest) (out *CreateBatchReply, err error) {
+	//  Make the HTTP request
+	var req *http.Request
+	if req, err = s.NewRequest(ctx, http.MethodPost, "/v1/secrets/batch", in); err != nil {
+		return nil, err
+	}
+
+	// Execute the request and get a response
+	out = &CreateBatchReply{}
+	if _, err = s.Do(req, out, true); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
 func (s APIv1) FetchSecret(ctx context.Context, token, password string) (out *FetchSecretReply, err error) {
 	//  Make the HTTP request
 	var req *http.Request
@@ -184,3 +267,190 @@ func (s APIv1) DestroySecret(ctx context.Context, token, password string) (out *
 
 	return out, nil
 }
+
+func (s APIv1) RequestSecret(ctx context.Context, in *RequestSecretRequest) (out *RequestSecretReply, err error) {
+	//  Make the HTTP request
+	var req *http.Request
+	if req, err = s.NewRequest(ctx, http.MethodPost, "/v1/requests", in); err != nil {
+		return nil, err
+	}
+
+	// Execute the request and get a response
+	out = &RequestSecretReply{}
+	if _, err = s.Do(req, out, true); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s APIv1) RespondSecret(ctx context.Context, token string, in *RespondSecretRequest) (out *RespondSecretReply, err error) {
+	//  Make the HTTP request
+	var req *http.Request
+	if req, err = s.NewRequest(ctx, http.MethodPost, fmt.Sprintf("/v1/requests/%s", token), in); err != nil {
+		return nil, err
+	}
+
+	// Execute the request and get a response
+	out = &RespondSecretReply{}
+	if _, err = s.Do(req, out, true); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s APIv1) FetchResponse(ctx context.Context, token, owner string) (out *FetchSecretReply, err error) {
+	//  Make the HTTP request
+	var req *http.Request
+	if req, err = s.NewRequest(ctx, http.MethodGet, fmt.Sprintf("/v1/requests/%s", token), nil); err != nil {
+		return nil, err
+	}
+
+	// The owner token is sent in the Authorization header in the same way as a password
+	if owner != "" {
+		req.Header.Add("Authorization", "Bearer "+base64.URLEncoding.EncodeToString([]byte(owner)))
+	}
+
+	// Execute the request and get a response
+	out = &FetchSecretReply{}
+	if _, err = s.Do(req, out, true); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s APIv1) AdminStats(ctx context.Context) (out *AdminStatsReply, err error) {
+	//  Make the HTTP request
+	var req *http.Request
+	if req, err = s.NewAdminRequest(ctx, http.MethodGet, "/admin/stats", nil); err != nil {
+		return nil, err
+	}
+
+	// Execute the request and get a response
+	out = &AdminStatsReply{}
+	if _, err = s.Do(req, out, true); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s APIv1) AdminListSecrets(ctx context.Context, in *AdminListRequest) (out *AdminListReply, err error) {
+	//  Make the HTTP request
+	var req *http.Request
+	if req, err = s.NewAdminRequest(ctx, http.MethodGet, "/admin/secrets", nil); err != nil {
+		return nil, err
+	}
+
+	// Add the pagination parameters to the query
+	if in != nil {
+		params := url.Values{}
+		if in.PageSize > 0 {
+			params.Set("page_size", strconv.Itoa(in.PageSize))
+		}
+		if in.PageToken != "" {
+			params.Set("page_token", in.PageToken)
+		}
+		req.URL.RawQuery = params.Encode()
+	}
+
+	// Execute the request and get a response
+	out = &AdminListReply{}
+	if _, err = s.Do(req, out, true); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s APIv1) AdminDestroySecret(ctx context.Context, secret string) (out *DestroySecretReply, err error) {
+	//  Make the HTTP request
+	var req *http.Request
+	if req, err = s.NewAdminRequest(ctx, http.MethodDelete, fmt.Sprintf("/admin/secrets/%s", secret), nil); err != nil {
+		return nil, err
+	}
+
+	// Execute the request and get a response
+	out = &DestroySecretReply{}
+	if _, err = s.Do(req, out, true); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s APIv1) AdminPurge(ctx context.Context, in *AdminPurgeRequest) (out *AdminPurgeReply, err error) {
+	//  Make the HTTP request
+	var req *http.Request
+	if req, err = s.NewAdminRequest(ctx, http.MethodPost, "/admin/purge", in); err != nil {
+		return nil, err
+	}
+
+	// Execute the request and get a response
+	out = &AdminPurgeReply{}
+	if _, err = s.Do(req, out, true); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// AdminStatus returns the status of the server including the state of its dependencies.
+func (s APIv1) AdminStatus(ctx context.Context) (out *StatusReply, err error) {
+	//  Make the HTTP request
+	var req *http.Request
+	if req, err = s.NewAdminRequest(ctx, http.MethodGet, "/v1/status", nil); err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = url.Values{"verbose": {"1"}}.Encode()
+	return s.status(req)
+}
+
+func (s APIv1) AdminMaintenance(ctx context.Context) (out *MaintenanceMode, err error) {
+	//  Make the HTTP request
+	var req *http.Request
+	if req, err = s.NewAdminRequest(ctx, http.MethodGet, "/admin/maintenance", nil); err != nil {
+		return nil, err
+	}
+
+	// Execute the request and get a response
+	out = &MaintenanceMode{}
+	if _, err = s.Do(req, out, true); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s APIv1) AdminSetMaintenance(ctx context.Context, in *MaintenanceMode) (out *MaintenanceMode, err error) {
+	//  Make the HTTP request
+	var req *http.Request
+	if req, err = s.NewAdminRequest(ctx, http.MethodPut, "/admin/maintenance", in); err != nil {
+		return nil, err
+	}
+
+	// Execute the request and get a response
+	out = &MaintenanceMode{}
+	if _, err = s.Do(req, out, true); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// NewAdminRequest creates a request to the operator API with the admin token in the
+// Authorization header. Unlike passwords, the admin token is not base64 encoded.
+func (s APIv1) NewAdminRequest(ctx context.Context, method, path string, data interface{}) (req *http.Request, err error) {
+	if s.adminToken == "" {
+		return nil, errors.New("an admin token is required to access the admin api")
+	}
+
+	if req, err = s.NewRequest(ctx, method, path, data); err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+s.adminToken)
+	return req, nil
+}
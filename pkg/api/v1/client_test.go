@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/rotationalio/whisper/pkg/api/v1"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestClient(t *testing.T) {
@@ -66,7 +70,108 @@ func TestClient(t *testing.T) {
 	req, err = apiv1.NewRequest(context.TODO(), http.MethodPost, "/bar", data)
 	require.NoError(t, err)
 	_, err = apiv1.Do(req, nil, true)
-	require.EqualError(t, err, "[400] 400 Bad Request")
+	require.EqualError(t, err, "[400] 400 Bad Request: bad request")
+}
+
+func TestUnixSocket(t *testing.T) {
+	// Socket paths are limited to about 100 characters so use a short temp directory
+	dir, err := os.MkdirTemp("", "whisper")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "whisper.sock")
+	sock, err := net.Listen("unix", path)
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/status", r.URL.Path)
+		w.Header().Add("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&api.StatusReply{Status: "ok"})
+	}))
+	ts.Listener = sock
+	ts.Start()
+	defer ts.Close()
+
+	client, err := api.New("unix://" + path)
+	require.NoError(t, err)
+
+	out, err := client.Status(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, "ok", out.Status)
+
+	_, err = api.New("unix://")
+	require.Error(t, err)
+}
+
+func TestStatusError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json; charset=utf-8")
+		w.Header().Add(api.HeaderRequestID, "01H2VDK8ZWDAAHT7GYRGGDBMJ4")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintln(w, `{"success":false,"error":"secret does not exist","code":"secret_not_found","request_id":"01H2VDK8ZWDAAHT7GYRGGDBMJ4"}`)
+	}))
+	defer ts.Close()
+
+	client, err := api.New(ts.URL)
+	require.NoError(t, err)
+
+	_, err = client.FetchSecret(context.TODO(), "foo", "")
+	require.EqualError(t, err, "[404] secret_not_found: secret does not exist (request id: 01H2VDK8ZWDAAHT7GYRGGDBMJ4)")
+
+	var serr *api.StatusError
+	require.ErrorAs(t, err, &serr)
+	require.Equal(t, http.StatusNotFound, serr.StatusCode)
+	require.Equal(t, "secret does not exist", serr.Message)
+	require.Equal(t, "01H2VDK8ZWDAAHT7GYRGGDBMJ4", serr.RequestID)
+	require.Equal(t, api.ErrSecretNotFound, serr.Code)
+
+	// Callers can test the error code without matching the message
+	require.ErrorIs(t, err, api.ErrSecretNotFound)
+	require.NotErrorIs(t, err, api.ErrPasswordRequired)
+}
+
+func TestRateLimited(t *testing.T) {
+	// Rate limits may be enforced by a proxy that does not return a whisper reply
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintln(w, "slow down")
+	}))
+	defer ts.Close()
+
+	client, err := api.New(ts.URL)
+	require.NoError(t, err)
+
+	_, err = client.FetchSecret(context.TODO(), "foo", "")
+	require.ErrorIs(t, err, api.ErrRateLimited)
+	require.EqualError(t, err, "[429] rate_limited")
+
+	_, err = client.Status(context.TODO())
+	require.ErrorIs(t, err, api.ErrRateLimited)
+}
+
+func TestTraceContext(t *testing.T) {
+	client, err := api.New("http://localhost")
+	require.NoError(t, err)
+	apiv1 := client.(*api.APIv1)
+
+	// No traceparent header is sent without a span in the context
+	req, err := apiv1.NewRequest(context.Background(), http.MethodGet, "/v1/status", nil)
+	require.NoError(t, err)
+	require.Empty(t, req.Header.Get("traceparent"))
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	req, err = apiv1.NewRequest(ctx, http.MethodGet, "/v1/status", nil)
+	require.NoError(t, err)
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", req.Header.Get("traceparent"))
 }
 
 func TestStatus(t *testing.T) {
@@ -147,6 +252,48 @@ func TestCreateSecret(t *testing.T) {
 	require.True(t, fixture.Expires.Equal(out.Expires))
 }
 
+func TestCreateBatch(t *testing.T) {
+	fixture := &api.CreateBatchReply{
+		Bundle: &api.CreateSecretReply{Token: "abc1234cde", Expires: time.Now().Add(24 * time.Hour)},
+	}
+
+	req := &api.CreateBatchRequest{
+		Secrets: []*api.CreateSecretRequest{
+			{Secret: "super secret squirrel"},
+			{Secret: "c3VwZXIgc2VjcmV0IHNxdWlycmVs", Filename: "squirrel.txt", IsBase64: true},
+		},
+		Password: "unlockingkey",
+		Bundle:   true,
+	}
+
+	// Create a Test Server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/v1/secrets/batch", r.URL.Path)
+
+		// Must be able to deserialize the request
+		in := new(api.CreateBatchRequest)
+		err := json.NewDecoder(r.Body).Decode(in)
+		require.NoError(t, err)
+		require.Equal(t, req, in)
+
+		w.Header().Add("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(fixture)
+	}))
+	defer ts.Close()
+
+	// Create a Client that makes requests to the test server
+	client, err := api.New(ts.URL)
+	require.NoError(t, err)
+
+	out, err := client.CreateBatch(context.TODO(), req)
+	require.NoError(t, err)
+	require.Empty(t, out.Secrets)
+	require.Equal(t, fixture.Bundle.Token, out.Bundle.Token)
+	require.True(t, fixture.Bundle.Expires.Equal(out.Bundle.Expires))
+}
+
 func TestFetchSecretNoPassword(t *testing.T) {
 	fixture := &api.FetchSecretReply{
 		Secret:    "the eagle flies at midnight",
@@ -252,3 +399,125 @@ func TestDestroySecretPassword(t *testing.T) {
 	_, err = client.DestroySecret(context.TODO(), "abcd1234dcba", "supersecret")
 	require.NoError(t, err)
 }
+
+func TestRequestSecret(t *testing.T) {
+	fixture := &api.RequestSecretReply{
+		Token:   "abc1234cde",
+		Owner:   "edc4321cba",
+		Expires: time.Now().Add(24 * time.Hour),
+	}
+
+	// Create a Test Server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/v1/requests", r.URL.Path)
+
+		in := new(api.RequestSecretRequest)
+		err := json.NewDecoder(r.Body).Decode(in)
+		require.NoError(t, err)
+		require.Equal(t, 2, in.Accesses)
+
+		w.Header().Add("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(fixture)
+	}))
+	defer ts.Close()
+
+	// Create a Client that makes requests to the test server
+	client, err := api.New(ts.URL)
+	require.NoError(t, err)
+
+	out, err := client.RequestSecret(context.TODO(), &api.RequestSecretRequest{Accesses: 2})
+	require.NoError(t, err)
+	require.Equal(t, fixture.Token, out.Token)
+	require.Equal(t, fixture.Owner, out.Owner)
+	require.True(t, fixture.Expires.Equal(out.Expires))
+}
+
+func TestRespondSecret(t *testing.T) {
+	fixture := &api.RespondSecretReply{Fulfilled: true}
+
+	// Create a Test Server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/v1/requests/abcd1234dcba", r.URL.Path)
+		require.Empty(t, r.Header.Get("Authorization"))
+
+		in := new(api.RespondSecretRequest)
+		err := json.NewDecoder(r.Body).Decode(in)
+		require.NoError(t, err)
+		require.Equal(t, "the vendor api key", in.Secret)
+
+		w.Header().Add("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(fixture)
+	}))
+	defer ts.Close()
+
+	// Create a Client that makes requests to the test server
+	client, err := api.New(ts.URL)
+	require.NoError(t, err)
+
+	out, err := client.RespondSecret(context.TODO(), "abcd1234dcba", &api.RespondSecretRequest{Secret: "the vendor api key"})
+	require.NoError(t, err)
+	require.True(t, out.Fulfilled)
+}
+
+func TestFetchResponse(t *testing.T) {
+	fixture := &api.FetchSecretReply{Secret: "the vendor api key"}
+
+	// Create a Test Server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, "/v1/requests/abcd1234dcba", r.URL.Path)
+		require.Equal(t, "Bearer c3VwZXJzZWNyZXQ=", r.Header.Get("Authorization"))
+
+		w.Header().Add("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(fixture)
+	}))
+	defer ts.Close()
+
+	// Create a Client that makes requests to the test server
+	client, err := api.New(ts.URL)
+	require.NoError(t, err)
+
+	out, err := client.FetchResponse(context.TODO(), "abcd1234dcba", "supersecret")
+	require.NoError(t, err)
+	require.Equal(t, fixture.Secret, out.Secret)
+}
+
+func TestAdminListSecrets(t *testing.T) {
+	fixture := &api.AdminListReply{
+		Secrets:       []*api.SecretMetadata{{Secret: "tok_0123456789abcdef01234567", Status: "active"}},
+		NextPageToken: "tok_0123456789abcdef01234567",
+	}
+
+	// Create a Test Server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, "/admin/secrets", r.URL.Path)
+		require.Equal(t, "10", r.URL.Query().Get("page_size"))
+		require.Equal(t, "tok_abcdef", r.URL.Query().Get("page_token"))
+		require.Equal(t, "Bearer theadmintoken", r.Header.Get("Authorization"))
+
+		w.Header().Add("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(fixture)
+	}))
+	defer ts.Close()
+
+	// Create a Client that makes requests to the test server
+	client, err := api.NewAdmin(ts.URL, "theadmintoken")
+	require.NoError(t, err)
+
+	out, err := client.AdminListSecrets(context.TODO(), &api.AdminListRequest{PageSize: 10, PageToken: "tok_abcdef"})
+	require.NoError(t, err)
+	require.Equal(t, fixture, out)
+
+	// An admin token is required to make admin requests
+	client, err = api.NewAdmin(ts.URL, "")
+	require.NoError(t, err)
+	_, err = client.AdminStats(context.TODO())
+	require.Error(t, err)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrDigestMismatch is returned when the data of a secret does not match the SHA-256
+// digest computed by the server when the secret was created, e.g. if the secret was
+// truncated or corrupted before it was received.
+var ErrDigestMismatch = errors.New("secret does not match its sha256 digest")
+
+// Digest returns the hex encoded SHA-256 digest of the data of a secret.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// DecodeSecret returns the data of a secret, decoding it if it is base64 encoded.
+func DecodeSecret(secret string, isBase64 bool) ([]byte, error) {
+	if !isBase64 {
+		return []byte(secret), nil
+	}
+	return base64.StdEncoding.DecodeString(secret)
+}
+
+// Decode returns the data of the secret and verifies it against the SHA-256 digest of
+// the reply. Secrets created before digests were recorded are not verified.
+func (r *FetchSecretReply) Decode() ([]byte, error) {
+	return verify(r.Secret, r.IsBase64, r.SHA256)
+}
+
+// Decode returns the data of the secret and verifies it against the SHA-256 digest of
+// the bundle item.
+func (b *BundleItem) Decode() ([]byte, error) {
+	return verify(b.Secret, b.IsBase64, b.SHA256)
+}
+
+func verify(secret string, isBase64 bool, digest string) (data []byte, err error) {
+	if data, err = DecodeSecret(secret, isBase64); err != nil {
+		return nil, fmt.Errorf("could not decode secret: %w", err)
+	}
+
+	if digest != "" && Digest(data) != digest {
+		return nil, fmt.Errorf("%w: expected %s got %s", ErrDigestMismatch, digest, Digest(data))
+	}
+	return data, nil
+}
@@ -0,0 +1,43 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	const digest = "456efa09eef00ec714935dc661a809388dbf9c4577de2935e52041da35c42c61"
+	require.Equal(t, digest, api.Digest([]byte("the eagle flies at midnight")))
+
+	// The digest is computed from the data after it is base64 decoded
+	rep := &api.FetchSecretReply{Secret: "dGhlIGVhZ2xlIGZsaWVzIGF0IG1pZG5pZ2h0", IsBase64: true, SHA256: digest}
+	data, err := rep.Decode()
+	require.NoError(t, err)
+	require.Equal(t, "the eagle flies at midnight", string(data))
+
+	item := &api.BundleItem{Secret: "the eagle flies at midnight", SHA256: digest}
+	data, err = item.Decode()
+	require.NoError(t, err)
+	require.Equal(t, "the eagle flies at midnight", string(data))
+
+	// Secrets that do not match their digest are rejected
+	rep.Secret = "dGhlIGVhZ2xlIGZsaWVzIGF0IG5vb24="
+	_, err = rep.Decode()
+	require.ErrorIs(t, err, api.ErrDigestMismatch)
+
+	item.Secret = "the eagle flies at noon"
+	_, err = item.Decode()
+	require.ErrorIs(t, err, api.ErrDigestMismatch)
+
+	// Secrets without a digest are not verified
+	rep.SHA256 = ""
+	data, err = rep.Decode()
+	require.NoError(t, err)
+	require.Equal(t, "the eagle flies at noon", string(data))
+
+	rep.Secret = "not base64!"
+	_, err = rep.Decode()
+	require.Error(t, err)
+}
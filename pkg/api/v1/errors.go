@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// HeaderRequestID is returned by the server to correlate a request with its logs.
+const HeaderRequestID = "X-Request-ID"
+
+// ErrorCode is a stable, machine-readable identifier of an error returned by the API so
+// that clients do not have to match error messages, which may change. Error codes are
+// errors so that the errors returned by the client can be tested with errors.Is, e.g.
+// errors.Is(err, api.ErrPasswordRequired).
+type ErrorCode string
+
+// Error codes returned by the whisper API.
+const (
+	ErrInvalidRequest    ErrorCode = "invalid_request"    // the request could not be parsed or is invalid
+	ErrSecretNotFound    ErrorCode = "secret_not_found"   // the secret does not exist, has expired, or was destroyed
+	ErrPasswordRequired  ErrorCode = "password_required"  // the secret is password protected or the policy requires a password
+	ErrPasswordIncorrect ErrorCode = "password_incorrect" // the password supplied for the secret is not correct
+	ErrPasswordTooWeak   ErrorCode = "password_too_weak"  // the password is not as strong as the policy requires
+	ErrPayloadTooLarge   ErrorCode = "payload_too_large"  // the secret is larger than the policy allows
+	ErrTTLInvalid        ErrorCode = "ttl_invalid"        // the lifetime of the secret is not allowed by the policy
+	ErrAccessesInvalid   ErrorCode = "accesses_invalid"   // the number of accesses is not allowed by the policy
+	ErrRequestPending    ErrorCode = "request_pending"    // the secret request has not been responded to yet
+	ErrRequestFulfilled  ErrorCode = "request_fulfilled"  // the secret request has already been responded to
+	ErrRateLimited       ErrorCode = "rate_limited"       // too many requests; retry later
+	ErrUnauthorized      ErrorCode = "unauthorized"       // the admin credentials are missing or invalid
+	ErrNotFound          ErrorCode = "not_found"          // the route does not exist
+	ErrMethodNotAllowed  ErrorCode = "method_not_allowed" // the route does not support the method
+)
+
+func (c ErrorCode) Error() string {
+	return string(c)
+}
+
+// StatusError is returned by the client when the server responds with an error status.
+// The request ID should be included when reporting the error so that the request can be
+// found in the server logs.
+type StatusError struct {
+	StatusCode int       // the http status code of the response
+	Status     string    // the http status text of the response
+	Code       ErrorCode // the error code returned by the server, if any
+	Message    string    // the error message returned by the server, if any
+	RequestID  string    // the request ID assigned by the server, if any
+}
+
+// Create a status error from an unsuccessful response, parsing the error reply from the
+// body if possible. The request ID from the reply is preferred to the response header.
+func newStatusError(rep *http.Response) *StatusError {
+	err := &StatusError{
+		StatusCode: rep.StatusCode,
+		Status:     rep.Status,
+		RequestID:  rep.Header.Get(HeaderRequestID),
+	}
+
+	reply := &Reply{}
+	if json.NewDecoder(rep.Body).Decode(reply) == nil {
+		err.Code = reply.Code
+		err.Message = reply.Error
+		if reply.RequestID != "" {
+			err.RequestID = reply.RequestID
+		}
+	}
+
+	// Rate limits may be enforced by a proxy that does not return an error code
+	if err.Code == "" && err.StatusCode == http.StatusTooManyRequests {
+		err.Code = ErrRateLimited
+	}
+	return err
+}
+
+// Error describes the error with the error code and the message from the server so that
+// it can be shown to the user, e.g. "[404] secret_not_found: secret does not exist". The
+// message is for humans and may change; use errors.Is with an ErrorCode to handle errors.
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("[%d] %s", e.StatusCode, e.Status)
+	if e.Code != "" {
+		msg = fmt.Sprintf("[%d] %s", e.StatusCode, e.Code)
+	}
+
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" (request id: %s)", e.RequestID)
+	}
+	return msg
+}
+
+// Unwrap returns the error code so that status errors can be tested with errors.Is.
+func (e *StatusError) Unwrap() error {
+	if e.Code == "" {
+		return nil
+	}
+	return e.Code
+}
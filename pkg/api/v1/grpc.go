@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/rotationalio/whisper/pkg/api/v1/pb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ErrorDomain identifies the whisper error codes attached to gRPC statuses.
+	ErrorDomain = "whisper"
+
+	// MetadataRequestID is the gRPC metadata key of the request ID, which is returned in
+	// the header metadata of every response.
+	MetadataRequestID = "x-request-id"
+)
+
+// NewGRPC creates a client for the gRPC API that implements the same Service interface
+// as the REST client so that callers can switch transports. The target is a host:port
+// or a unix:///path/to.sock target. Transport credentials must be specified in the dial
+// options, e.g. grpc.WithTransportCredentials(credentials.NewTLS(conf)). The client
+// should be closed when it is no longer needed.
+func NewGRPC(target string, opts ...grpc.DialOption) (_ *GRPCv1, err error) {
+	c := &GRPCv1{}
+	if c.conn, err = grpc.Dial(target, opts...); err != nil {
+		return nil, err
+	}
+	c.client = pb.NewWhisperClient(c.conn)
+	return c, nil
+}
+
+// GRPCv1 implements the Service interface with the gRPC API. Errors returned by the
+// server are converted to the same *StatusError returned by the REST client.
+type GRPCv1 struct {
+	conn   *grpc.ClientConn
+	client pb.WhisperClient
+}
+
+// Ensure that the gRPC client implements the Service interface
+var _ Service = &GRPCv1{}
+
+// Close the connection to the server.
+func (c *GRPCv1) Close() error {
+	return c.conn.Close()
+}
+
+func (c *GRPCv1) Status(ctx context.Context) (out *StatusReply, err error) {
+	var (
+		rep    *pb.StatusReply
+		header metadata.MD
+	)
+	if rep, err = c.client.Status(ctx, &pb.StatusRequest{}, grpc.Header(&header)); err != nil {
+		return nil, newGRPCStatusError(ctx, err, header)
+	}
+
+	out = &StatusReply{}
+	out.FromProto(rep)
+	return out, nil
+}
+
+func (c *GRPCv1) Policy(ctx context.Context) (out *PolicyReply, err error) {
+	var (
+		rep    *pb.PolicyReply
+		header metadata.MD
+	)
+	if rep, err = c.client.Policy(ctx, &pb.PolicyRequest{}, grpc.Header(&header)); err != nil {
+		return nil, newGRPCStatusError(ctx, err, header)
+	}
+
+	out = &PolicyReply{}
+	out.FromProto(rep)
+	return out, nil
+}
+
+func (c *GRPCv1) CreateSecret(ctx context.Context, in *CreateSecretRequest) (out *CreateSecretReply, err error) {
+	var (
+		rep    *pb.CreateSecretReply
+		header metadata.MD
+	)
+	if rep, err = c.client.CreateSecret(ctx, in.Proto(), grpc.Header(&header)); err != nil {
+		return nil, newGRPCStatusError(ctx, err, header)
+	}
+
+	out = &CreateSecretReply{}
+	out.FromProto(rep)
+	return out, nil
+}
+
+func (c *GRPCv1) CreateBatch(ctx context.Context, in *CreateBatchRequest) (out *CreateBatchReply, err error) {
+	var (
+		rep    *pb.CreateBatchReply
+		header metadata.MD
+	)
+	if rep, err = c.client.CreateBatch(ctx, in.Proto(), grpc.Header(&header)); err != nil {
+		return nil, newGRPCStatusError(ctx, err, header)
+	}
+
+	out = &CreateBatchReply{}
+	out.FromProto(rep)
+	return out, nil
+}
+
+func (c *GRPCv1) FetchSecret(ctx context.Context, token, password string) (out *FetchSecretReply, err error) {
+	var (
+		rep    *pb.FetchSecretReply
+		header metadata.MD
+	)
+	if rep, err = c.client.FetchSecret(ctx, &pb.FetchSecretRequest{Token: token, Password: password}, grpc.Header(&header)); err != nil {
+		return nil, newGRPCStatusError(ctx, err, header)
+	}
+
+	out = &FetchSecretReply{}
+	out.FromProto(rep)
+	return out, nil
+}
+
+func (c *GRPCv1) DestroySecret(ctx context.Context, token, password string) (out *DestroySecretReply, err error) {
+	var (
+		rep    *pb.DestroySecretReply
+		header metadata.MD
+	)
+	if rep, err = c.client.DestroySecret(ctx, &pb.DestroySecretRequest{Token: token, Password: password}, grpc.Header(&header)); err != nil {
+		return nil, newGRPCStatusError(ctx, err, header)
+	}
+
+	out = &DestroySecretReply{}
+	out.FromProto(rep)
+	return out, nil
+}
+
+func (c *GRPCv1) RequestSecret(ctx context.Context, in *RequestSecretRequest) (out *RequestSecretReply, err error) {
+	var (
+		rep    *pb.RequestSecretReply
+		header metadata.MD
+	)
+	if rep, err = c.client.RequestSecret(ctx, in.Proto(), grpc.Header(&header)); err != nil {
+		return nil, newGRPCStatusError(ctx, err, header)
+	}
+
+	out = &RequestSecretReply{}
+	out.FromProto(rep)
+	return out, nil
+}
+
+func (c *GRPCv1) RespondSecret(ctx context.Context, token string, in *RespondSecretRequest) (out *RespondSecretReply, err error) {
+	var (
+		rep    *pb.RespondSecretReply
+		header metadata.MD
+	)
+	if rep, err = c.client.RespondSecret(ctx, in.Proto(token), grpc.Header(&header)); err != nil {
+		return nil, newGRPCStatusError(ctx, err, header)
+	}
+
+	out = &RespondSecretReply{}
+	out.FromProto(rep)
+	return out, nil
+}
+
+func (c *GRPCv1) FetchResponse(ctx context.Context, token, owner string) (out *FetchSecretReply, err error) {
+	var (
+		rep    *pb.FetchSecretReply
+		header metadata.MD
+	)
+	if rep, err = c.client.FetchResponse(ctx, &pb.FetchResponseRequest{Token: token, Owner: owner}, grpc.Header(&header)); err != nil {
+		return nil, newGRPCStatusError(ctx, err, header)
+	}
+
+	out = &FetchSecretReply{}
+	out.FromProto(rep)
+	return out, nil
+}
+
+// The gRPC codes that are equivalent to the http status codes returned by the REST API.
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.FailedPrecondition,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusInternalServerError: codes.Internal,
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+}
+
+// GRPCCode returns the gRPC code that is equivalent to the http status code.
+func GRPCCode(status int) codes.Code {
+	if code, ok := grpcCodes[status]; ok {
+		return code
+	}
+	return codes.Unknown
+}
+
+// HTTPStatus returns the http status code that is equivalent to the gRPC code.
+func HTTPStatus(code codes.Code) int {
+	for status, c := range grpcCodes {
+		if c == code {
+			return status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// Convert a gRPC status into the error returned by the REST client so that errors can
+// be handled in the same way for both transports. Errors that are not gRPC statuses are
+// returned unchanged and the context error is returned if the request was canceled.
+func newGRPCStatusError(ctx context.Context, err error, header metadata.MD) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	if ctx.Err() != nil && (st.Code() == codes.Canceled || st.Code() == codes.DeadlineExceeded) {
+		return ctx.Err()
+	}
+
+	serr := &StatusError{
+		StatusCode: HTTPStatus(st.Code()),
+		Status:     st.Code().String(),
+		Message:    st.Message(),
+	}
+
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == ErrorDomain {
+			serr.Code = ErrorCode(info.Reason)
+		}
+	}
+
+	// Rate limits may be enforced by a proxy that does not return an error code
+	if serr.Code == "" && st.Code() == codes.ResourceExhausted {
+		serr.Code = ErrRateLimited
+	}
+
+	if ids := header.Get(MetadataRequestID); len(ids) > 0 {
+		serr.RequestID = ids[0]
+	}
+	return serr
+}
@@ -0,0 +1,533 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification the document conforms to.
+const OpenAPIVersion = "3.0.3"
+
+// Document is an OpenAPI 3 description of the whisper API. Only the parts of the
+// OpenAPI specification that are needed to describe whisper are implemented. The
+// schemas of the requests and replies are generated from the Go types in this package
+// so that the document cannot drift from the API that the client and server use.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Tags       []*Tag              `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lowercase http methods to the operations of a path.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query, or header
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema describes a JSON value. Schemas of structs are stored in the components of
+// the document and are referenced by name.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`             // http or apiKey
+	Scheme      string `json:"scheme,omitempty"` // bearer for http schemes
+	Name        string `json:"name,omitempty"`   // the header of apiKey schemes
+	In          string `json:"in,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// SecurityRequirement maps the names of security schemes to their (unused) scopes. An
+// empty requirement in the list of requirements of an operation makes it optional.
+type SecurityRequirement map[string][]string
+
+// Names of the security schemes of the API.
+const (
+	SecurityPassword = "password"
+	SecurityOwner    = "owner"
+	SecurityAdmin    = "admin"
+	SecuritySlack    = "slack"
+)
+
+// Tags group the operations of the API.
+const (
+	tagStatus   = "status"
+	tagSecrets  = "secrets"
+	tagRequests = "requests"
+	tagSlack    = "slack"
+	tagAdmin    = "admin"
+)
+
+// The error codes are listed in the schema of replies so that clients can be generated
+// with an enumeration of the codes.
+var errorCodes = []ErrorCode{
+	ErrInvalidRequest, ErrSecretNotFound, ErrPasswordRequired, ErrPasswordIncorrect,
+	ErrPasswordTooWeak, ErrPayloadTooLarge, ErrTTLInvalid, ErrAccessesInvalid,
+	ErrRequestPending, ErrRequestFulfilled, ErrRateLimited, ErrUnauthorized,
+	ErrNotFound, ErrMethodNotAllowed,
+}
+
+// OpenAPI returns the OpenAPI document of the whisper API for the specified server
+// version. Every route that the server registers must be described here.
+func OpenAPI(version string) *Document {
+	g := &generator{schemas: make(map[string]*Schema)}
+	doc := &Document{
+		OpenAPI: OpenAPIVersion,
+		Info: Info{
+			Title:       "Whisper",
+			Description: "Share secrets with one-time, expiring links.",
+			Version:     version,
+		},
+		Tags: []*Tag{
+			{Name: tagStatus, Description: "Status and configuration of the server"},
+			{Name: tagSecrets, Description: "Create, fetch, and destroy secrets"},
+			{Name: tagRequests, Description: "Request a secret from someone else"},
+			{Name: tagSlack, Description: "Slack slash command and modal interactions"},
+			{Name: tagAdmin, Description: "Operator routes that require the admin token"},
+		},
+		Paths: make(map[string]PathItem),
+		Components: Components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]*SecurityScheme{
+				SecurityPassword: {Type: "http", Scheme: "bearer", Description: "The password of the secret, base64 encoded."},
+				SecurityOwner:    {Type: "http", Scheme: "bearer", Description: "The owner token returned when the secret was requested."},
+				SecurityAdmin:    {Type: "http", Scheme: "bearer", Description: "The admin token configured on the server."},
+				SecuritySlack:    {Type: "apiKey", Name: "X-Slack-Signature", In: "header", Description: "Requests are signed by Slack with the signing secret of the app."},
+			},
+		},
+	}
+
+	token := &Parameter{Name: "token", In: "path", Description: "the token of the secret", Required: true, Schema: &Schema{Type: "string"}}
+
+	// Status and configuration
+	doc.add(http.MethodGet, "/v1/status", &Operation{
+		OperationID: "status",
+		Summary:     "Report the status of the server. The state of its dependencies is only reported to admins.",
+		Tags:        []string{tagStatus},
+		Parameters: []*Parameter{
+			{Name: "verbose", In: "query", Description: "include the state of the dependencies of the server; requires the admin token", Schema: &Schema{Type: "boolean"}},
+		},
+		Security:  optional(SecurityAdmin),
+		Responses: g.responses(http.StatusOK, StatusReply{}, http.StatusUnauthorized),
+	})
+
+	doc.add(http.MethodGet, "/v1/policy", &Operation{
+		OperationID: "policy",
+		Summary:     "Describe the limits that are enforced when secrets are created.",
+		Tags:        []string{tagStatus},
+		Responses:   g.responses(http.StatusOK, PolicyReply{}),
+	})
+
+	doc.add(http.MethodGet, "/v1/openapi.json", &Operation{
+		OperationID: "openapi",
+		Summary:     "Describe the API with this OpenAPI document.",
+		Tags:        []string{tagStatus},
+		Responses:   g.responses(http.StatusOK, nil),
+	})
+
+	// Secrets
+	doc.add(http.MethodPost, "/v1/secrets", &Operation{
+		OperationID: "createSecret",
+		Summary:     "Create a secret and return the token used to fetch it.",
+		Tags:        []string{tagSecrets},
+		RequestBody: g.body(CreateSecretRequest{}),
+		Responses:   g.responses(http.StatusCreated, CreateSecretReply{}, http.StatusBadRequest),
+	})
+
+	doc.add(http.MethodPost, "/v1/secrets/batch", &Operation{
+		OperationID: "createBatch",
+		Summary:     "Create several secrets at once, either all of them or none, returning their tokens or the token of a bundle that reveals all of them.",
+		Tags:        []string{tagSecrets},
+		RequestBody: g.body(CreateBatchRequest{}),
+		Responses:   g.responses(http.StatusCreated, CreateBatchReply{}, http.StatusBadRequest),
+	})
+
+	doc.add(http.MethodGet, "/v1/secrets/{token}", &Operation{
+		OperationID: "fetchSecret",
+		Summary:     "Fetch a secret, destroying it if it has no accesses remaining.",
+		Tags:        []string{tagSecrets},
+		Parameters:  []*Parameter{token},
+		Security:    optional(SecurityPassword),
+		Responses:   g.responses(http.StatusOK, FetchSecretReply{}, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict),
+	})
+
+	doc.add(http.MethodDelete, "/v1/secrets/{token}", &Operation{
+		OperationID: "destroySecret",
+		Summary:     "Destroy a secret before it expires.",
+		Tags:        []string{tagSecrets},
+		Parameters:  []*Parameter{token},
+		Security:    optional(SecurityPassword),
+		Responses:   g.responses(http.StatusOK, DestroySecretReply{}, http.StatusUnauthorized, http.StatusNotFound),
+	})
+
+	// Secret requests
+	doc.add(http.MethodPost, "/v1/requests", &Operation{
+		OperationID: "requestSecret",
+		Summary:     "Request a secret, returning the token to share with the responder and the owner token to fetch the response.",
+		Tags:        []string{tagRequests},
+		RequestBody: g.body(RequestSecretRequest{}),
+		Responses:   g.responses(http.StatusCreated, RequestSecretReply{}, http.StatusBadRequest),
+	})
+
+	doc.add(http.MethodPost, "/v1/requests/{token}", &Operation{
+		OperationID: "respondSecret",
+		Summary:     "Respond to a secret request with the secret.",
+		Tags:        []string{tagRequests},
+		Parameters:  []*Parameter{token},
+		RequestBody: g.body(RespondSecretRequest{}),
+		Responses:   g.responses(http.StatusOK, RespondSecretReply{}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict),
+	})
+
+	doc.add(http.MethodGet, "/v1/requests/{token}", &Operation{
+		OperationID: "fetchResponse",
+		Summary:     "Fetch the response to a secret request.",
+		Tags:        []string{tagRequests},
+		Parameters:  []*Parameter{token},
+		Security:    []SecurityRequirement{{SecurityOwner: {}}},
+		Responses:   g.responses(http.StatusOK, FetchSecretReply{}, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict),
+	})
+
+	// Slack, only available if the integration is configured
+	doc.add(http.MethodPost, "/v1/slack/commands", &Operation{
+		OperationID: "slackCommand",
+		Summary:     "Open the create secret form in response to the /whisper slash command.",
+		Tags:        []string{tagSlack},
+		RequestBody: form(map[string]*Schema{
+			"command":      {Type: "string"},
+			"text":         {Type: "string"},
+			"trigger_id":   {Type: "string"},
+			"response_url": {Type: "string", Format: "uri"},
+			"team_id":      {Type: "string"},
+			"channel_id":   {Type: "string"},
+			"user_id":      {Type: "string"},
+		}, "command", "trigger_id", "response_url"),
+		Security:  []SecurityRequirement{{SecuritySlack: {}}},
+		Responses: slackResponses(),
+	})
+
+	doc.add(http.MethodPost, "/v1/slack/interactions", &Operation{
+		OperationID: "slackInteraction",
+		Summary:     "Create a secret when the form opened by the slash command is submitted.",
+		Tags:        []string{tagSlack},
+		RequestBody: form(map[string]*Schema{
+			"payload": {Type: "string", Description: "the JSON encoded interaction"},
+		}, "payload"),
+		Security:  []SecurityRequirement{{SecuritySlack: {}}},
+		Responses: slackResponses(),
+	})
+
+	// Admin, only available if an admin token is configured
+	admin := []SecurityRequirement{{SecurityAdmin: {}}}
+	doc.add(http.MethodGet, "/admin/stats", &Operation{
+		OperationID: "adminStats",
+		Summary:     "Count the secrets in the vault by age and time until they expire.",
+		Tags:        []string{tagAdmin},
+		Security:    admin,
+		Responses:   g.responses(http.StatusOK, AdminStatsReply{}, http.StatusUnauthorized),
+	})
+
+	doc.add(http.MethodGet, "/admin/secrets", &Operation{
+		OperationID: "adminListSecrets",
+		Summary:     "List the metadata of the secrets in the vault.",
+		Tags:        []string{tagAdmin},
+		Parameters:  g.query(AdminListRequest{}),
+		Security:    admin,
+		Responses:   g.responses(http.StatusOK, AdminListReply{}, http.StatusBadRequest, http.StatusUnauthorized),
+	})
+
+	doc.add(http.MethodDelete, "/admin/secrets/{token}", &Operation{
+		OperationID: "adminDestroySecret",
+		Summary:     "Destroy a secret identified by the keyed hash of its token.",
+		Tags:        []string{tagAdmin},
+		Parameters:  []*Parameter{{Name: "token", In: "path", Description: "the keyed hash of the token of the secret", Required: true, Schema: &Schema{Type: "string"}}},
+		Security:    admin,
+		Responses:   g.responses(http.StatusOK, DestroySecretReply{}, http.StatusUnauthorized, http.StatusNotFound),
+	})
+
+	purge := g.responses(http.StatusOK, AdminPurgeReply{}, http.StatusBadRequest, http.StatusUnauthorized)
+	purge[strconv.Itoa(http.StatusAccepted)] = g.response("The purge must be confirmed with the returned token.", AdminPurgeReply{})
+	doc.add(http.MethodPost, "/admin/purge", &Operation{
+		OperationID: "adminPurge",
+		Summary:     "Destroy all secrets; the purge must be confirmed by a second request.",
+		Tags:        []string{tagAdmin},
+		RequestBody: g.body(AdminPurgeRequest{}),
+		Security:    admin,
+		Responses:   purge,
+	})
+
+	doc.add(http.MethodGet, "/admin/maintenance", &Operation{
+		OperationID: "adminMaintenance",
+		Summary:     "Report whether the server is in maintenance mode.",
+		Tags:        []string{tagAdmin},
+		Security:    admin,
+		Responses:   g.responses(http.StatusOK, MaintenanceMode{}, http.StatusUnauthorized),
+	})
+
+	doc.add(http.MethodPut, "/admin/maintenance", &Operation{
+		OperationID: "adminSetMaintenance",
+		Summary:     "Enable or disable maintenance mode without a restart.",
+		Tags:        []string{tagAdmin},
+		RequestBody: g.body(MaintenanceMode{}),
+		Security:    admin,
+		Responses:   g.responses(http.StatusOK, MaintenanceMode{}, http.StatusBadRequest, http.StatusUnauthorized),
+	})
+
+	return doc
+}
+
+// Operation returns the operation for the method and path if it is documented. Paths
+// use the OpenAPI template syntax, e.g. /v1/secrets/{token}.
+func (d *Document) Operation(method, path string) *Operation {
+	if item, ok := d.Paths[path]; ok {
+		return item[strings.ToLower(method)]
+	}
+	return nil
+}
+
+func (d *Document) add(method, path string, op *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// Security requirements that may be omitted, e.g. the password of unprotected secrets.
+func optional(scheme string) []SecurityRequirement {
+	return []SecurityRequirement{{}, {scheme: {}}}
+}
+
+func form(properties map[string]*Schema, required ...string) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content: map[string]*MediaType{
+			"application/x-www-form-urlencoded": {Schema: &Schema{Type: "object", Properties: properties, Required: required}},
+		},
+	}
+}
+
+// Slack expects an empty 200 response to acknowledge requests and displays any text.
+func slackResponses() map[string]*Response {
+	text := map[string]*MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}
+	return map[string]*Response{
+		strconv.Itoa(http.StatusOK):           {Description: "The request was acknowledged.", Content: text},
+		strconv.Itoa(http.StatusBadRequest):   {Description: "The request could not be parsed.", Content: text},
+		strconv.Itoa(http.StatusUnauthorized): {Description: "The request was not signed by Slack."},
+	}
+}
+
+//===========================================================================
+// Schema Generation
+//===========================================================================
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	durationType  = reflect.TypeOf(Duration(0))
+	errorCodeType = reflect.TypeOf(ErrorCode(""))
+)
+
+// generator creates schemas from Go types using their json tags, storing the schemas
+// of structs by name so that they are only described once.
+type generator struct {
+	schemas map[string]*Schema
+}
+
+// Responses of an operation, with the reply type of the successful status code and
+// the error statuses that the operation returns. All operations can return internal
+// errors and are unavailable when the server is in maintenance mode.
+func (g *generator) responses(status int, reply interface{}, errors ...int) map[string]*Response {
+	rep := map[string]*Response{
+		strconv.Itoa(status):                         g.response(http.StatusText(status), reply),
+		strconv.Itoa(http.StatusInternalServerError): g.response(http.StatusText(http.StatusInternalServerError), Reply{}),
+		strconv.Itoa(http.StatusServiceUnavailable):  g.response("The server is unavailable or in maintenance mode.", StatusReply{}),
+	}
+
+	for _, code := range errors {
+		rep[strconv.Itoa(code)] = g.response(http.StatusText(code), Reply{})
+	}
+	return rep
+}
+
+func (g *generator) response(description string, reply interface{}) *Response {
+	rep := &Response{Description: description}
+	if reply != nil {
+		rep.Content = map[string]*MediaType{
+			"application/json": {Schema: g.schema(reflect.TypeOf(reply))},
+		}
+	}
+	return rep
+}
+
+func (g *generator) body(request interface{}) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content: map[string]*MediaType{
+			"application/json": {Schema: g.schema(reflect.TypeOf(request))},
+		},
+	}
+}
+
+// Query parameters are described by the form tags of the fields of a request.
+func (g *generator) query(request interface{}) (params []*Parameter) {
+	t := reflect.TypeOf(request)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _ := tagName(field.Tag.Get("form"))
+		if name == "" || name == "-" {
+			continue
+		}
+
+		params = append(params, &Parameter{
+			Name:     name,
+			In:       "query",
+			Required: required(field),
+			Schema:   g.schema(field.Type),
+		})
+	}
+	return params
+}
+
+func (g *generator) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "string", Format: "duration", Description: "a duration such as 1h30m; numbers are parsed as nanoseconds"}
+	case errorCodeType:
+		codes := make([]string, 0, len(errorCodes))
+		for _, code := range errorCodes {
+			codes = append(codes, string(code))
+		}
+		return &Schema{Type: "string", Enum: codes}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.object(t)
+	default:
+		return &Schema{}
+	}
+}
+
+// Structs are described once in the components of the document and referenced by name.
+func (g *generator) object(t reflect.Type) *Schema {
+	ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+	if _, ok := g.schemas[t.Name()]; ok {
+		return ref
+	}
+
+	// Register the schema before describing the fields in case the type is recursive
+	obj := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.schemas[t.Name()] = obj
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _ := tagName(field.Tag.Get("json"))
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+
+		obj.Properties[name] = g.schema(field.Type)
+		if required(field) {
+			obj.Required = append(obj.Required, name)
+		}
+	}
+	return ref
+}
+
+func tagName(tag string) (name, opts string) {
+	name, opts, _ = strings.Cut(tag, ",")
+	return name, opts
+}
+
+// Fields are required if they are validated as required when requests are bound.
+func required(field reflect.StructField) bool {
+	for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package api_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPI(t *testing.T) {
+	doc := api.OpenAPI("1.2.3")
+	require.Equal(t, "3.0.3", doc.OpenAPI)
+	require.Equal(t, "1.2.3", doc.Info.Version)
+
+	// Schemas are generated from the json and binding tags of the api types
+	create := doc.Components.Schemas["CreateSecretRequest"]
+	require.NotNil(t, create)
+	require.Equal(t, "object", create.Type)
+	require.Equal(t, []string{"secret"}, create.Required)
+	require.Equal(t, "string", create.Properties["secret"].Type)
+	require.Equal(t, "integer", create.Properties["accesses"].Type)
+	require.Equal(t, "boolean", create.Properties["is_base64"].Type)
+	require.Equal(t, "duration", create.Properties["lifetime"].Format)
+
+	reply := doc.Components.Schemas["Reply"]
+	require.NotNil(t, reply)
+	require.Contains(t, reply.Properties["code"].Enum, string(api.ErrSecretNotFound))
+
+	list := doc.Components.Schemas["AdminListReply"]
+	require.NotNil(t, list)
+	require.Equal(t, "array", list.Properties["secrets"].Type)
+	require.Equal(t, "#/components/schemas/SecretMetadata", list.Properties["secrets"].Items.Ref)
+
+	stats := doc.Components.Schemas["AdminStatsReply"]
+	require.NotNil(t, stats)
+	require.Equal(t, "integer", stats.Properties["age"].AdditionalProperties.Type)
+	require.Equal(t, "date-time", stats.Properties["generated"].Format)
+
+	// Path parameters and query parameters are described
+	fetch := doc.Operation("GET", "/v1/secrets/{token}")
+	require.NotNil(t, fetch)
+	require.Len(t, fetch.Parameters, 1)
+	require.Equal(t, "path", fetch.Parameters[0].In)
+	require.True(t, fetch.Parameters[0].Required)
+	require.Contains(t, fetch.Responses, "404")
+
+	adminList := doc.Operation("GET", "/admin/secrets")
+	require.NotNil(t, adminList)
+	require.Len(t, adminList.Parameters, 2)
+	require.Equal(t, "page_size", adminList.Parameters[0].Name)
+	require.Equal(t, "query", adminList.Parameters[0].In)
+
+	require.Nil(t, doc.Operation("PATCH", "/v1/secrets/{token}"))
+	require.Nil(t, doc.Operation("GET", "/v1/unknown"))
+
+	// All references must resolve to a schema in the components
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+
+	var refs []string
+	collectRefs(t, data, &refs)
+	require.NotEmpty(t, refs)
+	for _, ref := range refs {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		require.Contains(t, doc.Components.Schemas, name, "unresolved reference %s", ref)
+	}
+
+	// Every operation must have a unique operation id and a security scheme that exists
+	ids := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			require.NotEmpty(t, op.OperationID, "%s %s has no operation id", method, path)
+			require.False(t, ids[op.OperationID], "duplicate operation id %s", op.OperationID)
+			ids[op.OperationID] = true
+
+			for _, requirement := range op.Security {
+				for scheme := range requirement {
+					require.Contains(t, doc.Components.SecuritySchemes, scheme)
+				}
+			}
+		}
+	}
+}
+
+func collectRefs(t *testing.T, data []byte, refs *[]string) {
+	var v interface{}
+	require.NoError(t, json.Unmarshal(data, &v))
+
+	var walk func(interface{})
+	walk = func(v interface{}) {
+		switch obj := v.(type) {
+		case map[string]interface{}:
+			for key, val := range obj {
+				if ref, ok := val.(string); ok && key == "$ref" {
+					*refs = append(*refs, ref)
+					continue
+				}
+				walk(val)
+			}
+		case []interface{}:
+			for _, val := range obj {
+				walk(val)
+			}
+		}
+	}
+	walk(v)
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ClientOption configures the APIv1 client when it is created.
+type ClientOption func(*APIv1) error
+
+// WithClientCertificate authenticates the client to servers that require mutual TLS
+// using the PEM encoded certificate and private key files.
+func WithClientCertificate(certFile, keyFile string) ClientOption {
+	return func(c *APIv1) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("could not load client certificate: %s", err)
+		}
+
+		conf := c.tlsConfig()
+		conf.Certificates = append(conf.Certificates, cert)
+		return nil
+	}
+}
+
+// WithCertificateAuthority verifies the server using the CAs in the PEM encoded bundle
+// rather than the system roots, e.g. for servers with certificates from a private CA.
+func WithCertificateAuthority(caFile string) ClientOption {
+	return func(c *APIv1) error {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("could not read ca bundle: %s", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in ca bundle %s", caFile)
+		}
+
+		c.tlsConfig().RootCAs = pool
+		return nil
+	}
+}
+
+// Returns the TLS config of the client's transport, creating a transport with the same
+// settings as the default transport if the client does not have one yet.
+func (s *APIv1) tlsConfig() *tls.Config {
+	transport, ok := s.client.Transport.(*http.Transport)
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+		s.client.Transport = transport
+	}
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return transport.TLSClientConfig
+}
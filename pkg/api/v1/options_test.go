@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMutualTLS(t *testing.T) {
+	// Create a test server with a certificate from an unknown CA that requires clients
+	// to present a certificate
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Len(t, r.TLS.PeerCertificates, 1)
+		require.Equal(t, "whisper-client", r.TLS.PeerCertificates[0].Subject.CommonName)
+
+		w.Header().Add("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&api.StatusReply{Status: "ok"})
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600))
+	certFile, keyFile := writeClientCertificate(t, dir)
+
+	// The server certificate cannot be verified without the CA
+	client, err := api.New(ts.URL)
+	require.NoError(t, err)
+	_, err = client.Status(context.TODO())
+	require.Error(t, err)
+
+	// The server rejects clients without a certificate
+	client, err = api.New(ts.URL, api.WithCertificateAuthority(caFile))
+	require.NoError(t, err)
+	_, err = client.Status(context.TODO())
+	require.Error(t, err)
+
+	client, err = api.New(ts.URL, api.WithCertificateAuthority(caFile), api.WithClientCertificate(certFile, keyFile))
+	require.NoError(t, err)
+	out, err := client.Status(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, "ok", out.Status)
+
+	// Invalid certificate files are reported when the client is created
+	_, err = api.New(ts.URL, api.WithCertificateAuthority(keyFile))
+	require.Error(t, err)
+
+	_, err = api.New(ts.URL, api.WithClientCertificate(certFile, filepath.Join(dir, "missing.key")))
+	require.Error(t, err)
+}
+
+// Write a self-signed client certificate and its key to the directory.
+func writeClientCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "whisper-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
@@ -0,0 +1,8 @@
+/*
+Package pb contains the protocol buffers and gRPC service that are generated from the
+whisper protocol buffer definitions in proto/whisper/v1. The gRPC service mirrors the v1
+REST API; use the gRPC client in the api package rather than using this package directly.
+*/
+package pb
+
+//go:generate protoc -I=../../../../proto --go_out=../../../.. --go_opt=module=github.com/rotationalio/whisper --go-grpc_out=../../../.. --go-grpc_opt=module=github.com/rotationalio/whisper whisper/v1/whisper.proto
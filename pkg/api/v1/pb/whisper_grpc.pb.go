@@ -0,0 +1,425 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.23.2
+// source: whisper/v1/whisper.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Whisper_Status_FullMethodName        = "/whisper.v1.Whisper/Status"
+	Whisper_Policy_FullMethodName        = "/whisper.v1.Whisper/Policy"
+	Whisper_CreateSecret_FullMethodName  = "/whisper.v1.Whisper/CreateSecret"
+	Whisper_CreateBatch_FullMethodName   = "/whisper.v1.Whisper/CreateBatch"
+	Whisper_FetchSecret_FullMethodName   = "/whisper.v1.Whisper/FetchSecret"
+	Whisper_DestroySecret_FullMethodName = "/whisper.v1.Whisper/DestroySecret"
+	Whisper_RequestSecret_FullMethodName = "/whisper.v1.Whisper/RequestSecret"
+	Whisper_RespondSecret_FullMethodName = "/whisper.v1.Whisper/RespondSecret"
+	Whisper_FetchResponse_FullMethodName = "/whisper.v1.Whisper/FetchResponse"
+)
+
+// WhisperClient is the client API for Whisper service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WhisperClient interface {
+	// Report the status of the server.
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusReply, error)
+	// Describe the limits that are enforced when secrets are created.
+	Policy(ctx context.Context, in *PolicyRequest, opts ...grpc.CallOption) (*PolicyReply, error)
+	// Create a secret and return the token used to fetch it.
+	CreateSecret(ctx context.Context, in *CreateSecretRequest, opts ...grpc.CallOption) (*CreateSecretReply, error)
+	// Create several secrets at once, either as separate secrets or as a bundle that is
+	// revealed by a single token. Either all of the secrets are created or none are.
+	CreateBatch(ctx context.Context, in *CreateBatchRequest, opts ...grpc.CallOption) (*CreateBatchReply, error)
+	// Fetch a secret, destroying it if it has no accesses remaining.
+	FetchSecret(ctx context.Context, in *FetchSecretRequest, opts ...grpc.CallOption) (*FetchSecretReply, error)
+	// Destroy a secret before it expires.
+	DestroySecret(ctx context.Context, in *DestroySecretRequest, opts ...grpc.CallOption) (*DestroySecretReply, error)
+	// Request a secret from someone else.
+	RequestSecret(ctx context.Context, in *RequestSecretRequest, opts ...grpc.CallOption) (*RequestSecretReply, error)
+	// Respond to a secret request with the secret.
+	RespondSecret(ctx context.Context, in *RespondSecretRequest, opts ...grpc.CallOption) (*RespondSecretReply, error)
+	// Fetch the response to a secret request with the owner token.
+	FetchResponse(ctx context.Context, in *FetchResponseRequest, opts ...grpc.CallOption) (*FetchSecretReply, error)
+}
+
+type whisperClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWhisperClient(cc grpc.ClientConnInterface) WhisperClient {
+	return &whisperClient{cc}
+}
+
+func (c *whisperClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusReply, error) {
+	out := new(StatusReply)
+	err := c.cc.Invoke(ctx, Whisper_Status_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *whisperClient) Policy(ctx context.Context, in *PolicyRequest, opts ...grpc.CallOption) (*PolicyReply, error) {
+	out := new(PolicyReply)
+	err := c.cc.Invoke(ctx, Whisper_Policy_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *whisperClient) CreateSecret(ctx context.Context, in *CreateSecretRequest, opts ...grpc.CallOption) (*CreateSecretReply, error) {
+	out := new(CreateSecretReply)
+	err := c.cc.Invoke(ctx, Whisper_CreateSecret_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *whisperClient) CreateBatch(ctx context.Context, in *CreateBatchRequest, opts ...grpc.CallOption) (*CreateBatchReply, error) {
+	out := new(CreateBatchReply)
+	err := c.cc.Invoke(ctx, Whisper_CreateBatch_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *whisperClient) FetchSecret(ctx context.Context, in *FetchSecretRequest, opts ...grpc.CallOption) (*FetchSecretReply, error) {
+	out := new(FetchSecretReply)
+	err := c.cc.Invoke(ctx, Whisper_FetchSecret_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *whisperClient) DestroySecret(ctx context.Context, in *DestroySecretRequest, opts ...grpc.CallOption) (*DestroySecretReply, error) {
+	out := new(DestroySecretReply)
+	err := c.cc.Invoke(ctx, Whisper_DestroySecret_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *whisperClient) RequestSecret(ctx context.Context, in *RequestSecretRequest, opts ...grpc.CallOption) (*RequestSecretReply, error) {
+	out := new(RequestSecretReply)
+	err := c.cc.Invoke(ctx, Whisper_RequestSecret_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *whisperClient) RespondSecret(ctx context.Context, in *RespondSecretRequest, opts ...grpc.CallOption) (*RespondSecretReply, error) {
+	out := new(RespondSecretReply)
+	err := c.cc.Invoke(ctx, Whisper_RespondSecret_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *whisperClient) FetchResponse(ctx context.Context, in *FetchResponseRequest, opts ...grpc.CallOption) (*FetchSecretReply, error) {
+	out := new(FetchSecretReply)
+	err := c.cc.Invoke(ctx, Whisper_FetchResponse_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WhisperServer is the server API for Whisper service.
+// All implementations must embed UnimplementedWhisperServer
+// for forward compatibility
+type WhisperServer interface {
+	// Report the status of the server.
+	Status(context.Context, *StatusRequest) (*StatusReply, error)
+	// Describe the limits that are enforced when secrets are created.
+	Policy(context.Context, *PolicyRequest) (*PolicyReply, error)
+	// Create a secret and return the token used to fetch it.
+	CreateSecret(context.Context, *CreateSecretRequest) (*CreateSecretReply, error)
+	// Create several secrets at once, either as separate secrets or as a bundle that is
+	// revealed by a single token. Either all of the secrets are created or none are.
+	CreateBatch(context.Context, *CreateBatchRequest) (*CreateBatchReply, error)
+	// Fetch a secret, destroying it if it has no accesses remaining.
+	FetchSecret(context.Context, *FetchSecretRequest) (*FetchSecretReply, error)
+	// Destroy a secret before it expires.
+	DestroySecret(context.Context, *DestroySecretRequest) (*DestroySecretReply, error)
+	// Request a secret from someone else.
+	RequestSecret(context.Context, *RequestSecretRequest) (*RequestSecretReply, error)
+	// Respond to a secret request with the secret.
+	RespondSecret(context.Context, *RespondSecretRequest) (*RespondSecretReply, error)
+	// Fetch the response to a secret request with the owner token.
+	FetchResponse(context.Context, *FetchResponseRequest) (*FetchSecretReply, error)
+	mustEmbedUnimplementedWhisperServer()
+}
+
+// UnimplementedWhisperServer must be embedded to have forward compatible implementations.
+type UnimplementedWhisperServer struct {
+}
+
+func (UnimplementedWhisperServer) Status(context.Context, *StatusRequest) (*StatusReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedWhisperServer) Policy(context.Context, *PolicyRequest) (*PolicyReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Policy not implemented")
+}
+func (UnimplementedWhisperServer) CreateSecret(context.Context, *CreateSecretRequest) (*CreateSecretReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSecret not implemented")
+}
+func (UnimplementedWhisperServer) CreateBatch(context.Context, *CreateBatchRequest) (*CreateBatchReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateBatch not implemented")
+}
+func (UnimplementedWhisperServer) FetchSecret(context.Context, *FetchSecretRequest) (*FetchSecretReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FetchSecret not implemented")
+}
+func (UnimplementedWhisperServer) DestroySecret(context.Context, *DestroySecretRequest) (*DestroySecretReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DestroySecret not implemented")
+}
+func (UnimplementedWhisperServer) RequestSecret(context.Context, *RequestSecretRequest) (*RequestSecretReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequestSecret not implemented")
+}
+func (UnimplementedWhisperServer) RespondSecret(context.Context, *RespondSecretRequest) (*RespondSecretReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RespondSecret not implemented")
+}
+func (UnimplementedWhisperServer) FetchResponse(context.Context, *FetchResponseRequest) (*FetchSecretReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FetchResponse not implemented")
+}
+func (UnimplementedWhisperServer) mustEmbedUnimplementedWhisperServer() {}
+
+// UnsafeWhisperServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WhisperServer will
+// result in compilation errors.
+type UnsafeWhisperServer interface {
+	mustEmbedUnimplementedWhisperServer()
+}
+
+func RegisterWhisperServer(s grpc.ServiceRegistrar, srv WhisperServer) {
+	s.RegisterService(&Whisper_ServiceDesc, srv)
+}
+
+func _Whisper_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WhisperServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Whisper_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WhisperServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Whisper_Policy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WhisperServer).Policy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Whisper_Policy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WhisperServer).Policy(ctx, req.(*PolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Whisper_CreateSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WhisperServer).CreateSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Whisper_CreateSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WhisperServer).CreateSecret(ctx, req.(*CreateSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Whisper_CreateBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WhisperServer).CreateBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Whisper_CreateBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WhisperServer).CreateBatch(ctx, req.(*CreateBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Whisper_FetchSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FetchSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WhisperServer).FetchSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Whisper_FetchSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WhisperServer).FetchSecret(ctx, req.(*FetchSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Whisper_DestroySecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DestroySecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WhisperServer).DestroySecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Whisper_DestroySecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WhisperServer).DestroySecret(ctx, req.(*DestroySecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Whisper_RequestSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WhisperServer).RequestSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Whisper_RequestSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WhisperServer).RequestSecret(ctx, req.(*RequestSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Whisper_RespondSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RespondSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WhisperServer).RespondSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Whisper_RespondSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WhisperServer).RespondSecret(ctx, req.(*RespondSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Whisper_FetchResponse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FetchResponseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WhisperServer).FetchResponse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Whisper_FetchResponse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WhisperServer).FetchResponse(ctx, req.(*FetchResponseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Whisper_ServiceDesc is the grpc.ServiceDesc for Whisper service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Whisper_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "whisper.v1.Whisper",
+	HandlerType: (*WhisperServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Status",
+			Handler:    _Whisper_Status_Handler,
+		},
+		{
+			MethodName: "Policy",
+			Handler:    _Whisper_Policy_Handler,
+		},
+		{
+			MethodName: "CreateSecret",
+			Handler:    _Whisper_CreateSecret_Handler,
+		},
+		{
+			MethodName: "CreateBatch",
+			Handler:    _Whisper_CreateBatch_Handler,
+		},
+		{
+			MethodName: "FetchSecret",
+			Handler:    _Whisper_FetchSecret_Handler,
+		},
+		{
+			MethodName: "DestroySecret",
+			Handler:    _Whisper_DestroySecret_Handler,
+		},
+		{
+			MethodName: "RequestSecret",
+			Handler:    _Whisper_RequestSecret_Handler,
+		},
+		{
+			MethodName: "RespondSecret",
+			Handler:    _Whisper_RespondSecret_Handler,
+		},
+		{
+			MethodName: "FetchResponse",
+			Handler:    _Whisper_FetchResponse_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "whisper/v1/whisper.proto",
+}
@@ -0,0 +1,332 @@
+package api
+
+import (
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/api/v1/pb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The api types are converted to and from their protocol buffers so that the gRPC
+// client and server can share the same types as the REST API. Zero times and durations
+// are omitted from protocol buffers in the same way they are omitted from JSON.
+
+func (r *StatusReply) Proto() *pb.StatusReply {
+	out := &pb.StatusReply{
+		Status:   r.Status,
+		Uptime:   r.Uptime,
+		Version:  r.Version,
+		Message:  r.Message,
+		ReadOnly: r.ReadOnly,
+	}
+
+	if r.ETA != nil {
+		out.Eta = timestamp(*r.ETA)
+	}
+	return out
+}
+
+func (r *StatusReply) FromProto(in *pb.StatusReply) {
+	*r = StatusReply{
+		Status:   in.GetStatus(),
+		Uptime:   in.GetUptime(),
+		Version:  in.GetVersion(),
+		Message:  in.GetMessage(),
+		ReadOnly: in.GetReadOnly(),
+	}
+
+	if in.GetEta() != nil {
+		eta := in.GetEta().AsTime()
+		r.ETA = &eta
+	}
+}
+
+func (r *PolicyReply) Proto() *pb.PolicyReply {
+	return &pb.PolicyReply{
+		DefaultLifetime:     duration(r.DefaultLifetime),
+		DefaultAccesses:     int64(r.DefaultAccesses),
+		MinLifetime:         duration(r.MinLifetime),
+		MaxLifetime:         duration(r.MaxLifetime),
+		MaxAccesses:         int64(r.MaxAccesses),
+		AllowUnlimited:      r.AllowUnlimited,
+		MaxSize:             int64(r.MaxSize),
+		RequirePassword:     r.RequirePassword,
+		MinPasswordStrength: int64(r.MinPasswordStrength),
+	}
+}
+
+func (r *PolicyReply) FromProto(in *pb.PolicyReply) {
+	*r = PolicyReply{
+		DefaultLifetime:     fromDuration(in.GetDefaultLifetime()),
+		DefaultAccesses:     int(in.GetDefaultAccesses()),
+		MinLifetime:         fromDuration(in.GetMinLifetime()),
+		MaxLifetime:         fromDuration(in.GetMaxLifetime()),
+		MaxAccesses:         int(in.GetMaxAccesses()),
+		AllowUnlimited:      in.GetAllowUnlimited(),
+		MaxSize:             int(in.GetMaxSize()),
+		RequirePassword:     in.GetRequirePassword(),
+		MinPasswordStrength: int(in.GetMinPasswordStrength()),
+	}
+}
+
+func (r *CreateSecretRequest) Proto() *pb.CreateSecretRequest {
+	return &pb.CreateSecretRequest{
+		Secret:   r.Secret,
+		Password: r.Password,
+		Accesses: int64(r.Accesses),
+		Lifetime: duration(r.Lifetime),
+		Filename: r.Filename,
+		IsBase64: r.IsBase64,
+		Callback: r.Callback,
+		Email:    r.Email,
+		Archive:  r.Archive,
+	}
+}
+
+func (r *CreateSecretRequest) FromProto(in *pb.CreateSecretRequest) {
+	*r = CreateSecretRequest{
+		Secret:   in.GetSecret(),
+		Password: in.GetPassword(),
+		Accesses: int(in.GetAccesses()),
+		Lifetime: fromDuration(in.GetLifetime()),
+		Filename: in.GetFilename(),
+		IsBase64: in.GetIsBase64(),
+		Callback: in.GetCallback(),
+		Email:    in.GetEmail(),
+		Archive:  in.GetArchive(),
+	}
+}
+
+func (r *CreateSecretReply) Proto() *pb.CreateSecretReply {
+	return &pb.CreateSecretReply{
+		Token:   r.Token,
+		Expires: timestamp(r.Expires),
+	}
+}
+
+func (r *CreateSecretReply) FromProto(in *pb.CreateSecretReply) {
+	*r = CreateSecretReply{
+		Token:   in.GetToken(),
+		Expires: fromTimestamp(in.GetExpires()),
+	}
+}
+
+func (r *CreateBatchRequest) Proto() *pb.CreateBatchRequest {
+	out := &pb.CreateBatchRequest{
+		Secrets:  make([]*pb.CreateSecretRequest, 0, len(r.Secrets)),
+		Password: r.Password,
+		Accesses: int64(r.Accesses),
+		Lifetime: duration(r.Lifetime),
+		Callback: r.Callback,
+		Email:    r.Email,
+		Bundle:   r.Bundle,
+	}
+
+	for _, secret := range r.Secrets {
+		out.Secrets = append(out.Secrets, secret.Proto())
+	}
+	return out
+}
+
+func (r *CreateBatchRequest) FromProto(in *pb.CreateBatchRequest) {
+	*r = CreateBatchRequest{
+		Secrets:  make([]*CreateSecretRequest, 0, len(in.GetSecrets())),
+		Password: in.GetPassword(),
+		Accesses: int(in.GetAccesses()),
+		Lifetime: fromDuration(in.GetLifetime()),
+		Callback: in.GetCallback(),
+		Email:    in.GetEmail(),
+		Bundle:   in.GetBundle(),
+	}
+
+	for _, secret := range in.GetSecrets() {
+		req := &CreateSecretRequest{}
+		req.FromProto(secret)
+		r.Secrets = append(r.Secrets, req)
+	}
+}
+
+func (r *CreateBatchReply) Proto() *pb.CreateBatchReply {
+	out := &pb.CreateBatchReply{}
+	for _, secret := range r.Secrets {
+		out.Secrets = append(out.Secrets, secret.Proto())
+	}
+
+	if r.Bundle != nil {
+		out.Bundle = r.Bundle.Proto()
+	}
+	return out
+}
+
+func (r *CreateBatchReply) FromProto(in *pb.CreateBatchReply) {
+	*r = CreateBatchReply{}
+	for _, secret := range in.GetSecrets() {
+		rep := &CreateSecretReply{}
+		rep.FromProto(secret)
+		r.Secrets = append(r.Secrets, rep)
+	}
+
+	if in.GetBundle() != nil {
+		r.Bundle = &CreateSecretReply{}
+		r.Bundle.FromProto(in.GetBundle())
+	}
+}
+
+func (r *FetchSecretReply) Proto() *pb.FetchSecretReply {
+	out := &pb.FetchSecretReply{
+		Secret:      r.Secret,
+		Filename:    r.Filename,
+		IsBase64:    r.IsBase64,
+		Created:     timestamp(r.Created),
+		Accesses:    int64(r.Accesses),
+		Destroyed:   r.Destroyed,
+		Archive:     r.Archive,
+		Files:       r.Files,
+		ContentType: r.ContentType,
+		Size:        r.Size,
+		Sha256:      r.SHA256,
+	}
+
+	for _, item := range r.Bundle {
+		out.Bundle = append(out.Bundle, &pb.BundleItem{
+			Secret:      item.Secret,
+			Filename:    item.Filename,
+			IsBase64:    item.IsBase64,
+			Archive:     item.Archive,
+			ContentType: item.ContentType,
+			Size:        item.Size,
+			Sha256:      item.SHA256,
+		})
+	}
+	return out
+}
+
+func (r *FetchSecretReply) FromProto(in *pb.FetchSecretReply) {
+	*r = FetchSecretReply{
+		Secret:      in.GetSecret(),
+		Filename:    in.GetFilename(),
+		IsBase64:    in.GetIsBase64(),
+		Created:     fromTimestamp(in.GetCreated()),
+		Accesses:    int(in.GetAccesses()),
+		Destroyed:   in.GetDestroyed(),
+		Archive:     in.GetArchive(),
+		Files:       in.GetFiles(),
+		ContentType: in.GetContentType(),
+		Size:        in.GetSize(),
+		SHA256:      in.GetSha256(),
+	}
+
+	for _, item := range in.GetBundle() {
+		r.Bundle = append(r.Bundle, &BundleItem{
+			Secret:      item.GetSecret(),
+			Filename:    item.GetFilename(),
+			IsBase64:    item.GetIsBase64(),
+			Archive:     item.GetArchive(),
+			ContentType: item.GetContentType(),
+			Size:        item.GetSize(),
+			SHA256:      item.GetSha256(),
+		})
+	}
+}
+
+func (r *DestroySecretReply) Proto() *pb.DestroySecretReply {
+	return &pb.DestroySecretReply{Destroyed: r.Destroyed}
+}
+
+func (r *DestroySecretReply) FromProto(in *pb.DestroySecretReply) {
+	*r = DestroySecretReply{Destroyed: in.GetDestroyed()}
+}
+
+func (r *RequestSecretRequest) Proto() *pb.RequestSecretRequest {
+	return &pb.RequestSecretRequest{
+		Accesses: int64(r.Accesses),
+		Lifetime: duration(r.Lifetime),
+	}
+}
+
+func (r *RequestSecretRequest) FromProto(in *pb.RequestSecretRequest) {
+	*r = RequestSecretRequest{
+		Accesses: int(in.GetAccesses()),
+		Lifetime: fromDuration(in.GetLifetime()),
+	}
+}
+
+func (r *RequestSecretReply) Proto() *pb.RequestSecretReply {
+	return &pb.RequestSecretReply{
+		Token:   r.Token,
+		Owner:   r.Owner,
+		Expires: timestamp(r.Expires),
+	}
+}
+
+func (r *RequestSecretReply) FromProto(in *pb.RequestSecretReply) {
+	*r = RequestSecretReply{
+		Token:   in.GetToken(),
+		Owner:   in.GetOwner(),
+		Expires: fromTimestamp(in.GetExpires()),
+	}
+}
+
+// Proto returns the protocol buffer of the response; the token is part of the path of
+// REST requests so it must be supplied separately.
+func (r *RespondSecretRequest) Proto(token string) *pb.RespondSecretRequest {
+	return &pb.RespondSecretRequest{
+		Token:    token,
+		Secret:   r.Secret,
+		Filename: r.Filename,
+		IsBase64: r.IsBase64,
+		Archive:  r.Archive,
+	}
+}
+
+func (r *RespondSecretRequest) FromProto(in *pb.RespondSecretRequest) {
+	*r = RespondSecretRequest{
+		Secret:   in.GetSecret(),
+		Filename: in.GetFilename(),
+		IsBase64: in.GetIsBase64(),
+		Archive:  in.GetArchive(),
+	}
+}
+
+func (r *RespondSecretReply) Proto() *pb.RespondSecretReply {
+	return &pb.RespondSecretReply{
+		Fulfilled: r.Fulfilled,
+		Expires:   timestamp(r.Expires),
+	}
+}
+
+func (r *RespondSecretReply) FromProto(in *pb.RespondSecretReply) {
+	*r = RespondSecretReply{
+		Fulfilled: in.GetFulfilled(),
+		Expires:   fromTimestamp(in.GetExpires()),
+	}
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func duration(d Duration) *durationpb.Duration {
+	if d == 0 {
+		return nil
+	}
+	return durationpb.New(time.Duration(d))
+}
+
+func fromDuration(d *durationpb.Duration) Duration {
+	if d == nil {
+		return 0
+	}
+	return Duration(d.AsDuration())
+}
@@ -0,0 +1,73 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestProtoRoundTrip(t *testing.T) {
+	now := time.Date(2023, 6, 12, 14, 32, 1, 0, time.UTC)
+
+	eta := now.Add(time.Hour)
+	status := &api.StatusReply{Status: "maintenance", Uptime: "1h", Version: "1.4", Message: "upgrading", ETA: &eta, ReadOnly: true}
+	statusOut := &api.StatusReply{}
+	statusOut.FromProto(status.Proto())
+	require.Equal(t, status, statusOut)
+
+	policy := &api.PolicyReply{DefaultLifetime: api.Duration(time.Hour), DefaultAccesses: 1, MaxLifetime: api.Duration(24 * time.Hour), MaxSize: 1024, RequirePassword: true, MinPasswordStrength: 3}
+	policyOut := &api.PolicyReply{}
+	policyOut.FromProto(policy.Proto())
+	require.Equal(t, policy, policyOut)
+
+	create := &api.CreateSecretRequest{Secret: "c2VjcmV0", Password: "hunter2", Accesses: 3, Lifetime: api.Duration(time.Minute), Filename: "secret.txt", IsBase64: true, Callback: "https://example.com/hook", Email: "owner@example.com", Archive: "tar+gzip"}
+	createOut := &api.CreateSecretRequest{}
+	createOut.FromProto(create.Proto())
+	require.Equal(t, create, createOut)
+
+	fetch := &api.FetchSecretReply{Secret: "secret", Filename: "secret.txt", Created: now, Accesses: 2, Destroyed: true, Archive: "tar+gzip", Files: []string{"configs/vpn.ovpn", "id_ed25519"}, ContentType: "application/gzip", Size: 1024, SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"}
+	fetchOut := &api.FetchSecretReply{}
+	fetchOut.FromProto(fetch.Proto())
+	require.Equal(t, fetch, fetchOut)
+
+	batch := &api.CreateBatchRequest{Secrets: []*api.CreateSecretRequest{{Secret: "a"}, {Secret: "Yg==", Filename: "b.txt", IsBase64: true}}, Password: "hunter2", Accesses: 2, Lifetime: api.Duration(time.Hour), Bundle: true}
+	batchOut := &api.CreateBatchRequest{}
+	batchOut.FromProto(batch.Proto())
+	require.Equal(t, batch, batchOut)
+
+	batchReply := &api.CreateBatchReply{Secrets: []*api.CreateSecretReply{{Token: "a", Expires: now}, {Token: "b", Expires: now}}}
+	batchReplyOut := &api.CreateBatchReply{}
+	batchReplyOut.FromProto(batchReply.Proto())
+	require.Equal(t, batchReply, batchReplyOut)
+
+	bundle := &api.FetchSecretReply{Created: now, Bundle: []*api.BundleItem{{Secret: "a"}, {Secret: "Yg==", Filename: "b.tar.gz", IsBase64: true, Archive: "tar+gzip", ContentType: "application/gzip", Size: 1, SHA256: "3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d"}}}
+	bundleOut := &api.FetchSecretReply{}
+	bundleOut.FromProto(bundle.Proto())
+	require.Equal(t, bundle, bundleOut)
+
+	respond := &api.RespondSecretRequest{Secret: "secret", Filename: "secret.txt", Archive: "tar+gzip"}
+	pb := respond.Proto("token")
+	require.Equal(t, "token", pb.Token)
+	respondOut := &api.RespondSecretRequest{}
+	respondOut.FromProto(pb)
+	require.Equal(t, respond, respondOut)
+
+	// Zero values are omitted from the protocol buffers
+	empty := (&api.CreateSecretReply{Token: "token"}).Proto()
+	require.Nil(t, empty.Expires)
+	require.Nil(t, (&api.RequestSecretRequest{}).Proto().Lifetime)
+	require.Nil(t, (&api.StatusReply{}).Proto().Eta)
+}
+
+func TestGRPCCodes(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		require.Equal(t, status, api.HTTPStatus(api.GRPCCode(status)))
+	}
+
+	require.Equal(t, codes.Unknown, api.GRPCCode(http.StatusTeapot))
+	require.Equal(t, http.StatusInternalServerError, api.HTTPStatus(codes.DataLoss))
+}
@@ -0,0 +1,422 @@
+/*
+Package archive packages several files or a directory as the contents of a single
+secret and unpacks them again. Archives are gzip compressed tar files that are created
+by the client; the server lists the files in an archive so that operators can see what
+it contains. Every entry is checked before an archive is unpacked so that an archive
+cannot write outside of the directory that it is unpacked into, either with a path such
+as ../../.bashrc or through a symbolic link that points outside of the directory.
+*/
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TarGzip is the type of the gzip compressed tar archives created by this package.
+const TarGzip = "tar+gzip"
+
+// MaxSize limits the uncompressed size of an archive that is created or unpacked by the
+// client since a small compressed secret can expand to a very large archive.
+const MaxSize = 256 * 1024 * 1024
+
+// Standard errors for error type checking
+var (
+	ErrUnsupported = errors.New("unsupported archive type")
+	ErrUnsafePath  = errors.New("archive entry is outside of the archive")
+	ErrUnsafeLink  = errors.New("archive link points outside of the archive")
+	ErrEntryType   = errors.New("archive entry is not a file, directory, or symbolic link")
+	ErrTooLarge    = errors.New("archive is too large to unpack")
+)
+
+// Check returns ErrUnsupported if the archive type cannot be unpacked.
+func Check(archiveType string) error {
+	if archiveType != TarGzip {
+		return fmt.Errorf("%w %q", ErrUnsupported, archiveType)
+	}
+	return nil
+}
+
+// Create writes an archive of the files and directories to w. Directories are archived
+// with their name so that they are unpacked into a directory of the same name. Symbolic
+// links are archived as links and must point to another file in the archive.
+func Create(w io.Writer, paths ...string) (err error) {
+	type file struct {
+		path string
+		hdr  *tar.Header
+	}
+
+	// Collect all of the headers so that the archive is checked before it is written
+	var files []file
+	for _, path := range paths {
+		if path, err = filepath.Abs(path); err != nil {
+			return err
+		}
+
+		root := filepath.Dir(path)
+		if err = filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			var hdr *tar.Header
+			if hdr, err = header(root, path, d); err != nil {
+				return err
+			}
+			files = append(files, file{path: path, hdr: hdr})
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	hdrs := make([]*tar.Header, 0, len(files))
+	for _, f := range files {
+		hdrs = append(hdrs, f.hdr)
+	}
+
+	if err = validate(hdrs, MaxSize); err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		if err = tw.WriteHeader(f.hdr); err != nil {
+			return err
+		}
+
+		if f.hdr.Typeflag == tar.TypeReg {
+			if err = copyFile(tw, f.path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Create the header of a file with its path relative to the root of the archive.
+func header(root, path string, d fs.DirEntry) (hdr *tar.Header, err error) {
+	var info fs.FileInfo
+	if info, err = d.Info(); err != nil {
+		return nil, err
+	}
+
+	var link string
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if hdr, err = tar.FileInfoHeader(info, link); err != nil {
+		return nil, err
+	}
+
+	var name string
+	if name, err = filepath.Rel(root, path); err != nil {
+		return nil, err
+	}
+
+	hdr.Name = filepath.ToSlash(name)
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+
+	// The owner of the files is not meaningful to the recipient of the secret
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+	return hdr, nil
+}
+
+func copyFile(w io.Writer, path string) (err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// List returns the names of the files and links in the archive, returning an error if
+// the archive cannot be read or if it could not be safely unpacked.
+func List(r io.Reader) (files []string, err error) {
+	return ListLimit(r, MaxSize)
+}
+
+// ListLimit lists the archive in the same manner as List, but returns ErrTooLarge as soon
+// as the uncompressed archive is larger than limit bytes so that the server does not
+// decompress more data than the policy allows a secret to contain.
+func ListLimit(r io.Reader, limit int64) (files []string, err error) {
+	var hdrs []*tar.Header
+	if hdrs, err = headers(r, limit); err != nil {
+		return nil, err
+	}
+
+	if err = validate(hdrs, limit); err != nil {
+		return nil, err
+	}
+
+	for _, hdr := range hdrs {
+		if hdr.Typeflag != tar.TypeDir {
+			files = append(files, filepath.ToSlash(clean(hdr.Name)))
+		}
+	}
+	return files, nil
+}
+
+func headers(r io.Reader, limit int64) (hdrs []*tar.Header, err error) {
+	var gz *gzip.Reader
+	if gz, err = gzip.NewReader(r); err != nil {
+		return nil, fmt.Errorf("could not read archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(&limitReader{r: gz, n: limit})
+	for {
+		var hdr *tar.Header
+		if hdr, err = tr.Next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return hdrs, nil
+			}
+			return nil, fmt.Errorf("could not read archive: %w", err)
+		}
+		hdrs = append(hdrs, hdr)
+	}
+}
+
+// Extract unpacks the archive into the directory, returning the names of the files that
+// were unpacked. The whole archive is checked before anything is written and existing
+// files are never replaced, nor are files written through existing symbolic links.
+func Extract(r io.Reader, dir string) (files []string, err error) {
+	// Archives are small enough to be read twice since they are stored as secrets
+	var data []byte
+	if data, err = io.ReadAll(r); err != nil {
+		return nil, err
+	}
+
+	if files, err = List(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	var gz *gzip.Reader
+	if gz, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("could not read archive: %w", err)
+	}
+	defer gz.Close()
+
+	// Links are created last so that no files are written through them
+	var links []*tar.Header
+	tr := tar.NewReader(gz)
+	for {
+		var hdr *tar.Header
+		if hdr, err = tr.Next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("could not read archive: %w", err)
+		}
+
+		name := clean(hdr.Name)
+		if err = checkParents(dir, name); err != nil {
+			return nil, err
+		}
+
+		target := filepath.Join(dir, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = mkdir(target); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return nil, err
+			}
+
+			if err = writeFile(target, tr, fs.FileMode(hdr.Mode)); err != nil {
+				return nil, err
+			}
+		case tar.TypeSymlink:
+			links = append(links, hdr)
+		}
+	}
+
+	for _, hdr := range links {
+		name := clean(hdr.Name)
+		if err = checkParents(dir, name); err != nil {
+			return nil, err
+		}
+
+		target := filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+
+		if err = os.Symlink(filepath.FromSlash(hdr.Linkname), target); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Create the directory unless it already exists as a directory.
+func mkdir(path string) error {
+	info, err := os.Lstat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return nil
+	case err == nil:
+		return fmt.Errorf("cannot unpack directory %s: file already exists", path)
+	case errors.Is(err, fs.ErrNotExist):
+		return os.MkdirAll(path, 0755)
+	default:
+		return err
+	}
+}
+
+// Write a file that does not exist yet; group and other users cannot write the file.
+func writeFile(path string, r io.Reader, mode fs.FileMode) (err error) {
+	var f *os.File
+	if f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm()&^0022|0600); err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Ensure that none of the existing parents of the entry in the directory are links.
+func checkParents(dir, name string) error {
+	parent := dir
+	for _, part := range strings.Split(filepath.Dir(name), string(filepath.Separator)) {
+		if part == "." {
+			continue
+		}
+
+		parent = filepath.Join(parent, part)
+		info, err := os.Lstat(parent)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return err
+		case info.Mode()&fs.ModeSymlink != 0:
+			return fmt.Errorf("%w: %s is a symbolic link", ErrUnsafePath, parent)
+		}
+	}
+	return nil
+}
+
+// limitReader returns ErrTooLarge once more than n bytes are read, unlike io.LimitReader
+// which returns io.EOF, so that a truncated archive is not mistaken for a complete one.
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (n int, err error) {
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+
+	n, err = l.r.Read(p)
+	if l.n -= int64(n); l.n < 0 {
+		return 0, ErrTooLarge
+	}
+	return n, err
+}
+
+// validate checks the entries of the archive before it is created or unpacked. Entries
+// must be relative paths inside of the archive and cannot be inside of a linked directory.
+// Links must be relative and cannot pass through other links, since a link to a parent
+// directory could otherwise be followed to escape the archive.
+func validate(hdrs []*tar.Header, limit int64) error {
+	links := make(map[string]bool)
+	for _, hdr := range hdrs {
+		if hdr.Typeflag == tar.TypeSymlink {
+			links[clean(hdr.Name)] = true
+		}
+	}
+
+	var size int64
+	for _, hdr := range hdrs {
+		name := clean(hdr.Name)
+		if !filepath.IsLocal(name) || throughLink(links, filepath.Dir(name)) {
+			return fmt.Errorf("%w: %s", ErrUnsafePath, hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+		case tar.TypeReg:
+			size += hdr.Size
+		case tar.TypeSymlink:
+			if !safeLink(links, name, hdr.Linkname) {
+				return fmt.Errorf("%w: %s -> %s", ErrUnsafeLink, hdr.Name, hdr.Linkname)
+			}
+		default:
+			return fmt.Errorf("%w: %s", ErrEntryType, hdr.Name)
+		}
+	}
+
+	if size > limit {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+func clean(name string) string {
+	return filepath.Clean(filepath.FromSlash(name))
+}
+
+// Returns true if the directory or any of its parents is a link.
+func throughLink(links map[string]bool, dir string) bool {
+	for dir != "." && dir != string(filepath.Separator) {
+		if links[dir] {
+			return true
+		}
+		dir = filepath.Dir(dir)
+	}
+	return false
+}
+
+// Returns true if the link resolves inside of the archive without following other links.
+func safeLink(links map[string]bool, name, link string) bool {
+	if link == "" || strings.HasPrefix(link, "/") || filepath.IsAbs(link) {
+		return false
+	}
+
+	parts := strings.Split(link, "/")
+	cur := filepath.Dir(name)
+	for i, part := range parts {
+		switch part {
+		case "", ".":
+		case "..":
+			if cur == "." {
+				return false
+			}
+			cur = filepath.Dir(cur)
+		default:
+			cur = filepath.Join(cur, part)
+			if i < len(parts)-1 && links[cur] {
+				return false
+			}
+		}
+	}
+	return true
+}
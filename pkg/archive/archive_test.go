@@ -0,0 +1,147 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rotationalio/whisper/pkg/archive"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	require.NoError(t, archive.Check(archive.TarGzip))
+	require.ErrorIs(t, archive.Check("zip"), archive.ErrUnsupported)
+}
+
+func TestRoundTrip(t *testing.T) {
+	// Create a directory with a nested file, a link, and a separate file
+	src := t.TempDir()
+	configs := filepath.Join(src, "configs")
+	require.NoError(t, os.MkdirAll(filepath.Join(configs, "vpn"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(configs, "vpn", "client.ovpn"), []byte("remote vpn.example.com 1194"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(configs, "deploy.sh"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.Symlink("vpn/client.ovpn", filepath.Join(configs, "current.ovpn")))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "id_ed25519"), []byte("ssh-ed25519 AAAA"), 0600))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, archive.Create(buf, configs, filepath.Join(src, "id_ed25519")))
+
+	files, err := archive.List(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"configs/current.ovpn", "configs/deploy.sh", "configs/vpn/client.ovpn", "id_ed25519"}, files)
+
+	dst := t.TempDir()
+	extracted, err := archive.Extract(bytes.NewReader(buf.Bytes()), dst)
+	require.NoError(t, err)
+	require.Equal(t, files, extracted)
+
+	data, err := os.ReadFile(filepath.Join(dst, "configs", "current.ovpn"))
+	require.NoError(t, err)
+	require.Equal(t, "remote vpn.example.com 1194", string(data))
+
+	info, err := os.Stat(filepath.Join(dst, "configs", "deploy.sh"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	// Existing files are not replaced
+	_, err = archive.Extract(bytes.NewReader(buf.Bytes()), dst)
+	require.ErrorIs(t, err, os.ErrExist)
+
+	// Links that point outside of the archive cannot be archived
+	require.NoError(t, os.Symlink("../../etc/passwd", filepath.Join(configs, "passwd")))
+	err = archive.Create(&bytes.Buffer{}, configs)
+	require.ErrorIs(t, err, archive.ErrUnsafeLink)
+}
+
+func TestUnsafeArchives(t *testing.T) {
+	testCases := []struct {
+		entries []*tar.Header
+		err     error
+	}{
+		{[]*tar.Header{file("../evil.sh")}, archive.ErrUnsafePath},
+		{[]*tar.Header{file("configs/../../evil.sh")}, archive.ErrUnsafePath},
+		{[]*tar.Header{file("/etc/cron.d/evil")}, archive.ErrUnsafePath},
+		{[]*tar.Header{link("passwd", "/etc/passwd")}, archive.ErrUnsafeLink},
+		{[]*tar.Header{link("configs/passwd", "../../etc/passwd")}, archive.ErrUnsafeLink},
+		{[]*tar.Header{link("root", "."), link("passwd", "root/../etc/passwd")}, archive.ErrUnsafeLink},
+		{[]*tar.Header{link("configs", "vpn"), file("configs/evil")}, archive.ErrUnsafePath},
+		{[]*tar.Header{file("configs/evil"), link("configs", "vpn")}, archive.ErrUnsafePath},
+		{[]*tar.Header{file("a"), {Name: "b", Typeflag: tar.TypeLink, Linkname: "a"}}, archive.ErrEntryType},
+		{[]*tar.Header{file("a"), {Name: "dev", Typeflag: tar.TypeChar}}, archive.ErrEntryType},
+	}
+
+	for i, tc := range testCases {
+		data := build(t, tc.entries...)
+		_, err := archive.List(bytes.NewReader(data))
+		require.ErrorIs(t, err, tc.err, "test case %d", i)
+
+		// Nothing is written if the archive is unsafe
+		dir := t.TempDir()
+		_, err = archive.Extract(bytes.NewReader(data), dir)
+		require.ErrorIs(t, err, tc.err, "test case %d", i)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, entries, "test case %d", i)
+	}
+
+	// Links inside of the archive are allowed
+	data := build(t, file("vpn/client.ovpn"), link("configs/current.ovpn", "../vpn/client.ovpn"), link("latest", "configs/current.ovpn"))
+	files, err := archive.List(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, []string{"vpn/client.ovpn", "configs/current.ovpn", "latest"}, files)
+
+	// Files are not written through links that already exist in the directory
+	dir := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "vpn")))
+	_, err = archive.Extract(bytes.NewReader(data), dir)
+	require.ErrorIs(t, err, archive.ErrUnsafePath)
+
+	entries, err := os.ReadDir(outside)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// Archives that are not gzip compressed tar files cannot be read
+	_, err = archive.List(bytes.NewReader([]byte("not an archive")))
+	require.Error(t, err)
+}
+
+func TestListLimit(t *testing.T) {
+	data := build(t, file("vpn/client.ovpn"), file("deploy.sh"))
+	files, err := archive.ListLimit(bytes.NewReader(data), 1<<20)
+	require.NoError(t, err)
+	require.Equal(t, []string{"vpn/client.ovpn", "deploy.sh"}, files)
+
+	// Archives are not expanded beyond the limit even if the headers are small
+	_, err = archive.ListLimit(bytes.NewReader(data), 1024)
+	require.ErrorIs(t, err, archive.ErrTooLarge)
+}
+
+func file(name string) *tar.Header {
+	return &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: 4}
+}
+
+func link(name, target string) *tar.Header {
+	return &tar.Header{Name: name, Typeflag: tar.TypeSymlink, Linkname: target, Mode: 0777}
+}
+
+func build(t *testing.T, entries ...*tar.Header) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, hdr := range entries {
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Typeflag == tar.TypeReg {
+			_, err := tw.Write([]byte("data"))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
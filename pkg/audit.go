@@ -0,0 +1,112 @@
+package whisper
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rotationalio/whisper/pkg/audit"
+	"github.com/rotationalio/whisper/pkg/logger"
+	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rotationalio/whisper/pkg/vault"
+)
+
+// record appends a security event to the audit log if auditing is enabled. The token is
+// recorded as its keyed hash so that the audit log can be correlated with the logs.
+// Failures to write the audit log are reported but do not interrupt the request. The
+// context is either the gin context of a REST request or a context created by
+// withCaller for logic that is shared by the REST and gRPC APIs.
+func (s *Server) record(ctx context.Context, event audit.Event, token, reason string) {
+	if s.auditor == nil {
+		return
+	}
+
+	rec := audit.Record{
+		Event:  event,
+		Reason: reason,
+	}
+
+	switch c := ctx.(type) {
+	case *gin.Context:
+		rec.RequestID = sentry.RequestIDFromContext(c)
+		rec.ClientIP = c.ClientIP()
+	default:
+		if caller, ok := ctx.Value(callerKey{}).(caller); ok {
+			rec.RequestID = caller.requestID
+			rec.ClientIP = caller.clientIP
+		}
+	}
+
+	if token != "" {
+		rec.Secret = logger.HashToken(token)
+	}
+
+	if err := s.auditor.Record(rec); err != nil {
+		sentry.Error(ctx).Err(err).Str("event", string(event)).Msg("could not write audit record")
+	}
+}
+
+// caller identifies the client of a request in the audit log when the gin context is
+// not available, e.g. in gRPC requests or in the logic shared with the REST API.
+type caller struct {
+	requestID string
+	clientIP  string
+}
+
+type callerKey struct{}
+
+// withCaller adds the client of the request to the context for the audit log.
+func withCaller(ctx context.Context, requestID, clientIP string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller{requestID: requestID, clientIP: clientIP})
+}
+
+// requestContext returns the context of a REST request with its caller so that the
+// logic shared with the gRPC API can audit the request.
+func requestContext(c *gin.Context) context.Context {
+	return withCaller(c.Request.Context(), sentry.RequestIDFromContext(c), c.ClientIP())
+}
+
+// recordFetch audits the outcome of fetching a secret or the response to a request.
+func (s *Server) recordFetch(ctx context.Context, token string, destroyed bool, err error) {
+	switch {
+	case err == nil:
+		s.record(ctx, audit.SecretFetched, token, "")
+		if destroyed {
+			s.record(ctx, audit.SecretDestroyed, token, "accesses exhausted")
+		}
+	case errors.Is(err, vault.ErrSecretNotFound) && destroyed:
+		s.record(ctx, audit.SecretExpired, token, "destroyed on fetch")
+	case errors.Is(err, vault.ErrNotAuthorized):
+		s.record(ctx, audit.AuthFailed, token, err.Error())
+	case errors.Is(err, vault.ErrPasswordAttempts):
+		s.record(ctx, audit.AuthFailed, token, err.Error())
+		s.record(ctx, audit.SecretDestroyed, token, "too many incorrect password attempts")
+	default:
+		s.record(ctx, audit.FetchFailed, token, fetchFailure(err))
+	}
+}
+
+// recordDestroy audits the outcome of a request to destroy a secret.
+func (s *Server) recordDestroy(ctx context.Context, token string, err error) {
+	switch {
+	case err == nil:
+		s.record(ctx, audit.SecretDestroyed, token, "destroyed by user")
+	case errors.Is(err, vault.ErrNotAuthorized):
+		s.record(ctx, audit.AuthFailed, token, err.Error())
+	case errors.Is(err, vault.ErrPasswordAttempts):
+		s.record(ctx, audit.AuthFailed, token, err.Error())
+		s.record(ctx, audit.SecretDestroyed, token, "too many incorrect password attempts")
+	}
+}
+
+// Internal errors may contain resource names, so only known errors are described.
+func fetchFailure(err error) string {
+	switch {
+	case errors.Is(err, vault.ErrSecretNotFound):
+		return "not found"
+	case errors.Is(err, vault.ErrRequestPending):
+		return "request pending"
+	default:
+		return "internal error"
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Package audit writes a tamper-evident log of security-relevant events, such as secrets
+being created, fetched, or destroyed and failed password attempts, to a dedicated file
+that is separate from the operational logs. Each record includes the hash of the
+previous record so that any record that is modified, removed, or reordered breaks the
+chain, which can be checked with Verify. Records must never contain secrets, passwords,
+or tokens; secrets are identified by the keyed hash of their token.
+*/
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config enables the audit log, which is appended to the file at Path.
+type Config struct {
+	Enabled bool   `default:"false" yaml:"enabled"`
+	Path    string `required:"false" yaml:"path"` // path of the append-only audit log file
+}
+
+func (c Config) Validate() error {
+	if c.Enabled && c.Path == "" {
+		return errors.New("invalid configuration: audit log requires a path")
+	}
+	return nil
+}
+
+// Event is the type of security-relevant event that is recorded.
+type Event string
+
+const (
+	SecretCreated   Event = "secret_created"
+	SecretFetched   Event = "secret_fetched"
+	FetchFailed     Event = "fetch_failed"
+	SecretDestroyed Event = "secret_destroyed"
+	SecretExpired   Event = "secret_expired"
+	AuthFailed      Event = "auth_failed"
+)
+
+// Genesis is the previous hash of the first record in the audit log.
+var Genesis = strings.Repeat("0", sha256.Size*2)
+
+// Record is a single entry in the audit log, written as one JSON object per line.
+type Record struct {
+	Sequence  uint64    `json:"seq"`
+	Timestamp time.Time `json:"timestamp"`
+	Event     Event     `json:"event"`
+	Secret    string    `json:"secret,omitempty"`     // keyed hash of the secret token
+	RequestID string    `json:"request_id,omitempty"` // correlates the record with the operational logs
+	ClientIP  string    `json:"client_ip,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+}
+
+// Digest computes the SHA-256 hash of the record, which covers every field of the
+// record (including the previous hash) other than the hash itself.
+func (r Record) Digest() (_ string, err error) {
+	r.Hash = ""
+	var data []byte
+	if data, err = json.Marshal(r); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Logger appends records to the audit log, maintaining the sequence and the hash chain.
+// A nil Logger discards all records so that callers do not have to check if auditing
+// is enabled.
+type Logger struct {
+	sync.Mutex
+	out  io.WriteCloser
+	seq  uint64
+	prev string
+}
+
+// New opens the audit log at the configured path, creating it if it does not exist. If
+// the file already contains records, the chain is continued from the last record.
+func New(conf Config) (_ *Logger, err error) {
+	if err = conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	log := &Logger{prev: Genesis}
+	if err = log.resume(conf.Path); err != nil {
+		return nil, err
+	}
+
+	var f *os.File
+	if f, err = os.OpenFile(conf.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+		return nil, fmt.Errorf("could not open audit log: %w", err)
+	}
+	log.out = f
+	return log, nil
+}
+
+// Record appends the event to the audit log, setting the sequence number, timestamp,
+// and hashes of the record. The record is synced to disk before Record returns.
+func (l *Logger) Record(rec Record) (err error) {
+	if l == nil {
+		return nil
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if l.out == nil {
+		return errors.New("audit log is closed")
+	}
+
+	rec.Sequence = l.seq + 1
+	rec.Timestamp = time.Now().UTC()
+	rec.PrevHash = l.prev
+	if rec.Hash, err = rec.Digest(); err != nil {
+		return fmt.Errorf("could not hash audit record: %w", err)
+	}
+
+	var data []byte
+	if data, err = json.Marshal(rec); err != nil {
+		return fmt.Errorf("could not marshal audit record: %w", err)
+	}
+
+	if _, err = l.out.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("could not write audit record: %w", err)
+	}
+
+	if f, ok := l.out.(*os.File); ok {
+		if err = f.Sync(); err != nil {
+			return fmt.Errorf("could not sync audit log: %w", err)
+		}
+	}
+
+	l.seq = rec.Sequence
+	l.prev = rec.Hash
+	return nil
+}
+
+// Close the audit log; subsequent records will return an error.
+func (l *Logger) Close() (err error) {
+	if l == nil {
+		return nil
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	if l.out == nil {
+		return nil
+	}
+
+	err = l.out.Close()
+	l.out = nil
+	return err
+}
+
+// Continues the chain from the last record of an existing audit log. The chain itself is
+// not verified on startup since the log may be large; use Verify to check the chain. If
+// the path is not a regular file (e.g. a named pipe) the chain starts from genesis.
+func (l *Logger) resume(path string) (err error) {
+	var info os.FileInfo
+	if info, err = os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("could not stat audit log: %w", err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return fmt.Errorf("could not open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var last []byte
+	scanner := newScanner(f)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("could not read audit log: %w", err)
+	}
+
+	if last == nil {
+		return nil
+	}
+
+	var rec Record
+	if err = json.Unmarshal(last, &rec); err != nil {
+		return fmt.Errorf("could not resume audit log, last record is invalid: %w", err)
+	}
+
+	l.seq = rec.Sequence
+	l.prev = rec.Hash
+	return nil
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return scanner
+}
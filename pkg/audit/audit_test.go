@@ -0,0 +1,122 @@
+package audit_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/rotationalio/whisper/pkg/audit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	require.NoError(t, Config{}.Validate())
+	require.NoError(t, Config{Enabled: true, Path: "audit.log"}.Validate())
+	require.Error(t, Config{Enabled: true}.Validate())
+}
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := New(Config{Enabled: true, Path: path})
+	require.NoError(t, err)
+
+	require.NoError(t, log.Record(Record{Event: SecretCreated, Secret: "tok_abc", RequestID: "1"}))
+	require.NoError(t, log.Record(Record{Event: AuthFailed, Secret: "tok_abc", Reason: "correct password required"}))
+	require.NoError(t, log.Close())
+	require.Error(t, log.Record(Record{Event: SecretFetched}), "cannot record after close")
+
+	// Reopening the audit log continues the chain
+	log, err = New(Config{Enabled: true, Path: path})
+	require.NoError(t, err)
+	require.NoError(t, log.Record(Record{Event: SecretFetched, Secret: "tok_abc"}))
+	require.NoError(t, log.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	n, err := Verify(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm(), "the audit log should only be readable by the server")
+
+	// A nil logger discards records
+	var nilLog *Logger
+	require.NoError(t, nilLog.Record(Record{Event: SecretCreated}))
+	require.NoError(t, nilLog.Close())
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := New(Config{Enabled: true, Path: path})
+	require.NoError(t, err)
+
+	for _, event := range []Event{SecretCreated, AuthFailed, SecretFetched, SecretDestroyed} {
+		require.NoError(t, log.Record(Record{Event: event, Secret: "tok_abc"}))
+	}
+	require.NoError(t, log.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 4)
+
+	testCases := []struct {
+		name     string
+		lines    []string
+		line     int
+		expected error
+	}{
+		{"modified", replace(lines, 1, strings.Replace(lines[1], "auth_failed", "secret_fetched", 1)), 2, ErrTampered},
+		{"removed", []string{lines[0], lines[2], lines[3]}, 2, ErrGap},
+		{"reordered", []string{lines[0], lines[2], lines[1], lines[3]}, 2, ErrGap},
+		{"truncated head", lines[1:], 1, ErrGap},
+		{"unknown field", replace(lines, 2, strings.Replace(lines[2], `{"seq"`, `{"note":"x","seq"`, 1)), 3, nil},
+		{"not json", replace(lines, 3, "hello world\n"), 4, nil},
+	}
+
+	for _, tc := range testCases {
+		_, err := Verify(strings.NewReader(strings.Join(tc.lines, "")))
+		require.Error(t, err, tc.name)
+
+		var verr *VerifyError
+		require.True(t, errors.As(err, &verr), tc.name)
+		require.Equal(t, tc.line, verr.Line, tc.name)
+		if tc.expected != nil {
+			require.ErrorIs(t, err, tc.expected, tc.name)
+		}
+	}
+
+	// A record with a recomputed hash still breaks the chain
+	rec := Record{Sequence: 2, Event: SecretFetched, PrevHash: Genesis}
+	rec.Hash, err = rec.Digest()
+	require.NoError(t, err)
+	forged, err := jsonLine(rec)
+	require.NoError(t, err)
+
+	_, err = Verify(strings.NewReader(strings.Join(replace(lines, 1, forged), "")))
+	require.ErrorIs(t, err, ErrBroken)
+
+	// An empty audit log is valid
+	n, err := Verify(strings.NewReader(""))
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func replace(lines []string, i int, line string) []string {
+	out := make([]string, len(lines))
+	copy(out, lines)
+	out[i] = line
+	return out
+}
+
+func jsonLine(rec Record) (string, error) {
+	data, err := json.Marshal(rec)
+	return string(data) + "\n", err
+}
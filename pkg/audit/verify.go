@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrTampered = errors.New("audit record has been modified")
+	ErrBroken   = errors.New("audit chain is broken")
+	ErrGap      = errors.New("audit log is missing records")
+)
+
+// VerifyError describes the first record at which verification of the chain failed.
+type VerifyError struct {
+	Line int   // the line number of the record in the audit log
+	Err  error // one of ErrTampered, ErrBroken, ErrGap, or a parse error
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// Verify reads the audit log and checks that every record hashes to its recorded hash,
+// that each record's previous hash is the hash of the record before it, and that the
+// sequence numbers have no gaps. The first record must begin the chain from genesis so
+// that removing records from the start of the log is also detected. Verify returns the
+// number of records that were verified before the first error.
+func Verify(r io.Reader) (n int, err error) {
+	var (
+		line int
+		prev *Record
+	)
+
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		// Unknown fields would not be covered by the hash so they are rejected
+		var rec Record
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err = decoder.Decode(&rec); err != nil {
+			return n, &VerifyError{Line: line, Err: fmt.Errorf("could not parse record: %w", err)}
+		}
+
+		var digest string
+		if digest, err = rec.Digest(); err != nil {
+			return n, &VerifyError{Line: line, Err: err}
+		}
+
+		if digest != rec.Hash {
+			return n, &VerifyError{Line: line, Err: ErrTampered}
+		}
+
+		if prev == nil {
+			if rec.Sequence != 1 {
+				return n, &VerifyError{Line: line, Err: fmt.Errorf("%w: expected sequence 1 but got %d", ErrGap, rec.Sequence)}
+			}
+
+			if rec.PrevHash != Genesis {
+				return n, &VerifyError{Line: line, Err: ErrBroken}
+			}
+		} else {
+			if rec.Sequence != prev.Sequence+1 {
+				return n, &VerifyError{Line: line, Err: fmt.Errorf("%w: expected sequence %d but got %d", ErrGap, prev.Sequence+1, rec.Sequence)}
+			}
+
+			if rec.PrevHash != prev.Hash {
+				return n, &VerifyError{Line: line, Err: ErrBroken}
+			}
+		}
+
+		prev = &rec
+		n++
+	}
+
+	if err = scanner.Err(); err != nil {
+		return n, &VerifyError{Line: line + 1, Err: err}
+	}
+	return n, nil
+}
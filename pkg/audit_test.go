@@ -0,0 +1,68 @@
+package whisper_test
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/rotationalio/whisper/pkg"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/audit"
+	"github.com/rotationalio/whisper/pkg/logger"
+)
+
+func (s *WhisperTestSuite) TestAudit() {
+	path := filepath.Join(s.T().TempDir(), "audit.log")
+	conf := s.conf
+	conf.Audit = audit.Config{Enabled: true, Path: path}
+	srv, err := New(conf)
+	s.NoError(err)
+	srv.SetStatus(true, true)
+
+	const password = "supersecretpassword"
+	prev := s.router
+	s.router = srv.Routes()
+	defer func() { s.router = prev }()
+
+	// Create a secret, fail to fetch it without a password, then fetch it
+	rep := s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight", Password: password}, http.StatusCreated)
+	s.sendFetchRequest(rep.Token, "", http.StatusUnauthorized)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/secrets/"+rep.Token, nil)
+	req.Header.Set("Authorization", "Bearer "+base64.URLEncoding.EncodeToString([]byte(password)))
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+
+	// The secret no longer exists
+	s.sendFetchRequest(rep.Token, "", http.StatusNotFound)
+
+	f, err := os.Open(path)
+	s.NoError(err)
+	defer f.Close()
+
+	events := make([]audit.Event, 0, 5)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		s.NotContains(scanner.Text(), rep.Token, "the audit log must not contain tokens")
+		s.NotContains(scanner.Text(), password, "the audit log must not contain passwords")
+
+		var rec audit.Record
+		s.NoError(json.Unmarshal(scanner.Bytes(), &rec))
+		s.Equal(logger.HashToken(rep.Token), rec.Secret)
+		s.NotEmpty(rec.RequestID)
+		events = append(events, rec.Event)
+	}
+	s.NoError(scanner.Err())
+	s.Equal([]audit.Event{audit.SecretCreated, audit.AuthFailed, audit.SecretFetched, audit.SecretDestroyed, audit.FetchFailed}, events)
+
+	_, err = f.Seek(0, 0)
+	s.NoError(err)
+	n, err := audit.Verify(f)
+	s.NoError(err)
+	s.Equal(5, n)
+}
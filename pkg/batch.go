@@ -0,0 +1,202 @@
+package whisper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/audit"
+	"github.com/rotationalio/whisper/pkg/logger"
+	"github.com/rotationalio/whisper/pkg/metrics"
+	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rotationalio/whisper/pkg/vault"
+)
+
+// The secrets created by a failed batch are destroyed even if the request was canceled.
+const rollbackTimeout = 30 * time.Second
+
+// CreateBatch handles an incoming CreateBatchRequest and creates all of the secrets in
+// the request or none of them, returning their tokens or the token of their bundle.
+func (s *Server) CreateBatch(c *gin.Context) {
+	var req v1.CreateBatchRequest
+	if err := c.ShouldBind(&req); err != nil {
+		sentry.Warn(c).Err(err).Msg("could not bind request")
+		c.JSON(http.StatusBadRequest, ErrorReply(c, BadRequest("invalid create batch request")))
+		return
+	}
+
+	rep, err := s.createBatch(requestContext(c), &req)
+	if err != nil {
+		code := errorStatus(err)
+		if code == http.StatusInternalServerError {
+			sentry.Error(c).Err(err).Msg("could not create batch")
+		}
+		c.JSON(code, ErrorReply(c, err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, rep)
+}
+
+// createBatch validates all of the secrets in the batch before any of them are created
+// so that invalid batches are rejected without touching the vault. If a secret cannot be
+// created, the secrets that were already created are destroyed. It is shared by the
+// REST and gRPC APIs and audits the secrets that were created; errors are returned in
+// the same manner as createSecret.
+func (s *Server) createBatch(ctx context.Context, req *v1.CreateBatchRequest) (_ *v1.CreateBatchReply, err error) {
+	switch {
+	case len(req.Secrets) == 0:
+		return nil, BadRequest("a batch requires at least one secret")
+	case len(req.Secrets) > v1.MaxBatchSize:
+		return nil, BadRequest(fmt.Sprintf("a batch cannot have more than %d secrets", v1.MaxBatchSize))
+	}
+
+	for i, item := range req.Secrets {
+		if item == nil || item.Secret == "" {
+			return nil, BadRequest(fmt.Sprintf("secret %d of the batch is empty", i+1))
+		}
+	}
+
+	if req.Bundle {
+		var rep *v1.CreateBatchReply
+		if rep, err = s.createBundle(ctx, req); err != nil {
+			return nil, err
+		}
+
+		s.recordBatch(ctx, rep)
+		return rep, nil
+	}
+
+	// Apply the options of the batch to the secrets that do not specify their own
+	secrets := make([]*v1.CreateSecretRequest, 0, len(req.Secrets))
+	files := make([][]string, 0, len(req.Secrets))
+	for i, item := range req.Secrets {
+		secret := *item
+		if secret.Password == "" {
+			secret.Password = req.Password
+		}
+		if secret.Accesses == 0 {
+			secret.Accesses = req.Accesses
+		}
+		if secret.Lifetime == 0 {
+			secret.Lifetime = req.Lifetime
+		}
+		if secret.Callback == "" {
+			secret.Callback = req.Callback
+		}
+		if secret.Email == "" {
+			secret.Email = req.Email
+		}
+
+		var listed []string
+		if listed, err = s.checkSecret(&secret); err != nil {
+			return nil, fmt.Errorf("secret %d of the batch: %w", i+1, err)
+		}
+		secrets = append(secrets, &secret)
+		files = append(files, listed)
+	}
+
+	// If a secret is only partially created before the vault fails, it cannot be fetched
+	// and is destroyed by the reaper, so only the completed secrets need to be rolled back.
+	rep := &v1.CreateBatchReply{Secrets: make([]*v1.CreateSecretReply, 0, len(secrets))}
+	for i, secret := range secrets {
+		var created *v1.CreateSecretReply
+		if created, err = s.storeSecret(ctx, secret, files[i], false); err != nil {
+			s.rollback(ctx, rep.Secrets)
+			return nil, fmt.Errorf("could not create secret %d of the batch: %w", i+1, err)
+		}
+		rep.Secrets = append(rep.Secrets, created)
+	}
+
+	s.recordBatch(ctx, rep)
+	return rep, nil
+}
+
+// createBundle stores the secrets of the batch as a single secret that reveals all of
+// them on fetch. The bundle is protected by the options of the batch, so the secrets in
+// the bundle can only specify their contents.
+func (s *Server) createBundle(ctx context.Context, req *v1.CreateBatchRequest) (_ *v1.CreateBatchReply, err error) {
+	conf := s.settings()
+	items := make([]*v1.BundleItem, 0, len(req.Secrets))
+	for i, item := range req.Secrets {
+		if item.Password != "" || item.Accesses != 0 || item.Lifetime != 0 || item.Callback != "" || item.Email != "" {
+			return nil, BadRequest(fmt.Sprintf("secret %d of the bundle cannot specify its own options", i+1))
+		}
+
+		if err = conf.Policy.CheckSize(item.Secret); err != nil {
+			return nil, BadRequest(Coded(v1.ErrPayloadTooLarge, fmt.Errorf("secret %d of the bundle: %w", i+1, err)))
+		}
+
+		if _, err = archiveFiles(item.Secret, item.Archive, item.IsBase64, conf.Policy.MaxSize); err != nil {
+			return nil, fmt.Errorf("secret %d of the bundle: %w", i+1, err)
+		}
+
+		bundled := &v1.BundleItem{
+			Secret:   item.Secret,
+			Filename: item.Filename,
+			IsBase64: item.IsBase64,
+			Archive:  item.Archive,
+		}
+
+		if bundled.ContentType, bundled.Size, bundled.SHA256, err = describe(item.Secret, item.Filename, item.IsBase64); err != nil {
+			return nil, fmt.Errorf("secret %d of the bundle: %w", i+1, err)
+		}
+		items = append(items, bundled)
+	}
+
+	var payload []byte
+	if payload, err = json.Marshal(items); err != nil {
+		return nil, fmt.Errorf("could not encode secret bundle: %w", err)
+	}
+
+	// The size of the encoded bundle is also limited by the policy
+	bundle := &v1.CreateSecretRequest{
+		Secret:   string(payload),
+		Password: req.Password,
+		Accesses: req.Accesses,
+		Lifetime: req.Lifetime,
+		Callback: req.Callback,
+		Email:    req.Email,
+	}
+
+	if _, err = s.checkSecret(bundle); err != nil {
+		return nil, err
+	}
+
+	rep := &v1.CreateBatchReply{}
+	if rep.Bundle, err = s.storeSecret(ctx, bundle, nil, true); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// rollback destroys the secrets created by a batch that could not be completed. Secrets
+// that cannot be destroyed are reported since they remain fetchable until they expire.
+func (s *Server) rollback(ctx context.Context, created []*v1.CreateSecretReply) {
+	rctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+
+	for _, secret := range created {
+		entry := &vault.Entry{Token: secret.Token, Secret: true, Metadata: true}
+		if err := s.vault.Purge(rctx, entry); err != nil {
+			sentry.Error(ctx).Err(err).Str("secret", logger.HashToken(secret.Token)).Msg("could not roll back secret created by batch")
+			continue
+		}
+		metrics.Secret(metrics.Destroyed)
+	}
+}
+
+// recordBatch audits the secrets created by a batch.
+func (s *Server) recordBatch(ctx context.Context, rep *v1.CreateBatchReply) {
+	for _, secret := range rep.Secrets {
+		s.record(ctx, audit.SecretCreated, secret.Token, "batch")
+	}
+
+	if rep.Bundle != nil {
+		s.record(ctx, audit.SecretCreated, rep.Bundle.Token, "bundle")
+	}
+}
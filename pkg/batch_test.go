@@ -0,0 +1,149 @@
+package whisper_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/rotationalio/whisper/pkg"
+	"github.com/rotationalio/whisper/pkg/admin"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+)
+
+func (s *WhisperTestSuite) TestCreateBatch() {
+	req := &api.CreateBatchRequest{
+		Secrets: []*api.CreateSecretRequest{
+			{Secret: "vpn.example.com:1194"},
+			{Secret: "c3NoLWVkMjU1MTkgQUFBQQ==", Filename: "id_ed25519", IsBase64: true},
+			{Secret: "correct horse battery staple", Password: "anothersecretsquirrel", Accesses: 2},
+		},
+		Password: "supersecretsquirrel",
+		Lifetime: api.Duration(time.Hour),
+	}
+
+	rep := &api.CreateBatchReply{}
+	s.sendJSON(http.MethodPost, "/v1/secrets/batch", "", req, http.StatusCreated, rep)
+	s.Nil(rep.Bundle)
+	s.Require().Len(rep.Secrets, 3)
+	for _, secret := range rep.Secrets {
+		s.NotEmpty(secret.Token)
+		s.WithinDuration(time.Now().Add(time.Hour), secret.Expires, time.Minute)
+	}
+
+	// Secrets use the options of the batch unless they specify their own
+	s.fetchBatchSecret(rep.Secrets[0].Token, "", http.StatusUnauthorized)
+	out := s.fetchBatchSecret(rep.Secrets[0].Token, "supersecretsquirrel", http.StatusOK)
+	s.Equal("vpn.example.com:1194", out.Secret)
+	s.True(out.Destroyed)
+
+	out = s.fetchBatchSecret(rep.Secrets[1].Token, "supersecretsquirrel", http.StatusOK)
+	s.Equal("id_ed25519", out.Filename)
+	s.True(out.IsBase64)
+
+	out = s.fetchBatchSecret(rep.Secrets[2].Token, "anothersecretsquirrel", http.StatusOK)
+	s.Equal("correct horse battery staple", out.Secret)
+	s.False(out.Destroyed)
+
+	// Invalid batches are rejected
+	testCases := []struct {
+		req  *api.CreateBatchRequest
+		code api.ErrorCode
+	}{
+		{&api.CreateBatchRequest{}, api.ErrInvalidRequest},
+		{&api.CreateBatchRequest{Secrets: []*api.CreateSecretRequest{{Secret: "a"}, {}}}, api.ErrInvalidRequest},
+		{&api.CreateBatchRequest{Secrets: make([]*api.CreateSecretRequest, api.MaxBatchSize+1)}, api.ErrInvalidRequest},
+		{&api.CreateBatchRequest{Secrets: []*api.CreateSecretRequest{{Secret: "a"}, {Secret: "b", Lifetime: api.Duration(time.Second)}}}, api.ErrTTLInvalid},
+		{&api.CreateBatchRequest{Secrets: []*api.CreateSecretRequest{{Secret: "a"}, {Secret: "b", Callback: "https://example.com/hook"}}}, api.ErrInvalidRequest},
+		{&api.CreateBatchRequest{Secrets: []*api.CreateSecretRequest{{Secret: "a", Password: "b"}}, Bundle: true}, api.ErrInvalidRequest},
+	}
+
+	for i := range testCases[2].req.Secrets {
+		testCases[2].req.Secrets[i] = &api.CreateSecretRequest{Secret: "a"}
+	}
+
+	for i, tc := range testCases {
+		rep := &api.Reply{}
+		s.sendJSON(http.MethodPost, "/v1/secrets/batch", "", tc.req, http.StatusBadRequest, rep)
+		s.Equal(tc.code, rep.Code, "test case %d", i)
+		s.NotEmpty(rep.Error, "test case %d", i)
+	}
+}
+
+func (s *WhisperTestSuite) TestCreateBundle() {
+	req := &api.CreateBatchRequest{
+		Secrets: []*api.CreateSecretRequest{
+			{Secret: "vpn.example.com:1194"},
+			{Secret: "c3NoLWVkMjU1MTkgQUFBQQ==", Filename: "id_ed25519", IsBase64: true},
+		},
+		Password: "supersecretsquirrel",
+		Bundle:   true,
+	}
+
+	rep := &api.CreateBatchReply{}
+	s.sendJSON(http.MethodPost, "/v1/secrets/batch", "", req, http.StatusCreated, rep)
+	s.Empty(rep.Secrets)
+	s.Require().NotNil(rep.Bundle)
+	s.NotEmpty(rep.Bundle.Token)
+
+	// The bundle is protected by the password of the batch
+	s.fetchBatchSecret(rep.Bundle.Token, "", http.StatusUnauthorized)
+
+	out := s.fetchBatchSecret(rep.Bundle.Token, "supersecretsquirrel", http.StatusOK)
+	s.Empty(out.Secret)
+	s.True(out.Destroyed)
+	s.Equal([]*api.BundleItem{
+		{Secret: "vpn.example.com:1194", ContentType: "text/plain; charset=utf-8", Size: 20, SHA256: api.Digest([]byte("vpn.example.com:1194"))},
+		{Secret: "c3NoLWVkMjU1MTkgQUFBQQ==", Filename: "id_ed25519", IsBase64: true, ContentType: "text/plain; charset=utf-8", Size: 16, SHA256: api.Digest([]byte("ssh-ed25519 AAAA"))},
+	}, out.Bundle)
+
+	s.fetchBatchSecret(rep.Bundle.Token, "supersecretsquirrel", http.StatusNotFound)
+}
+
+func (s *WhisperTestSuite) TestCreateBatchRollback() {
+	const token = "Wd8QaqkQ0pRL4hHvRAu5fiR79h3RU0W1XGrXH3qT"
+
+	// Use a separate server to count the secrets in the vault. The policy allows secrets
+	// that are larger than the vault can store so that the vault fails during the batch.
+	conf := s.conf
+	conf.Admin = admin.Config{Token: token}
+	conf.Policy.MaxSize = 1 << 20
+	srv, err := New(conf)
+	s.NoError(err)
+	srv.SetStatus(true, true)
+
+	prev := s.router
+	s.router = srv.Routes()
+	defer func() { s.router = prev }()
+
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+
+	client, err := api.NewAdmin(server.URL, token)
+	s.NoError(err)
+
+	req := &api.CreateBatchRequest{
+		Secrets: []*api.CreateSecretRequest{
+			{Secret: "vpn.example.com:1194"},
+			{Secret: "correct horse battery staple"},
+			{Secret: strings.Repeat("a", 1<<17)},
+		},
+	}
+
+	rep := &api.Reply{}
+	s.sendJSON(http.MethodPost, "/v1/secrets/batch", "", req, http.StatusInternalServerError, rep)
+	s.NotEmpty(rep.Error)
+
+	// The secrets that were created before the failure have been destroyed; only the
+	// secret whose payload could not be stored remains until it is reaped.
+	stats, err := client.AdminStats(context.Background())
+	s.NoError(err)
+	s.Equal(1, stats.Secrets)
+}
+
+func (s *WhisperTestSuite) fetchBatchSecret(token, password string, code int) *api.FetchSecretReply {
+	out := &api.FetchSecretReply{}
+	s.sendJSON(http.MethodGet, "/v1/secrets/"+token, password, nil, code, out)
+	return out
+}
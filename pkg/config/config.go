@@ -9,40 +9,163 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/kelseyhightower/envconfig"
+	"github.com/rotationalio/whisper/pkg/admin"
+	"github.com/rotationalio/whisper/pkg/audit"
+	"github.com/rotationalio/whisper/pkg/health"
+	"github.com/rotationalio/whisper/pkg/listener"
 	"github.com/rotationalio/whisper/pkg/logger"
+	"github.com/rotationalio/whisper/pkg/metrics"
+	"github.com/rotationalio/whisper/pkg/mtls"
+	"github.com/rotationalio/whisper/pkg/notify"
+	"github.com/rotationalio/whisper/pkg/policy"
 	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rotationalio/whisper/pkg/slack"
+	"github.com/rotationalio/whisper/pkg/tracing"
 	"github.com/rs/zerolog"
 )
 
 // Config uses envconfig to load required settings from the environment and validate
 // them in preparation for running the whisper service.
 type Config struct {
-	Maintenance  bool                `split_words:"true" default:"false"`
-	Mode         string              `split_words:"true" default:"debug"`
-	BindAddr     string              `split_words:"true" required:"false"`
-	LogLevel     logger.LevelDecoder `split_words:"true" default:"info"`
-	ConsoleLog   bool                `split_words:"true" default:"false"`
-	AllowOrigins []string            `split_words:"true" default:"https://whisper.rotational.dev"`
-	Google       GoogleConfig
-	Sentry       sentry.Config
-	processed    bool
+	Maintenance         bool                 `split_words:"true" default:"false" yaml:"maintenance"`
+	MaintenanceMessage  string               `split_words:"true" required:"false" yaml:"maintenance_message"`  // shown to users during maintenance
+	MaintenanceETA      time.Time            `split_words:"true" required:"false" yaml:"maintenance_eta"`      // RFC3339 timestamp when maintenance is expected to be over
+	MaintenanceReadOnly bool                 `split_words:"true" default:"false" yaml:"maintenance_read_only"` // allow fetches and destroys during maintenance
+	Mode                string               `split_words:"true" default:"debug" yaml:"mode"`
+	BindAddr            string               `split_words:"true" required:"false" yaml:"bind_addr"` // host:port, unix:///path/to.sock, or systemd
+	LogLevel            logger.LevelDecoder  `split_words:"true" default:"info" yaml:"log_level"`
+	ConsoleLog          bool                 `split_words:"true" default:"false" yaml:"console_log"` // deprecated: use $WHISPER_LOG_FORMAT=console
+	AllowOrigins        []string             `split_words:"true" default:"https://whisper.rotational.dev" yaml:"allow_origins"`
+	PasswordAttempts    int                  `split_words:"true" default:"0" yaml:"password_attempts"`   // destroy the secret after this many incorrect passwords; 0 for unlimited
+	DefaultLifetime     time.Duration        `split_words:"true" default:"168h" yaml:"default_lifetime"` // the lifetime of secrets that do not specify one
+	DefaultAccesses     int                  `split_words:"true" default:"1" yaml:"default_accesses"`    // the number of accesses of secrets that do not specify it
+	RedactionKey        string               `split_words:"true" required:"false" yaml:"redaction_key"`  // key used to hash tokens in logs; random if not set
+	HTTP                HTTPConfig           `yaml:"http"`
+	GRPC                GRPCConfig           `yaml:"grpc"`
+	TLS                 mtls.Config          `yaml:"tls"`
+	Log                 logger.Config        `yaml:"log"`
+	Google              GoogleConfig         `yaml:"google"`
+	Sentry              sentry.Config        `yaml:"sentry"`
+	Webhooks            notify.WebhookConfig `yaml:"webhooks"`
+	Email               notify.EmailConfig   `yaml:"email"`
+	Slack               slack.Config         `yaml:"slack"`
+	Metrics             metrics.Config       `yaml:"metrics"`
+	Tracing             tracing.Config       `yaml:"tracing"`
+	Audit               audit.Config         `yaml:"audit"`
+	Reaper              ReaperConfig         `yaml:"reaper"`
+	Health              health.Config        `yaml:"health"`
+	Admin               admin.Config         `yaml:"admin"`
+	Policy              policy.Config        `yaml:"policy"`
+	processed           bool
+	path                string
 }
 
 type GoogleConfig struct {
-	Credentials string `envconfig:"GOOGLE_APPLICATION_CREDENTIALS" required:"false"`
-	Project     string `envconfig:"GOOGLE_PROJECT_NAME" required:"true"`
-	Testing     bool   `split_words:"true" default:"false"`
+	Credentials string `envconfig:"GOOGLE_APPLICATION_CREDENTIALS" required:"false" yaml:"application_credentials"`
+	Project     string `envconfig:"GOOGLE_PROJECT_NAME" required:"false" yaml:"project_name"` // required by Validate since it may be set by the config file
+	Testing     bool   `split_words:"true" default:"false" yaml:"testing"`
 }
 
-// New creates a new Config object, loading environment variables and defaults.
+// HTTPConfig specifies the timeouts of the http server. Timeouts must be long enough
+// to upload large secrets and to check passwords; read, write, and idle timeouts of
+// zero mean that there is no timeout. The drain window is how long the server reports
+// that it is not ready before it stops accepting connections during shutdown, so that
+// load balancers stop sending it requests first.
+type HTTPConfig struct {
+	ReadTimeout       time.Duration `split_words:"true" default:"20s" yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `split_words:"true" default:"10s" yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `split_words:"true" default:"20s" yaml:"write_timeout"`
+	IdleTimeout       time.Duration `split_words:"true" default:"120s" yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `split_words:"true" default:"35s" yaml:"shutdown_timeout"` // how long to wait for requests to finish during shutdown
+	DrainWindow       time.Duration `split_words:"true" default:"0s" yaml:"drain_window"`
+	H2C               bool          `default:"false" yaml:"h2c"` // serve HTTP/2 without TLS, e.g. behind a proxy that terminates TLS
+}
+
+func (c HTTPConfig) Validate() error {
+	if c.ReadTimeout < 0 || c.ReadHeaderTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0 {
+		return errors.New("invalid configuration: http timeouts cannot be negative")
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		return errors.New("invalid configuration: http shutdown timeout must be positive")
+	}
+
+	if c.DrainWindow < 0 {
+		return errors.New("invalid configuration: http drain window cannot be negative")
+	}
+	return nil
+}
+
+// GRPCConfig serves the gRPC API on a separate address if a bind address is specified.
+// The gRPC API uses the TLS configuration of the REST API if TLS is enabled.
+type GRPCConfig struct {
+	BindAddr string `split_words:"true" required:"false" yaml:"bind_addr"` // host:port or unix:///path/to.sock
+}
+
+// Enabled returns true if the gRPC API should be served.
+func (c GRPCConfig) Enabled() bool {
+	return c.BindAddr != ""
+}
+
+func (c GRPCConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	// The socket passed by systemd is used by the REST API
+	if c.BindAddr == listener.SystemdAddr {
+		return errors.New("invalid configuration: the grpc api cannot be socket activated")
+	}
+
+	if err := listener.Validate(c.BindAddr); err != nil {
+		return fmt.Errorf("invalid grpc bind address: %w", err)
+	}
+	return nil
+}
+
+// ReaperConfig schedules the background cleanup of expired, exhausted, and orphaned
+// secrets that were not destroyed when they were fetched.
+type ReaperConfig struct {
+	Enabled     bool          `default:"true" yaml:"enabled"`
+	Interval    time.Duration `default:"1h" yaml:"interval"`
+	GracePeriod time.Duration `split_words:"true" default:"10m" yaml:"grace_period"` // incomplete secrets younger than this may still be being created
+}
+
+func (c ReaperConfig) Validate() error {
+	if c.Enabled && c.Interval <= 0 {
+		return errors.New("invalid configuration: reaper interval must be positive")
+	}
+
+	if c.GracePeriod < 0 {
+		return errors.New("invalid configuration: reaper grace period cannot be negative")
+	}
+	return nil
+}
+
+// New creates a new Config object, loading environment variables and defaults. If
+// $WHISPER_CONFIG_FILE is set, settings that are not in the environment are loaded
+// from the config file.
 func New() (_ Config, err error) {
-	var conf Config
-	if err = envconfig.Process("whisper", &conf); err != nil {
+	return Load(os.Getenv(FileEnv))
+}
+
+// Process the environment and the settings of the config file into the configuration,
+// recording the config file path so that the configuration can be reloaded from the
+// same file.
+func process(path string, file map[string]interface{}) (conf Config, err error) {
+	if err = envconfig.Process(Prefix, &conf); err != nil {
+		return Config{}, err
+	}
+
+	if err = decodeFile(&conf, file); err != nil {
 		return Config{}, err
 	}
+	conf.path = path
 
 	// If the BindAddr is not set, try setting it from $PORT (Google Cloud Run)
 	if conf.BindAddr == "" {
@@ -51,6 +174,16 @@ func New() (_ Config, err error) {
 		}
 	}
 
+	// Otherwise listen on the socket passed by systemd if the server was socket activated
+	if conf.BindAddr == "" && listener.Activated() > 0 {
+		conf.BindAddr = listener.SystemdAddr
+	}
+
+	// Console logging was configured before log formats were introduced
+	if conf.ConsoleLog {
+		conf.Log.Format = logger.Console
+	}
+
 	// If mode is testing, then google.testing is true, even if it is explicitly set as false.
 	if conf.Mode == gin.TestMode {
 		conf.Google.Testing = true
@@ -73,13 +206,122 @@ func (c Config) IsZero() bool {
 	return !c.processed
 }
 
+// Path returns the config file the configuration was loaded from, if any.
+func (c Config) Path() string {
+	return c.path
+}
+
 func (c Config) Validate() error {
+	if c.Google.Project == "" {
+		return errors.New("must specify $GOOGLE_PROJECT_NAME")
+	}
+
 	if c.BindAddr == "" {
 		return errors.New("must specify either $WHISPER_BIND_ADDR or $PORT")
 	}
 
+	if err := listener.Validate(c.BindAddr); err != nil {
+		return fmt.Errorf("invalid bind address: %w", err)
+	}
+
 	if c.Mode != gin.ReleaseMode && c.Mode != gin.DebugMode && c.Mode != gin.TestMode {
 		return fmt.Errorf("%q is not a valid gin mode", c.Mode)
 	}
+
+	if c.PasswordAttempts < 0 {
+		return errors.New("password attempts must be zero (unlimited) or positive")
+	}
+
+	if c.DefaultLifetime <= 0 {
+		return errors.New("default lifetime must be positive")
+	}
+
+	if c.DefaultAccesses <= 0 {
+		return errors.New("default accesses must be positive")
+	}
+
+	// Validate the origins before they are used so that a reload cannot panic
+	if err := (cors.Config{AllowOrigins: c.AllowOrigins}).Validate(); err != nil {
+		return fmt.Errorf("invalid allowed origins: %w", err)
+	}
+
+	if err := c.HTTP.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.GRPC.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.TLS.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Log.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Webhooks.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Email.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Slack.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Metrics.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Tracing.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Audit.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Reaper.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Health.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Admin.Validate(); err != nil {
+		return err
+	}
+
+	// The admin API identifies secrets by the keyed hash of their token, so the key must
+	// be the same across restarts and replicas for the IDs and page tokens to be stable.
+	if c.Admin.Enabled() && c.RedactionKey == "" {
+		return errors.New("invalid configuration: a redaction key is required when the admin api is enabled")
+	}
+
+	if err := c.Policy.Validate(); err != nil {
+		return err
+	}
+
+	// The defaults must be allowed by the policy
+	if err := c.Policy.CheckLifetime(c.DefaultLifetime); err != nil {
+		return fmt.Errorf("invalid default lifetime: %w", err)
+	}
+
+	if err := c.Policy.CheckAccesses(c.DefaultAccesses); err != nil {
+		return fmt.Errorf("invalid default accesses: %w", err)
+	}
+
+	if c.Metrics.BindAddr != "" && c.Metrics.BindAddr == c.BindAddr {
+		return errors.New("metrics must be served on a different address than the api")
+	}
+
+	if c.GRPC.Enabled() && (c.GRPC.BindAddr == c.BindAddr || c.GRPC.BindAddr == c.Metrics.BindAddr) {
+		return errors.New("the grpc api must be served on a different address than the api and metrics")
+	}
 	return nil
 }
@@ -2,26 +2,88 @@ package config_test
 
 import (
 	"os"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rotationalio/whisper/pkg/config"
+	"github.com/rotationalio/whisper/pkg/listener"
+	"github.com/rotationalio/whisper/pkg/logger"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 )
 
 var testEnv = map[string]string{
-	"WHISPER_MAINTENANCE":            "false",
-	"WHISPER_MODE":                   "release",
-	"WHISPER_BIND_ADDR":              ":443",
-	"WHISPER_LOG_LEVEL":              "debug",
-	"WHISPER_CONSOLE_LOG":            "true",
-	"WHISPER_ALLOW_ORIGINS":          "https://whisper.rotational.dev,https://whisper.rotational.io",
-	"GOOGLE_APPLICATION_CREDENTIALS": "fixtures/whisper-sa.json",
-	"GOOGLE_PROJECT_NAME":            "test-project",
-	"WHISPER_GOOGLE_TESTING":         "true",
+	"WHISPER_MAINTENANCE":             "false",
+	"WHISPER_MAINTENANCE_MESSAGE":     "upgrading storage",
+	"WHISPER_MAINTENANCE_ETA":         "2022-08-01T12:00:00Z",
+	"WHISPER_MAINTENANCE_READ_ONLY":   "true",
+	"WHISPER_MODE":                    "release",
+	"WHISPER_BIND_ADDR":               ":443",
+	"WHISPER_LOG_LEVEL":               "debug",
+	"WHISPER_CONSOLE_LOG":             "true",
+	"WHISPER_ALLOW_ORIGINS":           "https://whisper.rotational.dev,https://whisper.rotational.io",
+	"GOOGLE_APPLICATION_CREDENTIALS":  "fixtures/whisper-sa.json",
+	"GOOGLE_PROJECT_NAME":             "test-project",
+	"WHISPER_GOOGLE_TESTING":          "true",
+	"WHISPER_METRICS_ENABLED":         "true",
+	"WHISPER_METRICS_BIND_ADDR":       ":9090",
+	"WHISPER_GRPC_BIND_ADDR":          ":9443",
+	"WHISPER_LOG_FORMAT":              "ecs",
+	"WHISPER_LOG_FILE":                "/var/log/whisper.log",
+	"WHISPER_LOG_MAX_SIZE":            "10",
+	"WHISPER_ADMIN_TOKEN":             "Wd8QaqkQ0pRL4hHvRAu5fiR79h3RU0W1XGrXH3qT",
+	"WHISPER_REDACTION_KEY":           "Pz2GfJ9aHq0vWJb5tTk8Nc3Xy7Lm4RsD",
+	"WHISPER_HTTP_WRITE_TIMEOUT":      "2m",
+	"WHISPER_HTTP_H2C":                "true",
+	"WHISPER_TLS_MIN_VERSION":         "1.3",
+	"WHISPER_POLICY_MAX_LIFETIME":     "336h",
+	"WHISPER_POLICY_REQUIRE_PASSWORD": "true",
+	"WHISPER_TRACING_SAMPLE_RATE":     "0.5",
 }
 
+// The settings of testEnv in a config file.
+const testFile = `
+maintenance: false
+maintenance_message: upgrading storage
+maintenance_eta: 2022-08-01T12:00:00Z
+maintenance_read_only: true
+mode: release
+bind_addr: ":443"
+log_level: debug
+console_log: true
+allow_origins:
+  - https://whisper.rotational.dev
+  - https://whisper.rotational.io
+google:
+  application_credentials: fixtures/whisper-sa.json
+  project_name: test-project
+  testing: true
+metrics:
+  enabled: true
+  bind_addr: ":9090"
+grpc:
+  bind_addr: ":9443"
+log:
+  format: ecs
+  file: /var/log/whisper.log
+  max_size: 10
+admin:
+  token: Wd8QaqkQ0pRL4hHvRAu5fiR79h3RU0W1XGrXH3qT
+redaction_key: Pz2GfJ9aHq0vWJb5tTk8Nc3Xy7Lm4RsD
+http:
+  write_timeout: 2m
+  h2c: true
+tls:
+  min_version: "1.3"
+policy:
+  max_lifetime: 336h
+  require_password: true
+tracing:
+  sample_rate: 0.5
+`
+
 func TestConfig(t *testing.T) {
 	// Set required environment variables and cleanup after
 	prevEnv := curEnv()
@@ -41,6 +103,9 @@ func TestConfig(t *testing.T) {
 
 	// Test configuration set from the environment
 	require.Equal(t, false, conf.Maintenance)
+	require.Equal(t, testEnv["WHISPER_MAINTENANCE_MESSAGE"], conf.MaintenanceMessage)
+	require.Equal(t, time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC), conf.MaintenanceETA)
+	require.True(t, conf.MaintenanceReadOnly)
 	require.Equal(t, gin.ReleaseMode, conf.Mode)
 	require.Equal(t, testEnv["WHISPER_BIND_ADDR"], conf.BindAddr)
 	require.Equal(t, zerolog.DebugLevel, conf.GetLogLevel())
@@ -49,6 +114,74 @@ func TestConfig(t *testing.T) {
 	require.Equal(t, testEnv["GOOGLE_PROJECT_NAME"], conf.Google.Project)
 	require.True(t, conf.Google.Testing)
 	require.Equal(t, true, conf.ConsoleLog)
+	require.True(t, conf.Metrics.Enabled)
+	require.Equal(t, testEnv["WHISPER_METRICS_BIND_ADDR"], conf.Metrics.BindAddr)
+	require.Equal(t, testEnv["WHISPER_GRPC_BIND_ADDR"], conf.GRPC.BindAddr)
+	require.True(t, conf.GRPC.Enabled())
+	require.Equal(t, "/metrics", conf.Metrics.Path)
+	require.Equal(t, logger.Console, conf.Log.Format, "console log should override the log format")
+	require.Equal(t, testEnv["WHISPER_LOG_FILE"], conf.Log.File)
+	require.Equal(t, 10, conf.Log.MaxSize)
+	require.Equal(t, 3, conf.Log.MaxBackups)
+	require.True(t, conf.Reaper.Enabled)
+	require.Equal(t, time.Hour, conf.Reaper.Interval)
+	require.Equal(t, 10*time.Minute, conf.Reaper.GracePeriod)
+	require.True(t, conf.Health.Enabled)
+	require.Equal(t, 30*time.Second, conf.Health.Interval)
+	require.Equal(t, 10*time.Second, conf.Health.Timeout)
+	require.Equal(t, 2, conf.Health.Threshold)
+	require.Equal(t, testEnv["WHISPER_ADMIN_TOKEN"], conf.Admin.Token)
+	require.True(t, conf.Admin.Enabled())
+	require.Equal(t, 20*time.Second, conf.HTTP.ReadTimeout)
+	require.Equal(t, 2*time.Minute, conf.HTTP.WriteTimeout)
+	require.Equal(t, 120*time.Second, conf.HTTP.IdleTimeout)
+	require.Equal(t, 35*time.Second, conf.HTTP.ShutdownTimeout)
+	require.Equal(t, time.Duration(0), conf.HTTP.DrainWindow)
+	require.True(t, conf.HTTP.H2C)
+	require.False(t, conf.TLS.Enabled())
+	require.Equal(t, "1.3", conf.TLS.MinVersion)
+	require.Equal(t, time.Minute, conf.TLS.ReloadInterval)
+	require.Equal(t, time.Minute, conf.Policy.MinLifetime)
+	require.Equal(t, 336*time.Hour, conf.Policy.MaxLifetime)
+	require.Equal(t, 0, conf.Policy.MaxAccesses)
+	require.True(t, conf.Policy.AllowUnlimited)
+	require.Equal(t, 65536, conf.Policy.MaxSize)
+	require.True(t, conf.Policy.RequirePassword)
+	require.Equal(t, 0, conf.Policy.MinPasswordStrength)
+
+	// The admin api requires a redaction key so that secret ids are stable
+	os.Unsetenv("WHISPER_REDACTION_KEY")
+	_, err = config.New()
+	require.EqualError(t, err, "invalid configuration: a redaction key is required when the admin api is enabled")
+}
+
+func TestConfigFile(t *testing.T) {
+	// Set required environment variables and cleanup after
+	prevEnv := curEnv()
+	t.Cleanup(func() {
+		for key, val := range prevEnv {
+			if val != "" {
+				os.Setenv(key, val)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+	})
+	setEnv()
+
+	expected, err := config.New()
+	require.NoError(t, err)
+
+	// Every type of setting is parsed from the config file in the same way as the environment
+	for key := range testEnv {
+		os.Unsetenv(key)
+	}
+
+	conf, err := config.Load(writeConfig(t, testFile))
+	require.NoError(t, err)
+	require.Empty(t, config.Diff(expected, conf))
+	require.Equal(t, 0.5, conf.Tracing.SampleRate)
+	require.Equal(t, zerolog.DebugLevel, conf.GetLogLevel())
 }
 
 func TestRequiredConfig(t *testing.T) {
@@ -64,7 +197,7 @@ func TestRequiredConfig(t *testing.T) {
 		}
 	})
 
-	// Required EnvVars from struct tags
+	// Required EnvVars from Validate
 	conf, err := config.New()
 	require.Error(t, err)
 	require.True(t, conf.IsZero())
@@ -150,3 +283,48 @@ func setEnv(keys ...string) {
 		}
 	}
 }
+
+func TestBindAddr(t *testing.T) {
+	t.Setenv("GOOGLE_PROJECT_NAME", testEnv["GOOGLE_PROJECT_NAME"])
+	t.Setenv("WHISPER_BIND_ADDR", "unix:///run/whisper/whisper.sock")
+	t.Setenv("PORT", "")
+
+	conf, err := config.New()
+	require.NoError(t, err)
+	require.Equal(t, "unix:///run/whisper/whisper.sock", conf.BindAddr)
+
+	t.Setenv("WHISPER_BIND_ADDR", "unix://")
+	_, err = config.New()
+	require.Error(t, err)
+
+	// Socket activated servers do not need a bind address
+	t.Setenv("WHISPER_BIND_ADDR", "")
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+
+	conf, err = config.New()
+	require.NoError(t, err)
+	require.Equal(t, listener.SystemdAddr, conf.BindAddr)
+}
+
+func TestGRPCBindAddr(t *testing.T) {
+	t.Setenv("GOOGLE_PROJECT_NAME", testEnv["GOOGLE_PROJECT_NAME"])
+	t.Setenv("WHISPER_BIND_ADDR", ":8318")
+	t.Setenv("PORT", "")
+
+	conf, err := config.New()
+	require.NoError(t, err)
+	require.False(t, conf.GRPC.Enabled())
+
+	t.Setenv("WHISPER_GRPC_BIND_ADDR", "unix:///run/whisper/grpc.sock")
+	conf, err = config.New()
+	require.NoError(t, err)
+	require.True(t, conf.GRPC.Enabled())
+
+	// The gRPC API cannot share an address with the REST API or be socket activated
+	for _, addr := range []string{":8318", listener.SystemdAddr, "unix://"} {
+		t.Setenv("WHISPER_GRPC_BIND_ADDR", addr)
+		_, err = config.New()
+		require.Error(t, err, "expected %q to be invalid", addr)
+	}
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// Prefix of the environment variables that configure whisper.
+const Prefix = "whisper"
+
+// FileEnv is the environment variable that specifies the path of the config file.
+const FileEnv = "WHISPER_CONFIG_FILE"
+
+// Load the configuration from the config file at the specified path, if any, and from
+// the environment. Environment variables take precedence over the config file and the
+// config file takes precedence over the defaults.
+//
+// The sections and keys of the config file are the yaml tags of the Config, which match
+// the environment variables without the whisper prefix; for example `log_level: debug`
+// sets $WHISPER_LOG_LEVEL and `log: {format: json}` sets $WHISPER_LOG_FORMAT. The
+// Google credentials and project name are set by the `google` section.
+func Load(path string) (_ Config, err error) {
+	var file map[string]interface{}
+	if path != "" {
+		if file, err = readFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+	return process(path, file)
+}
+
+// Reads the YAML config file, removing the settings that are set in the environment so
+// that the environment takes precedence when the file is decoded.
+func readFile(path string) (file map[string]interface{}, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return nil, fmt.Errorf("could not read config file: %w", err)
+	}
+
+	if err = yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("could not parse config file: %w", err)
+	}
+
+	var keys map[string][]string
+	if keys, err = settings(); err != nil {
+		return nil, err
+	}
+
+	prune("", file, keys)
+	return file, nil
+}
+
+// Decodes the settings of the config file into the configuration. Unknown settings are
+// rejected so that typos are not silently ignored.
+func decodeFile(conf *Config, file map[string]interface{}) (err error) {
+	if len(file) == 0 {
+		return nil
+	}
+
+	var data []byte
+	if data, err = yaml.Marshal(file); err != nil {
+		return fmt.Errorf("could not parse config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(conf); err != nil {
+		return fmt.Errorf("could not parse config file: %w", err)
+	}
+	return nil
+}
+
+// Returns the environment variables of the settings that can be set by the config file
+// by their key in the file. Settings with an alternate name (e.g. $GOOGLE_PROJECT_NAME)
+// are set by either environment variable. Sections (e.g. the Google config struct) are
+// not settings and do not have a type description.
+func settings() (keys map[string][]string, err error) {
+	buf := &bytes.Buffer{}
+	if err = envconfig.Usagef(Prefix, &Config{}, buf, "{{range .}}{{if usage_type .}}{{usage_key .}} {{.Alt}}\n{{end}}{{end}}"); err != nil {
+		return nil, err
+	}
+
+	keys = make(map[string][]string)
+	prefix := strings.ToUpper(Prefix) + "_"
+	for _, line := range strings.Split(buf.String(), "\n") {
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			keys[strings.TrimPrefix(fields[0], prefix)] = fields
+		case 2:
+			keys[strings.TrimPrefix(fields[0], prefix)] = fields
+			keys[fields[1]] = fields
+		}
+	}
+	return keys, nil
+}
+
+// Removes the settings from the config file that are set in the environment.
+func prune(section string, values map[string]interface{}, keys map[string][]string) {
+	for name, value := range values {
+		key := strings.ToUpper(section + name)
+		if val, ok := value.(map[string]interface{}); ok {
+			prune(key+"_", val, keys)
+			continue
+		}
+
+		for _, envvar := range keys[key] {
+			if _, ok := os.LookupEnv(envvar); ok {
+				delete(values, name)
+			}
+		}
+	}
+}
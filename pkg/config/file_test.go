@@ -0,0 +1,114 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+const configFile = `
+bind_addr: ":8318"
+log_level: warn
+allow_origins:
+  - https://whisper.rotational.dev
+  - https://whisper.rotational.io
+default_lifetime: 24h
+maintenance_eta: 2022-08-01T12:00:00Z
+log:
+  format: json
+  max_size: 5
+google:
+  project_name: file-project
+  testing: true
+reaper:
+  grace_period: 1m
+redaction_key: Pz2GfJ9aHq0vWJb5tTk8Nc3Xy7Lm4RsD
+`
+
+func TestLoad(t *testing.T) {
+	for key := range testEnv {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	// Environment variables take precedence over the config file
+	t.Setenv("WHISPER_LOG_LEVEL", "error")
+	path := writeConfig(t, configFile)
+	conf, err := config.Load(path)
+	require.NoError(t, err)
+	require.Equal(t, path, conf.Path())
+	require.Equal(t, ":8318", conf.BindAddr)
+	require.Equal(t, "error", conf.LogLevel.String())
+	require.Len(t, conf.AllowOrigins, 2)
+	require.Equal(t, 24*time.Hour, conf.DefaultLifetime)
+	require.Equal(t, 1, conf.DefaultAccesses)
+	require.Equal(t, "file-project", conf.Google.Project)
+	require.Equal(t, 5, conf.Log.MaxSize)
+	require.Equal(t, time.Minute, conf.Reaper.GracePeriod)
+
+	// The config file does not modify the environment
+	_, ok := os.LookupEnv("WHISPER_BIND_ADDR")
+	require.False(t, ok)
+
+	// Settings in the file that are set in the environment are not parsed
+	t.Setenv("WHISPER_DEFAULT_ACCESSES", "2")
+	conf, err = config.Load(writeConfig(t, configFile+"default_accesses: many\n"))
+	require.NoError(t, err)
+	require.Equal(t, 2, conf.DefaultAccesses)
+	os.Unsetenv("WHISPER_DEFAULT_ACCESSES")
+
+	// Settings that cannot be parsed are rejected
+	_, err = config.Load(writeConfig(t, configFile+"default_accesses: many\n"))
+	require.Error(t, err)
+
+	// Unknown settings are rejected so that typos are not silently ignored
+	_, err = config.Load(writeConfig(t, configFile+"metrics:\n  enabld: true\n"))
+	require.ErrorContains(t, err, "field enabld not found")
+
+	// Sections are not settings
+	_, err = config.Load(writeConfig(t, configFile+"policy: foo\n"))
+	require.Error(t, err)
+
+	_, err = config.Load(writeConfig(t, "{{not yaml"))
+	require.Error(t, err)
+
+	_, err = config.Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	// The config file is validated
+	_, err = config.Load(writeConfig(t, configFile+"default_accesses: 0\n"))
+	require.Error(t, err)
+
+	// The config file can be specified by the environment
+	t.Setenv(config.FileEnv, path)
+	conf, err = config.New()
+	require.NoError(t, err)
+	require.Equal(t, path, conf.Path())
+}
+
+func TestReload(t *testing.T) {
+	conf, err := config.Load(writeConfig(t, configFile))
+	require.NoError(t, err)
+
+	next, err := config.Load(writeConfig(t, configFile+"maintenance: true\ndefault_accesses: 3\nadmin:\n  token: Wd8QaqkQ0pRL4hHvRAu5fiR79h3RU0W1XGrXH3qT\n"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"Maintenance", "DefaultAccesses", "Admin.Token"}, config.Diff(conf, next))
+
+	reloaded, applied, rejected := conf.Reload(next)
+	require.Equal(t, []string{"Maintenance", "DefaultAccesses"}, applied)
+	require.Equal(t, []string{"Admin.Token"}, rejected)
+	require.True(t, reloaded.Maintenance)
+	require.Equal(t, 3, reloaded.DefaultAccesses)
+	require.False(t, reloaded.Admin.Enabled(), "settings that require a restart must not be reloaded")
+	require.Empty(t, config.Diff(reloaded, reloaded))
+}
+
+func writeConfig(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "whisper.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0600))
+	return path
+}
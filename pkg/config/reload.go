@@ -0,0 +1,85 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Settings that can be changed while the server is running, identified by their field
+// path in the Config. All other settings require a restart to change. The server does
+// not have rate limits, so there are no rate limit settings to reload.
+var reloadable = map[string]struct{}{
+	"LogLevel":            {},
+	"AllowOrigins":        {},
+	"Maintenance":         {},
+	"MaintenanceMessage":  {},
+	"MaintenanceETA":      {},
+	"MaintenanceReadOnly": {},
+	"DefaultLifetime":     {},
+	"DefaultAccesses":     {},
+}
+
+// Reloadable returns true if the setting at the field path can be changed by a reload.
+// All of the settings of the policy can be reloaded.
+func Reloadable(setting string) bool {
+	if strings.HasPrefix(setting, "Policy.") {
+		return true
+	}
+
+	_, ok := reloadable[setting]
+	return ok
+}
+
+// Reload returns the configuration with the reloadable settings of the next
+// configuration. Applied are the reloadable settings that changed and rejected are the
+// settings that changed but require a restart, identified by their field path.
+func (c Config) Reload(next Config) (conf Config, applied, rejected []string) {
+	for _, setting := range Diff(c, next) {
+		if Reloadable(setting) {
+			applied = append(applied, setting)
+		} else {
+			rejected = append(rejected, setting)
+		}
+	}
+
+	conf = c
+	conf.LogLevel = next.LogLevel
+	conf.AllowOrigins = next.AllowOrigins
+	conf.Maintenance = next.Maintenance
+	conf.MaintenanceMessage = next.MaintenanceMessage
+	conf.MaintenanceETA = next.MaintenanceETA
+	conf.MaintenanceReadOnly = next.MaintenanceReadOnly
+	conf.DefaultLifetime = next.DefaultLifetime
+	conf.DefaultAccesses = next.DefaultAccesses
+	conf.Policy = next.Policy
+	return conf, applied, rejected
+}
+
+// Diff returns the field paths of the settings that differ between the configurations
+// (e.g. "Log.Format"). Values are not returned since many settings are secrets.
+func Diff(a, b Config) []string {
+	return diff("", reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func diff(prefix string, a, b reflect.Value) (settings []string) {
+	for i := 0; i < a.NumField(); i++ {
+		field := a.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fa, fb := a.Field(i), b.Field(i)
+		if field.Type.Kind() == reflect.Struct && field.Type != timeType {
+			settings = append(settings, diff(prefix+field.Name+".", fa, fb)...)
+			continue
+		}
+
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			settings = append(settings, prefix+field.Name)
+		}
+	}
+	return settings
+}
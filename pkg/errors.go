@@ -2,19 +2,41 @@ package whisper
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/policy"
+	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rotationalio/whisper/pkg/vault"
 )
 
 var (
 	unsuccessful = v1.Reply{Success: false}
-	notFound     = v1.Reply{Success: false, Error: "resource not found"}
-	notAllowed   = v1.Reply{Success: false, Error: "method not allowed"}
+	notFound     = v1.Reply{Success: false, Error: "resource not found", Code: v1.ErrNotFound}
+	notAllowed   = v1.Reply{Success: false, Error: "method not allowed", Code: v1.ErrMethodNotAllowed}
 )
 
+// The error codes of the errors returned by the vault and the policy, in the order that
+// they are checked since some errors wrap others.
+var errorCodes = []struct {
+	err  error
+	code v1.ErrorCode
+}{
+	{vault.ErrSecretNotFound, v1.ErrSecretNotFound},
+	{vault.ErrPasswordRequired, v1.ErrPasswordRequired},
+	{vault.ErrNotAuthorized, v1.ErrPasswordIncorrect},
+	{vault.ErrFileSizeLimit, v1.ErrPayloadTooLarge},
+	{vault.ErrTimeToLive, v1.ErrTTLInvalid},
+	{vault.ErrRequestPending, v1.ErrRequestPending},
+	{vault.ErrRequestFulfilled, v1.ErrRequestFulfilled},
+	{policy.ErrPasswordRequired, v1.ErrPasswordRequired},
+	{policy.ErrPasswordTooWeak, v1.ErrPasswordTooWeak},
+	{errAdminUnauthorized, v1.ErrUnauthorized},
+}
+
 // ErrorResponse constructs an new response from the error or returns a success: false.
 func ErrorResponse(err interface{}) v1.Reply {
 	if err == nil {
@@ -24,7 +46,9 @@ func ErrorResponse(err interface{}) v1.Reply {
 	rep := v1.Reply{Success: false}
 	switch err := err.(type) {
 	case error:
+		err = undisclosed(err)
 		rep.Error = err.Error()
+		rep.Code = ErrorCode(err)
 	case string:
 		rep.Error = err
 	case fmt.Stringer:
@@ -42,12 +66,123 @@ func ErrorResponse(err interface{}) v1.Reply {
 	return rep
 }
 
+// ErrorReply constructs an error response from the error and adds the request ID so
+// that the user can report it and the error can be correlated with the server logs.
+func ErrorReply(c *gin.Context, err interface{}) v1.Reply {
+	rep := ErrorResponse(err)
+	rep.RequestID = sentry.RequestIDFromContext(c)
+	return rep
+}
+
+// ErrorCode returns the code that identifies the error to clients. Errors without a
+// specific code that were caused by the client are invalid requests; other errors do
+// not have a code.
+func ErrorCode(err error) v1.ErrorCode {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			return known.code
+		}
+	}
+
+	if IsBadRequest(err) {
+		return v1.ErrInvalidRequest
+	}
+	return ""
+}
+
+// errorStatus returns the http status code of an error returned by the logic that is
+// shared by the REST and gRPC APIs. Secrets that were destroyed because of too many
+// incorrect passwords are not found so that their existence is not disclosed.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, vault.ErrSecretNotFound), errors.Is(err, vault.ErrPasswordAttempts):
+		return http.StatusNotFound
+	case errors.Is(err, vault.ErrNotAuthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, vault.ErrRequestPending), errors.Is(err, vault.ErrRequestFulfilled):
+		return http.StatusConflict
+	case IsBadRequest(err):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// undisclosed replaces the errors of secrets that were destroyed because of too many
+// incorrect passwords with the not found error, so that the message and code of the
+// reply do not disclose that the secret existed.
+func undisclosed(err error) error {
+	if errors.Is(err, vault.ErrPasswordAttempts) {
+		return vault.ErrSecretNotFound
+	}
+	return err
+}
+
+// Coded associates an error with the code that identifies it to clients.
+func Coded(code v1.ErrorCode, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+type codedError struct {
+	code v1.ErrorCode
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// BadRequest wraps an error or message to indicate that it was caused by an invalid
+// request from the client rather than by the server. This allows handler logic that is
+// shared between endpoints to report what status code the error should produce.
+func BadRequest(err interface{}) error {
+	switch err := err.(type) {
+	case error:
+		return &badRequest{err: err}
+	case string:
+		return &badRequest{err: errors.New(err)}
+	default:
+		return &badRequest{err: fmt.Errorf("%v", err)}
+	}
+}
+
+// IsBadRequest returns true if the error was wrapped by BadRequest.
+func IsBadRequest(err error) bool {
+	var target *badRequest
+	return errors.As(err, &target)
+}
+
+type badRequest struct {
+	err error
+}
+
+func (e *badRequest) Error() string {
+	return e.err.Error()
+}
+
+func (e *badRequest) Unwrap() error {
+	return e.err
+}
+
 // NotFound returns a JSON 404 response for the API.
 func NotFound(c *gin.Context) {
-	c.JSON(http.StatusNotFound, notFound)
+	rep := notFound
+	rep.RequestID = sentry.RequestIDFromContext(c)
+	c.JSON(http.StatusNotFound, rep)
 }
 
 // NotAllowed returns a JSON 405 response for the API.
 func NotAllowed(c *gin.Context) {
-	c.JSON(http.StatusMethodNotAllowed, notAllowed)
+	rep := notAllowed
+	rep.RequestID = sentry.RequestIDFromContext(c)
+	c.JSON(http.StatusMethodNotAllowed, rep)
 }
@@ -2,12 +2,17 @@ package whisper_test
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	. "github.com/rotationalio/whisper/pkg"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/policy"
+	"github.com/rotationalio/whisper/pkg/vault"
 	"github.com/stretchr/testify/require"
 )
 
@@ -25,7 +30,7 @@ func TestNotFound(t *testing.T) {
 	var data map[string]interface{}
 	err := json.NewDecoder(result.Body).Decode(&data)
 	require.NoError(t, err)
-
+	require.Equal(t, "not_found", data["code"])
 }
 
 func TestNotAllowed(t *testing.T) {
@@ -42,4 +47,42 @@ func TestNotAllowed(t *testing.T) {
 	var data map[string]interface{}
 	err := json.NewDecoder(result.Body).Decode(&data)
 	require.NoError(t, err)
+	require.Equal(t, "method_not_allowed", data["code"])
+}
+
+func TestErrorCode(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected api.ErrorCode
+	}{
+		{vault.ErrSecretNotFound, api.ErrSecretNotFound},
+		{fmt.Errorf("could not load secret: %w", vault.ErrSecretNotFound), api.ErrSecretNotFound},
+		{vault.ErrPasswordRequired, api.ErrPasswordRequired},
+		{vault.ErrNotAuthorized, api.ErrPasswordIncorrect},
+		{vault.ErrFileSizeLimit, api.ErrPayloadTooLarge},
+		{BadRequest(vault.ErrTimeToLive), api.ErrTTLInvalid},
+		{vault.ErrRequestPending, api.ErrRequestPending},
+		{vault.ErrRequestFulfilled, api.ErrRequestFulfilled},
+		{policy.ErrPasswordRequired, api.ErrPasswordRequired},
+		{policy.Config{MinPasswordStrength: 4}.CheckPassword("password"), api.ErrPasswordTooWeak},
+		{BadRequest(Coded(api.ErrTTLInvalid, errors.New("lifetime too long"))), api.ErrTTLInvalid},
+		{BadRequest("invalid create secret request"), api.ErrInvalidRequest},
+		{errors.New("could not connect to secret manager"), ""},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, ErrorCode(tc.err), "unexpected code for %q", tc.err)
+	}
+
+	// Coded errors keep their message
+	rep := ErrorResponse(Coded(api.ErrPayloadTooLarge, errors.New("secret cannot be larger than 64 bytes")))
+	require.Equal(t, api.Reply{Error: "secret cannot be larger than 64 bytes", Code: api.ErrPayloadTooLarge}, rep)
+
+	// Secrets destroyed after too many incorrect passwords are not found
+	rep = ErrorResponse(fmt.Errorf("could not fetch secret: %w", vault.ErrPasswordAttempts))
+	require.Equal(t, ErrorResponse(vault.ErrSecretNotFound), rep)
+
+	// Messages do not have codes
+	rep = ErrorResponse("could not list secrets")
+	require.Empty(t, rep.Code)
 }
@@ -0,0 +1,9 @@
+package whisper
+
+import "github.com/rotationalio/whisper/pkg/vault"
+
+// Vault exposes the secret manager of the server so that tests can store secrets that
+// were not created by whisper in the same project.
+func (s *Server) Vault() *vault.SecretManager {
+	return s.vault
+}
@@ -0,0 +1,267 @@
+package whisper
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/api/v1/pb"
+	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// The gRPC methods that are allowed in read-only maintenance mode, equivalent to the
+// GET and DELETE requests that are allowed by the REST API.
+var readOnlyMethods = map[string]bool{
+	pb.Whisper_Status_FullMethodName:        true,
+	pb.Whisper_Policy_FullMethodName:        true,
+	pb.Whisper_FetchSecret_FullMethodName:   true,
+	pb.Whisper_DestroySecret_FullMethodName: true,
+	pb.Whisper_FetchResponse_FullMethodName: true,
+}
+
+// Create the gRPC server, which serves the gRPC API with the TLS configuration of the
+// REST API so that certificates are reloaded and client certificates are verified.
+func (s *Server) newGRPC() *grpc.Server {
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(s.interceptor)}
+	if s.srv.TLSConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.srv.TLSConfig)))
+	}
+
+	srv := grpc.NewServer(opts...)
+	pb.RegisterWhisperServer(srv, &rpc{s: s})
+	return srv
+}
+
+// GRPC returns the gRPC server if the gRPC API is enabled and is primarily exposed for
+// testing purposes.
+func (s *Server) GRPC() *grpc.Server {
+	return s.grpc
+}
+
+// interceptor handles every gRPC request in the same manner as the middleware of the
+// REST API: it assigns the request ID, logs the request, recovers from panics, and
+// rejects requests while the server is unavailable.
+func (s *Server) interceptor(ctx context.Context, in interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (out interface{}, err error) {
+	started := time.Now()
+
+	// Accept the request ID from the client or create one and return it in the header
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(v1.MetadataRequestID); len(ids) > 0 {
+			requestID = ids[0]
+		}
+	}
+	requestID = sentry.AcceptRequestID(requestID)
+	grpc.SetHeader(ctx, metadata.Pairs(v1.MetadataRequestID, requestID))
+
+	var clientIP string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		var serr error
+		if clientIP, _, serr = net.SplitHostPort(p.Addr.String()); serr != nil {
+			clientIP = p.Addr.String()
+		}
+	}
+
+	logger := log.With().Str(sentry.ContextKeyRequestID, requestID).Logger()
+	ctx = withCaller(logger.WithContext(ctx), requestID, clientIP)
+
+	defer func() {
+		if r := recover(); r != nil {
+			sentry.Error(ctx).Err(fmt.Errorf("%v", r)).Str("method", info.FullMethod).Msg("recovered from panic in grpc handler")
+			err = status.Error(codes.Internal, "internal error")
+		}
+
+		code := status.Code(err)
+		logctx := logger.With().
+			Str("method", info.FullMethod).
+			Dur("resp_time", time.Since(started)).
+			Str("code", code.String()).
+			Str("client_ip", clientIP).
+			Logger()
+
+		// Client errors are warnings and server errors are errors as in the REST API
+		level := zerolog.InfoLevel
+		switch {
+		case code == codes.OK:
+		case v1.HTTPStatus(code) < http.StatusInternalServerError:
+			level = zerolog.WarnLevel
+		default:
+			level = zerolog.ErrorLevel
+		}
+		logctx.WithLevel(level).Msgf("%s %s %s", ServiceName, info.FullMethod, code)
+	}()
+
+	// Reject requests while the server is unavailable; the status is always reported
+	if info.FullMethod != pb.Whisper_Status_FullMethodName {
+		if state, ok := s.available(info.FullMethod); !ok {
+			return nil, status.Error(codes.Unavailable, state)
+		}
+	}
+	return handler(ctx, in)
+}
+
+// available mirrors the Available middleware for gRPC requests, returning the status
+// of the server and false if the method cannot be handled.
+func (s *Server) available(method string) (string, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	switch {
+	case !s.healthy:
+		return serverStatusUnhealthy, false
+	case !s.ready && !s.draining:
+		return serverStatusNotReady, false
+	case s.maintenance.Enabled && !(s.maintenance.ReadOnly && readOnlyMethods[method]):
+		return serverStatusMaintenance, false
+	default:
+		return serverStatusOK, true
+	}
+}
+
+// rpc implements the gRPC API with the logic that is shared with the REST API.
+type rpc struct {
+	pb.UnimplementedWhisperServer
+	s *Server
+}
+
+func (r *rpc) Status(ctx context.Context, _ *pb.StatusRequest) (*pb.StatusReply, error) {
+	out := &v1.StatusReply{
+		Uptime:  time.Since(r.s.started).String(),
+		Version: Version(),
+	}
+
+	var ok bool
+	if out.Status, ok = r.s.available(""); !ok && out.Status == serverStatusMaintenance {
+		mode := r.s.Maintenance()
+		out.Message = mode.Message
+		out.ETA = mode.ETA
+		out.ReadOnly = mode.ReadOnly
+	}
+	return out.Proto(), nil
+}
+
+func (r *rpc) Policy(context.Context, *pb.PolicyRequest) (*pb.PolicyReply, error) {
+	return policyReply(r.s.settings()).Proto(), nil
+}
+
+func (r *rpc) CreateSecret(ctx context.Context, in *pb.CreateSecretRequest) (_ *pb.CreateSecretReply, err error) {
+	if in.Secret == "" {
+		return nil, rpcError(BadRequest("invalid create secret request"))
+	}
+
+	req := &v1.CreateSecretRequest{}
+	req.FromProto(in)
+
+	var rep *v1.CreateSecretReply
+	if rep, err = r.s.createSecret(ctx, req, ""); err != nil {
+		return nil, r.error(ctx, err, "could not create secret")
+	}
+	return rep.Proto(), nil
+}
+
+func (r *rpc) CreateBatch(ctx context.Context, in *pb.CreateBatchRequest) (_ *pb.CreateBatchReply, err error) {
+	req := &v1.CreateBatchRequest{}
+	req.FromProto(in)
+
+	var rep *v1.CreateBatchReply
+	if rep, err = r.s.createBatch(ctx, req); err != nil {
+		return nil, r.error(ctx, err, "could not create batch")
+	}
+	return rep.Proto(), nil
+}
+
+func (r *rpc) FetchSecret(ctx context.Context, in *pb.FetchSecretRequest) (_ *pb.FetchSecretReply, err error) {
+	if in.Token == "" {
+		return nil, rpcError(BadRequest("a token is required"))
+	}
+
+	var rep *v1.FetchSecretReply
+	if rep, err = r.s.fetchSecret(ctx, in.Token, in.Password); err != nil {
+		return nil, r.error(ctx, err, "could not fetch secret")
+	}
+	return rep.Proto(), nil
+}
+
+func (r *rpc) DestroySecret(ctx context.Context, in *pb.DestroySecretRequest) (_ *pb.DestroySecretReply, err error) {
+	if in.Token == "" {
+		return nil, rpcError(BadRequest("a token is required"))
+	}
+
+	var rep *v1.DestroySecretReply
+	if rep, err = r.s.destroySecret(ctx, in.Token, in.Password); err != nil {
+		return nil, r.error(ctx, err, "could not destroy secret")
+	}
+	return rep.Proto(), nil
+}
+
+func (r *rpc) RequestSecret(ctx context.Context, in *pb.RequestSecretRequest) (_ *pb.RequestSecretReply, err error) {
+	req := &v1.RequestSecretRequest{}
+	req.FromProto(in)
+
+	var rep *v1.RequestSecretReply
+	if rep, err = r.s.requestSecret(ctx, req); err != nil {
+		return nil, r.error(ctx, err, "could not create secret request")
+	}
+	return rep.Proto(), nil
+}
+
+func (r *rpc) RespondSecret(ctx context.Context, in *pb.RespondSecretRequest) (_ *pb.RespondSecretReply, err error) {
+	if in.Token == "" || in.Secret == "" {
+		return nil, rpcError(BadRequest("invalid respond secret request"))
+	}
+
+	req := &v1.RespondSecretRequest{}
+	req.FromProto(in)
+
+	var rep *v1.RespondSecretReply
+	if rep, err = r.s.respondSecret(ctx, in.Token, req); err != nil {
+		return nil, r.error(ctx, err, "could not respond to secret request")
+	}
+	return rep.Proto(), nil
+}
+
+func (r *rpc) FetchResponse(ctx context.Context, in *pb.FetchResponseRequest) (_ *pb.FetchSecretReply, err error) {
+	if in.Token == "" {
+		return nil, rpcError(BadRequest("a token is required"))
+	}
+
+	var rep *v1.FetchSecretReply
+	if rep, err = r.s.fetchResponse(ctx, in.Token, in.Owner); err != nil {
+		return nil, r.error(ctx, err, "could not fetch secret request response")
+	}
+	return rep.Proto(), nil
+}
+
+// Report internal errors in the same way as the REST handlers before converting them.
+func (r *rpc) error(ctx context.Context, err error, msg string) error {
+	if errorStatus(err) == http.StatusInternalServerError {
+		sentry.Error(ctx).Err(err).Msg(msg)
+	}
+	return rpcError(err)
+}
+
+// rpcError converts an error returned by the logic shared with the REST API to a gRPC
+// status with the equivalent code. The error code returned in REST replies is attached
+// to the status so that the gRPC client can return the same errors as the REST client.
+func rpcError(err error) error {
+	err = undisclosed(err)
+	st := status.New(v1.GRPCCode(errorStatus(err)), err.Error())
+	if code := ErrorCode(err); code != "" {
+		if detailed, derr := st.WithDetails(&errdetails.ErrorInfo{Reason: string(code), Domain: v1.ErrorDomain}); derr == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
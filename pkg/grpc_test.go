@@ -0,0 +1,179 @@
+package whisper_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	. "github.com/rotationalio/whisper/pkg"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func (s *WhisperTestSuite) TestGRPC() {
+	// Use a separate server with the gRPC API enabled
+	conf := s.conf
+	conf.GRPC.BindAddr = "127.0.0.1:0"
+	conf.PasswordAttempts = 3
+	srv, err := New(conf)
+	s.NoError(err)
+	srv.SetStatus(true, true)
+	s.Require().NotNil(srv.GRPC())
+
+	client := s.grpcClient(srv)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	status, err := client.Status(ctx)
+	s.NoError(err)
+	s.Equal("ok", status.Status)
+	s.Equal(Version(), status.Version)
+
+	policy, err := client.Policy(ctx)
+	s.NoError(err)
+	s.Equal(api.Duration(conf.Policy.MaxLifetime), policy.MaxLifetime)
+
+	// Create, fetch, and destroy a password protected secret
+	secret, err := client.CreateSecret(ctx, &api.CreateSecretRequest{Secret: "the eagle flies at midnight", Password: "supersecretsquirrel", Accesses: 2})
+	s.NoError(err)
+	s.NotEmpty(secret.Token)
+	s.False(secret.Expires.IsZero())
+
+	_, err = client.FetchSecret(ctx, secret.Token, "")
+	s.True(errors.Is(err, api.ErrPasswordRequired), "expected password required, got %v", err)
+
+	serr, ok := err.(*api.StatusError)
+	s.Require().True(ok)
+	s.Equal(http.StatusUnauthorized, serr.StatusCode)
+	s.NotEmpty(serr.RequestID)
+
+	_, err = client.FetchSecret(ctx, secret.Token, "wrongpassword")
+	s.True(errors.Is(err, api.ErrPasswordIncorrect), "expected password incorrect, got %v", err)
+
+	out, err := client.FetchSecret(ctx, secret.Token, "supersecretsquirrel")
+	s.NoError(err)
+	s.Equal("the eagle flies at midnight", out.Secret)
+	s.Equal(1, out.Accesses)
+	s.False(out.Destroyed)
+
+	destroyed, err := client.DestroySecret(ctx, secret.Token, "supersecretsquirrel")
+	s.NoError(err)
+	s.True(destroyed.Destroyed)
+
+	_, err = client.FetchSecret(ctx, secret.Token, "supersecretsquirrel")
+	s.True(errors.Is(err, api.ErrSecretNotFound), "expected secret not found, got %v", err)
+
+	// Secrets destroyed after too many incorrect passwords are not found
+	secret, err = client.CreateSecret(ctx, &api.CreateSecretRequest{Secret: "the eagle flies at midnight", Password: "supersecretsquirrel"})
+	s.NoError(err)
+	for i := 0; i < 3; i++ {
+		_, err = client.FetchSecret(ctx, secret.Token, "wrongpassword")
+	}
+	s.True(errors.Is(err, api.ErrSecretNotFound), "expected secret not found, got %v", err)
+	s.NotContains(err.Error(), "attempts")
+
+	// Invalid requests are rejected before they reach the vault
+	_, err = client.CreateSecret(ctx, &api.CreateSecretRequest{})
+	s.True(errors.Is(err, api.ErrInvalidRequest), "expected invalid request, got %v", err)
+
+	// Request a secret, respond to it, and fetch the response
+	req, err := client.RequestSecret(ctx, &api.RequestSecretRequest{})
+	s.NoError(err)
+	s.NotEmpty(req.Token)
+	s.NotEmpty(req.Owner)
+
+	_, err = client.FetchResponse(ctx, req.Token, req.Owner)
+	s.True(errors.Is(err, api.ErrRequestPending), "expected request pending, got %v", err)
+
+	rep, err := client.RespondSecret(ctx, req.Token, &api.RespondSecretRequest{Secret: "the owl hoots at dawn"})
+	s.NoError(err)
+	s.True(rep.Fulfilled)
+
+	_, err = client.RespondSecret(ctx, req.Token, &api.RespondSecretRequest{Secret: "the owl hoots at dawn"})
+	s.True(errors.Is(err, api.ErrRequestFulfilled), "expected request fulfilled, got %v", err)
+
+	_, err = client.FetchResponse(ctx, req.Token, "notthetoken")
+	s.True(errors.Is(err, api.ErrPasswordIncorrect), "expected password incorrect, got %v", err)
+
+	out, err = client.FetchResponse(ctx, req.Token, req.Owner)
+	s.NoError(err)
+	s.Equal("the owl hoots at dawn", out.Secret)
+
+	// Create a bundle and fetch all of its secrets at once
+	batch, err := client.CreateBatch(ctx, &api.CreateBatchRequest{
+		Secrets:  []*api.CreateSecretRequest{{Secret: "the eagle flies at midnight"}, {Secret: "dGhlIG93bA==", Filename: "owl.txt", IsBase64: true}},
+		Password: "supersecretsquirrel",
+		Bundle:   true,
+	})
+	s.NoError(err)
+	s.Require().NotNil(batch.Bundle)
+
+	out, err = client.FetchSecret(ctx, batch.Bundle.Token, "supersecretsquirrel")
+	s.NoError(err)
+	s.Equal([]*api.BundleItem{
+		{Secret: "the eagle flies at midnight", ContentType: "text/plain; charset=utf-8", Size: 27, SHA256: api.Digest([]byte("the eagle flies at midnight"))},
+		{Secret: "dGhlIG93bA==", Filename: "owl.txt", IsBase64: true, ContentType: "text/plain; charset=utf-8", Size: 7, SHA256: api.Digest([]byte("the owl"))},
+	}, out.Bundle)
+
+	_, err = client.CreateBatch(ctx, &api.CreateBatchRequest{})
+	s.True(errors.Is(err, api.ErrInvalidRequest), "expected invalid request, got %v", err)
+
+	// Only reads are allowed in read-only maintenance mode
+	srv.SetMaintenance(api.MaintenanceMode{Enabled: true, ReadOnly: true})
+	_, err = client.CreateSecret(ctx, &api.CreateSecretRequest{Secret: "the eagle flies at midnight"})
+	serr, ok = err.(*api.StatusError)
+	s.Require().True(ok, "expected status error, got %v", err)
+	s.Equal(http.StatusServiceUnavailable, serr.StatusCode)
+
+	status, err = client.Status(ctx)
+	s.NoError(err)
+	s.Equal("maintenance", status.Status)
+	s.True(status.ReadOnly)
+
+	_, err = client.Policy(ctx)
+	s.NoError(err)
+}
+
+func (s *WhisperTestSuite) TestGRPCRequestID() {
+	conf := s.conf
+	conf.GRPC.BindAddr = "127.0.0.1:0"
+	srv, err := New(conf)
+	s.NoError(err)
+	srv.SetStatus(true, true)
+
+	client := s.grpcClient(srv)
+	defer client.Close()
+
+	// The request ID supplied by the client is returned in the errors
+	const requestID = "01GX647S8PCVBCPJHXGJSPM87P"
+	ctx := metadata.AppendToOutgoingContext(context.Background(), api.MetadataRequestID, requestID)
+	_, err = client.FetchSecret(ctx, "notatoken", "")
+	s.True(errors.Is(err, api.ErrSecretNotFound), "expected secret not found, got %v", err)
+
+	serr, ok := err.(*api.StatusError)
+	s.Require().True(ok)
+	s.Equal(requestID, serr.RequestID)
+	s.Equal(http.StatusNotFound, serr.StatusCode)
+}
+
+// Serve the gRPC API of the server in memory and connect a client to it.
+func (s *WhisperTestSuite) grpcClient(srv *Server) *api.GRPCv1 {
+	lis := bufconn.Listen(1024 * 1024)
+	go srv.GRPC().Serve(lis)
+	s.T().Cleanup(srv.GRPC().Stop)
+
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}
+
+	client, err := api.NewGRPC("bufnet", grpc.WithContextDialer(dialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	s.Require().NoError(err)
+	return client
+}
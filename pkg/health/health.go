@@ -0,0 +1,202 @@
+/*
+Package health periodically checks the dependencies of the whisper server, such as the
+secret manager and the random number generator, so that the server can report that it
+is not ready when it cannot handle requests rather than failing every request.
+*/
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rs/zerolog/log"
+)
+
+// Config schedules the dependency checks. A component is unhealthy after the number of
+// consecutive failures in the threshold so that a single slow request does not take
+// the server out of service.
+type Config struct {
+	Enabled   bool          `default:"true" yaml:"enabled"`
+	Interval  time.Duration `default:"30s" yaml:"interval"`
+	Timeout   time.Duration `default:"10s" yaml:"timeout"`
+	Threshold int           `default:"2" yaml:"threshold"` // consecutive failures before the component is unhealthy
+}
+
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Interval <= 0 || c.Timeout <= 0 {
+		return errors.New("invalid configuration: health interval and timeout must be positive")
+	}
+
+	if c.Threshold < 1 {
+		return errors.New("invalid configuration: health threshold must be at least 1")
+	}
+	return nil
+}
+
+// Check returns an error if the component is not available.
+type Check func(ctx context.Context) error
+
+// State is the result of the most recent checks of a component. Components that have
+// not been checked yet are assumed to be healthy.
+type State struct {
+	Name        string
+	Healthy     bool
+	Checked     time.Time     // when the component was last checked; zero if not checked yet
+	Latency     time.Duration // how long the last check took
+	Failures    int           // the number of consecutive failed checks
+	LastError   string        // the error of the most recent failed check, even if it has recovered
+	LastErrorAt time.Time
+}
+
+// Monitor runs the checks of the registered components in the background.
+type Monitor struct {
+	sync.RWMutex
+	conf       Config
+	components []*component
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+type component struct {
+	check Check
+	state State
+}
+
+// New creates a monitor with no components.
+func New(conf Config) *Monitor {
+	return &Monitor{conf: conf}
+}
+
+// Register a component to be checked; components are reported in registration order.
+func (m *Monitor) Register(name string, check Check) {
+	m.Lock()
+	defer m.Unlock()
+	m.components = append(m.components, &component{check: check, state: State{Name: name, Healthy: true}})
+}
+
+// Start checking the components in a background go routine, immediately and then on
+// the configured interval until the monitor is stopped.
+func (m *Monitor) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel, m.done = cancel, make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.conf.Interval)
+		defer ticker.Stop()
+
+		log.Info().Dur("interval", m.conf.Interval).Msg("health monitor started")
+		for {
+			m.Run(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop the monitor, interrupting checks in progress, and wait for it to return.
+func (m *Monitor) Stop(ctx context.Context) error {
+	if m.cancel == nil {
+		return nil
+	}
+
+	m.cancel()
+	select {
+	case <-m.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run checks all of the components concurrently and updates their state. It is called
+// by the background go routine and is primarily exposed for testing purposes.
+func (m *Monitor) Run(ctx context.Context) {
+	m.RLock()
+	components := m.components
+	m.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, c := range components {
+		wg.Add(1)
+		go func(c *component) {
+			defer wg.Done()
+			m.check(ctx, c)
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (m *Monitor) check(ctx context.Context, c *component) {
+	cctx, cancel := context.WithTimeout(ctx, m.conf.Timeout)
+	defer cancel()
+
+	started := time.Now()
+	err := c.check(cctx)
+
+	// Do not count checks that were interrupted by the monitor being stopped
+	if ctx.Err() != nil {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	wasHealthy := c.state.Healthy
+	c.state.Checked = time.Now()
+	c.state.Latency = time.Since(started)
+
+	if err == nil {
+		c.state.Failures = 0
+		c.state.Healthy = true
+		if !wasHealthy {
+			log.Info().Str("component", c.state.Name).Msg("component recovered")
+		}
+		return
+	}
+
+	c.state.Failures++
+	c.state.LastError = err.Error()
+	c.state.LastErrorAt = c.state.Checked
+	c.state.Healthy = c.state.Failures < m.conf.Threshold
+
+	if wasHealthy && !c.state.Healthy {
+		sentry.Error(nil).Err(err).Str("component", c.state.Name).Int("failures", c.state.Failures).Msg("component is unhealthy")
+	} else {
+		log.Warn().Err(err).Str("component", c.state.Name).Int("failures", c.state.Failures).Msg("health check failed")
+	}
+}
+
+// Healthy returns true if all of the components are healthy.
+func (m *Monitor) Healthy() bool {
+	m.RLock()
+	defer m.RUnlock()
+	for _, c := range m.components {
+		if !c.state.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// States returns the current state of each component.
+func (m *Monitor) States() []State {
+	m.RLock()
+	defer m.RUnlock()
+
+	states := make([]State, 0, len(m.components))
+	for _, c := range m.components {
+		states = append(states, c.state)
+	}
+	return states
+}
@@ -0,0 +1,109 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/health"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, health.Config{}.Validate(), "disabled config should be valid")
+
+	conf := health.Config{Enabled: true, Interval: time.Minute, Timeout: time.Second, Threshold: 1}
+	require.NoError(t, conf.Validate())
+
+	testCases := []func(*health.Config){
+		func(c *health.Config) { c.Interval = 0 },
+		func(c *health.Config) { c.Timeout = 0 },
+		func(c *health.Config) { c.Threshold = 0 },
+	}
+
+	for i, modify := range testCases {
+		invalid := conf
+		modify(&invalid)
+		require.Error(t, invalid.Validate(), "test case %d did not error", i)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	var failing atomic.Bool
+	monitor := health.New(health.Config{Enabled: true, Interval: time.Minute, Timeout: 50 * time.Millisecond, Threshold: 2})
+	monitor.Register("ok", func(context.Context) error { return nil })
+	monitor.Register("flaky", func(context.Context) error {
+		if failing.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	// Components are healthy until they have been checked
+	require.True(t, monitor.Healthy())
+	states := monitor.States()
+	require.Len(t, states, 2)
+	require.Equal(t, "ok", states[0].Name)
+	require.Equal(t, "flaky", states[1].Name)
+	require.True(t, states[1].Checked.IsZero())
+
+	ctx := context.Background()
+	monitor.Run(ctx)
+	require.True(t, monitor.Healthy())
+	require.False(t, monitor.States()[1].Checked.IsZero())
+
+	// Components are unhealthy after the threshold of consecutive failures
+	failing.Store(true)
+	monitor.Run(ctx)
+	require.True(t, monitor.Healthy())
+	require.Equal(t, 1, monitor.States()[1].Failures)
+
+	monitor.Run(ctx)
+	require.False(t, monitor.Healthy())
+
+	state := monitor.States()[1]
+	require.False(t, state.Healthy)
+	require.Equal(t, 2, state.Failures)
+	require.Equal(t, "connection refused", state.LastError)
+	require.False(t, state.LastErrorAt.IsZero())
+	require.True(t, monitor.States()[0].Healthy)
+
+	// Components recover after a successful check but the last error is kept
+	failing.Store(false)
+	monitor.Run(ctx)
+	require.True(t, monitor.Healthy())
+
+	state = monitor.States()[1]
+	require.Equal(t, 0, state.Failures)
+	require.Equal(t, "connection refused", state.LastError)
+}
+
+func TestMonitorTimeout(t *testing.T) {
+	monitor := health.New(health.Config{Enabled: true, Interval: time.Minute, Timeout: 10 * time.Millisecond, Threshold: 1})
+	monitor.Register("hang", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	monitor.Run(context.Background())
+	require.False(t, monitor.Healthy())
+	require.Equal(t, context.DeadlineExceeded.Error(), monitor.States()[0].LastError)
+}
+
+func TestMonitorStart(t *testing.T) {
+	var checks atomic.Int32
+	monitor := health.New(health.Config{Enabled: true, Interval: 10 * time.Millisecond, Timeout: time.Second, Threshold: 1})
+	monitor.Register("counter", func(context.Context) error {
+		checks.Add(1)
+		return nil
+	})
+
+	// Stopping a monitor that was not started is a no-op
+	require.NoError(t, monitor.Stop(context.Background()))
+
+	monitor.Start()
+	require.Eventually(t, func() bool { return checks.Load() >= 2 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, monitor.Stop(context.Background()))
+}
@@ -0,0 +1,122 @@
+/*
+Package listener creates the network listeners that the whisper server accepts
+connections on. In addition to TCP addresses, the server can listen on a unix domain
+socket (e.g. behind a local reverse proxy) or on a socket passed to it by systemd
+socket activation so that the server does not need to bind a TCP port itself.
+*/
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// UnixScheme prefixes bind addresses that are paths to unix domain sockets, e.g.
+	// unix:///run/whisper/whisper.sock
+	UnixScheme = "unix://"
+
+	// SystemdAddr is the bind address of servers that use the socket passed to them by
+	// systemd socket activation.
+	SystemdAddr = "systemd"
+)
+
+// The first file descriptor passed by systemd, following stdin, stdout, and stderr.
+const listenFdsStart = 3
+
+// Listen on the bind address, which can be a TCP address (e.g. :8318), the path of a
+// unix socket prefixed by unix://, or systemd to use socket activation. If the process
+// was socket activated, the socket passed by systemd is used whatever the address.
+func Listen(addr string) (net.Listener, error) {
+	if addr == SystemdAddr || Activated() > 0 {
+		return Systemd()
+	}
+
+	if path, ok := UnixPath(addr); ok {
+		return Unix(path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// Validate that the bind address can be listened on.
+func Validate(addr string) error {
+	if path, ok := UnixPath(addr); ok && path == "" {
+		return errors.New("the path of the unix socket must be specified, e.g. unix:///run/whisper.sock")
+	}
+	return nil
+}
+
+// UnixPath returns the path of the socket if the address is a unix socket address.
+func UnixPath(addr string) (path string, ok bool) {
+	if !strings.HasPrefix(addr, UnixScheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(addr, UnixScheme), true
+}
+
+// Unix listens on a unix domain socket at the path. A socket left behind by a server
+// that did not shutdown cleanly is removed, but other files are never replaced. The
+// socket is removed when the listener is closed.
+func Unix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("cannot listen on %s: file exists and is not a socket", path)
+		}
+
+		// Only remove the socket if no server is listening on it
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("cannot listen on %s: socket is already in use", path)
+		}
+
+		if err = os.Remove(path); err != nil {
+			return nil, fmt.Errorf("could not remove stale socket: %w", err)
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// Activated returns the number of sockets passed to this process by systemd socket
+// activation, using the LISTEN_PID and LISTEN_FDS environment variables.
+func Activated() int {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return 0
+	}
+
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 0 {
+		return 0
+	}
+	return fds
+}
+
+// Systemd returns a listener for the socket passed by systemd socket activation. The
+// whisper server serves a single API so exactly one socket must be passed. The
+// environment variables are unset so that they are not inherited by child processes.
+func Systemd() (_ net.Listener, err error) {
+	switch fds := Activated(); fds {
+	case 0:
+		return nil, errors.New("no sockets were passed by systemd socket activation")
+	case 1:
+	default:
+		return nil, fmt.Errorf("expected one socket from systemd socket activation but %d were passed", fds)
+	}
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	file := os.NewFile(listenFdsStart, "LISTEN_FD_3")
+	defer file.Close()
+
+	var sock net.Listener
+	if sock, err = net.FileListener(file); err != nil {
+		return nil, fmt.Errorf("could not listen on socket passed by systemd: %w", err)
+	}
+	return sock, nil
+}
@@ -0,0 +1,103 @@
+package listener_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/rotationalio/whisper/pkg/listener"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListen(t *testing.T) {
+	sock, err := listener.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	require.Equal(t, "tcp", sock.Addr().Network())
+	require.NoError(t, sock.Close())
+
+	path := filepath.Join(tempDir(t), "whisper.sock")
+	sock, err = listener.Listen("unix://" + path)
+	require.NoError(t, err)
+	require.Equal(t, "unix", sock.Addr().Network())
+	require.Equal(t, path, sock.Addr().String())
+
+	// A socket that is in use cannot be replaced
+	_, err = listener.Listen("unix://" + path)
+	require.EqualError(t, err, "cannot listen on "+path+": socket is already in use")
+
+	// The socket is removed when the listener is closed
+	require.NoError(t, sock.Close())
+	require.NoFileExists(t, path)
+
+	// Without socket activation there are no sockets from systemd
+	_, err = listener.Listen(listener.SystemdAddr)
+	require.Error(t, err)
+}
+
+func TestUnix(t *testing.T) {
+	dir := tempDir(t)
+
+	// Create a stale socket that is not removed when its listener is closed
+	path := filepath.Join(dir, "stale.sock")
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	require.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+	require.FileExists(t, path)
+
+	sock, err := listener.Unix(path)
+	require.NoError(t, err)
+	require.NoError(t, sock.Close())
+
+	// Files that are not sockets are never replaced
+	path = filepath.Join(dir, "whisper.conf")
+	require.NoError(t, os.WriteFile(path, []byte("important"), 0600))
+	_, err = listener.Unix(path)
+	require.EqualError(t, err, "cannot listen on "+path+": file exists and is not a socket")
+	require.FileExists(t, path)
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, listener.Validate(":8318"))
+	require.NoError(t, listener.Validate("unix:///run/whisper.sock"))
+	require.NoError(t, listener.Validate(listener.SystemdAddr))
+	require.Error(t, listener.Validate("unix://"))
+
+	path, ok := listener.UnixPath("unix:///run/whisper.sock")
+	require.True(t, ok)
+	require.Equal(t, "/run/whisper.sock", path)
+
+	_, ok = listener.UnixPath("localhost:8318")
+	require.False(t, ok)
+}
+
+func TestActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+	require.Equal(t, 0, listener.Activated())
+
+	// Sockets passed to another process are ignored
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	require.Equal(t, 0, listener.Activated())
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	require.Equal(t, 1, listener.Activated())
+
+	// Only one socket can be used by the server
+	t.Setenv("LISTEN_FDS", "2")
+	require.Equal(t, 2, listener.Activated())
+	_, err := listener.Systemd()
+	require.EqualError(t, err, "expected one socket from systemd socket activation but 2 were passed")
+}
+
+// Unix socket paths are limited to about 100 characters, so a short temporary directory
+// is used rather than the test's temporary directory.
+func tempDir(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "whisper")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
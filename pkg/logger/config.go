@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Format specifies the field names and encoding of the log output.
+type Format string
+
+// Log formats supported by the whisper server.
+const (
+	GCP     Format = "gcp"     // JSON with GCP severity levels for Cloud Logging (the default)
+	JSON    Format = "json"    // JSON with the standard zerolog field names
+	Logfmt  Format = "logfmt"  // key=value pairs for log aggregators such as Loki
+	ECS     Format = "ecs"     // JSON with Elastic Common Schema (and OpenTelemetry) field names
+	Console Format = "console" // human readable output for development
+)
+
+// ECSVersion is the version of the Elastic Common Schema the ECS format conforms to.
+const ECSVersion = "8.6.0"
+
+// Config specifies the format of the logs and an optional file that the logs are written
+// to instead of stdout. Log files are rotated when they exceed the maximum size.
+type Config struct {
+	Format     Format `default:"gcp" yaml:"format"`
+	File       string `required:"false" yaml:"file"`                      // path to write logs to instead of stdout
+	MaxSize    int    `split_words:"true" default:"100" yaml:"max_size"`  // maximum size of the log file in megabytes before it is rotated
+	MaxBackups int    `split_words:"true" default:"3" yaml:"max_backups"` // number of rotated log files to retain; 0 to discard them
+}
+
+func (c Config) Validate() error {
+	switch Format(strings.ToLower(string(c.Format))) {
+	case GCP, JSON, Logfmt, ECS, Console, "":
+	default:
+		return fmt.Errorf("invalid configuration: %q is not a valid log format", c.Format)
+	}
+
+	if c.File != "" && c.MaxSize <= 0 {
+		return fmt.Errorf("invalid configuration: log file max size must be positive")
+	}
+
+	if c.MaxBackups < 0 {
+		return fmt.Errorf("invalid configuration: log file max backups cannot be negative")
+	}
+	return nil
+}
+
+// New creates a logger that writes to stdout or to the configured log file in the
+// configured format. All output is scrubbed of tokens and credentials. Zerolog field
+// names are package globals, so New also sets the field names for all loggers; it is
+// expected to be called once when the server is created. The returned closer must be
+// called to close the log file on shutdown.
+func New(conf Config) (logger zerolog.Logger, closer io.Closer, err error) {
+	if err = conf.Validate(); err != nil {
+		return logger, nil, err
+	}
+
+	// Determine the sink of the logs: stdout by default or a rotating log file
+	var out io.Writer = os.Stdout
+	closer = nopCloser{}
+	format := Format(strings.ToLower(string(conf.Format)))
+
+	if conf.File != "" {
+		var file *RotatingFile
+		if file, err = NewRotatingFile(conf.File, int64(conf.MaxSize)*megabyte, conf.MaxBackups); err != nil {
+			return logger, nil, err
+		}
+		out, closer = file, file
+	} else if format == Console {
+		// Console output has historically been written to stderr
+		out = os.Stderr
+	}
+
+	// Reset the field names to the zerolog defaults before applying the format
+	zerolog.TimeFieldFormat = time.RFC3339
+	zerolog.TimestampFieldName = "time"
+	zerolog.LevelFieldName = "level"
+	zerolog.MessageFieldName = "message"
+	zerolog.ErrorFieldName = "error"
+
+	// The scrubber operates on the JSON output of zerolog so it must wrap any writer
+	// that converts the JSON into another encoding.
+	switch format {
+	case GCP, "":
+		zerolog.TimestampFieldName = GCPFieldKeyTime
+		zerolog.MessageFieldName = GCPFieldKeyMsg
+		logger = zerolog.New(NewScrubber(out)).Hook(SeverityHook{})
+	case JSON:
+		logger = zerolog.New(NewScrubber(out))
+	case Logfmt:
+		logger = zerolog.New(NewScrubber(NewLogfmtWriter(out)))
+	case ECS:
+		zerolog.TimeFieldFormat = time.RFC3339Nano
+		zerolog.TimestampFieldName = ECSFieldKeyTime
+		zerolog.LevelFieldName = ECSFieldKeyLevel
+		zerolog.MessageFieldName = ECSFieldKeyMsg
+		zerolog.ErrorFieldName = ECSFieldKeyError
+		logger = zerolog.New(NewScrubber(out)).With().Str(ECSFieldKeyVersion, ECSVersion).Logger()
+	case Console:
+		// Colors are only written to a terminal and not to a log file
+		logger = zerolog.New(NewScrubber(zerolog.ConsoleWriter{Out: out, NoColor: conf.File != ""}))
+	}
+
+	return logger.With().Timestamp().Logger(), closer, nil
+}
+
+const megabyte = 1024 * 1024
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// Configure sets the global logger from the configuration unless the global logger has
+// been replaced for tests with Testing or Discard, in which case only the field names
+// are updated. The returned closer must be called to close the log file on shutdown.
+func Configure(conf Config) (closer io.Closer, err error) {
+	var logger zerolog.Logger
+	if logger, closer, err = New(conf); err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if orig == nil {
+		log.Logger = logger
+	}
+	return closer, nil
+}
@@ -0,0 +1,100 @@
+package logger_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/rotationalio/whisper/pkg/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	conf := Config{Format: GCP, MaxSize: 100, MaxBackups: 3}
+	require.NoError(t, conf.Validate())
+
+	conf.Format = "LOGFMT"
+	require.NoError(t, conf.Validate(), "formats should be case insensitive")
+
+	conf.Format = "xml"
+	require.EqualError(t, conf.Validate(), `invalid configuration: "xml" is not a valid log format`)
+
+	conf = Config{Format: JSON, File: "whisper.log", MaxSize: 0}
+	require.EqualError(t, conf.Validate(), "invalid configuration: log file max size must be positive")
+
+	conf = Config{Format: JSON, MaxBackups: -1}
+	require.EqualError(t, conf.Validate(), "invalid configuration: log file max backups cannot be negative")
+}
+
+func TestFormats(t *testing.T) {
+	// The field names are globals so they must be restored for the other tests
+	t.Cleanup(func() { New(Config{Format: JSON}) })
+
+	testCases := []struct {
+		format   Format
+		expected map[string]interface{}
+		absent   []string
+	}{
+		{GCP, map[string]interface{}{"severity": "WARNING", "level": "warn", "message": "be careful", "error": "whoops"}, nil},
+		{JSON, map[string]interface{}{"level": "warn", "message": "be careful", "error": "whoops"}, []string{"severity"}},
+		{ECS, map[string]interface{}{"log.level": "warn", "message": "be careful", "error.message": "whoops", "ecs.version": ECSVersion}, []string{"level", "time"}},
+	}
+
+	for _, tc := range testCases {
+		path := filepath.Join(t.TempDir(), "whisper.log")
+		logger, closer, err := New(Config{Format: tc.format, File: path, MaxSize: 1})
+		require.NoError(t, err)
+
+		logger.Warn().Err(errString("whoops")).Str("path", "/v1/secrets/"+token).Msg("be careful")
+		require.NoError(t, closer.Close())
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NotContains(t, string(data), token, "tokens must be scrubbed in all formats")
+
+		var fields map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &fields), "%s logs should be json", tc.format)
+		for key, value := range tc.expected {
+			require.Equal(t, value, fields[key], "unexpected %s field in %s logs", key, tc.format)
+		}
+		for _, key := range tc.absent {
+			require.NotContains(t, fields, key, "unexpected %s field in %s logs", key, tc.format)
+		}
+	}
+
+	// ECS uses @timestamp rather than time
+	path := filepath.Join(t.TempDir(), "whisper.log")
+	logger, closer, err := New(Config{Format: ECS, File: path, MaxSize: 1})
+	require.NoError(t, err)
+	logger.Info().Msg("hello")
+	require.NoError(t, closer.Close())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"@timestamp":`)
+}
+
+func TestLogfmt(t *testing.T) {
+	t.Cleanup(func() { New(Config{Format: JSON}) })
+
+	path := filepath.Join(t.TempDir(), "whisper.log")
+	logger, closer, err := New(Config{Format: Logfmt, File: path, MaxSize: 1})
+	require.NoError(t, err)
+
+	logger.Info().Str("path", "/v1/secrets/"+token).Int("status", 200).Bool("ok", true).Str("empty", "").Strs("origins", []string{"a", "b"}).Msg("request served")
+	require.NoError(t, closer.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	line := strings.TrimSpace(string(data))
+
+	require.True(t, strings.HasPrefix(line, "time="), "the timestamp should be written first")
+	require.Contains(t, line, ` level=info message="request served" empty="" ok=true origins="[\"a\",\"b\"]" path=/v1/secrets/tok_`)
+	require.True(t, strings.HasSuffix(line, " status=200"))
+	require.NotContains(t, line, token)
+}
+
+type errString string
+
+func (e errString) Error() string { return string(e) }
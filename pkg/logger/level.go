@@ -45,6 +45,12 @@ func (ll *LevelDecoder) Decode(value string) error {
 	return nil
 }
 
+// UnmarshalText implements encoding.TextUnmarshaler so the level can be read from a
+// config file.
+func (ll *LevelDecoder) UnmarshalText(text []byte) error {
+	return ll.Decode(string(text))
+}
+
 // Encode converts the loglevel into a string for use in YAML and JSON
 func (ll *LevelDecoder) Encode() (string, error) {
 	switch zerolog.Level(*ll) {
@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// LogfmtWriter is an io.Writer that converts the JSON log lines written by zerolog into
+// logfmt key=value pairs. The timestamp, level, and message are written first and the
+// remaining fields are sorted by key. Nested objects and arrays are written as quoted
+// JSON. Lines that are not JSON objects are written unmodified.
+type LogfmtWriter struct {
+	out io.Writer
+}
+
+// NewLogfmtWriter wraps the writer so that all log lines are written as logfmt.
+func NewLogfmtWriter(out io.Writer) *LogfmtWriter {
+	return &LogfmtWriter{out: out}
+}
+
+// Write implements io.Writer and is called by zerolog with one JSON object per call.
+func (w *LogfmtWriter) Write(p []byte) (n int, err error) {
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	if err = decoder.Decode(&fields); err != nil {
+		return w.out.Write(p)
+	}
+
+	first := []string{zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		if !contains(first, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	buf := &bytes.Buffer{}
+	for _, key := range append(first, keys...) {
+		value, ok := fields[key]
+		if !ok {
+			continue
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(value))
+	}
+	buf.WriteByte('\n')
+
+	if _, err = w.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	// Report that all of the original bytes were written so zerolog doesn't error
+	return len(p), nil
+}
+
+func logfmtValue(value interface{}) string {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		s = v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		s = string(data)
+	}
+
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -1,5 +1,6 @@
 /*
-Package logger adapts the zerolog package to use GCP serverity levels.
+Package logger adapts the zerolog package to use GCP serverity levels or other log
+formats such as logfmt and the Elastic Common Schema, and scrubs secrets from the logs.
 */
 package logger
 
@@ -18,6 +19,12 @@ const (
 	GCPFieldKeySeverity = "severity"
 	GCPFieldKeyMsg      = "message"
 	GCPFieldKeyTime     = "time"
+
+	ECSFieldKeyTime    = "@timestamp"
+	ECSFieldKeyLevel   = "log.level"
+	ECSFieldKeyMsg     = "message"
+	ECSFieldKeyError   = "error.message"
+	ECSFieldKeyVersion = "ecs.version"
 )
 
 var (
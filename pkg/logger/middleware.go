@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
@@ -17,17 +18,17 @@ func GinLogger(server, version string) gin.HandlerFunc {
 		// Before request
 		started := time.Now()
 
-		path := c.Request.URL.Path
-		if c.Request.URL.RawQuery != "" {
-			path = path + "?" + c.Request.URL.RawQuery
-		}
+		// Tokens in the path and query are the capability to fetch secrets and must
+		// never be logged; they are replaced with a keyed hash for correlation.
+		path := RedactURL(c.Request.URL)
+		redactedPath := RedactPath(c.Request.URL.Path)
 
 		// Handle the request
 		c.Next()
 
 		// After request
 		status := c.Writer.Status()
-		logctx := log.With().
+		logctx := requestLogger(c).With().
 			Str("path", path).
 			Str("ser_name", server).
 			Str("version", version).
@@ -51,11 +52,11 @@ func GinLogger(server, version string) gin.HandlerFunc {
 		var msg string
 		switch len(c.Errors) {
 		case 0:
-			msg = fmt.Sprintf("%s %s %s %d", server, c.Request.Method, c.Request.URL.Path, status)
+			msg = fmt.Sprintf("%s %s %s %d", server, c.Request.Method, redactedPath, status)
 		case 1:
 			msg = c.Errors.String()
 		default:
-			msg = fmt.Sprintf("%s %s %s [%d] %d errors occurred", server, c.Request.Method, c.Request.URL.Path, status, len(c.Errors))
+			msg = fmt.Sprintf("%s %s %s [%d] %d errors occurred", server, c.Request.Method, redactedPath, status, len(c.Errors))
 		}
 
 		switch {
@@ -68,3 +69,12 @@ func GinLogger(server, version string) gin.HandlerFunc {
 		}
 	}
 }
+
+// Returns the request-scoped logger set by request ID middleware if available so that
+// the request ID is included in the log, otherwise returns the global logger.
+func requestLogger(c *gin.Context) *zerolog.Logger {
+	if logger := zerolog.Ctx(c.Request.Context()); logger != nil && logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return &log.Logger
+}
@@ -0,0 +1,291 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces values that must never be logged and cannot be usefully hashed.
+const Redacted = "[REDACTED]"
+
+// Prefix of the keyed hash that replaces tokens so that it is clear in the logs that a
+// value has been redacted. The same token always has the same hash with the same key.
+const hashPrefix = "tok_"
+
+// Path segments and query values that look like capability tokens (URL-safe base64 of
+// at least 16 bytes) are replaced by their keyed hash.
+var (
+	tokenPattern    = regexp.MustCompile(`^[A-Za-z0-9_-]{22,}={0,2}$`)
+	embeddedPattern = regexp.MustCompile(`/[A-Za-z0-9_-]{22,}`)
+)
+
+// Query parameters, headers, and log fields whose values are always redacted.
+var (
+	sensitiveParams = map[string]struct{}{
+		"token": {}, "owner": {}, "password": {}, "secret": {}, "key": {}, "code": {},
+	}
+
+	// Tokens are hashed so that they can be correlated, all other values are redacted
+	// since even a keyed hash of a password could be brute forced if the key is leaked.
+	hashedParams = map[string]struct{}{
+		"token": {}, "owner": {},
+	}
+
+	sensitiveHeaders = []string{
+		"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Slack-Signature",
+	}
+
+	sensitiveFields = map[string]struct{}{
+		"password": {}, "secret": {}, "token": {}, "owner": {}, "authorization": {},
+	}
+)
+
+var (
+	keymu sync.RWMutex
+	// the key of the keyed hash; random unless configured so hashes only correlate
+	// within a single process unless a key is shared between replicas.
+	redactionKey = randomKey()
+)
+
+// SetRedactionKey sets the key used to hash tokens so that the hashes of the same token
+// are stable across restarts and replicas. If the key is empty a random key is used.
+func SetRedactionKey(key string) {
+	keymu.Lock()
+	defer keymu.Unlock()
+	if key == "" {
+		redactionKey = randomKey()
+		return
+	}
+	redactionKey = []byte(key)
+}
+
+// HashToken returns the keyed hash of a token that is safe to log. The hash allows log
+// lines about the same secret to be correlated without revealing the token itself.
+func HashToken(token string) string {
+	keymu.RLock()
+	mac := hmac.New(sha256.New, redactionKey)
+	keymu.RUnlock()
+
+	mac.Write([]byte(token))
+	return hashPrefix + hex.EncodeToString(mac.Sum(nil))[:24]
+}
+
+// RedactPath replaces any path segments that look like tokens with their keyed hash.
+func RedactPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if isToken(segment) {
+			segments[i] = HashToken(segment)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// RedactQuery replaces the values of sensitive query parameters and any values that
+// look like tokens with their keyed hash. If the query cannot be parsed it is redacted.
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Redacted
+	}
+
+	for key, values := range query {
+		_, sensitive := sensitiveParams[strings.ToLower(key)]
+		_, hashed := hashedParams[strings.ToLower(key)]
+		for i, value := range values {
+			switch {
+			case value == Redacted || strings.HasPrefix(value, hashPrefix):
+				continue
+			case sensitive && !hashed:
+				values[i] = Redacted
+			case sensitive || isToken(value):
+				values[i] = HashToken(value)
+			}
+		}
+	}
+	return query.Encode()
+}
+
+// RedactURL returns the path and query of the URL with all tokens redacted.
+func RedactURL(u *url.URL) string {
+	path := RedactPath(u.Path)
+	if u.RawQuery != "" {
+		path = path + "?" + RedactQuery(u.RawQuery)
+	}
+	return path
+}
+
+// RedactHeaders returns a copy of the headers with credentials stripped.
+func RedactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for key := range redacted {
+		if IsSensitiveHeader(key) {
+			redacted.Set(key, Redacted)
+		}
+	}
+	return redacted
+}
+
+// IsSensitiveHeader returns true if the header carries credentials.
+func IsSensitiveHeader(key string) bool {
+	for _, header := range sensitiveHeaders {
+		if strings.EqualFold(key, header) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scrubber is an io.Writer that scrubs known-sensitive fields from JSON log lines before
+// writing them to the underlying writer. Zerolog hooks can only add fields to an event,
+// so the scrubbing is done on the serialized output instead. Sensitive fields such as
+// password or token are hashed or redacted and path fields have their tokens hashed.
+// Lines that are not JSON objects are written unmodified.
+type Scrubber struct {
+	out io.Writer
+}
+
+// NewScrubber wraps the writer so that all log lines are scrubbed before being written.
+func NewScrubber(out io.Writer) *Scrubber {
+	return &Scrubber{out: out}
+}
+
+// Write implements io.Writer and is called by zerolog with one JSON object per call.
+func (s *Scrubber) Write(p []byte) (n int, err error) {
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	if err = decoder.Decode(&fields); err != nil {
+		return s.out.Write(p)
+	}
+
+	if !scrub(fields) {
+		return s.out.Write(p)
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err = encoder.Encode(fields); err != nil {
+		return 0, err
+	}
+
+	if _, err = s.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	// Report that all of the original bytes were written so zerolog doesn't error
+	return len(p), nil
+}
+
+// Scrubs the fields in place, returning true if any field was modified.
+func scrub(fields map[string]interface{}) (modified bool) {
+	for key, value := range fields {
+		str, isString := value.(string)
+		lkey := strings.ToLower(key)
+
+		switch {
+		case lkey == "path" && isString:
+			if redacted := redactPathAndQuery(str); redacted != str {
+				fields[key] = redacted
+				modified = true
+			}
+		case isSensitiveField(lkey):
+			if isString && str != "" {
+				if str == Redacted || strings.HasPrefix(str, hashPrefix) {
+					continue
+				}
+
+				if lkey == "token" || lkey == "owner" {
+					fields[key] = HashToken(str)
+				} else {
+					fields[key] = Redacted
+				}
+				modified = true
+			} else if _, isBool := value.(bool); !isString && !isBool && value != nil {
+				fields[key] = Redacted
+				modified = true
+			}
+		case isString:
+			if redacted := redactEmbedded(str); redacted != str {
+				fields[key] = redacted
+				modified = true
+			}
+		default:
+			if scrubValue(value) {
+				modified = true
+			}
+		}
+	}
+	return modified
+}
+
+// Scrubs nested objects and arrays such as the errors field, returning true if modified.
+func scrubValue(value interface{}) (modified bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return scrub(v)
+	case []interface{}:
+		for i, item := range v {
+			if str, ok := item.(string); ok {
+				if redacted := redactEmbedded(str); redacted != str {
+					v[i] = redacted
+					modified = true
+				}
+			} else if scrubValue(item) {
+				modified = true
+			}
+		}
+	}
+	return modified
+}
+
+func isSensitiveField(key string) bool {
+	_, ok := sensitiveFields[key]
+	return ok
+}
+
+func redactPathAndQuery(path string) string {
+	if idx := strings.IndexByte(path, '?'); idx >= 0 {
+		return RedactPath(path[:idx]) + "?" + RedactQuery(path[idx+1:])
+	}
+	return RedactPath(path)
+}
+
+// Replaces path segments that look like tokens in free text such as error messages that
+// include URLs or secret manager resource names.
+func redactEmbedded(s string) string {
+	return embeddedPattern.ReplaceAllStringFunc(s, func(segment string) string {
+		if !isToken(segment[1:]) {
+			return segment
+		}
+		return "/" + HashToken(segment[1:])
+	})
+}
+
+// Hashed tokens also look like tokens so they are excluded to prevent double hashing.
+func isToken(s string) bool {
+	return tokenPattern.MatchString(s) && !strings.HasPrefix(s, hashPrefix)
+}
+
+func randomKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
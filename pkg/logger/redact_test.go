@@ -0,0 +1,131 @@
+package logger_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/rotationalio/whisper/pkg/logger"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+// A token generated the same way as the whisper server generates tokens.
+const token = "tJAO2Q5uVJQv8nVbGzkqS9aTzUyJ1W7-wBPgQ8tZLcU"
+
+func TestHashToken(t *testing.T) {
+	SetRedactionKey("supersecretkey")
+	t.Cleanup(func() { SetRedactionKey("") })
+
+	hash := HashToken(token)
+	require.True(t, strings.HasPrefix(hash, "tok_"))
+	require.NotContains(t, hash, token)
+	require.Equal(t, hash, HashToken(token), "hashes must be stable for correlation")
+	require.NotEqual(t, hash, HashToken("other"+token))
+
+	// The hash depends on the key
+	SetRedactionKey("anotherkey")
+	require.NotEqual(t, hash, HashToken(token))
+}
+
+func TestRedactPath(t *testing.T) {
+	testCases := []string{
+		"/v1/secrets/" + token,
+		"/v1/requests/" + token,
+		"/v1/secrets/" + token + "/unknown",
+		"/unknown/" + token,
+	}
+
+	for _, path := range testCases {
+		redacted := RedactPath(path)
+		require.NotContains(t, redacted, token)
+		require.Contains(t, redacted, HashToken(token))
+	}
+
+	// Paths without tokens are unchanged and redaction is idempotent
+	require.Equal(t, "/v1/secrets", RedactPath("/v1/secrets"))
+	require.Equal(t, "/v1/status", RedactPath("/v1/status"))
+	redacted := RedactPath("/v1/secrets/" + token)
+	require.Equal(t, redacted, RedactPath(redacted))
+}
+
+func TestRedactQuery(t *testing.T) {
+	redacted := RedactQuery("token=abc&password=hunter2&foo=" + token + "&page=2")
+	require.NotContains(t, redacted, "abc")
+	require.NotContains(t, redacted, "hunter2")
+	require.NotContains(t, redacted, token)
+	require.Contains(t, redacted, "page=2")
+	require.Contains(t, redacted, "password=%5BREDACTED%5D")
+	require.Equal(t, redacted, RedactQuery(redacted))
+
+	require.Equal(t, "", RedactQuery(""))
+	require.Equal(t, Redacted, RedactQuery("%zz"))
+}
+
+func TestRedactHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	header.Set("Cookie", "session="+token)
+	header.Set("Content-Type", "application/json")
+
+	redacted := RedactHeaders(header)
+	require.Equal(t, Redacted, redacted.Get("Authorization"))
+	require.Equal(t, Redacted, redacted.Get("Cookie"))
+	require.Equal(t, "application/json", redacted.Get("Content-Type"))
+
+	// The original headers must not be modified
+	require.Equal(t, "Bearer "+token, header.Get("Authorization"))
+}
+
+func TestScrubber(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := zerolog.New(NewScrubber(buf))
+
+	logger.Info().Str("path", "/v1/secrets/"+token+"?owner="+token).Msg("request")
+	logger.Info().Str("token", token).Str("password", "hunter2").Bool("authorization", true).Msg("sensitive fields")
+	logger.Info().Err(errors.New(`could not access "projects/test/secrets/` + token + `-secret"`)).Msg("vault error")
+	logger.Info().Errs("errors", []error{errors.New("fetch /v1/secrets/" + token)}).Msg("multiple errors")
+	logger.Info().Dict("request", zerolog.Dict().Str("secret", "the eagle flies at midnight")).Msg("nested")
+	logger.Info().Msg("GET /v1/secrets/" + token + " 404")
+
+	out := buf.String()
+	require.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 6)
+	require.NotContains(t, out, token)
+	require.NotContains(t, out, "hunter2")
+	require.NotContains(t, out, "the eagle flies at midnight")
+	require.Contains(t, out, HashToken(token))
+	require.Contains(t, out, `"authorization":true`)
+
+	// Log lines without sensitive data are written unmodified
+	buf.Reset()
+	logger.Info().Str("path", "/v1/status").Int("status", 200).Msg("ok")
+	require.Equal(t, `{"level":"info","path":"/v1/status","status":200,"message":"ok"}`+"\n", buf.String())
+}
+
+func TestGinLoggerRedaction(t *testing.T) {
+	buf := &bytes.Buffer{}
+	prev := log.Logger
+	log.Logger = zerolog.New(buf)
+	t.Cleanup(func() { log.Logger = prev })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GinLogger("whisper", "1.0"))
+	router.GET("/v1/secrets/:token", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/secrets/"+token+"?owner="+token, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// The GinLogger must redact without the Scrubber
+	require.NotEmpty(t, buf.String())
+	require.NotContains(t, buf.String(), token)
+	require.Contains(t, buf.String(), HashToken(token))
+}
@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.Writer that appends to a log file and rotates the file when a
+// write would cause it to exceed the maximum size. Rotated files are renamed with a
+// numeric suffix (e.g. whisper.log.1 is the most recent) and only the configured number
+// of backups are retained.
+type RotatingFile struct {
+	sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewRotatingFile opens or creates the log file at path, appending to any existing logs.
+// The maxSize is the size of the file in bytes before it is rotated.
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (f *RotatingFile, err error) {
+	if maxSize <= 0 {
+		return nil, errors.New("log file max size must be positive")
+	}
+
+	f = &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err = f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write implements io.Writer. A single write that is larger than the maximum size is
+// written to a new file rather than split across files so that log lines stay intact.
+func (f *RotatingFile) Write(p []byte) (n int, err error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.file == nil {
+		return 0, fs.ErrClosed
+	}
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err = f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err = f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close the log file; subsequent writes will return an error.
+func (f *RotatingFile) Close() error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) open() (err error) {
+	if f.file, err = os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640); err != nil {
+		return fmt.Errorf("could not open log file: %w", err)
+	}
+
+	var info os.FileInfo
+	if info, err = f.file.Stat(); err != nil {
+		f.file.Close()
+		return fmt.Errorf("could not stat log file: %w", err)
+	}
+	f.size = info.Size()
+	return nil
+}
+
+// Shifts each backup up by one, removing the oldest, then reopens an empty log file.
+func (f *RotatingFile) rotate() (err error) {
+	if err = f.file.Close(); err != nil {
+		return fmt.Errorf("could not close log file: %w", err)
+	}
+	f.file = nil
+
+	if f.maxBackups == 0 {
+		if err = os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("could not remove log file: %w", err)
+		}
+		return f.open()
+	}
+
+	if err = os.Remove(f.backup(f.maxBackups)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not remove oldest log file: %w", err)
+	}
+
+	for i := f.maxBackups - 1; i > 0; i-- {
+		if err = os.Rename(f.backup(i), f.backup(i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("could not rotate log file: %w", err)
+		}
+	}
+
+	if err = os.Rename(f.path, f.backup(1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not rotate log file: %w", err)
+	}
+	return f.open()
+}
+
+func (f *RotatingFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
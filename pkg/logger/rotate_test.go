@@ -0,0 +1,56 @@
+package logger_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/rotationalio/whisper/pkg/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "whisper.log")
+	line := strings.Repeat("a", 9) + "\n"
+
+	// Appends to an existing log file
+	require.NoError(t, os.WriteFile(path, []byte(line), 0640))
+	file, err := NewRotatingFile(path, 25, 2)
+	require.NoError(t, err)
+
+	// Two lines fit in the file, the third causes it to rotate
+	for i := 0; i < 7; i++ {
+		n, err := file.Write([]byte(line))
+		require.NoError(t, err)
+		require.Equal(t, len(line), n)
+	}
+	require.NoError(t, file.Close())
+
+	// 8 lines were written: 2 in each backup, 2 in the current file, and 2 discarded
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(name)
+		require.NoError(t, err, "expected %s to exist", name)
+		require.Equal(t, line+line, string(data))
+	}
+	require.NoFileExists(t, path+".3")
+
+	// Writes after close return an error
+	_, err = file.Write([]byte(line))
+	require.Error(t, err)
+	require.NoError(t, file.Close(), "close should be idempotent")
+
+	// Lines larger than the max size are not split
+	file, err = NewRotatingFile(path, 5, 0)
+	require.NoError(t, err)
+	_, err = file.Write([]byte(line))
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, line, string(data), "without backups the rotated file is removed")
+
+	_, err = NewRotatingFile(path, 0, 0)
+	require.Error(t, err)
+}
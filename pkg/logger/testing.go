@@ -19,19 +19,26 @@ func ResetLogger() {
 	defer mu.Unlock()
 	if orig != nil {
 		log.Logger = *orig
+		orig = nil
 	}
 }
 
 func Testing(tb testing.TB) {
 	mu.Lock()
 	defer mu.Unlock()
-	orig = &log.Logger
+	if orig == nil {
+		prev := log.Logger
+		orig = &prev
+	}
 	log.Logger = log.Output(zerolog.NewTestWriter(tb))
 }
 
 func Discard() {
 	mu.Lock()
 	defer mu.Unlock()
-	orig = &log.Logger
+	if orig == nil {
+		prev := log.Logger
+		orig = &prev
+	}
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: io.Discard})
 }
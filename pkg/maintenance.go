@@ -0,0 +1,112 @@
+package whisper
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/config"
+	"github.com/rs/zerolog/log"
+)
+
+var errMaintenanceETA = errors.New("maintenance eta must be in the future")
+
+// Maintenance returns the current maintenance mode of the server.
+func (s *Server) Maintenance() v1.MaintenanceMode {
+	s.RLock()
+	defer s.RUnlock()
+	return s.maintenance
+}
+
+// SetMaintenance puts the server into or takes the server out of maintenance mode
+// without a restart. The message, ETA, and read-only flag are cleared when maintenance
+// mode is disabled.
+func (s *Server) SetMaintenance(mode v1.MaintenanceMode) {
+	if !mode.Enabled {
+		mode = v1.MaintenanceMode{}
+	}
+
+	s.Lock()
+	s.maintenance = mode
+	s.Unlock()
+
+	if mode.Enabled {
+		log.Warn().Bool("read_only", mode.ReadOnly).Str("message", mode.Message).Msg("maintenance mode enabled")
+	} else {
+		log.Info().Msg("maintenance mode disabled")
+	}
+}
+
+// AdminMaintenance returns the current maintenance mode of the server.
+func (s *Server) AdminMaintenance(c *gin.Context) {
+	out := s.Maintenance()
+	c.JSON(http.StatusOK, &out)
+}
+
+// AdminSetMaintenance puts the server into or takes the server out of maintenance mode.
+func (s *Server) AdminSetMaintenance(c *gin.Context) {
+	in := v1.MaintenanceMode{}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorReply(c, BadRequest(err)))
+		return
+	}
+
+	if in.Enabled && in.ETA != nil && !in.ETA.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, ErrorReply(c, BadRequest(errMaintenanceETA)))
+		return
+	}
+
+	s.SetMaintenance(in)
+	out := s.Maintenance()
+	c.JSON(http.StatusOK, &out)
+}
+
+// Create the maintenance mode from the configuration.
+func maintenanceMode(conf config.Config) v1.MaintenanceMode {
+	mode := v1.MaintenanceMode{
+		Enabled:  conf.Maintenance,
+		ReadOnly: conf.MaintenanceReadOnly,
+		Message:  conf.MaintenanceMessage,
+	}
+
+	if !conf.MaintenanceETA.IsZero() {
+		eta := conf.MaintenanceETA
+		mode.ETA = &eta
+	}
+
+	if !mode.Enabled {
+		return v1.MaintenanceMode{}
+	}
+	return mode
+}
+
+// maintenanceAllows returns true if the request can be handled in the maintenance mode.
+// Operators can always use the admin API and secrets can be fetched and destroyed in
+// read-only maintenance mode, but nothing can be created.
+func maintenanceAllows(mode v1.MaintenanceMode, c *gin.Context) bool {
+	if !mode.Enabled || isAdminRoute(c) {
+		return true
+	}
+
+	if mode.ReadOnly {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodDelete:
+			return true
+		}
+	}
+	return false
+}
+
+// Set the Retry-After header so clients know when to try again after maintenance.
+func retryAfter(c *gin.Context, eta *time.Time) {
+	if eta == nil {
+		return
+	}
+
+	if wait := time.Until(*eta); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+	}
+}
@@ -0,0 +1,95 @@
+package whisper_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/rotationalio/whisper/pkg"
+	"github.com/rotationalio/whisper/pkg/admin"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+)
+
+func (s *WhisperTestSuite) TestMaintenance() {
+	const token = "Wd8QaqkQ0pRL4hHvRAu5fiR79h3RU0W1XGrXH3qT"
+
+	// Use a separate server so that maintenance mode does not affect the other tests
+	conf := s.conf
+	conf.Admin = admin.Config{Token: token}
+	srv, err := New(conf)
+	s.NoError(err)
+	srv.SetStatus(true, true)
+	s.False(srv.Maintenance().Enabled)
+
+	prev := s.router
+	s.router = srv.Routes()
+	defer func() { s.router = prev }()
+
+	secret := s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight", Accesses: 3}, http.StatusCreated)
+
+	// Nothing but the admin API is available in maintenance mode
+	eta := time.Now().Add(time.Hour)
+	srv.SetMaintenance(api.MaintenanceMode{Enabled: true, Message: "upgrading storage", ETA: &eta})
+
+	status := &api.StatusReply{}
+	s.sendJSON(http.MethodGet, "/v1/status", "", nil, http.StatusServiceUnavailable, status)
+	s.Equal("maintenance", status.Status)
+	s.Equal("upgrading storage", status.Message)
+	s.Require().NotNil(status.ETA)
+	s.WithinDuration(eta, *status.ETA, time.Second)
+	s.False(status.ReadOnly)
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/secrets/"+secret.Token, nil))
+	s.Equal(http.StatusServiceUnavailable, w.Code)
+	s.NotEmpty(w.Header().Get("Retry-After"))
+
+	// Secrets can be fetched and destroyed but not created in read-only maintenance
+	srv.SetMaintenance(api.MaintenanceMode{Enabled: true, ReadOnly: true})
+	s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight"}, http.StatusServiceUnavailable)
+	s.sendJSON(http.MethodPost, "/v1/requests", "", &api.RequestSecretRequest{}, http.StatusServiceUnavailable, &api.StatusReply{})
+	out := s.sendFetchRequest(secret.Token, "", http.StatusOK)
+	s.Equal("the eagle flies at midnight", out.Secret)
+	s.sendJSON(http.MethodDelete, "/v1/secrets/"+secret.Token, "", nil, http.StatusOK, &api.DestroySecretReply{})
+
+	status = &api.StatusReply{}
+	s.sendJSON(http.MethodGet, "/v1/status", "", nil, http.StatusOK, status)
+	s.Equal("maintenance", status.Status)
+	s.True(status.ReadOnly)
+
+	// Maintenance mode can be changed by operators using the admin API
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := api.NewAdmin(server.URL, token)
+	s.NoError(err)
+
+	mode, err := client.AdminMaintenance(ctx)
+	s.NoError(err)
+	s.Equal(&api.MaintenanceMode{Enabled: true, ReadOnly: true}, mode)
+
+	past := time.Now().Add(-time.Minute)
+	_, err = client.AdminSetMaintenance(ctx, &api.MaintenanceMode{Enabled: true, ETA: &past})
+	s.ErrorContains(err, "400")
+
+	mode, err = client.AdminSetMaintenance(ctx, &api.MaintenanceMode{Enabled: false, Message: "ignored"})
+	s.NoError(err)
+	s.Equal(&api.MaintenanceMode{}, mode)
+	s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight"}, http.StatusCreated)
+
+	// Maintenance mode is reloaded from the environment on SIGHUP
+	s.T().Setenv("WHISPER_MAINTENANCE", "true")
+	s.T().Setenv("WHISPER_MAINTENANCE_MESSAGE", "migrating secrets")
+	s.T().Setenv("WHISPER_MAINTENANCE_READ_ONLY", "true")
+	s.T().Setenv("WHISPER_MAINTENANCE_ETA", eta.Format(time.RFC3339))
+	s.NoError(srv.Reload())
+
+	reloaded := srv.Maintenance()
+	s.True(reloaded.Enabled)
+	s.True(reloaded.ReadOnly)
+	s.Equal("migrating secrets", reloaded.Message)
+	s.Require().NotNil(reloaded.ETA)
+	s.WithinDuration(eta, *reloaded.ETA, time.Second)
+}
@@ -0,0 +1,136 @@
+/*
+Package metrics defines the Prometheus collectors that are used to observe the whisper
+service, including HTTP requests, secret lifecycle events, vault RPC latency, the
+time spent verifying argon2 derived keys, and the secrets cleaned up by the reaper. The collectors are always available to record
+observations but are only exposed when metrics are enabled in the configuration.
+*/
+package metrics
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Namespace of all of the whisper metrics.
+const Namespace = "whisper"
+
+// Config enables the metrics endpoint. If BindAddr is set, metrics are served on a
+// separate admin port rather than on the API server.
+type Config struct {
+	Enabled  bool   `default:"false" yaml:"enabled"`
+	BindAddr string `split_words:"true" required:"false" yaml:"bind_addr"`
+	Path     string `default:"/metrics" yaml:"path"`
+}
+
+func (c Config) Validate() error {
+	if c.Enabled && (c.Path == "" || c.Path[0] != '/') {
+		return errors.New("invalid configuration: metrics path must begin with a /")
+	}
+	return nil
+}
+
+// Secret lifecycle events that are counted by SecretEvents.
+const (
+	Created       = "created"
+	Fetched       = "fetched"
+	Destroyed     = "destroyed"
+	Expired       = "expired"
+	WrongPassword = "wrong_password"
+	SizeLimit     = "size_limit"
+	Exhausted     = "exhausted"
+	Orphaned      = "orphaned"
+)
+
+var (
+	// RequestsTotal counts the HTTP requests handled by the API by route and status.
+	RequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests by route, method, and status code.",
+	}, []string{"route", "method", "code"})
+
+	// RequestDuration is the latency of HTTP requests by route and status.
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests by route, method, and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	// SecretEvents counts secret lifecycle events such as created or fetched secrets.
+	SecretEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "secrets",
+		Name:      "events_total",
+		Help:      "Total number of secret lifecycle events by event type.",
+	}, []string{"event"})
+
+	// VaultLatency is the latency of the secret manager RPCs by method and status code.
+	VaultLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "vault",
+		Name:      "rpc_duration_seconds",
+		Help:      "Latency of secret manager RPCs by method and gRPC status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	// PasswordVerification is the time spent verifying argon2 derived keys.
+	PasswordVerification = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "passwd",
+		Name:      "verify_duration_seconds",
+		Help:      "Time spent verifying argon2 derived keys.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 10),
+	})
+
+	// StoredSecrets is the number of secrets in the vault when the reaper last ran.
+	StoredSecrets = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "reaper",
+		Name:      "stored_secrets",
+		Help:      "Number of secrets stored in the vault when the reaper last ran.",
+	})
+
+	// ReaperLastRun is the unix timestamp of the last successful run of the reaper.
+	ReaperLastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "reaper",
+		Name:      "last_run_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful run of the reaper.",
+	})
+)
+
+var (
+	registry *prometheus.Registry
+	setup    sync.Once
+)
+
+// Registry returns the registry that all of the whisper collectors are registered on,
+// creating it on the first call. The registry also includes process and Go collectors.
+func Registry() *prometheus.Registry {
+	setup.Do(func() {
+		registry = prometheus.NewRegistry()
+		registry.MustRegister(
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+			collectors.NewGoCollector(),
+			RequestsTotal,
+			RequestDuration,
+			SecretEvents,
+			VaultLatency,
+			PasswordVerification,
+			StoredSecrets,
+			ReaperLastRun,
+		)
+	})
+	return registry
+}
+
+// Secret increments the counter of the specified secret lifecycle event.
+func Secret(event string) {
+	SecretEvents.WithLabelValues(event).Inc()
+}
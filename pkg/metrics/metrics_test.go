@@ -0,0 +1,45 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rotationalio/whisper/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(metrics.Middleware())
+	router.GET("/v1/secrets/:token", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/secrets/supersecrettoken", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// The route pattern must be used as the label rather than the secret token
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.RequestsTotal.WithLabelValues("/v1/secrets/:token", http.MethodGet, "404")))
+
+	metrics.Secret(metrics.Created)
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.SecretEvents.WithLabelValues(metrics.Created)))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `whisper_http_requests_total{code="404",method="GET",route="/v1/secrets/:token"} 1`)
+	require.Contains(t, w.Body.String(), `whisper_secrets_events_total{event="created"} 1`)
+	require.NotContains(t, w.Body.String(), "supersecrettoken")
+}
+
+func TestConfig(t *testing.T) {
+	require.NoError(t, metrics.Config{}.Validate())
+	require.NoError(t, metrics.Config{Enabled: true, Path: "/metrics"}.Validate())
+	require.Error(t, metrics.Config{Enabled: true, Path: "metrics"}.Validate())
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Middleware records the count and latency of requests by route. The route is the
+// registered path pattern rather than the request URL so that secret tokens are never
+// used as label values; unmatched requests are recorded with an empty route.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		started := time.Now()
+		c.Next()
+
+		labels := []string{c.FullPath(), c.Request.Method, strconv.Itoa(c.Writer.Status())}
+		RequestsTotal.WithLabelValues(labels...).Inc()
+		RequestDuration.WithLabelValues(labels...).Observe(time.Since(started).Seconds())
+	}
+}
+
+// Handler returns the http handler that serves the metrics in the Prometheus format.
+func Handler() http.Handler {
+	reg := Registry()
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
+}
@@ -0,0 +1,45 @@
+package whisper_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/rotationalio/whisper/pkg"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/metrics"
+)
+
+func (s *WhisperTestSuite) TestMetrics() {
+	// Metrics are not served unless enabled
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	s.Equal(http.StatusNotFound, w.Code)
+
+	conf := s.conf
+	conf.Metrics = metrics.Config{Enabled: true, Path: "/metrics"}
+	srv, err := New(conf)
+	s.NoError(err)
+	srv.SetStatus(true, true)
+	router := srv.Routes()
+
+	created := testutil.ToFloat64(metrics.SecretEvents.WithLabelValues(metrics.Created))
+	fetched := testutil.ToFloat64(metrics.SecretEvents.WithLabelValues(metrics.Fetched))
+
+	// Create and fetch a secret to record the lifecycle events
+	prev := s.router
+	s.router = router
+	defer func() { s.router = prev }()
+	rep := s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight"}, http.StatusCreated)
+	s.sendFetchRequest(rep.Token, "", http.StatusOK)
+
+	s.Equal(created+1, testutil.ToFloat64(metrics.SecretEvents.WithLabelValues(metrics.Created)))
+	s.Equal(fetched+1, testutil.ToFloat64(metrics.SecretEvents.WithLabelValues(metrics.Fetched)))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), `whisper_http_requests_total{code="201",method="POST",route="/v1/secrets"}`)
+	s.Contains(w.Body.String(), `whisper_vault_rpc_duration_seconds_count{code="OK",method="CreateSecret"}`)
+	s.NotContains(w.Body.String(), rep.Token)
+}
@@ -0,0 +1,91 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rs/zerolog/log"
+)
+
+// Certificates holds the server certificate and reloads it when the certificate or key
+// file is modified, so that certificates can be rotated without restarting the server.
+type Certificates struct {
+	sync.RWMutex
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	modified [2]time.Time // modification times of the cert and key files when loaded
+}
+
+// LoadCertificates loads the certificate and key pair from the files.
+func LoadCertificates(certFile, keyFile string) (c *Certificates, err error) {
+	c = &Certificates{certFile: certFile, keyFile: keyFile}
+	if _, err = c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate returns the current certificate for the tls.Config.
+func (c *Certificates) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.cert, nil
+}
+
+// Reload the certificate if either file has been modified since it was last loaded.
+// If the new files cannot be loaded (e.g. because only one of them has been replaced so
+// far) an error is returned and the current certificate continues to be used.
+func (c *Certificates) Reload() (reloaded bool, err error) {
+	var modified [2]time.Time
+	for i, path := range []string{c.certFile, c.keyFile} {
+		var info os.FileInfo
+		if info, err = os.Stat(path); err != nil {
+			return false, fmt.Errorf("could not stat tls certificate: %w", err)
+		}
+		modified[i] = info.ModTime()
+	}
+
+	c.RLock()
+	unchanged := c.cert != nil && modified == c.modified
+	c.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	var cert tls.Certificate
+	if cert, err = tls.LoadX509KeyPair(c.certFile, c.keyFile); err != nil {
+		return false, fmt.Errorf("could not load tls certificate: %w", err)
+	}
+
+	c.Lock()
+	c.cert = &cert
+	c.modified = modified
+	c.Unlock()
+	return true, nil
+}
+
+// Watch checks the certificate files for changes on the interval until the context is
+// canceled, logging when the certificate is reloaded or cannot be loaded.
+func (c *Certificates) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if reloaded, err := c.Reload(); err != nil {
+				sentry.Error(nil).Err(err).Msg("could not reload tls certificate")
+			} else if reloaded {
+				log.Info().Str("cert", c.certFile).Msg("tls certificate reloaded")
+			}
+		}
+	}
+}
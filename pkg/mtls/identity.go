@@ -0,0 +1,85 @@
+package mtls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// ContextKeyIdentity is the key the client identity is stored with on the gin context.
+const ContextKeyIdentity = "client_identity"
+
+// Identity describes the verified certificate that a client connected with. Handlers
+// can authorize requests using any of the names in the certificate, the fingerprint
+// pins a specific certificate.
+type Identity struct {
+	Subject        string   `json:"subject"`
+	CommonName     string   `json:"common_name"`
+	Organization   []string `json:"organization,omitempty"`
+	DNSNames       []string `json:"dns_names,omitempty"`
+	EmailAddresses []string `json:"email_addresses,omitempty"`
+	URIs           []string `json:"uris,omitempty"`
+	Issuer         string   `json:"issuer"`
+	SerialNumber   string   `json:"serial_number"`
+	Fingerprint    string   `json:"fingerprint"` // hex encoded SHA-256 of the DER certificate
+}
+
+// NewIdentity creates the identity of the client from its certificate.
+func NewIdentity(cert *x509.Certificate) *Identity {
+	fingerprint := sha256.Sum256(cert.Raw)
+	id := &Identity{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		Organization:   cert.Subject.Organization,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Issuer:         cert.Issuer.String(),
+		SerialNumber:   cert.SerialNumber.String(),
+		Fingerprint:    hex.EncodeToString(fingerprint[:]),
+	}
+
+	for _, uri := range cert.URIs {
+		id.URIs = append(id.URIs, uri.String())
+	}
+	return id
+}
+
+// FromRequest returns the identity of the client if the client certificate was verified
+// against the client CA bundle, otherwise nil. Unverified certificates are ignored.
+func FromRequest(r *http.Request) *Identity {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return NewIdentity(r.TLS.VerifiedChains[0][0])
+}
+
+// Identify is gin middleware that stores the identity of verified clients on the gin
+// context and adds the client to the request-scoped logger. It must follow the
+// sentry.RequestID middleware so that the request logger is available.
+func Identify() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if id := FromRequest(c.Request); id != nil {
+			c.Set(ContextKeyIdentity, id)
+
+			ctx := c.Request.Context()
+			logger := zerolog.Ctx(ctx).With().Str("client", id.Subject).Logger()
+			c.Request = c.Request.WithContext(logger.WithContext(ctx))
+		}
+		c.Next()
+	}
+}
+
+// IdentityFromContext returns the identity set by the Identify middleware or nil if
+// the client did not connect with a verified certificate.
+func IdentityFromContext(c *gin.Context) *Identity {
+	if val, ok := c.Get(ContextKeyIdentity); ok {
+		if id, ok := val.(*Identity); ok {
+			return id
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+Package mtls configures the whisper server to terminate TLS itself rather than relying on
+a load balancer, optionally verifying client certificates against a CA bundle (mutual
+TLS). The server certificate is reloaded when the certificate files change so that it
+can be rotated without a restart, and the identities of verified clients are made
+available to handlers so that they can be used for authorization.
+*/
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config enables TLS when a certificate and key are specified. Client certificates are
+// verified if a CA bundle is specified.
+type Config struct {
+	CertFile          string        `split_words:"true" required:"false" yaml:"cert_file"`              // PEM encoded certificate chain of the server
+	KeyFile           string        `split_words:"true" required:"false" yaml:"key_file"`               // PEM encoded private key of the server
+	MinVersion        string        `split_words:"true" default:"1.2" yaml:"min_version"`               // either 1.2 or 1.3
+	CipherSuites      []string      `split_words:"true" required:"false" yaml:"cipher_suites"`          // names of the TLS 1.2 cipher suites; Go defaults if not set
+	ClientCAFile      string        `envconfig:"CLIENT_CA_FILE" required:"false" yaml:"client_ca_file"` // PEM encoded CA bundle to verify client certificates with
+	RequireClientCert bool          `split_words:"true" default:"false" yaml:"require_client_cert"`     // reject clients without a verified certificate
+	ReloadInterval    time.Duration `split_words:"true" default:"1m" yaml:"reload_interval"`            // how often to check the certificate files for changes
+}
+
+// Enabled returns true if the server should terminate TLS.
+func (c Config) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// VerifyClients returns true if client certificates are verified (mutual TLS).
+func (c Config) VerifyClients() bool {
+	return c.ClientCAFile != ""
+}
+
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		if c.VerifyClients() || c.RequireClientCert {
+			return errors.New("invalid configuration: tls cert and key files are required to verify client certificates")
+		}
+		return nil
+	}
+
+	if c.CertFile == "" || c.KeyFile == "" {
+		return errors.New("invalid configuration: both the tls cert and key files are required")
+	}
+
+	if _, err := c.Version(); err != nil {
+		return err
+	}
+
+	if _, err := c.Ciphers(); err != nil {
+		return err
+	}
+
+	if c.RequireClientCert && !c.VerifyClients() {
+		return errors.New("invalid configuration: a client ca bundle is required to require client certificates")
+	}
+
+	if c.ReloadInterval <= 0 {
+		return errors.New("invalid configuration: tls reload interval must be positive")
+	}
+	return nil
+}
+
+// Version returns the minimum TLS version; versions older than TLS 1.2 are not allowed.
+func (c Config) Version() (uint16, error) {
+	switch c.MinVersion {
+	case "1.2", "":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("invalid configuration: unsupported tls min version %q (must be 1.2 or 1.3)", c.MinVersion)
+	}
+}
+
+// Ciphers returns the IDs of the configured cipher suites or nil to use the defaults.
+// Only the cipher suites that Go considers secure can be configured. Cipher suites
+// are not configurable in TLS 1.3 so they only apply to TLS 1.2 connections.
+func (c Config) Ciphers() (ids []uint16, err error) {
+	if len(c.CipherSuites) == 0 {
+		return nil, nil
+	}
+
+	suites := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+
+	ids = make([]uint16, 0, len(c.CipherSuites))
+	for _, name := range c.CipherSuites {
+		id, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid configuration: unknown or insecure tls cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// TLSConfig returns the server TLS configuration, which gets the server certificate
+// from the certificates so that it is reloaded when the files change.
+func (c Config) TLSConfig(certs *Certificates) (conf *tls.Config, err error) {
+	conf = &tls.Config{GetCertificate: certs.GetCertificate}
+	if conf.MinVersion, err = c.Version(); err != nil {
+		return nil, err
+	}
+
+	if conf.CipherSuites, err = c.Ciphers(); err != nil {
+		return nil, err
+	}
+
+	if c.VerifyClients() {
+		if conf.ClientCAs, err = LoadCertPool(c.ClientCAFile); err != nil {
+			return nil, err
+		}
+
+		conf.ClientAuth = tls.VerifyClientCertIfGiven
+		if c.RequireClientCert {
+			conf.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return conf, nil
+}
+
+// LoadCertPool reads a PEM encoded bundle of CA certificates.
+func LoadCertPool(path string) (pool *x509.CertPool, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return nil, fmt.Errorf("could not read ca bundle: %w", err)
+	}
+
+	pool = x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in ca bundle %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,243 @@
+package mtls_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rotationalio/whisper/pkg/mtls"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, mtls.Config{}.Validate(), "tls should not be required")
+
+	conf := mtls.Config{CertFile: "server.pem", KeyFile: "server.key", MinVersion: "1.2", ReloadInterval: time.Minute}
+	require.NoError(t, conf.Validate())
+	require.True(t, conf.Enabled())
+	require.False(t, conf.VerifyClients())
+
+	testCases := []func(*mtls.Config){
+		func(c *mtls.Config) { c.KeyFile = "" },
+		func(c *mtls.Config) { c.MinVersion = "1.1" },
+		func(c *mtls.Config) { c.CipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"} },
+		func(c *mtls.Config) { c.RequireClientCert = true },
+		func(c *mtls.Config) { c.ReloadInterval = 0 },
+		func(c *mtls.Config) { c.CertFile, c.KeyFile, c.ClientCAFile = "", "", "ca.pem" },
+	}
+
+	for i, modify := range testCases {
+		invalid := conf
+		modify(&invalid)
+		require.Error(t, invalid.Validate(), "test case %d did not error", i)
+	}
+
+	conf.MinVersion = "1.3"
+	conf.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}
+	conf.ClientCAFile = "ca.pem"
+	conf.RequireClientCert = true
+	require.NoError(t, conf.Validate())
+
+	version, err := conf.Version()
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS13), version)
+
+	ciphers, err := conf.Ciphers()
+	require.NoError(t, err)
+	require.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}, ciphers)
+}
+
+func TestCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t)
+	certFile, keyFile := ca.issue(t, dir, "server", "localhost")
+
+	certs, err := mtls.LoadCertificates(certFile, keyFile)
+	require.NoError(t, err)
+
+	orig, err := certs.GetCertificate(nil)
+	require.NoError(t, err)
+
+	reloaded, err := certs.Reload()
+	require.NoError(t, err)
+	require.False(t, reloaded, "unchanged files should not be reloaded")
+
+	// Replace the certificate, ensuring the modification times change
+	ca.issue(t, dir, "server", "localhost")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+
+	reloaded, err = certs.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+
+	cert, err := certs.GetCertificate(nil)
+	require.NoError(t, err)
+	require.NotEqual(t, orig.Certificate, cert.Certificate)
+
+	// An invalid certificate is not used
+	require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0600))
+	_, err = certs.Reload()
+	require.Error(t, err)
+
+	current, err := certs.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, cert, current)
+
+	_, err = mtls.LoadCertificates(certFile, keyFile)
+	require.Error(t, err)
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t)
+	certFile, keyFile := ca.issue(t, dir, "server", "localhost")
+	clientCert, clientKey := ca.issue(t, dir, "client", "")
+
+	conf := mtls.Config{
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		MinVersion:        "1.2",
+		ClientCAFile:      ca.write(t, dir),
+		RequireClientCert: true,
+		ReloadInterval:    time.Minute,
+	}
+	require.NoError(t, conf.Validate())
+
+	certs, err := mtls.LoadCertificates(certFile, keyFile)
+	require.NoError(t, err)
+
+	// Handlers can authorize the client using its identity
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(mtls.Identify())
+	router.GET("/whoami", func(c *gin.Context) {
+		c.JSON(http.StatusOK, mtls.IdentityFromContext(c))
+	})
+
+	// The test server would replace the certificate so serve using the TLS config
+	tlsConf, err := conf.TLSConfig(certs)
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(router)
+	srv.Listener = tls.NewListener(srv.Listener, tlsConf)
+	srv.Start()
+	defer srv.Close()
+	url := strings.Replace(srv.URL, "http://", "https://", 1)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	require.NoError(t, err)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cert}},
+		},
+	}
+
+	rep, err := client.Get(url + "/whoami")
+	require.NoError(t, err)
+	defer rep.Body.Close()
+	require.Equal(t, http.StatusOK, rep.StatusCode)
+
+	id := &mtls.Identity{}
+	require.NoError(t, json.NewDecoder(rep.Body).Decode(id))
+	require.Equal(t, "client", id.CommonName)
+	require.Equal(t, "CN=client,O=Whisper Test", id.Subject)
+	require.Equal(t, []string{"Whisper Test"}, id.Organization)
+	require.Len(t, id.Fingerprint, 64)
+
+	// Clients without a certificate are rejected
+	client.Transport.(*http.Transport).TLSClientConfig.Certificates = nil
+	client.CloseIdleConnections()
+	_, err = client.Get(url + "/whoami")
+	require.Error(t, err)
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Whisper Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+// Write the CA certificate to the directory and return its path.
+func (ca *testCA) write(t *testing.T, dir string) string {
+	path := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0600))
+	return path
+}
+
+// Issue a certificate signed by the CA for a server if a host is specified, otherwise
+// for a client, and write the certificate and key to the directory.
+func (ca *testCA) issue(t *testing.T, dir, name, host string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name, Organization: []string{"Whisper Test"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	if host != "" {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		tmpl.DNSNames = []string{host}
+		tmpl.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1)}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".pem")
+	keyFile = filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
@@ -0,0 +1,258 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// EmailConfig configures the delivery of events to the creator of the secret by email.
+type EmailConfig struct {
+	Enabled      bool          `default:"false" yaml:"enabled"`
+	Host         string        `required:"false" yaml:"host"`
+	Port         int           `default:"587" yaml:"port"`
+	Username     string        `required:"false" yaml:"username"`
+	Password     string        `required:"false" yaml:"password"`
+	From         string        `required:"false" yaml:"from"`
+	ImplicitTLS  bool          `split_words:"true" default:"false" yaml:"implicit_tls"`
+	TemplateFile string        `split_words:"true" required:"false" yaml:"template_file"`
+	QueueSize    int           `split_words:"true" default:"256" yaml:"queue_size"`
+	Workers      int           `default:"2" yaml:"workers"`
+	MaxRetries   int           `split_words:"true" default:"3" yaml:"max_retries"`
+	Backoff      time.Duration `default:"5s" yaml:"backoff"`
+	Timeout      time.Duration `default:"30s" yaml:"timeout"`
+}
+
+// Validate the email configuration if email notifications are enabled.
+func (c EmailConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Host == "" || c.Port < 1 {
+		return errors.New("invalid configuration: email notifications require an smtp host and port")
+	}
+
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("invalid configuration: could not parse email from address: %s", err)
+	}
+
+	if c.QueueSize < 1 || c.Workers < 1 {
+		return errors.New("invalid configuration: email queue size and workers must be positive")
+	}
+	return nil
+}
+
+// ParseAddress validates an email address that notifications will be sent to, returning
+// just the address portion so that names and other data cannot inject headers.
+func ParseAddress(email string) (_ string, err error) {
+	var addr *mail.Address
+	if addr, err = mail.ParseAddress(email); err != nil {
+		return "", ErrInvalidEmail
+	}
+	return addr.Address, nil
+}
+
+// The default template for email notifications, which must define both a subject and a
+// body template. A custom template file can be configured with the same definitions.
+const defaultEmailTemplate = `{{ define "subject" }}Your Whisper secret was {{ .Action }}{{ end }}
+{{ define "body" }}Hello,
+
+This is a notification from Whisper that the secret you created was {{ .Action }} at
+{{ .Timestamp.UTC.Format "Jan 2, 2006 15:04 MST" }}. The secret has been accessed {{ .Accesses }} time(s).
+{{ if .Destroyed }}
+The secret has been destroyed and can no longer be fetched.
+{{ end }}
+Secret ID: {{ .Secret }}
+
+You received this email because your address was attached to the secret when it was
+created. Your address was stored only with the secret and is deleted along with it.
+{{ end }}`
+
+// Describes the event in the subject and body of the email notification.
+var emailActions = map[EventType]string{
+	SecretFetched:   "fetched",
+	SecretExhausted: "fetched for the last time",
+	SecretLocked:    "locked after too many incorrect passwords",
+	SecretDestroyed: "destroyed",
+	SecretExpired:   "destroyed after it expired",
+}
+
+// The data that is passed to the email templates.
+type emailData struct {
+	Event
+	Action    string
+	Destroyed bool
+}
+
+// Mailer sends email notifications to the address the creator of the secret provided.
+// Like webhooks, emails are queued in a bounded buffer and sent by a pool of workers.
+type Mailer struct {
+	conf  EmailConfig
+	tmpl  *template.Template
+	queue *queue
+}
+
+// Ensure Mailer implements the Notifier interface
+var _ Notifier = &Mailer{}
+
+// NewMailer creates the email notifier, parsing the templates and starting its workers.
+func NewMailer(conf EmailConfig) (mailer *Mailer, err error) {
+	if err = conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	mailer = &Mailer{conf: conf}
+	if conf.TemplateFile != "" {
+		if mailer.tmpl, err = template.ParseFiles(conf.TemplateFile); err != nil {
+			return nil, fmt.Errorf("could not parse email template: %s", err)
+		}
+	} else {
+		mailer.tmpl = template.Must(template.New("email").Parse(defaultEmailTemplate))
+	}
+
+	for _, name := range []string{"subject", "body"} {
+		if mailer.tmpl.Lookup(name) == nil {
+			return nil, fmt.Errorf("email template must define %q", name)
+		}
+	}
+
+	mailer.queue = newQueue("email", conf.QueueSize, conf.Workers, conf.MaxRetries, conf.Backoff, mailer.send)
+	return mailer, nil
+}
+
+// Notify enqueues the event for delivery if it has an email address.
+func (m *Mailer) Notify(event Event) error {
+	if event.Email == "" {
+		return nil
+	}
+	return m.queue.enqueue(event)
+}
+
+// Shutdown stops accepting new events and waits for the queued emails to be sent.
+func (m *Mailer) Shutdown(ctx context.Context) error {
+	return m.queue.shutdown(ctx)
+}
+
+// Compose the email message with headers from the templates.
+func (m *Mailer) compose(event Event) (_ []byte, err error) {
+	data := emailData{
+		Event:     event,
+		Action:    emailActions[event.Type],
+		Destroyed: event.Type != SecretFetched,
+	}
+
+	subject := &strings.Builder{}
+	if err = m.tmpl.ExecuteTemplate(subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("could not execute subject template: %s", err)
+	}
+
+	msg := &bytes.Buffer{}
+	fmt.Fprintf(msg, "From: %s\r\n", m.conf.From)
+	fmt.Fprintf(msg, "To: %s\r\n", event.Email)
+	fmt.Fprintf(msg, "Subject: %s\r\n", strings.Join(strings.Fields(subject.String()), " "))
+	fmt.Fprintf(msg, "Date: %s\r\n", event.Timestamp.Format(time.RFC1123Z))
+	fmt.Fprint(msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprint(msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	body := &bytes.Buffer{}
+	if err = m.tmpl.ExecuteTemplate(body, "body", data); err != nil {
+		return nil, fmt.Errorf("could not execute body template: %s", err)
+	}
+
+	// SMTP requires CRLF line endings in the message body
+	msg.WriteString(strings.ReplaceAll(strings.TrimSpace(body.String()), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return msg.Bytes(), nil
+}
+
+// Send the email to the SMTP server, returning true if the error is transient.
+func (m *Mailer) send(event Event) (retry bool, err error) {
+	var msg []byte
+	if msg, err = m.compose(event); err != nil {
+		return false, err
+	}
+
+	addr := net.JoinHostPort(m.conf.Host, strconv.Itoa(m.conf.Port))
+	dialer := &net.Dialer{Timeout: m.conf.Timeout}
+	tlsConf := &tls.Config{ServerName: m.conf.Host, MinVersion: tls.VersionTLS12}
+
+	var conn net.Conn
+	if m.conf.ImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConf)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return true, fmt.Errorf("could not connect to smtp server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(m.conf.Timeout))
+
+	var client *smtp.Client
+	if client, err = smtp.NewClient(conn, m.conf.Host); err != nil {
+		conn.Close()
+		return true, fmt.Errorf("could not create smtp client: %w", err)
+	}
+	defer client.Close()
+
+	if !m.conf.ImplicitTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(tlsConf); err != nil {
+				return smtpRetry(err), err
+			}
+		}
+	}
+
+	if m.conf.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", m.conf.Username, m.conf.Password, m.conf.Host)); err != nil {
+			return smtpRetry(err), err
+		}
+	}
+
+	var from *mail.Address
+	if from, err = mail.ParseAddress(m.conf.From); err != nil {
+		return false, err
+	}
+
+	if err = client.Mail(from.Address); err != nil {
+		return smtpRetry(err), err
+	}
+
+	if err = client.Rcpt(event.Email); err != nil {
+		return smtpRetry(err), err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return smtpRetry(err), err
+	}
+
+	if _, err = w.Write(msg); err != nil {
+		return true, err
+	}
+
+	if err = w.Close(); err != nil {
+		return smtpRetry(err), err
+	}
+	return false, client.Quit()
+}
+
+// Transient SMTP errors have 4xx codes; other protocol errors are permanent. Network
+// errors that are not SMTP replies can also be retried.
+func smtpRetry(err error) bool {
+	var perr *textproto.Error
+	if errors.As(err, &perr) {
+		return perr.Code < 500
+	}
+	return true
+}
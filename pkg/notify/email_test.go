@@ -0,0 +1,177 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/notify"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailConfig(t *testing.T) {
+	conf := notify.EmailConfig{Enabled: false}
+	require.NoError(t, conf.Validate(), "disabled email should not be validated")
+
+	conf.Enabled = true
+	require.EqualError(t, conf.Validate(), "invalid configuration: email notifications require an smtp host and port")
+
+	conf.Host, conf.Port = "smtp.example.com", 587
+	require.ErrorContains(t, conf.Validate(), "could not parse email from address")
+
+	conf.From = "Whisper <whisper@example.com>"
+	conf.QueueSize, conf.Workers = 1, 1
+	require.NoError(t, conf.Validate())
+}
+
+func TestParseAddress(t *testing.T) {
+	addr, err := notify.ParseAddress("Jane Doe <jane@example.com>")
+	require.NoError(t, err)
+	require.Equal(t, "jane@example.com", addr)
+
+	_, err = notify.ParseAddress("jane@example.com\r\nBcc: eve@example.com")
+	require.ErrorIs(t, err, notify.ErrInvalidEmail)
+}
+
+func TestMailer(t *testing.T) {
+	server := newFakeSMTP(t)
+	defer server.Close()
+
+	mailer, err := notify.NewMailer(notify.EmailConfig{
+		Enabled:    true,
+		Host:       "127.0.0.1",
+		Port:       server.Port(),
+		From:       "Whisper <whisper@example.com>",
+		QueueSize:  4,
+		Workers:    1,
+		MaxRetries: 1,
+		Backoff:    time.Millisecond,
+		Timeout:    5 * time.Second,
+	})
+	require.NoError(t, err)
+
+	// Events without an email address are ignored
+	require.NoError(t, mailer.Notify(notify.Event{Type: notify.SecretFetched}))
+
+	err = mailer.Notify(notify.Event{
+		Type:      notify.SecretExhausted,
+		Secret:    notify.SecretID("token"),
+		Accesses:  1,
+		Timestamp: time.Now(),
+		Email:     "jane@example.com",
+	})
+	require.NoError(t, err)
+	require.NoError(t, mailer.Shutdown(context.Background()))
+
+	select {
+	case msg := <-server.messages:
+		require.Equal(t, "whisper@example.com", msg.from)
+		require.Equal(t, []string{"jane@example.com"}, msg.to)
+		require.Contains(t, msg.data, "Subject: Your Whisper secret was fetched for the last time\r\n")
+		require.Contains(t, msg.data, "To: jane@example.com\r\n")
+		require.Contains(t, msg.data, notify.SecretID("token"))
+		require.Contains(t, msg.data, "The secret has been destroyed")
+		require.NotContains(t, msg.data, "token\r\n")
+	case <-time.After(5 * time.Second):
+		t.Fatal("email was not delivered")
+	}
+}
+
+type fakeMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP is a minimal in-process SMTP server that records the messages it receives.
+type fakeSMTP struct {
+	t        *testing.T
+	ln       net.Listener
+	messages chan fakeMessage
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeSMTP{t: t, ln: ln, messages: make(chan fakeMessage, 8)}
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTP) Port() int {
+	_, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+func (s *fakeSMTP) Close() error {
+	return s.ln.Close()
+}
+
+func (s *fakeSMTP) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	msg := fakeMessage{}
+	reply("220 localhost fake smtp ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimSpace(line)
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(line[5:], "FROM:"), "<>")
+			reply("250 OK")
+		case "RCPT":
+			msg.to = append(msg.to, strings.Trim(strings.TrimPrefix(line[5:], "TO:"), "<>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			data := &strings.Builder{}
+			for {
+				dline, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dline == ".\r\n" {
+					break
+				}
+				data.WriteString(dline)
+			}
+			msg.data = data.String()
+			s.messages <- msg
+			msg = fakeMessage{}
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
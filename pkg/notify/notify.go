@@ -0,0 +1,65 @@
+/*
+Package notify delivers secret lifecycle events (e.g. when a secret is fetched or
+destroyed) to the creator of the secret. Events never contain the secret payload, the
+password, or the token used to access the secret; secrets are identified by a hash of
+their token that the creator can compute themselves.
+*/
+package notify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// EventType describes what happened to the secret.
+type EventType string
+
+const (
+	SecretFetched   EventType = "secret.fetched"   // the secret was successfully fetched
+	SecretExhausted EventType = "secret.exhausted" // the last allowed access destroyed the secret
+	SecretLocked    EventType = "secret.locked"    // too many incorrect passwords destroyed the secret
+	SecretDestroyed EventType = "secret.destroyed" // the secret was explicitly destroyed
+	SecretExpired   EventType = "secret.expired"   // the secret expired before all of its accesses were used
+)
+
+// Standard errors for error type checking
+var (
+	ErrQueueFull        = errors.New("notification queue is full")
+	ErrQueueClosed      = errors.New("notification queue is closed")
+	ErrCallbackNotAllow = errors.New("callback url is not in the allowed domains")
+	ErrInvalidCallback  = errors.New("could not parse callback url")
+	ErrInvalidEmail     = errors.New("could not parse notification email address")
+)
+
+// Event is sent to notifiers when something happens to a secret. The routing fields
+// describe where the event should be delivered and are never serialized.
+type Event struct {
+	Type      EventType `json:"type"`      // the type of the event
+	Secret    string    `json:"secret"`    // the hex encoded SHA-256 hash of the secret token
+	Accesses  int       `json:"accesses"`  // the number of times the secret has been accessed
+	Timestamp time.Time `json:"timestamp"` // when the event occurred
+
+	// Routing information that is used by notifiers but is not delivered.
+	Callback string `json:"-"` // the webhook URL to POST the event to
+	Email    string `json:"-"` // the email address to send the event to
+}
+
+// Notifier delivers events asynchronously to an external channel.
+type Notifier interface {
+	// Notify enqueues the event for delivery and must not block.
+	Notify(Event) error
+
+	// Shutdown stops accepting events and waits for queued events to be delivered
+	// until the context is done.
+	Shutdown(context.Context) error
+}
+
+// SecretID returns the hex encoded SHA-256 hash of the token, which is used to identify
+// the secret in events without disclosing the token.
+func SecretID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
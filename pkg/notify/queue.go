@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// deliverFunc sends a single event, returning true if a failed delivery can be retried.
+type deliverFunc func(Event) (retry bool, err error)
+
+// queue is a bounded buffer of events that are delivered by a pool of workers, which
+// retry failed deliveries with exponential backoff. It is shared by the notifiers so
+// that no notifier ever blocks the request that generated the event.
+type queue struct {
+	sync.RWMutex
+	name    string
+	events  chan Event
+	done    chan struct{}
+	stop    sync.Once
+	wg      sync.WaitGroup
+	closed  bool
+	retries int
+	backoff time.Duration
+	deliver deliverFunc
+}
+
+// Create the queue and start the delivery workers.
+func newQueue(name string, size, workers, retries int, backoff time.Duration, deliver deliverFunc) *queue {
+	q := &queue{
+		name:    name,
+		events:  make(chan Event, size),
+		done:    make(chan struct{}),
+		retries: retries,
+		backoff: backoff,
+		deliver: deliver,
+	}
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	return q
+}
+
+// Enqueue the event without blocking; if the queue is full the event is dropped.
+func (q *queue) enqueue(event Event) error {
+	q.RLock()
+	defer q.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.events <- event:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Stop accepting new events and wait for the queued events to be delivered. If the
+// context is done before delivery is complete, pending retries are abandoned.
+func (q *queue) shutdown(ctx context.Context) error {
+	q.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	q.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		q.stop.Do(func() { close(q.done) })
+		return ctx.Err()
+	}
+}
+
+func (q *queue) worker() {
+	defer q.wg.Done()
+	for event := range q.events {
+		if err := q.send(event); err != nil {
+			log.Warn().Err(err).Str("notifier", q.name).Str("event", string(event.Type)).Msg("could not deliver notification")
+		}
+	}
+}
+
+// Send the event, retrying retryable failures with backoff.
+func (q *queue) send(event Event) (err error) {
+	backoff := q.backoff
+	for attempt := 0; attempt <= q.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-q.done:
+				return fmt.Errorf("shutdown before delivery: %w", err)
+			}
+		}
+
+		var retry bool
+		if retry, err = q.deliver(event); err == nil || !retry {
+			return err
+		}
+	}
+	return fmt.Errorf("delivery failed after %d attempts: %w", q.retries+1, err)
+}
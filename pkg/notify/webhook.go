@@ -0,0 +1,187 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers set on webhook deliveries so that receivers can verify the event.
+const (
+	HeaderSignature = "X-Whisper-Signature"
+	HeaderTimestamp = "X-Whisper-Timestamp"
+	HeaderEvent     = "X-Whisper-Event"
+)
+
+// WebhookConfig configures the delivery of events to callback URLs.
+type WebhookConfig struct {
+	Enabled        bool          `default:"false" yaml:"enabled"`
+	AllowedDomains []string      `split_words:"true" yaml:"allowed_domains"`
+	SigningKey     string        `split_words:"true" yaml:"signing_key"`
+	AllowInsecure  bool          `split_words:"true" default:"false" yaml:"allow_insecure"`
+	QueueSize      int           `split_words:"true" default:"1024" yaml:"queue_size"`
+	Workers        int           `default:"4" yaml:"workers"`
+	MaxRetries     int           `split_words:"true" default:"5" yaml:"max_retries"`
+	Backoff        time.Duration `default:"1s" yaml:"backoff"`
+	Timeout        time.Duration `default:"10s" yaml:"timeout"`
+}
+
+// Validate the webhook configuration if webhooks are enabled.
+func (c WebhookConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.SigningKey == "" {
+		return errors.New("invalid configuration: webhooks require a signing key")
+	}
+
+	if len(c.AllowedDomains) == 0 {
+		return errors.New("invalid configuration: webhooks require at least one allowed domain")
+	}
+
+	if c.QueueSize < 1 || c.Workers < 1 {
+		return errors.New("invalid configuration: webhook queue size and workers must be positive")
+	}
+	return nil
+}
+
+// Allowed returns an error if the callback URL is not a valid URL in one of the allowed
+// domains. A domain allows itself and any of its subdomains.
+func (c WebhookConfig) Allowed(callback string) (err error) {
+	var u *url.URL
+	if u, err = url.Parse(callback); err != nil {
+		return ErrInvalidCallback
+	}
+
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !c.AllowInsecure {
+			return ErrCallbackNotAllow
+		}
+	default:
+		return ErrInvalidCallback
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return ErrInvalidCallback
+	}
+
+	for _, domain := range c.AllowedDomains {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "."))
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return nil
+		}
+	}
+	return ErrCallbackNotAllow
+}
+
+// Webhooks POSTs signed JSON events to the callback URL specified by the creator of the
+// secret. Events are queued in a bounded buffer and delivered by a pool of workers that
+// retry failed deliveries with exponential backoff.
+type Webhooks struct {
+	conf   WebhookConfig
+	client *http.Client
+	queue  *queue
+}
+
+// Ensure Webhooks implements the Notifier interface
+var _ Notifier = &Webhooks{}
+
+// NewWebhooks creates the webhook notifier and starts its delivery workers.
+func NewWebhooks(conf WebhookConfig) (hooks *Webhooks, err error) {
+	if err = conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Redirects are not followed since an allowed callback could otherwise redirect the
+	// webhook to a host that is not allowed, such as an internal address.
+	hooks = &Webhooks{
+		conf: conf,
+		client: &http.Client{
+			Timeout: conf.Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	hooks.queue = newQueue("webhooks", conf.QueueSize, conf.Workers, conf.MaxRetries, conf.Backoff, hooks.post)
+	return hooks, nil
+}
+
+// Notify enqueues the event for delivery if it has a callback URL. If the queue is full
+// the event is dropped and an error is returned rather than blocking the caller.
+func (w *Webhooks) Notify(event Event) error {
+	if event.Callback == "" {
+		return nil
+	}
+	return w.queue.enqueue(event)
+}
+
+// Shutdown stops accepting new events and waits for the queued events to be delivered.
+// If the context is done before delivery is complete, pending retries are abandoned.
+func (w *Webhooks) Shutdown(ctx context.Context) error {
+	return w.queue.shutdown(ctx)
+}
+
+// Post the event to the callback, returning true if the delivery should be retried.
+func (w *Webhooks) post(event Event) (retry bool, err error) {
+	var body []byte
+	if body, err = json.Marshal(event); err != nil {
+		return false, err
+	}
+
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodPost, event.Callback, bytes.NewReader(body)); err != nil {
+		return false, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "Whisper-Webhook/1.0")
+	req.Header.Set(HeaderEvent, string(event.Type))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(w.conf.SigningKey, timestamp, body))
+
+	var rep *http.Response
+	if rep, err = w.client.Do(req); err != nil {
+		return true, err
+	}
+	rep.Body.Close()
+
+	switch {
+	case rep.StatusCode >= 200 && rep.StatusCode < 300:
+		return false, nil
+	case rep.StatusCode == http.StatusTooManyRequests || rep.StatusCode >= 500:
+		return true, fmt.Errorf("[%d] %s", rep.StatusCode, rep.Status)
+	default:
+		return false, fmt.Errorf("[%d] %s", rep.StatusCode, rep.Status)
+	}
+}
+
+// Sign computes the signature header value of the webhook body, which is the hex
+// encoded HMAC-SHA256 of the timestamp and body joined by a period.
+func Sign(key, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify is used by webhook receivers to check that the signature of the event is valid.
+func Verify(key, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(key, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,189 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/notify"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookConfig(t *testing.T) {
+	conf := notify.WebhookConfig{Enabled: false}
+	require.NoError(t, conf.Validate(), "disabled webhooks should not be validated")
+
+	conf.Enabled = true
+	require.EqualError(t, conf.Validate(), "invalid configuration: webhooks require a signing key")
+
+	conf.SigningKey = "supersecretkey"
+	require.EqualError(t, conf.Validate(), "invalid configuration: webhooks require at least one allowed domain")
+
+	conf.AllowedDomains = []string{"example.com", ".rotational.io"}
+	require.EqualError(t, conf.Validate(), "invalid configuration: webhook queue size and workers must be positive")
+
+	conf.QueueSize, conf.Workers = 8, 1
+	require.NoError(t, conf.Validate())
+
+	testCases := []struct {
+		callback string
+		err      error
+	}{
+		{"https://example.com/hooks", nil},
+		{"https://hooks.example.com/whisper", nil},
+		{"https://EXAMPLE.com:8443/hooks", nil},
+		{"https://api.rotational.io/hooks", nil},
+		{"https://notexample.com/hooks", notify.ErrCallbackNotAllow},
+		{"https://example.com.evil.io/hooks", notify.ErrCallbackNotAllow},
+		{"http://example.com/hooks", notify.ErrCallbackNotAllow},
+		{"ftp://example.com/hooks", notify.ErrInvalidCallback},
+		{"https:///hooks", notify.ErrInvalidCallback},
+	}
+
+	for _, tc := range testCases {
+		require.ErrorIs(t, conf.Allowed(tc.callback), tc.err, tc.callback)
+	}
+
+	conf.AllowInsecure = true
+	require.NoError(t, conf.Allowed("http://example.com/hooks"))
+}
+
+func TestWebhooks(t *testing.T) {
+	var calls int32
+	events := make(chan notify.Event, 1)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first delivery to ensure that retries happen
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, string(notify.SecretFetched), r.Header.Get(notify.HeaderEvent))
+		require.True(t, notify.Verify("supersecretkey", r.Header.Get(notify.HeaderTimestamp), body, r.Header.Get(notify.HeaderSignature)))
+
+		// The payload must only contain the event fields
+		data := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(body, &data))
+		require.Len(t, data, 4)
+
+		event := notify.Event{}
+		require.NoError(t, json.Unmarshal(body, &event))
+		events <- event
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	hooks, err := notify.NewWebhooks(notify.WebhookConfig{
+		Enabled:        true,
+		AllowedDomains: []string{"127.0.0.1"},
+		SigningKey:     "supersecretkey",
+		AllowInsecure:  true,
+		QueueSize:      1,
+		Workers:        1,
+		MaxRetries:     2,
+		Backoff:        time.Millisecond,
+		Timeout:        time.Second,
+	})
+	require.NoError(t, err)
+
+	// Events without a callback are ignored
+	require.NoError(t, hooks.Notify(notify.Event{Type: notify.SecretFetched}))
+
+	err = hooks.Notify(notify.Event{
+		Type:      notify.SecretFetched,
+		Secret:    notify.SecretID("token"),
+		Accesses:  1,
+		Timestamp: time.Now(),
+		Callback:  ts.URL + "/hooks",
+	})
+	require.NoError(t, err)
+
+	select {
+	case event := <-events:
+		require.Equal(t, notify.SecretFetched, event.Type)
+		require.Equal(t, notify.SecretID("token"), event.Secret)
+		require.Empty(t, event.Callback)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	require.NoError(t, hooks.Shutdown(context.Background()))
+	require.ErrorIs(t, hooks.Notify(notify.Event{Callback: ts.URL}), notify.ErrQueueClosed)
+}
+
+func TestWebhooksQueueFull(t *testing.T) {
+	block := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	defer close(block)
+
+	hooks, err := notify.NewWebhooks(notify.WebhookConfig{
+		Enabled:        true,
+		AllowedDomains: []string{"127.0.0.1"},
+		SigningKey:     "supersecretkey",
+		AllowInsecure:  true,
+		QueueSize:      1,
+		Workers:        1,
+		Timeout:        time.Second,
+	})
+	require.NoError(t, err)
+
+	// The worker takes the first event and blocks, the second fills the queue
+	event := notify.Event{Type: notify.SecretDestroyed, Callback: ts.URL}
+	require.NoError(t, hooks.Notify(event))
+	require.Eventually(t, func() bool { return hooks.Notify(event) == nil }, time.Second, 5*time.Millisecond)
+	require.ErrorIs(t, hooks.Notify(event), notify.ErrQueueFull)
+}
+
+func TestWebhooksRedirect(t *testing.T) {
+	// The redirect target is not an allowed callback and must never be called
+	var redirected int32
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&redirected, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer internal.Close()
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Redirect(w, r, internal.URL, http.StatusTemporaryRedirect)
+	}))
+	defer ts.Close()
+
+	hooks, err := notify.NewWebhooks(notify.WebhookConfig{
+		Enabled:        true,
+		AllowedDomains: []string{"127.0.0.1"},
+		SigningKey:     "supersecretkey",
+		AllowInsecure:  true,
+		QueueSize:      1,
+		Workers:        1,
+		MaxRetries:     2,
+		Backoff:        time.Millisecond,
+		Timeout:        time.Second,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, hooks.Notify(notify.Event{Type: notify.SecretFetched, Callback: ts.URL}))
+	require.NoError(t, hooks.Shutdown(context.Background()))
+
+	// The redirect is treated as a failed delivery that is not retried
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	require.Zero(t, atomic.LoadInt32(&redirected))
+}
+
+func TestSecretID(t *testing.T) {
+	require.Equal(t, "3c469e9d6c5875d37a43f353d4f88e61fcf812c66eee3457465a40b0da4153e0", notify.SecretID("token"))
+}
@@ -0,0 +1,15 @@
+package whisper
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+)
+
+// OpenAPI returns the OpenAPI document that describes the API. The document is generated
+// from the request and reply types of the v1 API, so it includes the Slack and admin
+// routes even if they are not enabled on this server.
+func (s *Server) OpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, v1.OpenAPI(Version()))
+}
@@ -0,0 +1,73 @@
+package whisper_test
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/rotationalio/whisper/pkg"
+	"github.com/rotationalio/whisper/pkg/admin"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/metrics"
+	"github.com/rotationalio/whisper/pkg/slack"
+)
+
+// Routes that are not part of the API and are not described by the specification.
+var undocumented = map[string]bool{
+	"GET /":        true,
+	"GET /healthz": true,
+	"GET /livez":   true,
+	"GET /readyz":  true,
+	"GET /metrics": true,
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+func (s *WhisperTestSuite) TestOpenAPI() {
+	out := &api.Document{}
+	s.sendJSON(http.MethodGet, "/v1/openapi.json", "", nil, http.StatusOK, out)
+	s.Equal(api.OpenAPIVersion, out.OpenAPI)
+	s.Equal(Version(), out.Info.Version)
+	s.NotNil(out.Operation(http.MethodPost, "/v1/secrets"))
+	s.Contains(out.Components.Schemas, "CreateSecretRequest")
+}
+
+func (s *WhisperTestSuite) TestOpenAPIRoutes() {
+	// Enable all of the optional routes so that they are checked against the spec
+	conf := s.conf
+	conf.Admin = admin.Config{Token: "Ww0Ni0tPXsxkVJTg1JIIUKbDRAn1oUhK"}
+	conf.Metrics = metrics.Config{Enabled: true, Path: "/metrics"}
+	conf.Slack = slack.Config{
+		SigningSecret: testSigningSecret,
+		BotToken:      "xoxb-test",
+		WebURL:        "https://whisper.example.com",
+	}
+
+	srv, err := New(conf)
+	s.NoError(err)
+
+	router, ok := srv.Routes().(*gin.Engine)
+	s.True(ok, "expected the server routes to be a gin engine")
+
+	doc := api.OpenAPI(Version())
+	documented := make(map[string]bool)
+	for _, route := range router.Routes() {
+		key := route.Method + " " + route.Path
+		if undocumented[key] {
+			continue
+		}
+
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		s.NotNil(doc.Operation(route.Method, path), "%s is not described by the OpenAPI specification", key)
+		documented[route.Method+" "+path] = true
+	}
+
+	// Every operation in the specification must be handled by the server
+	for path, item := range doc.Paths {
+		for method := range item {
+			key := strings.ToUpper(method) + " " + path
+			s.True(documented[key], "%s is described by the OpenAPI specification but is not a route", key)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package whisper
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/config"
+)
+
+// Policy returns the limits that are enforced when secrets are created so that clients
+// can validate secrets before they are submitted. The policy can change on reload.
+func (s *Server) Policy(c *gin.Context) {
+	c.JSON(http.StatusOK, policyReply(s.settings()))
+}
+
+// policyReply describes the policy of the configuration to clients.
+func policyReply(conf config.Config) *v1.PolicyReply {
+	return &v1.PolicyReply{
+		DefaultLifetime:     v1.Duration(conf.DefaultLifetime),
+		DefaultAccesses:     conf.DefaultAccesses,
+		MinLifetime:         v1.Duration(conf.Policy.MinLifetime),
+		MaxLifetime:         v1.Duration(conf.Policy.MaxLifetime),
+		MaxAccesses:         conf.Policy.MaxAccesses,
+		AllowUnlimited:      conf.Policy.AllowUnlimited,
+		MaxSize:             conf.Policy.MaxSize,
+		RequirePassword:     conf.Policy.RequirePassword,
+		MinPasswordStrength: conf.Policy.MinPasswordStrength,
+	}
+}
+
+// checkLimits returns a bad request error if the lifetime or accesses requested for a
+// secret are not allowed by the policy. Zero values are replaced by the defaults, which
+// are always allowed by the policy.
+func checkLimits(conf config.Config, lifetime v1.Duration, accesses int) error {
+	if lifetime != 0 {
+		if err := conf.Policy.CheckLifetime(time.Duration(lifetime)); err != nil {
+			return BadRequest(Coded(v1.ErrTTLInvalid, err))
+		}
+	}
+
+	if accesses != 0 {
+		if err := conf.Policy.CheckAccesses(accesses); err != nil {
+			return BadRequest(Coded(v1.ErrAccessesInvalid, err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+Package policy defines the limits that the server enforces on the secrets that users
+create, such as how long secrets can live, how many times they can be fetched, how large
+they can be, and how strong their passwords must be. The active policy is published so
+that clients can check a secret before it is submitted.
+*/
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxSecretSize is the largest payload that can be stored in Google Secret Manager.
+const MaxSecretSize = 65536
+
+// Errors returned by CheckPassword so that the reason can be identified.
+var (
+	ErrPasswordRequired = errors.New("a password is required")
+	ErrPasswordTooWeak  = errors.New("password is too weak")
+)
+
+// Config is the policy enforced on the secrets created by users. Accesses that are
+// negative mean that the secret can be fetched an unlimited number of times until it
+// expires.
+type Config struct {
+	MinLifetime         time.Duration `split_words:"true" default:"1m" yaml:"min_lifetime"`
+	MaxLifetime         time.Duration `split_words:"true" default:"0" yaml:"max_lifetime"`       // the maximum lifetime of a secret; 0 for no limit
+	MaxAccesses         int           `split_words:"true" default:"0" yaml:"max_accesses"`       // the maximum number of accesses; 0 for no limit
+	AllowUnlimited      bool          `split_words:"true" default:"true" yaml:"allow_unlimited"` // allow secrets with unlimited (negative) accesses
+	MaxSize             int           `split_words:"true" default:"65536" yaml:"max_size"`       // the maximum size of a secret in bytes
+	RequirePassword     bool          `split_words:"true" default:"false" yaml:"require_password"`
+	MinPasswordStrength int           `split_words:"true" default:"0" yaml:"min_password_strength"` // see Strength for the scores from 0 to 4
+}
+
+func (c Config) Validate() error {
+	if c.MinLifetime <= 0 {
+		return errors.New("invalid configuration: policy min lifetime must be positive")
+	}
+
+	if c.MaxLifetime < 0 {
+		return errors.New("invalid configuration: policy max lifetime must be zero (no limit) or positive")
+	}
+
+	if c.MaxLifetime > 0 && c.MaxLifetime < c.MinLifetime {
+		return errors.New("invalid configuration: policy max lifetime cannot be less than the min lifetime")
+	}
+
+	if c.MaxAccesses < 0 {
+		return errors.New("invalid configuration: policy max accesses must be zero (no limit) or positive")
+	}
+
+	if c.MaxSize <= 0 || c.MaxSize > MaxSecretSize {
+		return fmt.Errorf("invalid configuration: policy max size must be between 1 and %d bytes", MaxSecretSize)
+	}
+
+	if c.MinPasswordStrength < 0 || c.MinPasswordStrength > MaxStrength {
+		return fmt.Errorf("invalid configuration: policy min password strength must be between 0 and %d", MaxStrength)
+	}
+	return nil
+}
+
+// CheckLifetime returns an error if the lifetime of a secret is outside of the policy.
+func (c Config) CheckLifetime(lifetime time.Duration) error {
+	switch {
+	case lifetime < c.MinLifetime:
+		return fmt.Errorf("lifetime must be at least %s", c.MinLifetime)
+	case c.MaxLifetime > 0 && lifetime > c.MaxLifetime:
+		return fmt.Errorf("lifetime cannot be longer than %s", c.MaxLifetime)
+	}
+	return nil
+}
+
+// CheckAccesses returns an error if the number of accesses is not allowed by the policy.
+func (c Config) CheckAccesses(accesses int) error {
+	switch {
+	case accesses < 0 && !c.AllowUnlimited:
+		return errors.New("secrets with unlimited accesses are not allowed")
+	case accesses < 0:
+		return nil
+	case accesses == 0:
+		return errors.New("secrets must be accessible at least once")
+	case c.MaxAccesses > 0 && accesses > c.MaxAccesses:
+		return fmt.Errorf("secrets cannot be accessed more than %d times", c.MaxAccesses)
+	}
+	return nil
+}
+
+// CheckSize returns an error if the secret is larger than the policy allows.
+func (c Config) CheckSize(secret string) error {
+	if len(secret) > c.MaxSize {
+		return fmt.Errorf("secret cannot be larger than %d bytes", c.MaxSize)
+	}
+	return nil
+}
+
+// CheckPassword returns an error if the password is required and missing or if it is
+// not as strong as the policy requires.
+func (c Config) CheckPassword(password string) error {
+	if password == "" {
+		if c.RequirePassword {
+			return ErrPasswordRequired
+		}
+		return nil
+	}
+
+	if strength := Strength(password); strength < c.MinPasswordStrength {
+		return fmt.Errorf("%w: strength %d of %d is required", ErrPasswordTooWeak, strength, c.MinPasswordStrength)
+	}
+	return nil
+}
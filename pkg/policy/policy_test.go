@@ -0,0 +1,96 @@
+package policy_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/policy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	conf := policy.Config{MinLifetime: time.Minute, MaxLifetime: time.Hour, MaxSize: 1024}
+	require.NoError(t, conf.Validate())
+
+	testCases := []func(*policy.Config){
+		func(c *policy.Config) { c.MinLifetime = 0 },
+		func(c *policy.Config) { c.MaxLifetime = time.Second },
+		func(c *policy.Config) { c.MaxLifetime = -1 },
+		func(c *policy.Config) { c.MaxAccesses = -1 },
+		func(c *policy.Config) { c.MaxSize = 0 },
+		func(c *policy.Config) { c.MaxSize = policy.MaxSecretSize + 1 },
+		func(c *policy.Config) { c.MinPasswordStrength = policy.MaxStrength + 1 },
+	}
+
+	for i, modify := range testCases {
+		invalid := conf
+		modify(&invalid)
+		require.Error(t, invalid.Validate(), "test case %d did not error", i)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	conf := policy.Config{
+		MinLifetime:         time.Minute,
+		MaxLifetime:         time.Hour,
+		MaxAccesses:         5,
+		MaxSize:             16,
+		MinPasswordStrength: 2,
+	}
+
+	require.NoError(t, conf.CheckLifetime(time.Minute))
+	require.NoError(t, conf.CheckLifetime(time.Hour))
+	require.EqualError(t, conf.CheckLifetime(time.Second), "lifetime must be at least 1m0s")
+	require.EqualError(t, conf.CheckLifetime(2*time.Hour), "lifetime cannot be longer than 1h0m0s")
+
+	// A max lifetime of zero does not limit the lifetime
+	unlimited := conf
+	unlimited.MaxLifetime = 0
+	require.NoError(t, unlimited.Validate())
+	require.NoError(t, unlimited.CheckLifetime(10000*time.Hour))
+	require.Error(t, unlimited.CheckLifetime(time.Second))
+
+	require.NoError(t, conf.CheckAccesses(1))
+	require.NoError(t, conf.CheckAccesses(5))
+	require.EqualError(t, conf.CheckAccesses(6), "secrets cannot be accessed more than 5 times")
+	require.EqualError(t, conf.CheckAccesses(-1), "secrets with unlimited accesses are not allowed")
+	require.Error(t, conf.CheckAccesses(0))
+
+	conf.AllowUnlimited = true
+	conf.MaxAccesses = 0
+	require.NoError(t, conf.CheckAccesses(-1))
+	require.NoError(t, conf.CheckAccesses(1000))
+
+	require.NoError(t, conf.CheckSize(strings.Repeat("a", 16)))
+	require.EqualError(t, conf.CheckSize(strings.Repeat("a", 17)), "secret cannot be larger than 16 bytes")
+
+	require.NoError(t, conf.CheckPassword(""))
+	require.NoError(t, conf.CheckPassword("correct horse battery staple"))
+	require.EqualError(t, conf.CheckPassword("password"), "password is too weak: strength 1 of 2 is required")
+
+	conf.RequirePassword = true
+	require.EqualError(t, conf.CheckPassword(""), "a password is required")
+}
+
+func TestStrength(t *testing.T) {
+	testCases := []struct {
+		password string
+		expected int
+	}{
+		{"", 0},
+		{"aaaaaaaaaaaaaaaa", 0},
+		{"abc", 0},
+		{"password", 1},
+		{"Password1", 2},
+		{"correct horse battery staple", 4},
+		{"Tr0ub4dor&3", 3},
+		{"xK#9vL!2qW$7", 3},
+		{"8Hs!4pQ@zV^7mN*2", 3},
+		{"8Hs!4pQ@zV^7mN*2kR#5tY&9", 4},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, policy.Strength(tc.password), "unexpected strength for %q", tc.password)
+	}
+}
@@ -0,0 +1,80 @@
+package policy
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// MaxStrength is the score of the strongest passwords.
+const MaxStrength = 4
+
+// Upper bounds of the estimated entropy in bits for each strength score.
+var strengthBits = [MaxStrength]float64{28, 36, 60, 128}
+
+// Strength scores a password from 0 (very weak) to 4 (very strong) by estimating its
+// entropy from its length and the classes of characters that it uses. Characters that
+// repeat the previous character do not add to the estimate, so "aaaaaaaaaaaa" is weak.
+// The estimate is deliberately simple; it cannot detect dictionary words or patterns,
+// so the score is an upper bound of the strength of the password.
+func Strength(password string) int {
+	var (
+		pool                               float64
+		lower, upper, digit, symbol, other bool
+	)
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case r < unicode.MaxASCII && (unicode.IsPunct(r) || unicode.IsSymbol(r) || r == ' '):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		pool += 33
+	}
+	if other {
+		pool += 100
+	}
+
+	if pool == 0 {
+		return 0
+	}
+
+	bits := float64(distinct(password)) * math.Log2(pool)
+	for score, bound := range strengthBits {
+		if bits < bound {
+			return score
+		}
+	}
+	return MaxStrength
+}
+
+// Count the characters of the password, ignoring characters that repeat the previous one.
+func distinct(password string) (n int) {
+	var prev rune = -1
+	for _, r := range strings.TrimSpace(password) {
+		if r != prev {
+			n++
+		}
+		prev = r
+	}
+	return n
+}
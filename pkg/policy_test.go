@@ -0,0 +1,85 @@
+package whisper_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/rotationalio/whisper/pkg"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/policy"
+)
+
+func (s *WhisperTestSuite) TestPolicy() {
+	// Use a separate server so that the strict policy does not affect the other tests
+	conf := s.conf
+	conf.Policy = policy.Config{
+		MinLifetime:         time.Minute,
+		MaxLifetime:         24 * time.Hour,
+		MaxAccesses:         10,
+		MaxSize:             64,
+		RequirePassword:     true,
+		MinPasswordStrength: 2,
+	}
+	srv, err := New(conf)
+	s.NoError(err)
+	srv.SetStatus(true, true)
+
+	prev := s.router
+	s.router = srv.Routes()
+	defer func() { s.router = prev }()
+
+	// The policy is published so that clients can check secrets before they are created
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+
+	client, err := api.New(server.URL)
+	s.NoError(err)
+
+	rep, err := client.Policy(context.Background())
+	s.NoError(err)
+	s.Equal(&api.PolicyReply{
+		DefaultLifetime:     api.Duration(conf.DefaultLifetime),
+		DefaultAccesses:     conf.DefaultAccesses,
+		MinLifetime:         api.Duration(time.Minute),
+		MaxLifetime:         api.Duration(24 * time.Hour),
+		MaxAccesses:         10,
+		MaxSize:             64,
+		RequirePassword:     true,
+		MinPasswordStrength: 2,
+	}, rep)
+
+	// Secrets that are not allowed by the policy are rejected with a clear error
+	const password = "correct horse battery staple"
+	testCases := []struct {
+		req  *api.CreateSecretRequest
+		err  string
+		code api.ErrorCode
+	}{
+		{&api.CreateSecretRequest{Secret: "the eagle flies at midnight", Password: password, Lifetime: api.Duration(48 * time.Hour)}, "lifetime cannot be longer than 24h0m0s", api.ErrTTLInvalid},
+		{&api.CreateSecretRequest{Secret: "the eagle flies at midnight", Password: password, Lifetime: api.Duration(time.Second)}, "lifetime must be at least 1m0s", api.ErrTTLInvalid},
+		{&api.CreateSecretRequest{Secret: "the eagle flies at midnight", Password: password, Accesses: 11}, "secrets cannot be accessed more than 10 times", api.ErrAccessesInvalid},
+		{&api.CreateSecretRequest{Secret: "the eagle flies at midnight", Password: password, Accesses: -1}, "secrets with unlimited accesses are not allowed", api.ErrAccessesInvalid},
+		{&api.CreateSecretRequest{Secret: strings.Repeat("a", 65), Password: password}, "secret cannot be larger than 64 bytes", api.ErrPayloadTooLarge},
+		{&api.CreateSecretRequest{Secret: "the eagle flies at midnight"}, "a password is required", api.ErrPasswordRequired},
+		{&api.CreateSecretRequest{Secret: "the eagle flies at midnight", Password: "password"}, "password is too weak: strength 1 of 2 is required", api.ErrPasswordTooWeak},
+	}
+
+	for _, tc := range testCases {
+		out := &api.Reply{}
+		s.sendJSON(http.MethodPost, "/v1/secrets", "", tc.req, http.StatusBadRequest, out)
+		s.Equal(tc.err, out.Error)
+		s.Equal(tc.code, out.Code)
+	}
+
+	// Requests for secrets are also limited by the policy
+	out := &api.Reply{}
+	s.sendJSON(http.MethodPost, "/v1/requests", "", &api.RequestSecretRequest{Accesses: 20}, http.StatusBadRequest, out)
+	s.Equal("secrets cannot be accessed more than 10 times", out.Error)
+	s.Equal(api.ErrAccessesInvalid, out.Code)
+
+	// Secrets within the policy can be created
+	s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight", Password: password, Accesses: 10, Lifetime: api.Duration(time.Hour)}, http.StatusCreated)
+}
@@ -0,0 +1,109 @@
+package whisper
+
+import (
+	"context"
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/audit"
+	"github.com/rotationalio/whisper/pkg/logger"
+	"github.com/rotationalio/whisper/pkg/metrics"
+	"github.com/rotationalio/whisper/pkg/notify"
+	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rotationalio/whisper/pkg/vault"
+	"github.com/rs/zerolog/log"
+)
+
+// reaper runs Reap in the background on the configured interval until it is stopped.
+type reaper struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start the reaper in a background go routine; the first run is after one interval.
+func (s *Server) startReaper() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &reaper{cancel: cancel, done: make(chan struct{})}
+	s.reaper = r
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(s.conf.Reaper.Interval)
+		defer ticker.Stop()
+
+		log.Info().Dur("interval", s.conf.Reaper.Interval).Msg("secret reaper started")
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.Reap(ctx); err != nil && ctx.Err() == nil {
+					sentry.Error(nil).Err(err).Msg("could not reap secrets")
+				}
+			}
+		}
+	}()
+}
+
+// Stop the reaper, interrupting a run in progress, and wait for it to return.
+func (r *reaper) stop(ctx context.Context) error {
+	r.cancel()
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reap destroys the expired, exhausted, and orphaned secrets in the vault, recording
+// each destroyed secret in the metrics and the audit log, notifying the creators of
+// expired and exhausted secrets, and logging a summary. It is called periodically by
+// the reaper and is primarily exposed for testing purposes.
+func (s *Server) Reap(ctx context.Context) (stats *vault.ReapStats, err error) {
+	started := time.Now()
+	if stats, err = s.vault.Reap(ctx, s.conf.Reaper.GracePeriod, s.reaped); err != nil {
+		return stats, err
+	}
+
+	metrics.StoredSecrets.Set(float64(stats.Listed - stats.Reaped()))
+	metrics.ReaperLastRun.SetToCurrentTime()
+
+	log.Info().
+		Int("listed", stats.Listed).
+		Int("expired", stats.Expired).
+		Int("exhausted", stats.Exhausted).
+		Int("orphaned", stats.Orphaned).
+		Int("failed", stats.Failed).
+		Dur("duration", time.Since(started)).
+		Msg("secret reaper finished")
+	return stats, nil
+}
+
+// Called for each secret that was destroyed by the reaper. Orphaned secrets do not have
+// valid metadata so their creators cannot be notified.
+func (s *Server) reaped(token string, reason vault.Reason, meta *vault.SecretContext) {
+	switch reason {
+	case vault.Expired:
+		metrics.Secret(metrics.Expired)
+		s.dispatch(notify.SecretExpired, token, meta)
+	case vault.Exhausted:
+		metrics.Secret(metrics.Exhausted)
+		s.dispatch(notify.SecretExhausted, token, meta)
+	case vault.Orphaned:
+		metrics.Secret(metrics.Orphaned)
+	}
+
+	if s.auditor == nil {
+		return
+	}
+
+	event := audit.SecretDestroyed
+	if reason == vault.Expired {
+		event = audit.SecretExpired
+	}
+
+	rec := audit.Record{Event: event, Secret: logger.HashToken(token), Reason: "reaped: " + string(reason)}
+	if err := s.auditor.Record(rec); err != nil {
+		sentry.Error(nil).Err(err).Str("event", string(event)).Msg("could not write audit record")
+	}
+}
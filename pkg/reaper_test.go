@@ -0,0 +1,25 @@
+package whisper_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/metrics"
+)
+
+func (s *WhisperTestSuite) TestReap() {
+	rep := s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight"}, http.StatusCreated)
+
+	// Valid secrets are never reaped
+	stats, err := s.api.Reap(context.Background())
+	s.NoError(err)
+	s.GreaterOrEqual(stats.Listed, 1)
+	s.Zero(stats.Failed)
+	s.Equal(float64(stats.Listed-stats.Reaped()), testutil.ToFloat64(metrics.StoredSecrets))
+	s.NotZero(testutil.ToFloat64(metrics.ReaperLastRun))
+
+	out := s.sendFetchRequest(rep.Token, "", http.StatusOK)
+	s.Equal("the eagle flies at midnight", out.Secret)
+}
@@ -0,0 +1,116 @@
+package whisper
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/rotationalio/whisper/pkg/config"
+	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Reload the configuration from the config file and the environment and apply the
+// settings that can be changed while the server is running. The new configuration is
+// validated before any settings are changed. Changes to any other settings are logged
+// and ignored until the server is restarted. The maintenance mode is only replaced if
+// the maintenance settings changed, so a reload does not undo maintenance mode that was
+// set using the admin API. The TLS certificate is also reloaded if its files changed.
+// Reload is called when the server receives SIGHUP.
+func (s *Server) Reload() (err error) {
+	var next config.Config
+	if next, err = config.Load(s.conf.Path()); err != nil {
+		return err
+	}
+
+	s.Lock()
+	prev := s.live
+	conf, applied, rejected := prev.Reload(next)
+	s.live = conf
+	s.Unlock()
+
+	for _, setting := range rejected {
+		log.Warn().Str("setting", setting).Msg("setting cannot be changed without a restart")
+	}
+
+	var maintenance bool
+	for _, setting := range applied {
+		log.Info().
+			Str("setting", setting).
+			Interface("from", value(prev, setting)).
+			Interface("to", value(conf, setting)).
+			Msg("setting reloaded")
+
+		switch {
+		case setting == "LogLevel":
+			zerolog.SetGlobalLevel(conf.GetLogLevel())
+		case setting == "AllowOrigins":
+			handler := cors.New(corsConfig(conf.AllowOrigins))
+			s.Lock()
+			s.cors = handler
+			s.Unlock()
+		case strings.HasPrefix(setting, "Maintenance"):
+			maintenance = true
+		}
+	}
+
+	if maintenance {
+		s.SetMaintenance(maintenanceMode(conf))
+	}
+
+	// Reload the tls certificate now rather than waiting for the files to be checked
+	if s.certs != nil {
+		if reloaded, err := s.certs.Reload(); err != nil {
+			sentry.Error(nil).Err(err).Msg("could not reload tls certificate")
+		} else if reloaded {
+			log.Info().Str("cert", s.conf.TLS.CertFile).Msg("tls certificate reloaded")
+		}
+	}
+
+	log.Info().Int("applied", len(applied)).Int("rejected", len(rejected)).Msg("server configuration reloaded")
+	return nil
+}
+
+// CORS is middleware that allows the front-end to make cross-origin requests. The
+// handler is replaced when the allowed origins are reloaded.
+func (s *Server) CORS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s.RLock()
+		handler := s.cors
+		s.RUnlock()
+		handler(c)
+	}
+}
+
+// settings returns the current configuration, including settings changed by a reload.
+func (s *Server) settings() config.Config {
+	s.RLock()
+	defer s.RUnlock()
+	return s.live
+}
+
+func corsConfig(origins []string) cors.Config {
+	return cors.Config{
+		AllowOrigins:     origins,
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-CSRF-TOKEN", "sentry-trace", "baggage", "traceparent", "tracestate", sentry.HeaderRequestID},
+		ExposeHeaders:    []string{sentry.HeaderRequestID},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}
+}
+
+// Returns the value of a setting by its field path (e.g. "Policy.MaxSize") for logging;
+// reloadable settings are never secret.
+func value(conf config.Config, setting string) interface{} {
+	field := reflect.ValueOf(conf)
+	for _, name := range strings.Split(setting, ".") {
+		if field = field.FieldByName(name); !field.IsValid() {
+			return nil
+		}
+	}
+	return field.Interface()
+}
@@ -0,0 +1,72 @@
+package whisper_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/rotationalio/whisper/pkg"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/config"
+)
+
+func (s *WhisperTestSuite) TestReload() {
+	path := filepath.Join(s.T().TempDir(), "whisper.yaml")
+	s.NoError(os.WriteFile(path, []byte("default_lifetime: 1h\n"), 0600))
+
+	// Use a separate server so that reloaded settings do not affect the other tests
+	conf, err := config.Load(path)
+	s.NoError(err)
+	conf.Google.Testing = true
+	srv, err := New(conf)
+	s.NoError(err)
+	srv.SetStatus(true, true)
+
+	prev := s.router
+	s.router = srv.Routes()
+	defer func() { s.router = prev }()
+
+	rep := s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight"}, http.StatusCreated)
+	s.WithinDuration(time.Now().Add(time.Hour), rep.Expires, time.Minute)
+	s.Equal(http.StatusForbidden, s.preflight("https://whisper.rotational.io"))
+
+	// Reload the defaults and the policy from the file and the origins from the environment
+	s.NoError(os.WriteFile(path, []byte("default_lifetime: 2h\ndefault_accesses: 2\npolicy:\n  max_size: 1024\n"), 0600))
+	s.T().Setenv("WHISPER_ALLOW_ORIGINS", "http://localhost:3000,https://whisper.rotational.io")
+
+	// Settings that cannot be changed while the server is running are not reloaded
+	s.T().Setenv("WHISPER_BIND_ADDR", "127.0.0.1:8312")
+	s.NoError(srv.Reload())
+
+	rep = s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight"}, http.StatusCreated)
+	s.WithinDuration(time.Now().Add(2*time.Hour), rep.Expires, time.Minute)
+	s.Equal(http.StatusNoContent, s.preflight("https://whisper.rotational.io"))
+
+	out := s.sendFetchRequest(rep.Token, "", http.StatusOK)
+	s.False(out.Destroyed, "the reloaded default accesses should be used")
+
+	reply := &api.Reply{}
+	s.sendJSON(http.MethodPost, "/v1/secrets", "", &api.CreateSecretRequest{Secret: strings.Repeat("a", 2048)}, http.StatusBadRequest, reply)
+	s.Equal(api.ErrPayloadTooLarge, reply.Code, "the reloaded policy should be used")
+
+	// An invalid configuration is not applied
+	s.NoError(os.WriteFile(path, []byte("default_lifetime: -1h\n"), 0600))
+	s.Error(srv.Reload())
+
+	rep = s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight"}, http.StatusCreated)
+	s.WithinDuration(time.Now().Add(2*time.Hour), rep.Expires, time.Minute)
+}
+
+// Send a CORS preflight request from the origin and return the status code.
+func (s *WhisperTestSuite) preflight(origin string) int {
+	req := httptest.NewRequest(http.MethodOptions, "/v1/secrets", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w.Code
+}
@@ -0,0 +1,259 @@
+package whisper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/audit"
+	"github.com/rotationalio/whisper/pkg/metrics"
+	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rotationalio/whisper/pkg/tracing"
+	"github.com/rotationalio/whisper/pkg/vault"
+	"github.com/rs/zerolog/log"
+)
+
+// RequestSecret handles an incoming RequestSecretRequest and creates a one-time upload
+// slot that someone else can submit a secret into. The token is shared with the person
+// who will respond to the request and the owner token is kept by the requester, who
+// must supply it as the password to fetch the response.
+func (s *Server) RequestSecret(c *gin.Context) {
+	// Parse incoming JSON data from the client request
+	var req v1.RequestSecretRequest
+	if err := c.ShouldBind(&req); err != nil {
+		sentry.Warn(c).Err(err).Msg("could not bind request")
+		c.JSON(http.StatusBadRequest, ErrorReply(c, BadRequest("invalid secret request")))
+		return
+	}
+
+	rep, err := s.requestSecret(requestContext(c), &req)
+	if err != nil {
+		code := errorStatus(err)
+		if code == http.StatusInternalServerError {
+			sentry.Error(c).Err(err).Msg("could not create secret request")
+		}
+		c.JSON(code, ErrorReply(c, err))
+		return
+	}
+
+	// Return successful reply back to the user
+	c.JSON(http.StatusCreated, rep)
+}
+
+// requestSecret creates the secret request in the vault and audits it. It is shared by
+// the REST and gRPC APIs; use errorStatus to convert errors.
+func (s *Server) requestSecret(ctx context.Context, req *v1.RequestSecretRequest) (_ *v1.RequestSecretReply, err error) {
+	// The response to the request must be allowed by the policy
+	conf := s.settings()
+	if err = checkLimits(conf, req.Lifetime, req.Accesses); err != nil {
+		return nil, err
+	}
+
+	// Make a random URL to store the secret request in
+	var token, owner string
+	if token, err = s.GenerateUniqueURL(ctx); err != nil {
+		return nil, fmt.Errorf("could not generate unique token for secret request: %w", err)
+	}
+
+	// Make the owner token that only the requester will know
+	if owner, err = generateOwnerToken(); err != nil {
+		return nil, fmt.Errorf("could not generate owner token for secret request: %w", err)
+	}
+
+	// Create the secret context
+	meta := s.vault.With(token)
+	meta.MaxFailures = conf.PasswordAttempts
+	meta.Created = time.Now()
+
+	// The owner token is stored as a derived key in the same way as a password
+	_, span := tracing.Start(ctx, "passwd.CreateDerivedKey")
+	err = meta.SetPassword(owner)
+	tracing.End(span, err, "could not create derived key")
+	if err != nil {
+		return nil, fmt.Errorf("could not create derived key: %w", err)
+	}
+
+	// Compute the number of accesses for the response
+	if req.Accesses == 0 {
+		meta.Accesses = conf.DefaultAccesses
+	} else {
+		meta.Accesses = req.Accesses
+	}
+
+	// Compute the expiration time of both the request and the response
+	if req.Lifetime == v1.Duration(0) {
+		meta.Expires = meta.Created.Add(conf.DefaultLifetime)
+	} else {
+		meta.Expires = meta.Created.Add(time.Duration(req.Lifetime))
+	}
+
+	// Create the secret request in the vault.
+	if err = meta.NewRequest(ctx); err != nil {
+		if errors.Is(err, vault.ErrTimeToLive) {
+			return nil, BadRequest(err)
+		}
+		return nil, fmt.Errorf("could not create new secret request in vault: %w", err)
+	}
+
+	s.record(ctx, audit.SecretCreated, token, "secret request")
+	return &v1.RequestSecretReply{
+		Token:   token,
+		Owner:   owner,
+		Expires: meta.Expires,
+	}, nil
+}
+
+// RespondSecret handles an incoming RespondSecretRequest, storing the secret into the
+// upload slot created by RequestSecret. No password is required since the token is the
+// capability to respond; however a request can only be responded to once.
+func (s *Server) RespondSecret(c *gin.Context) {
+	// Parse incoming JSON data from the client request
+	var req v1.RespondSecretRequest
+	if err := c.ShouldBind(&req); err != nil {
+		sentry.Warn(c).Err(err).Msg("could not bind request")
+		c.JSON(http.StatusBadRequest, ErrorReply(c, BadRequest("invalid respond secret request")))
+		return
+	}
+
+	rep, err := s.respondSecret(requestContext(c), c.Param("token"), &req)
+	if err != nil {
+		code := errorStatus(err)
+		if code == http.StatusInternalServerError {
+			sentry.Error(c).Err(err).Msg("could not respond to secret request")
+		}
+		c.JSON(code, ErrorReply(c, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, rep)
+}
+
+// respondSecret stores the secret in response to the secret request and audits it. It
+// is shared by the REST and gRPC APIs; use errorStatus to convert errors.
+func (s *Server) respondSecret(ctx context.Context, token string, req *v1.RespondSecretRequest) (_ *v1.RespondSecretReply, err error) {
+	if err = s.settings().Policy.CheckSize(req.Secret); err != nil {
+		return nil, BadRequest(Coded(v1.ErrPayloadTooLarge, err))
+	}
+
+	// Load the secret request metadata from the vault
+	meta := s.vault.With(token)
+	if err = meta.Load(ctx, false); err != nil {
+		if errors.Is(err, vault.ErrSecretNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("could not load secret request: %w", err)
+	}
+
+	// Do not disclose the existence of secrets that are not requests
+	if !meta.Request {
+		return nil, vault.ErrSecretNotFound
+	}
+
+	meta.Filename = req.Filename
+	meta.IsBase64 = req.IsBase64
+	meta.Archive = req.Archive
+	if meta.Files, err = archiveFiles(req.Secret, req.Archive, req.IsBase64, s.settings().Policy.MaxSize); err != nil {
+		return nil, err
+	}
+
+	if meta.ContentType, meta.Size, meta.SHA256, err = describe(req.Secret, req.Filename, req.IsBase64); err != nil {
+		return nil, err
+	}
+
+	if err = meta.Respond(ctx, req.Secret); err != nil {
+		if errors.Is(err, vault.ErrFileSizeLimit) {
+			return nil, BadRequest(err)
+		}
+		return nil, err
+	}
+
+	log.Ctx(ctx).Debug().Msg("secret request fulfilled")
+	metrics.Secret(metrics.Created)
+	s.record(ctx, audit.SecretCreated, token, "secret request fulfilled")
+	return &v1.RespondSecretReply{
+		Fulfilled: true,
+		Expires:   meta.Expires,
+	}, nil
+}
+
+// FetchResponse handles an incoming request from the requester to retrieve the secret
+// submitted in response to their secret request. The owner token must be supplied in
+// the Authorization header in the same manner as a password.
+func (s *Server) FetchResponse(c *gin.Context) {
+	owner := ParseBearerToken(c.GetHeader("Authorization"))
+	rep, err := s.fetchResponse(requestContext(c), c.Param("token"), owner)
+	if err != nil {
+		code := errorStatus(err)
+		if code == http.StatusInternalServerError {
+			sentry.Error(c).Err(err).Msg("could not fetch secret request response")
+		}
+		c.JSON(code, ErrorReply(c, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, rep)
+}
+
+// fetchResponse retrieves the response to the secret request with the owner token and
+// audits the fetch. It is shared by the REST and gRPC APIs; use errorStatus to convert
+// errors.
+func (s *Server) fetchResponse(ctx context.Context, token, owner string) (_ *v1.FetchSecretReply, err error) {
+	meta := s.vault.With(token)
+	log.Ctx(ctx).Debug().Bool("authorization", owner != "").Msg("beginning fetch response")
+
+	// Load the metadata first to ensure this is a secret request
+	if err = meta.Load(ctx, false); err != nil {
+		if errors.Is(err, vault.ErrSecretNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("could not load secret request: %w", err)
+	}
+
+	if !meta.Request {
+		return nil, vault.ErrSecretNotFound
+	}
+
+	// Attempt to retrieve the response from the database
+	var (
+		secret    string
+		destroyed bool
+	)
+	secret, destroyed, err = meta.Fetch(ctx, owner)
+	s.recordFetch(ctx, token, destroyed, err)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.Secret(metrics.Fetched)
+	return &v1.FetchSecretReply{
+		Secret:      secret,
+		Filename:    meta.Filename,
+		IsBase64:    meta.IsBase64,
+		Created:     meta.Created,
+		Accesses:    meta.Retrievals,
+		Destroyed:   destroyed,
+		Archive:     meta.Archive,
+		Files:       meta.Files,
+		ContentType: meta.ContentType,
+		Size:        meta.Size,
+		SHA256:      meta.SHA256,
+	}, nil
+}
+
+// Length of the owner token in bytes before it is base64 encoded.
+const ownerTokenLength = 32
+
+// Create a random URL-safe owner token for a secret request.
+func generateOwnerToken() (_ string, err error) {
+	buf := make([]byte, ownerTokenLength)
+	if _, err = rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,83 @@
+package whisper_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/api/v1"
+)
+
+func (s *WhisperTestSuite) TestSecretRequestFlow() {
+	// Create the secret request
+	rep := &api.RequestSecretReply{}
+	s.sendJSON(http.MethodPost, "/v1/requests", "", &api.RequestSecretRequest{Lifetime: api.Duration(30 * time.Minute)}, http.StatusCreated, rep)
+	s.NotEmpty(rep.Token)
+	s.NotEmpty(rep.Owner)
+	s.NotEqual(rep.Token, rep.Owner)
+
+	path := fmt.Sprintf("/v1/requests/%s", rep.Token)
+
+	// The response cannot be fetched until the request is fulfilled
+	s.sendJSON(http.MethodGet, path, rep.Owner, nil, http.StatusConflict, &api.Reply{})
+
+	// Respond to the request
+	respond := &api.RespondSecretReply{}
+	s.sendJSON(http.MethodPost, path, "", &api.RespondSecretRequest{Secret: "the vendor api key"}, http.StatusOK, respond)
+	s.True(respond.Fulfilled)
+
+	// Cannot respond to the request a second time
+	s.sendJSON(http.MethodPost, path, "", &api.RespondSecretRequest{Secret: "another key"}, http.StatusConflict, &api.Reply{})
+
+	// The response cannot be fetched without the owner token
+	s.sendJSON(http.MethodGet, path, "", nil, http.StatusUnauthorized, &api.Reply{})
+
+	// The owner can fetch the response
+	secret := &api.FetchSecretReply{}
+	s.sendJSON(http.MethodGet, path, rep.Owner, nil, http.StatusOK, secret)
+	s.Equal("the vendor api key", secret.Secret)
+	s.Equal(int64(18), secret.Size)
+	s.Equal(api.Digest([]byte("the vendor api key")), secret.SHA256)
+	s.True(secret.Destroyed)
+
+	// The request has been destroyed after the fetch
+	s.sendJSON(http.MethodGet, path, rep.Owner, nil, http.StatusNotFound, &api.Reply{})
+}
+
+func (s *WhisperTestSuite) TestRespondToSecret() {
+	// Cannot respond to a secret that is not a secret request
+	rep := s.sendCreateSecret(&api.CreateSecretRequest{Secret: "not a request"}, http.StatusCreated)
+	path := fmt.Sprintf("/v1/requests/%s", rep.Token)
+	s.sendJSON(http.MethodPost, path, "", &api.RespondSecretRequest{Secret: "overwrite"}, http.StatusNotFound, &api.Reply{})
+	s.sendJSON(http.MethodGet, path, "", nil, http.StatusNotFound, &api.Reply{})
+}
+
+// Send a JSON request to the router with an optional password and decode the reply.
+func (s *WhisperTestSuite) sendJSON(method, path, password string, in interface{}, code int, out interface{}) {
+	var body *bytes.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		s.NoError(err)
+		body = bytes.NewReader(data)
+	} else {
+		body = bytes.NewReader(nil)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, body)
+	req.Header.Add("Content-Type", "application/json")
+	if password != "" {
+		req.Header.Add("Authorization", "Bearer "+base64.URLEncoding.EncodeToString([]byte(password)))
+	}
+	s.router.ServeHTTP(w, req)
+
+	rep := w.Result()
+	defer rep.Body.Close()
+
+	s.Equal(code, rep.StatusCode)
+	s.NoError(json.NewDecoder(rep.Body).Decode(out))
+}
@@ -1,30 +1,33 @@
 package whisper
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/archive"
+	"github.com/rotationalio/whisper/pkg/audit"
+	"github.com/rotationalio/whisper/pkg/metrics"
+	"github.com/rotationalio/whisper/pkg/notify"
 	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rotationalio/whisper/pkg/tracing"
 	"github.com/rotationalio/whisper/pkg/vault"
 	"github.com/rs/zerolog/log"
 )
 
-// DefaultSecretLifetime is one week after which the secret will be destroyed.
-const DefaultSecretLifetime = time.Hour * 24 * 7
-
-// DefaultSecretAccesses ensures that once the secret is fetched it is destroyed
-const DefaultSecretAccesses = 1
-
 // CreateSecret handles an incoming CreateSecretRequest and attempts to create a new
 // secret that will only be displayed when the correct link is retrieved.
 func (s *Server) CreateSecret(c *gin.Context) {
@@ -32,68 +35,210 @@ func (s *Server) CreateSecret(c *gin.Context) {
 	var req v1.CreateSecretRequest
 	if err := c.ShouldBind(&req); err != nil {
 		sentry.Warn(c).Err(err).Msg("could not bind request")
-		c.JSON(http.StatusBadRequest, ErrorResponse("invalid create secret request"))
+		c.JSON(http.StatusBadRequest, ErrorReply(c, BadRequest("invalid create secret request")))
 		return
 	}
 
-	// Make a random URL to store the secret in
-	var (
-		err   error
-		token string
-	)
-	if token, err = s.GenerateUniqueURL(context.TODO()); err != nil {
-		sentry.Error(c).Err(err).Msg("could not generate unique token for secret")
-		c.JSON(http.StatusInternalServerError, ErrorResponse(err))
+	// Create the secret using the logic shared by all of the secret creation endpoints
+	rep, err := s.createSecret(requestContext(c), &req, "")
+	if err != nil {
+		if IsBadRequest(err) {
+			c.JSON(http.StatusBadRequest, ErrorReply(c, err))
+			return
+		}
+		sentry.Error(c).Err(err).Msg("could not create secret")
+		c.JSON(http.StatusInternalServerError, ErrorReply(c, err))
 		return
 	}
 
+	// Return successful reply back to the user
+	c.JSON(http.StatusCreated, rep)
+}
+
+// createSecret validates the request, creates the secret in the vault, and audits it with
+// the reason, e.g. the integration that created it. It is shared by the handlers that
+// create secrets on behalf of the user; errors that are caused by the request are wrapped
+// with BadRequest so that the caller can return the correct status, all other errors
+// should be treated as internal errors.
+func (s *Server) createSecret(ctx context.Context, req *v1.CreateSecretRequest, reason string) (rep *v1.CreateSecretReply, err error) {
+	var files []string
+	if files, err = s.checkSecret(req); err != nil {
+		return nil, err
+	}
+
+	if rep, err = s.storeSecret(ctx, req, files, false); err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, audit.SecretCreated, rep.Token, reason)
+	return rep, nil
+}
+
+// checkSecret validates the request against the configuration and the policy, returning
+// an error wrapped by BadRequest if the secret cannot be created. The email address of
+// the request is normalized so that it is stored in the same form it was validated. If
+// the secret is an archive, the files in the archive are returned to be stored with it.
+func (s *Server) checkSecret(req *v1.CreateSecretRequest) (files []string, err error) {
+	// Ensure the callback is allowed before creating the secret
+	if req.Callback != "" {
+		if !s.conf.Webhooks.Enabled {
+			return nil, BadRequest("webhook callbacks are not enabled")
+		}
+
+		if err = s.conf.Webhooks.Allowed(req.Callback); err != nil {
+			return nil, BadRequest(err)
+		}
+	}
+
+	// Ensure the notification email address is valid before creating the secret
+	if req.Email != "" {
+		if !s.conf.Email.Enabled {
+			return nil, BadRequest("email notifications are not enabled")
+		}
+
+		if req.Email, err = notify.ParseAddress(req.Email); err != nil {
+			return nil, BadRequest(err)
+		}
+	}
+
+	// Enforce the policy before creating the secret; the policy can be reloaded
+	conf := s.settings()
+	if err = checkLimits(conf, req.Lifetime, req.Accesses); err != nil {
+		return nil, err
+	}
+
+	if err = conf.Policy.CheckSize(req.Secret); err != nil {
+		return nil, BadRequest(Coded(v1.ErrPayloadTooLarge, err))
+	}
+
+	if err = conf.Policy.CheckPassword(req.Password); err != nil {
+		return nil, BadRequest(err)
+	}
+
+	// The archive is only listed once since decompressing it is expensive
+	return archiveFiles(req.Secret, req.Archive, req.IsBase64, conf.Policy.MaxSize)
+}
+
+// describe computes the content type, size, and SHA-256 digest of the secret when it is
+// created so that the recipient does not have to guess the type of the secret and can
+// verify that it was received intact. The content type is determined by the extension of
+// the filename if it is known, otherwise it is detected from the contents of the secret.
+func describe(secret, filename string, isBase64 bool) (contentType string, size int64, digest string, err error) {
+	var data []byte
+	if data, err = v1.DecodeSecret(secret, isBase64); err != nil {
+		return "", 0, "", BadRequest("could not decode base64 encoded secret")
+	}
+
+	if ext := filepath.Ext(filename); ext != "" {
+		contentType = mime.TypeByExtension(ext)
+	}
+
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return contentType, int64(len(data)), v1.Digest(data), nil
+}
+
+// archiveFiles lists the files of an archive uploaded as a secret so that the listing
+// is stored with the secret. The archive is checked to ensure it can be safely unpacked
+// by the recipient, returning an error wrapped by BadRequest if it cannot be or if it
+// expands to more than the maximum size of a secret.
+func archiveFiles(secret, archiveType string, isBase64 bool, maxSize int) (_ []string, err error) {
+	if archiveType == "" {
+		return nil, nil
+	}
+
+	if err = archive.Check(archiveType); err != nil {
+		return nil, BadRequest(err)
+	}
+
+	if !isBase64 {
+		return nil, BadRequest("archives must be base64 encoded")
+	}
+
+	var data []byte
+	if data, err = base64.StdEncoding.DecodeString(secret); err != nil {
+		return nil, BadRequest("could not decode archive")
+	}
+
+	var files []string
+	if files, err = archive.ListLimit(bytes.NewReader(data), int64(maxSize)); err != nil {
+		return nil, BadRequest(err)
+	}
+	return files, nil
+}
+
+// storeSecret creates a secret that has been validated by checkSecret in the vault with
+// the files returned by checkSecret. If bundle is set the secret is the JSON encoded items
+// of a bundle created by a batch.
+func (s *Server) storeSecret(ctx context.Context, req *v1.CreateSecretRequest, files []string, bundle bool) (_ *v1.CreateSecretReply, err error) {
+	// Defaults are taken from the most recent policy
+	conf := s.settings()
+
+	// Make a random URL to store the secret in
+	var token string
+	if token, err = s.GenerateUniqueURL(ctx); err != nil {
+		return nil, fmt.Errorf("could not generate unique token for secret: %w", err)
+	}
+
 	// Create the secret context
 	meta := s.vault.With(token)
 	meta.Filename = req.Filename
 	meta.IsBase64 = req.IsBase64
+	meta.Bundle = bundle
+	meta.Archive = req.Archive
+	meta.Files = files
+
+	// The items of a bundle are described individually instead of the encoded bundle
+	if !bundle {
+		if meta.ContentType, meta.Size, meta.SHA256, err = describe(req.Secret, req.Filename, req.IsBase64); err != nil {
+			return nil, err
+		}
+	}
+	meta.Callback = req.Callback
+	meta.Email = req.Email
+	meta.MaxFailures = conf.PasswordAttempts
 	meta.Created = time.Now()
 
 	// Store the password as a derived key
-	if err = meta.SetPassword(req.Password); err != nil {
-		sentry.Error(c).Err(err).Msg("could not create derived key")
-		c.JSON(http.StatusInternalServerError, ErrorResponse(err))
-		return
+	_, span := tracing.Start(ctx, "passwd.CreateDerivedKey")
+	err = meta.SetPassword(req.Password)
+	tracing.End(span, err, "could not create derived key")
+	if err != nil {
+		return nil, fmt.Errorf("could not create derived key: %w", err)
 	}
 
 	// Compute the number of accesses for the secret
 	if req.Accesses == 0 {
-		meta.Accesses = DefaultSecretAccesses
-		log.Debug().Int("accesses", meta.Accesses).Msg("using default number of accesses")
+		meta.Accesses = conf.DefaultAccesses
+		log.Ctx(ctx).Debug().Int("accesses", meta.Accesses).Msg("using default number of accesses")
 	} else {
 		meta.Accesses = req.Accesses
-		log.Debug().Int("accesses", meta.Accesses).Msg("using user supplied number of accesses")
+		log.Ctx(ctx).Debug().Int("accesses", meta.Accesses).Msg("using user supplied number of accesses")
 	}
 
 	// Compute the expiration time from the request
 	if req.Lifetime == v1.Duration(0) {
-		meta.Expires = meta.Created.Add(DefaultSecretLifetime)
-		log.Debug().Dur("ttl", DefaultSecretLifetime).Msg("using default secret lifetime")
+		meta.Expires = meta.Created.Add(conf.DefaultLifetime)
+		log.Ctx(ctx).Debug().Dur("ttl", conf.DefaultLifetime).Msg("using default secret lifetime")
 	} else {
 		meta.Expires = meta.Created.Add(time.Duration(req.Lifetime))
-		log.Debug().Dur("ttl", time.Duration(req.Lifetime)).Msg("using user supplied secret lifetime")
+		log.Ctx(ctx).Debug().Dur("ttl", time.Duration(req.Lifetime)).Msg("using user supplied secret lifetime")
 	}
 
 	// Create the secret in the vault.
-	if err = meta.New(context.TODO(), req.Secret); err != nil {
+	if err = meta.New(ctx, req.Secret); err != nil {
 		if errors.Is(err, vault.ErrTimeToLive) {
-			c.JSON(http.StatusBadRequest, ErrorResponse(err))
-			return
+			return nil, BadRequest(err)
 		}
-		sentry.Error(c).Err(err).Msg("could not create new secret in vault")
-		c.JSON(http.StatusInternalServerError, ErrorResponse(err))
-		return
+		return nil, fmt.Errorf("could not create new secret in vault: %w", err)
 	}
+	metrics.Secret(metrics.Created)
 
-	// Return successful reply back to the user
-	c.JSON(http.StatusCreated, &v1.CreateSecretReply{
+	return &v1.CreateSecretReply{
 		Token:   token,
 		Expires: meta.Expires,
-	})
+	}, nil
 }
 
 // FetchSecret handles an incoming fetch secret request and attempts to retrieve the
@@ -101,74 +246,115 @@ func (s *Server) CreateSecret(c *gin.Context) {
 // password and ensures that a 404 is returned to obfuscate the existence of the secret
 // on bad requests.
 func (s *Server) FetchSecret(c *gin.Context) {
-	// Prepare to fetch the meta with the token and password from the request
-	token := c.Param("token")
-	meta := s.vault.With(token)
 	password := ParseBearerToken(c.GetHeader("Authorization"))
-	log.Debug().Bool("authorization", password != "").Msg("beginning fetch")
-
-	// Attempt to retrieve the secret from the database
-	secret, destroyed, err := meta.Fetch(context.TODO(), password)
+	rep, err := s.fetchSecret(requestContext(c), c.Param("token"), password)
 	if err != nil {
-		switch err {
-		case vault.ErrSecretNotFound:
-			c.JSON(http.StatusNotFound, ErrorResponse(err))
-		case vault.ErrNotAuthorized:
-			c.JSON(http.StatusUnauthorized, ErrorResponse(err))
-		default:
+		code := errorStatus(err)
+		if code == http.StatusInternalServerError {
 			sentry.Error(c).Err(err).Msg("could not fetch secret")
-			c.JSON(http.StatusInternalServerError, ErrorResponse(err))
 		}
+		c.JSON(code, ErrorReply(c, err))
 		return
 	}
 
-	// Create the secret reply
-	rep := v1.FetchSecretReply{
-		Secret:    secret,
-		Filename:  meta.Filename,
-		IsBase64:  meta.IsBase64,
-		Created:   meta.Created,
-		Accesses:  meta.Retrievals,
-		Destroyed: destroyed,
-	}
-
 	// Return the successful reply
 	c.JSON(http.StatusOK, rep)
 }
 
+// fetchSecret retrieves the secret from the vault with the password, destroying it if
+// it has no accesses remaining, then audits the fetch and notifies the creator of the
+// secret. It is shared by the REST and gRPC APIs; use errorStatus to convert errors.
+func (s *Server) fetchSecret(ctx context.Context, token, password string) (_ *v1.FetchSecretReply, err error) {
+	meta := s.vault.With(token)
+	log.Ctx(ctx).Debug().Bool("authorization", password != "").Msg("beginning fetch")
+
+	// Attempt to retrieve the secret from the database
+	var (
+		secret    string
+		destroyed bool
+	)
+	secret, destroyed, err = meta.Fetch(ctx, password)
+	s.recordFetch(ctx, token, destroyed, err)
+	if err != nil {
+		if errors.Is(err, vault.ErrPasswordAttempts) {
+			s.dispatch(notify.SecretLocked, token, meta)
+		}
+		return nil, err
+	}
+
+	// Notify the creator of the secret that it has been fetched
+	metrics.Secret(metrics.Fetched)
+	s.dispatch(notify.SecretFetched, token, meta)
+	if destroyed {
+		s.dispatch(notify.SecretExhausted, token, meta)
+	}
+
+	rep := &v1.FetchSecretReply{
+		Secret:      secret,
+		Filename:    meta.Filename,
+		IsBase64:    meta.IsBase64,
+		Created:     meta.Created,
+		Accesses:    meta.Retrievals,
+		Destroyed:   destroyed,
+		Archive:     meta.Archive,
+		Files:       meta.Files,
+		ContentType: meta.ContentType,
+		Size:        meta.Size,
+		SHA256:      meta.SHA256,
+	}
+
+	// The items of a bundle are returned instead of the encoded bundle
+	if meta.Bundle {
+		if err = json.Unmarshal([]byte(secret), &rep.Bundle); err != nil {
+			return nil, fmt.Errorf("could not decode secret bundle: %w", err)
+		}
+		rep.Secret = ""
+	}
+	return rep, nil
+}
+
 // DestroySecret handles an incoming destroy secret request and attempts to delete the
 // secret from the database. This RPC is password protected in the same way fetch is.
 func (s *Server) DestroySecret(c *gin.Context) {
-	// Prepare to fetch the meta with the token and password from the request
-	token := c.Param("token")
-	meta := s.vault.With(token)
 	password := ParseBearerToken(c.GetHeader("Authorization"))
-	log.Debug().Bool("authorization", password != "").Msg("beginning destroy")
-
-	// Delete the secret from the database
-	// Attempt to retrieve the secret from the database
-	err := meta.Destroy(context.TODO(), password)
+	rep, err := s.destroySecret(requestContext(c), c.Param("token"), password)
 	if err != nil {
-		switch err {
-		case vault.ErrSecretNotFound:
-			c.JSON(http.StatusNotFound, ErrorResponse(err))
-		case vault.ErrNotAuthorized:
-			c.JSON(http.StatusUnauthorized, ErrorResponse(err))
-		default:
+		code := errorStatus(err)
+		if code == http.StatusInternalServerError {
 			sentry.Error(c).Err(err).Msg("could not destroy secret")
-			c.JSON(http.StatusInternalServerError, ErrorResponse(err))
 		}
+		c.JSON(code, ErrorReply(c, err))
 		return
 	}
 
 	// Return the successful reply
-	c.JSON(http.StatusOK, &v1.DestroySecretReply{Destroyed: true})
+	c.JSON(http.StatusOK, rep)
 }
 
-const (
-	generateUniqueLength   = 32
-	generateUniqueAttempts = 8
-)
+// destroySecret deletes the secret from the vault if the password is correct, then
+// audits the destroy and notifies the creator of the secret. It is shared by the REST
+// and gRPC APIs; use errorStatus to convert errors.
+func (s *Server) destroySecret(ctx context.Context, token, password string) (_ *v1.DestroySecretReply, err error) {
+	meta := s.vault.With(token)
+	log.Ctx(ctx).Debug().Bool("authorization", password != "").Msg("beginning destroy")
+
+	// Delete the secret from the database
+	err = meta.Destroy(ctx, password)
+	s.recordDestroy(ctx, token, err)
+	if err != nil {
+		if errors.Is(err, vault.ErrPasswordAttempts) {
+			s.dispatch(notify.SecretLocked, token, meta)
+		}
+		return nil, err
+	}
+
+	// Notify the creator of the secret that it has been destroyed
+	metrics.Secret(metrics.Destroyed)
+	s.dispatch(notify.SecretDestroyed, token, meta)
+	return &v1.DestroySecretReply{Destroyed: true}, nil
+}
+
+const generateUniqueAttempts = 8
 
 // GenerateUniqueURL is a helper function that uses crypto/rand to create a random
 // URL-safe string and determines if it is in the database or not. If it finds a
@@ -177,7 +363,7 @@ const (
 func (s *Server) GenerateUniqueURL(ctx context.Context) (token string, err error) {
 	for i := 0; i < generateUniqueAttempts; i++ {
 		// Create a random array of bytes
-		buf := make([]byte, generateUniqueLength)
+		buf := make([]byte, vault.TokenLength)
 		if _, err = rand.Read(buf); err != nil {
 			return "", err
 		}
@@ -198,6 +384,29 @@ func (s *Server) GenerateUniqueURL(ctx context.Context) (token string, err error
 	return "", fmt.Errorf("could not generate unique URL after %d attempts", generateUniqueAttempts)
 }
 
+// dispatch sends a secret lifecycle event to all of the configured notifiers. Errors are
+// logged rather than returned since notifications must never interrupt the request.
+func (s *Server) dispatch(event notify.EventType, token string, meta *vault.SecretContext) {
+	if len(s.notifiers) == 0 {
+		return
+	}
+
+	e := notify.Event{
+		Type:      event,
+		Secret:    notify.SecretID(token),
+		Accesses:  meta.Retrievals,
+		Timestamp: time.Now(),
+		Callback:  meta.Callback,
+		Email:     meta.Email,
+	}
+
+	for _, notifier := range s.notifiers {
+		if err := notifier.Notify(e); err != nil {
+			log.Warn().Err(err).Str("event", string(event)).Msg("could not send notification")
+		}
+	}
+}
+
 // Check that a cryptographically secure PRNG is available.
 func checkAvailablePRNG() (err error) {
 	buf := make([]byte, 1)
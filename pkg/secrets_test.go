@@ -1,17 +1,25 @@
 package whisper_test
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	. "github.com/rotationalio/whisper/pkg"
 	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/archive"
+	"github.com/rotationalio/whisper/pkg/logger"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/require"
 )
 
@@ -63,6 +71,103 @@ func (s *WhisperTestSuite) TestCreateFetchSecret() {
 	s.sendFetchRequest(rep1.Token, "", http.StatusNotFound)
 }
 
+func (s *WhisperTestSuite) TestCreateSecretNotifications() {
+	// Webhooks are not enabled in the test configuration so callbacks are rejected
+	s.sendJSON(http.MethodPost, "/v1/secrets", "", &api.CreateSecretRequest{
+		Secret:   "do not share this with anyone",
+		Callback: "https://example.com/hooks",
+	}, http.StatusBadRequest, &api.Reply{})
+
+	// Email notifications are not enabled in the test configuration either
+	s.sendJSON(http.MethodPost, "/v1/secrets", "", &api.CreateSecretRequest{
+		Secret: "do not share this with anyone",
+		Email:  "jane@example.com",
+	}, http.StatusBadRequest, &api.Reply{})
+}
+
+func (s *WhisperTestSuite) TestSecretContents() {
+	png := []byte("\x89PNG\r\n\x1a\nnot really an image")
+	testCases := []struct {
+		req         *api.CreateSecretRequest
+		data        []byte
+		contentType string
+	}{
+		{&api.CreateSecretRequest{Secret: "do not share this with anyone"}, []byte("do not share this with anyone"), "text/plain; charset=utf-8"},
+		{&api.CreateSecretRequest{Secret: "eyJrZXkiOiAiYWJjIn0=", Filename: "creds.json", IsBase64: true}, []byte(`{"key": "abc"}`), "application/json"},
+		{&api.CreateSecretRequest{Secret: base64.StdEncoding.EncodeToString(png), Filename: "logo", IsBase64: true}, png, "image/png"},
+	}
+
+	for i, tc := range testCases {
+		rep := s.sendCreateSecret(tc.req, http.StatusCreated)
+		out := s.sendFetchRequest(rep.Token, "", http.StatusOK)
+		s.Equal(tc.contentType, out.ContentType, "test case %d", i)
+		s.Equal(int64(len(tc.data)), out.Size, "test case %d", i)
+		s.Equal(api.Digest(tc.data), out.SHA256, "test case %d", i)
+
+		data, err := out.Decode()
+		s.NoError(err, "test case %d", i)
+		s.Equal(tc.data, data, "test case %d", i)
+	}
+
+	// Secrets that claim to be base64 encoded must be decodable
+	out := &api.Reply{}
+	s.sendJSON(http.MethodPost, "/v1/secrets", "", &api.CreateSecretRequest{Secret: "not base64!", IsBase64: true}, http.StatusBadRequest, out)
+	s.Equal(api.ErrInvalidRequest, out.Code)
+}
+
+func (s *WhisperTestSuite) TestCreateArchive() {
+	// Archive a directory with a nested file
+	dir := filepath.Join(s.T().TempDir(), "configs")
+	s.NoError(os.MkdirAll(filepath.Join(dir, "vpn"), 0755))
+	s.NoError(os.WriteFile(filepath.Join(dir, "vpn", "client.ovpn"), []byte("remote vpn.example.com 1194"), 0644))
+	s.NoError(os.WriteFile(filepath.Join(dir, "deploy.sh"), []byte("#!/bin/sh\n"), 0755))
+
+	buf := &bytes.Buffer{}
+	s.NoError(archive.Create(buf, dir))
+	secret := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	rep := s.sendCreateSecret(&api.CreateSecretRequest{Secret: secret, Filename: "configs.tar.gz", IsBase64: true, Archive: archive.TarGzip}, http.StatusCreated)
+	s.NotEmpty(rep.Token)
+
+	// The listing of the archive is returned with the secret
+	out := s.sendFetchRequest(rep.Token, "", http.StatusOK)
+	s.Equal(secret, out.Secret)
+	s.Equal(archive.TarGzip, out.Archive)
+	s.Equal([]string{"configs/deploy.sh", "configs/vpn/client.ovpn"}, out.Files)
+
+	// Archives that cannot be listed or safely unpacked are rejected
+	unsafe := &bytes.Buffer{}
+	gz := gzip.NewWriter(unsafe)
+	tw := tar.NewWriter(gz)
+	s.NoError(tw.WriteHeader(&tar.Header{Name: "../.bashrc", Typeflag: tar.TypeReg, Mode: 0644}))
+	s.NoError(tw.Close())
+	s.NoError(gz.Close())
+
+	// Archives that expand beyond the maximum secret size are rejected
+	bomb := &bytes.Buffer{}
+	gz = gzip.NewWriter(bomb)
+	tw = tar.NewWriter(gz)
+	s.NoError(tw.WriteHeader(&tar.Header{Name: "zeros", Typeflag: tar.TypeReg, Mode: 0644, Size: 1 << 20}))
+	_, err := tw.Write(make([]byte, 1<<20))
+	s.NoError(err)
+	s.NoError(tw.Close())
+	s.NoError(gz.Close())
+
+	testCases := []*api.CreateSecretRequest{
+		{Secret: base64.StdEncoding.EncodeToString(unsafe.Bytes()), IsBase64: true, Archive: archive.TarGzip},
+		{Secret: base64.StdEncoding.EncodeToString(bomb.Bytes()), IsBase64: true, Archive: archive.TarGzip},
+		{Secret: base64.StdEncoding.EncodeToString([]byte("not an archive")), IsBase64: true, Archive: archive.TarGzip},
+		{Secret: secret, Archive: archive.TarGzip},
+		{Secret: secret, IsBase64: true, Archive: "zip"},
+	}
+
+	for i, tc := range testCases {
+		out := &api.Reply{}
+		s.sendJSON(http.MethodPost, "/v1/secrets", "", tc, http.StatusBadRequest, out)
+		s.Equal(api.ErrInvalidRequest, out.Code, "test case %d", i)
+	}
+}
+
 // TODO: CreateFetchSecretPasswordFlow
 // TODO: CreateDeleteSecretFlow
 // TODO: CreateDeleteSecretPassword Flow
@@ -105,3 +210,109 @@ func (s *WhisperTestSuite) sendFetchRequest(token, password string, code int) *a
 	s.NoError(json.NewDecoder(rep.Body).Decode(&out))
 	return out
 }
+
+func (s *WhisperTestSuite) TestRequestID() {
+	req, _ := http.NewRequest(http.MethodGet, "/v1/secrets/doesnotexist", nil)
+	req.Header.Set("X-Request-ID", "test-request-1234")
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusNotFound, w.Code)
+	s.Equal("test-request-1234", w.Header().Get("X-Request-ID"))
+
+	// The request ID is returned in the error reply so users can report it
+	out := &api.Reply{}
+	s.NoError(json.NewDecoder(w.Body).Decode(out))
+	s.False(out.Success)
+	s.Equal("test-request-1234", out.RequestID)
+
+	// A request ID is generated if not supplied and is returned on unknown routes too
+	req, _ = http.NewRequest(http.MethodGet, "/v1/foo", nil)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusNotFound, w.Code)
+	s.NotEmpty(w.Header().Get("X-Request-ID"))
+	s.NoError(json.NewDecoder(w.Body).Decode(out))
+	s.Equal(w.Header().Get("X-Request-ID"), out.RequestID)
+}
+
+func (s *WhisperTestSuite) TestNoTokensInLogs() {
+	// Capture all log output through the scrubber as the server does in production
+	buf := &bytes.Buffer{}
+	prev := log.Logger
+	log.Logger = zerolog.New(logger.NewScrubber(buf)).Level(zerolog.DebugLevel)
+	defer func() { log.Logger = prev }()
+
+	prevLevel := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	defer zerolog.SetGlobalLevel(prevLevel)
+
+	// Create a password protected secret, fail to fetch it, then fetch it
+	rep := s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight", Password: "hunter2"}, http.StatusCreated)
+	s.sendFetchRequest(rep.Token, "", http.StatusUnauthorized)
+
+	req, _ := http.NewRequest(http.MethodGet, "/v1/secrets/"+rep.Token+"?token="+rep.Token, nil)
+	req.Header.Set("Authorization", "Bearer "+base64.URLEncoding.EncodeToString([]byte("hunter2")))
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+
+	// Fetching the destroyed secret and unknown routes are also logged
+	s.sendFetchRequest(rep.Token, "", http.StatusNotFound)
+	req, _ = http.NewRequest(http.MethodGet, "/v1/secrets/"+rep.Token+"/unknown", nil)
+	s.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	s.NotEmpty(buf.String(), "expected requests to be logged")
+	s.NotContains(buf.String(), rep.Token)
+	s.NotContains(buf.String(), "hunter2")
+	s.NotContains(buf.String(), "the eagle flies at midnight")
+	s.Contains(buf.String(), logger.HashToken(rep.Token))
+}
+
+func (s *WhisperTestSuite) TestFetchErrorCodes() {
+	rep := s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight", Password: "hunter2", Accesses: 3}, http.StatusCreated)
+
+	// Clients can distinguish a missing password from an incorrect one
+	out := &api.Reply{}
+	s.sendJSON(http.MethodGet, "/v1/secrets/"+rep.Token, "", nil, http.StatusUnauthorized, out)
+	s.Equal(api.ErrPasswordRequired, out.Code)
+
+	out = &api.Reply{}
+	s.sendJSON(http.MethodGet, "/v1/secrets/"+rep.Token, "hunter3", nil, http.StatusUnauthorized, out)
+	s.Equal(api.ErrPasswordIncorrect, out.Code)
+
+	out = &api.Reply{}
+	s.sendJSON(http.MethodGet, "/v1/secrets/notarealtoken", "", nil, http.StatusNotFound, out)
+	s.Equal(api.ErrSecretNotFound, out.Code)
+}
+
+func (s *WhisperTestSuite) TestPasswordAttempts() {
+	// Use a separate server that destroys secrets after two incorrect passwords
+	conf := s.conf
+	conf.PasswordAttempts = 2
+	srv, err := New(conf)
+	s.NoError(err)
+	srv.SetStatus(true, true)
+
+	prev := s.router
+	s.router = srv.Routes()
+	defer func() { s.router = prev }()
+
+	rep := s.sendCreateSecret(&api.CreateSecretRequest{Secret: "the eagle flies at midnight", Password: "hunter2"}, http.StatusCreated)
+	missing := &api.Reply{}
+	s.sendJSON(http.MethodGet, "/v1/secrets/notarealtoken", "hunter3", nil, http.StatusNotFound, missing)
+
+	out := &api.Reply{}
+	s.sendJSON(http.MethodGet, "/v1/secrets/"+rep.Token, "hunter3", nil, http.StatusUnauthorized, out)
+	s.Equal(api.ErrPasswordIncorrect, out.Code)
+
+	// The reply does not disclose that the secret existed before it was destroyed
+	out = &api.Reply{}
+	s.sendJSON(http.MethodGet, "/v1/secrets/"+rep.Token, "hunter3", nil, http.StatusNotFound, out)
+	s.Equal(api.ErrSecretNotFound, out.Code)
+	s.Equal(missing.Error, out.Error)
+
+	out = &api.Reply{}
+	s.sendJSON(http.MethodGet, "/v1/secrets/"+rep.Token, "hunter2", nil, http.StatusNotFound, out)
+	s.Equal(api.ErrSecretNotFound, out.Code)
+}
@@ -2,21 +2,23 @@ package sentry
 
 import (
 	"errors"
+	"net/url"
 
 	"github.com/getsentry/sentry-go"
+	"github.com/rotationalio/whisper/pkg/logger"
 )
 
 // Sentry configuration for use in application-configuration
 type Config struct {
-	DSN              string  `split_words:"true"`
-	ServerName       string  `split_words:"true"`
-	Environment      string  `split_words:"true" default:"production"`
-	Release          string  `split_words:"true"`
-	TrackPerformance bool    `split_words:"true" default:"true"`
-	SampleRate       float64 `split_words:"true" default:"0.25"`
-	ReportErrors     bool    `split_words:"true" default:"true"`
-	Repanic          bool    `ignored:"true"`
-	Debug            bool    `default:"false"`
+	DSN              string  `split_words:"true" yaml:"dsn"`
+	ServerName       string  `split_words:"true" yaml:"server_name"`
+	Environment      string  `split_words:"true" default:"production" yaml:"environment"`
+	Release          string  `split_words:"true" yaml:"release"`
+	TrackPerformance bool    `split_words:"true" default:"true" yaml:"track_performance"`
+	SampleRate       float64 `split_words:"true" default:"0.25" yaml:"sample_rate"`
+	ReportErrors     bool    `split_words:"true" default:"true" yaml:"report_errors"`
+	Repanic          bool    `ignored:"true" yaml:"-"`
+	Debug            bool    `default:"false" yaml:"debug"`
 }
 
 // Returns true if Sentry is enabled (e.g. a DSN is configured)
@@ -38,13 +40,41 @@ func (c Config) Validate() error {
 
 func (c Config) ClientOptions() sentry.ClientOptions {
 	return sentry.ClientOptions{
-		Dsn:              c.DSN,
-		Environment:      c.Environment,
-		Release:          c.Release,
-		AttachStacktrace: true,
-		Debug:            c.Debug,
-		ServerName:       c.ServerName,
-		EnableTracing:    c.TrackPerformance,
-		TracesSampleRate: c.SampleRate,
+		Dsn:                   c.DSN,
+		Environment:           c.Environment,
+		Release:               c.Release,
+		AttachStacktrace:      true,
+		Debug:                 c.Debug,
+		ServerName:            c.ServerName,
+		EnableTracing:         c.TrackPerformance,
+		TracesSampleRate:      c.SampleRate,
+		BeforeSend:            redactEvent,
+		BeforeSendTransaction: redactEvent,
 	}
 }
+
+// Removes secret tokens and credentials from the request captured with Sentry events.
+func redactEvent(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+	if event.Request != nil {
+		if u, err := url.Parse(event.Request.URL); err == nil {
+			u.Path = logger.RedactPath(u.Path)
+			u.RawPath = ""
+			u.RawQuery = logger.RedactQuery(u.RawQuery)
+			event.Request.URL = u.String()
+		} else {
+			event.Request.URL = logger.Redacted
+		}
+
+		event.Request.QueryString = logger.RedactQuery(event.Request.QueryString)
+		event.Request.Cookies = ""
+		event.Request.Data = ""
+		for key := range event.Request.Headers {
+			if logger.IsSensitiveHeader(key) {
+				event.Request.Headers[key] = logger.Redacted
+			}
+		}
+	}
+
+	event.Transaction = logger.RedactPath(event.Transaction)
+	return event
+}
@@ -47,24 +47,34 @@ type Event struct {
 // created it must be sent using Msg or Msgf.
 func CreateEvent(level sentry.Level, ctx interface{}) *Event {
 	event := &Event{
-		zero:  log.WithLevel(sentryToZerologLevel[level]),
 		extra: make(map[string]interface{}),
 		level: level,
 	}
 
-	// Attempt to fetch the hub from the context
+	// Attempt to fetch the hub from the context; the zerolog logger is fetched from the
+	// request context so that the request ID is included in the log message.
+	logger := &log.Logger
 	switch c := ctx.(type) {
 	case *gin.Context:
 		event.hub = sentrygin.GetHubFromContext(c)
 		event.ginc = c
+		if c.Request != nil {
+			logger = Logger(c.Request.Context())
+		}
+		if requestID := RequestIDFromContext(c); requestID != "" {
+			event.extra[ContextKeyRequestID] = requestID
+		}
 	case context.Context:
 		event.hub = sentry.GetHubFromContext(c)
+		logger = Logger(c)
 	case *sentry.Hub:
 		event.hub = c
 	case nil:
 		event.hub = sentry.CurrentHub().Clone()
 	}
 
+	event.zero = logger.WithLevel(sentryToZerologLevel[level])
+
 	return event
 }
 
@@ -0,0 +1,60 @@
+package sentry
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oklog/ulid/v2"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// ContextKeyRequestID is the key the request ID is stored with on the gin context.
+const ContextKeyRequestID = "request_id"
+
+// Request IDs supplied by clients are only accepted if they are reasonably short and
+// contain only characters that are safe to log and to return in headers.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID is gin middleware that accepts the X-Request-ID header from the client or
+// generates a new ULID if the header is missing or invalid. The request ID is returned
+// in the response headers, stored on the gin context, and added to a zerolog logger
+// on the request context so that all log lines and Sentry events for the request can
+// be correlated. It should be the outermost middleware.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := AcceptRequestID(c.Request.Header.Get(HeaderRequestID))
+
+		c.Set(ContextKeyRequestID, requestID)
+		c.Header(HeaderRequestID, requestID)
+
+		logger := log.With().Str(ContextKeyRequestID, requestID).Logger()
+		c.Request = c.Request.WithContext(logger.WithContext(c.Request.Context()))
+		c.Next()
+	}
+}
+
+// AcceptRequestID returns the request ID supplied by the client if it is valid, otherwise
+// it returns a new ULID. It is used by both the REST and gRPC APIs.
+func AcceptRequestID(requestID string) string {
+	if !validRequestID.MatchString(requestID) {
+		return ulid.Make().String()
+	}
+	return requestID
+}
+
+// RequestIDFromContext returns the request ID set by the RequestID middleware or an
+// empty string if it has not been set.
+func RequestIDFromContext(c *gin.Context) string {
+	return c.GetString(ContextKeyRequestID)
+}
+
+// Logger returns the request-scoped logger from the context if one was added by the
+// RequestID middleware, otherwise the global logger is returned.
+func Logger(ctx context.Context) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx); logger != nil && logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return &log.Logger
+}
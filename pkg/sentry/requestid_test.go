@@ -0,0 +1,58 @@
+package sentry_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	// Capture the log output to ensure the request ID is included
+	buf := &bytes.Buffer{}
+	prev := log.Logger
+	log.Logger = zerolog.New(buf)
+	t.Cleanup(func() { log.Logger = prev })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(sentry.RequestID())
+	router.GET("/", func(c *gin.Context) {
+		sentry.Warn(c).Msg("something happened")
+		c.String(http.StatusOK, sentry.RequestIDFromContext(c))
+	})
+
+	// A request ID is generated if one is not supplied
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	requestID := w.Header().Get(sentry.HeaderRequestID)
+	_, err := ulid.Parse(requestID)
+	require.NoError(t, err, "expected a ulid request id")
+	require.Equal(t, requestID, w.Body.String())
+	require.Contains(t, buf.String(), `"request_id":"`+requestID+`"`)
+
+	// A valid request ID supplied by the client is used
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(sentry.HeaderRequestID, "client-request.1234")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, "client-request.1234", w.Header().Get(sentry.HeaderRequestID))
+
+	// Invalid request IDs are replaced so they cannot be used to inject into logs
+	for _, invalid := range []string{"bad\nid", strings.Repeat("a", 129), "<script>"} {
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(sentry.HeaderRequestID, invalid)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.NotEqual(t, invalid, w.Header().Get(sentry.HeaderRequestID))
+		require.NotEmpty(t, w.Header().Get(sentry.HeaderRequestID))
+	}
+}
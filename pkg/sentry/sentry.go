@@ -9,6 +9,7 @@ import (
 	sentrygin "github.com/getsentry/sentry-go/gin"
 	"github.com/gin-gonic/gin"
 	"github.com/oklog/ulid/v2"
+	"github.com/rotationalio/whisper/pkg/logger"
 	"github.com/rs/zerolog/log"
 )
 
@@ -53,16 +54,19 @@ func UseTags(tags map[string]string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if hub := sentrygin.GetHubFromContext(c); hub != nil {
 			// Set a unique request-ID either from the header or generated
+			// Prefer the request ID set by the RequestID middleware if it is in use
 			var requestID string
-			if requestID = c.Request.Header.Get(HeaderRequestID); requestID == "" {
-				requestID = ulid.Make().String()
+			if requestID = RequestIDFromContext(c); requestID == "" {
+				if requestID = c.Request.Header.Get(HeaderRequestID); requestID == "" {
+					requestID = ulid.Make().String()
+				}
+				c.Set(ContextKeyRequestID, requestID)
 			}
-			c.Set("request_id", requestID)
 
 			hub.ConfigureScope(func(scope *sentry.Scope) {
 				scope.SetTags(tags)
 				scope.SetTag("method", c.Request.Method)
-				scope.SetTag("path", c.Request.URL.Path)
+				scope.SetTag("path", logger.RedactPath(c.Request.URL.Path))
 				scope.SetTag("request_id", requestID)
 			})
 
@@ -98,5 +102,5 @@ func ReportErrors(conf Config) gin.HandlerFunc {
 }
 
 func TransactionName(c *gin.Context) string {
-	return fmt.Sprintf("%s %s", c.Request.Method, c.Request.URL.Path)
+	return fmt.Sprintf("%s %s", c.Request.Method, logger.RedactPath(c.Request.URL.Path))
 }
@@ -0,0 +1,104 @@
+package whisper
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rotationalio/whisper/pkg/slack"
+	"github.com/rs/zerolog/log"
+)
+
+// SlackCommand handles the /whisper slash command by opening a modal in which the user
+// can enter the secret, so that the secret is never posted into the channel itself.
+// The requests are verified by the slack.Authenticate middleware before this handler.
+func (s *Server) SlackCommand(c *gin.Context) {
+	if err := c.Request.ParseForm(); err != nil {
+		c.String(http.StatusBadRequest, "could not parse slash command")
+		return
+	}
+
+	cmd, err := slack.ParseSlashCommand(c.Request.PostForm)
+	if err != nil {
+		sentry.Warn(c).Err(err).Msg("could not parse slash command")
+		c.String(http.StatusBadRequest, "could not parse slash command")
+		return
+	}
+
+	var view map[string]interface{}
+	if view, err = slack.CreateSecretModal(&slack.Metadata{ResponseURL: cmd.ResponseURL, ChannelID: cmd.ChannelID}); err != nil {
+		sentry.Error(c).Err(err).Msg("could not create slack modal")
+		c.String(http.StatusOK, "Sorry, whisper could not open the secret form.")
+		return
+	}
+
+	// Slack requires the modal to be opened within 3 seconds of the trigger
+	if err = s.slack.OpenView(c.Request.Context(), cmd.TriggerID, view); err != nil {
+		sentry.Error(c).Err(err).Msg("could not open slack modal")
+		c.String(http.StatusOK, "Sorry, whisper could not open the secret form.")
+		return
+	}
+
+	// An empty 200 response acknowledges the command without posting a message
+	c.Status(http.StatusOK)
+}
+
+// SlackInteraction handles the submission of the modal opened by SlackCommand. The
+// secret is created with the same logic as CreateSecret and the link is posted back to
+// the user as an ephemeral message that only they can see.
+func (s *Server) SlackInteraction(c *gin.Context) {
+	in, err := slack.ParseInteraction(c.PostForm("payload"))
+	if err != nil {
+		sentry.Warn(c).Err(err).Msg("could not parse slack interaction")
+		c.String(http.StatusBadRequest, "could not parse interaction")
+		return
+	}
+
+	// Acknowledge any interactions that whisper does not handle
+	if in.Type != slack.TypeViewSubmission || in.View.CallbackID != slack.CallbackID {
+		log.Ctx(c.Request.Context()).Debug().Str("type", in.Type).Str("callback_id", in.View.CallbackID).Msg("ignoring slack interaction")
+		c.Status(http.StatusOK)
+		return
+	}
+
+	var meta *slack.Metadata
+	if meta, err = in.View.Metadata(); err != nil {
+		sentry.Warn(c).Err(err).Msg("could not parse slack view metadata")
+		c.String(http.StatusBadRequest, "could not parse interaction")
+		return
+	}
+
+	// Validation errors are displayed on the modal rather than closing it
+	form, errs := in.View.Form()
+	if len(errs) > 0 {
+		c.JSON(http.StatusOK, slack.ValidationErrors(errs))
+		return
+	}
+
+	req := &v1.CreateSecretRequest{
+		Secret:   form.Secret,
+		Password: form.Password,
+		Accesses: form.Accesses,
+		Lifetime: v1.Duration(form.Lifetime),
+	}
+
+	var msg *slack.Message
+	rep, err := s.createSecret(requestContext(c), req, "slack")
+	switch {
+	case err == nil:
+		msg = slack.Ephemeral("Your whisper link is ready, it expires %s:\n%s", rep.Expires.Format("Jan 2, 2006 15:04 MST"), s.slack.Link(rep.Token))
+	case IsBadRequest(err):
+		msg = slack.Ephemeral("Sorry, whisper could not create your secret: %s", err)
+	default:
+		sentry.Error(c).Err(err).Msg("could not create secret from slack")
+		msg = slack.Ephemeral("Sorry, whisper could not create your secret, please try again later.")
+	}
+
+	if err = s.slack.Respond(c.Request.Context(), meta.ResponseURL, msg); err != nil {
+		sentry.Error(c).Err(err).Msg("could not respond to slack command")
+	}
+
+	// An empty 200 response closes the modal
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,97 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client makes requests to the Slack Web API and to the response URLs of commands.
+type Client struct {
+	conf   Config
+	client *http.Client
+}
+
+// New creates a Slack client from the configuration.
+func New(conf Config) (*Client, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		conf:   conf,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Link returns the URL of the web application that displays the secret.
+func (c *Client) Link(token string) string {
+	return fmt.Sprintf("%s/secret/%s", strings.TrimSuffix(c.conf.WebURL, "/"), token)
+}
+
+// OpenView opens a modal for the user who triggered the interaction.
+func (c *Client) OpenView(ctx context.Context, triggerID string, view interface{}) (err error) {
+	in := map[string]interface{}{"trigger_id": triggerID, "view": view}
+	endpoint := strings.TrimSuffix(c.conf.Endpoint, "/") + "/views.open"
+
+	var rep *http.Response
+	if rep, err = c.post(ctx, endpoint, in, true); err != nil {
+		return err
+	}
+	defer rep.Body.Close()
+
+	// The Web API returns 200 OK with ok: false on errors
+	out := struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}{}
+	if err = json.NewDecoder(rep.Body).Decode(&out); err != nil {
+		return fmt.Errorf("could not decode slack response: %w", err)
+	}
+
+	if !out.OK {
+		return fmt.Errorf("could not open slack view: %s", out.Error)
+	}
+	return nil
+}
+
+// Respond posts a message to the response URL of a slash command.
+func (c *Client) Respond(ctx context.Context, responseURL string, msg *Message) (err error) {
+	var rep *http.Response
+	if rep, err = c.post(ctx, responseURL, msg, false); err != nil {
+		return err
+	}
+	rep.Body.Close()
+	return nil
+}
+
+func (c *Client) post(ctx context.Context, url string, data interface{}, auth bool) (rep *http.Response, err error) {
+	var body []byte
+	if body, err = json.Marshal(data); err != nil {
+		return nil, err
+	}
+
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body)); err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if auth {
+		req.Header.Set("Authorization", "Bearer "+c.conf.BotToken)
+	}
+
+	if rep, err = c.client.Do(req); err != nil {
+		return nil, fmt.Errorf("could not execute slack request: %w", err)
+	}
+
+	if rep.StatusCode < 200 || rep.StatusCode >= 300 {
+		rep.Body.Close()
+		return nil, fmt.Errorf("[%d] %s", rep.StatusCode, rep.Status)
+	}
+	return rep, nil
+}
@@ -0,0 +1,28 @@
+/*
+Package slack implements the Slack slash command and interactive modal protocol so that
+whisper links can be created from chat without pasting raw secrets into a channel. See
+https://api.slack.com/interactivity/slash-commands for details about the protocol.
+*/
+package slack
+
+import "errors"
+
+// Config enables the Slack integration when a signing secret is configured.
+type Config struct {
+	SigningSecret string `split_words:"true" yaml:"signing_secret"`
+	BotToken      string `split_words:"true" yaml:"bot_token"`
+	Endpoint      string `default:"https://slack.com/api" yaml:"endpoint"`
+	WebURL        string `split_words:"true" default:"https://whisper.rotational.dev" yaml:"web_url"`
+}
+
+// Enabled returns true if Slack requests can be verified (e.g. a signing secret is set).
+func (c Config) Enabled() bool {
+	return c.SigningSecret != ""
+}
+
+func (c Config) Validate() error {
+	if c.Enabled() && c.BotToken == "" {
+		return errors.New("invalid configuration: a bot token is required when Slack is enabled")
+	}
+	return nil
+}
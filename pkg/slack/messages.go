@@ -0,0 +1,268 @@
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CallbackID identifies the modal used to create whisper secrets.
+const CallbackID = "whisper_create"
+
+// Block and action IDs of the inputs in the create secret modal.
+const (
+	BlockSecret   = "secret_block"
+	BlockPassword = "password_block"
+	BlockAccesses = "accesses_block"
+	BlockLifetime = "lifetime_block"
+	ActionInput   = "input"
+)
+
+// Types of interaction payloads handled by whisper.
+const (
+	TypeViewSubmission = "view_submission"
+)
+
+// Standard errors for error type checking
+var (
+	ErrMissingField  = errors.New("missing required slack field")
+	ErrUnhandledType = errors.New("unhandled slack interaction type")
+)
+
+// SlashCommand is sent as a form by Slack when a user invokes /whisper.
+type SlashCommand struct {
+	Command     string
+	Text        string
+	TeamID      string
+	UserID      string
+	ChannelID   string
+	ResponseURL string
+	TriggerID   string
+}
+
+// ParseSlashCommand parses the form values of a slash command request.
+func ParseSlashCommand(form url.Values) (cmd *SlashCommand, err error) {
+	cmd = &SlashCommand{
+		Command:     form.Get("command"),
+		Text:        form.Get("text"),
+		TeamID:      form.Get("team_id"),
+		UserID:      form.Get("user_id"),
+		ChannelID:   form.Get("channel_id"),
+		ResponseURL: form.Get("response_url"),
+		TriggerID:   form.Get("trigger_id"),
+	}
+
+	if cmd.Command == "" || cmd.TriggerID == "" || cmd.ResponseURL == "" {
+		return nil, ErrMissingField
+	}
+	return cmd, nil
+}
+
+// Metadata is stored in the private metadata of the modal so that the response to the
+// modal submission can be posted back to the channel where the command was invoked.
+type Metadata struct {
+	ResponseURL string `json:"response_url"`
+	ChannelID   string `json:"channel_id"`
+}
+
+// Interaction is the JSON payload sent by Slack when a user interacts with a modal.
+type Interaction struct {
+	Type string `json:"type"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	View View `json:"view"`
+}
+
+// View is the modal state submitted by the user.
+type View struct {
+	ID              string `json:"id"`
+	CallbackID      string `json:"callback_id"`
+	PrivateMetadata string `json:"private_metadata"`
+	State           struct {
+		Values map[string]map[string]Value `json:"values"`
+	} `json:"state"`
+}
+
+// Value is the state of a single input in the modal.
+type Value struct {
+	Type           string  `json:"type"`
+	Value          string  `json:"value"`
+	SelectedOption *Option `json:"selected_option"`
+}
+
+// Option is a selected option in a static select input.
+type Option struct {
+	Value string `json:"value"`
+}
+
+// ParseInteraction parses the payload form field sent with interaction requests.
+func ParseInteraction(payload string) (in *Interaction, err error) {
+	if payload == "" {
+		return nil, ErrMissingField
+	}
+
+	in = &Interaction{}
+	if err = json.Unmarshal([]byte(payload), in); err != nil {
+		return nil, fmt.Errorf("could not parse slack interaction: %w", err)
+	}
+	return in, nil
+}
+
+// Metadata returns the private metadata stored on the view when the modal was opened.
+func (v View) Metadata() (meta *Metadata, err error) {
+	meta = &Metadata{}
+	if err = json.Unmarshal([]byte(v.PrivateMetadata), meta); err != nil {
+		return nil, fmt.Errorf("could not parse private metadata: %w", err)
+	}
+
+	if meta.ResponseURL == "" {
+		return nil, ErrMissingField
+	}
+	return meta, nil
+}
+
+// Form is the create secret request submitted in the modal.
+type Form struct {
+	Secret   string
+	Password string
+	Accesses int
+	Lifetime time.Duration
+}
+
+// Form extracts the create secret form from the view state. Validation errors are
+// returned as a map of block IDs to messages so they can be displayed in the modal.
+func (v View) Form() (form *Form, errs map[string]string) {
+	form = &Form{}
+	errs = make(map[string]string)
+
+	if form.Secret = v.input(BlockSecret).Value; strings.TrimSpace(form.Secret) == "" {
+		errs[BlockSecret] = "a secret is required"
+	}
+
+	form.Password = v.input(BlockPassword).Value
+
+	if opt := v.input(BlockAccesses).SelectedOption; opt != nil {
+		var err error
+		if form.Accesses, err = strconv.Atoi(opt.Value); err != nil {
+			errs[BlockAccesses] = "invalid number of accesses"
+		}
+	}
+
+	if opt := v.input(BlockLifetime).SelectedOption; opt != nil {
+		var err error
+		if form.Lifetime, err = time.ParseDuration(opt.Value); err != nil {
+			errs[BlockLifetime] = "invalid lifetime"
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return form, nil
+}
+
+func (v View) input(block string) Value {
+	if values, ok := v.State.Values[block]; ok {
+		return values[ActionInput]
+	}
+	return Value{}
+}
+
+// Message is posted to the response URL of a slash command.
+type Message struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// Ephemeral creates a message that is only visible to the user who invoked the command.
+func Ephemeral(format string, args ...interface{}) *Message {
+	return &Message{ResponseType: "ephemeral", Text: fmt.Sprintf(format, args...)}
+}
+
+// ErrorsResponse is returned from a view submission to display errors in the modal.
+type ErrorsResponse struct {
+	ResponseAction string            `json:"response_action"`
+	Errors         map[string]string `json:"errors"`
+}
+
+// ValidationErrors creates a response that displays the errors on the modal blocks.
+func ValidationErrors(errs map[string]string) *ErrorsResponse {
+	return &ErrorsResponse{ResponseAction: "errors", Errors: errs}
+}
+
+// CreateSecretModal returns the modal view that is opened by the slash command. The
+// metadata is stored privately on the view and returned on submission.
+func CreateSecretModal(meta *Metadata) (_ map[string]interface{}, err error) {
+	var private []byte
+	if private, err = json.Marshal(meta); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"type":             "modal",
+		"callback_id":      CallbackID,
+		"private_metadata": string(private),
+		"title":            plainText("Create a Whisper"),
+		"submit":           plainText("Create Link"),
+		"close":            plainText("Cancel"),
+		"blocks": []interface{}{
+			map[string]interface{}{
+				"type":     "input",
+				"block_id": BlockSecret,
+				"label":    plainText("Secret"),
+				"element": map[string]interface{}{
+					"type":      "plain_text_input",
+					"action_id": ActionInput,
+					"multiline": true,
+				},
+			},
+			map[string]interface{}{
+				"type":     "input",
+				"block_id": BlockPassword,
+				"optional": true,
+				"label":    plainText("Password"),
+				"hint":     plainText("Share the password with the recipient separately."),
+				"element": map[string]interface{}{
+					"type":      "plain_text_input",
+					"action_id": ActionInput,
+				},
+			},
+			selectInput(BlockAccesses, "Accesses", [][2]string{
+				{"1 access", "1"}, {"3 accesses", "3"}, {"10 accesses", "10"}, {"Unlimited until expired", "-1"},
+			}),
+			selectInput(BlockLifetime, "Expires After", [][2]string{
+				{"1 hour", "1h"}, {"1 day", "24h"}, {"3 days", "72h"}, {"7 days", "168h"},
+			}),
+		},
+	}, nil
+}
+
+func plainText(text string) map[string]interface{} {
+	return map[string]interface{}{"type": "plain_text", "text": text}
+}
+
+// Creates an optional static select input whose first option is the initial option.
+func selectInput(block, label string, options [][2]string) map[string]interface{} {
+	opts := make([]interface{}, 0, len(options))
+	for _, opt := range options {
+		opts = append(opts, map[string]interface{}{"text": plainText(opt[0]), "value": opt[1]})
+	}
+
+	return map[string]interface{}{
+		"type":     "input",
+		"block_id": block,
+		"optional": true,
+		"label":    plainText(label),
+		"element": map[string]interface{}{
+			"type":           "static_select",
+			"action_id":      ActionInput,
+			"options":        opts,
+			"initial_option": opts[0],
+		},
+	}
+}
@@ -0,0 +1,164 @@
+package slack_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rotationalio/whisper/pkg/slack"
+	"github.com/stretchr/testify/require"
+)
+
+const testSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+func TestVerify(t *testing.T) {
+	body, err := os.ReadFile("testdata/command.txt")
+	require.NoError(t, err)
+
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	header := http.Header{}
+	header.Set(slack.HeaderTimestamp, timestamp)
+	header.Set(slack.HeaderSignature, slack.Sign(testSigningSecret, timestamp, body))
+
+	require.NoError(t, slack.Verify(testSigningSecret, header, body, now))
+	require.ErrorIs(t, slack.Verify("wrong secret", header, body, now), slack.ErrInvalidSignature)
+	require.ErrorIs(t, slack.Verify(testSigningSecret, header, append(body, '&'), now), slack.ErrInvalidSignature)
+	require.ErrorIs(t, slack.Verify(testSigningSecret, header, body, now.Add(10*time.Minute)), slack.ErrStaleRequest)
+
+	header.Del(slack.HeaderTimestamp)
+	require.ErrorIs(t, slack.Verify(testSigningSecret, header, body, now), slack.ErrInvalidSignature)
+}
+
+func TestAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/", slack.Authenticate(testSigningSecret), func(c *gin.Context) {
+		// The body must be restored so that the handler can parse the form
+		c.String(http.StatusOK, c.PostForm("command"))
+	})
+
+	body, err := os.ReadFile("testdata/command.txt")
+	require.NoError(t, err)
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(slack.HeaderTimestamp, timestamp)
+	req.Header.Set(slack.HeaderSignature, slack.Sign(testSigningSecret, timestamp, body))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "/whisper", w.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	req.Header.Set(slack.HeaderTimestamp, timestamp)
+	req.Header.Set(slack.HeaderSignature, "v0=invalid")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestParseSlashCommand(t *testing.T) {
+	body, err := os.ReadFile("testdata/command.txt")
+	require.NoError(t, err)
+
+	form, err := url.ParseQuery(string(body))
+	require.NoError(t, err)
+
+	cmd, err := slack.ParseSlashCommand(form)
+	require.NoError(t, err)
+	require.Equal(t, "/whisper", cmd.Command)
+	require.Equal(t, "C2147483705", cmd.ChannelID)
+	require.Equal(t, "https://hooks.slack.com/commands/1234/5678", cmd.ResponseURL)
+	require.Equal(t, "13345224609.738474920.8088930838d88f008e0", cmd.TriggerID)
+
+	form.Del("trigger_id")
+	_, err = slack.ParseSlashCommand(form)
+	require.ErrorIs(t, err, slack.ErrMissingField)
+}
+
+func TestParseInteraction(t *testing.T) {
+	payload, err := os.ReadFile("testdata/view_submission.json")
+	require.NoError(t, err)
+
+	in, err := slack.ParseInteraction(string(payload))
+	require.NoError(t, err)
+	require.Equal(t, slack.TypeViewSubmission, in.Type)
+	require.Equal(t, slack.CallbackID, in.View.CallbackID)
+
+	meta, err := in.View.Metadata()
+	require.NoError(t, err)
+	require.Equal(t, "https://hooks.slack.com/commands/1234/5678", meta.ResponseURL)
+
+	form, errs := in.View.Form()
+	require.Empty(t, errs)
+	require.Equal(t, "the eagle flies at midnight", form.Secret)
+	require.Equal(t, "supersecret", form.Password)
+	require.Equal(t, 3, form.Accesses)
+	require.Equal(t, 24*time.Hour, form.Lifetime)
+
+	// A missing secret is a validation error on the secret block
+	delete(in.View.State.Values, slack.BlockSecret)
+	_, errs = in.View.Form()
+	require.Contains(t, errs, slack.BlockSecret)
+
+	_, err = slack.ParseInteraction("")
+	require.ErrorIs(t, err, slack.ErrMissingField)
+}
+
+func TestCreateSecretModal(t *testing.T) {
+	meta := &slack.Metadata{ResponseURL: "https://hooks.slack.com/commands/1234/5678", ChannelID: "C2147483705"}
+	view, err := slack.CreateSecretModal(meta)
+	require.NoError(t, err)
+	require.Equal(t, slack.CallbackID, view["callback_id"])
+
+	// The private metadata must be returned in the view submission
+	parsed := slack.View{PrivateMetadata: view["private_metadata"].(string)}
+	out, err := parsed.Metadata()
+	require.NoError(t, err)
+	require.Equal(t, meta, out)
+
+	_, err = json.Marshal(view)
+	require.NoError(t, err)
+}
+
+func TestClient(t *testing.T) {
+	var opened map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/views.open", r.URL.Path)
+		require.Equal(t, "Bearer xoxb-test", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&opened))
+
+		w.Header().Set("Content-Type", "application/json")
+		if opened["trigger_id"] == "bad" {
+			w.Write([]byte(`{"ok": false, "error": "invalid_trigger_id"}`))
+			return
+		}
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer ts.Close()
+
+	_, err := slack.New(slack.Config{SigningSecret: testSigningSecret})
+	require.Error(t, err, "a bot token is required")
+
+	client, err := slack.New(slack.Config{SigningSecret: testSigningSecret, BotToken: "xoxb-test", Endpoint: ts.URL, WebURL: "https://whisper.example.com/"})
+	require.NoError(t, err)
+	require.Equal(t, "https://whisper.example.com/secret/abc123", client.Link("abc123"))
+
+	view, err := slack.CreateSecretModal(&slack.Metadata{ResponseURL: "https://hooks.slack.com/commands/1234/5678"})
+	require.NoError(t, err)
+	require.NoError(t, client.OpenView(context.Background(), "trigger", view))
+	require.Equal(t, "trigger", opened["trigger_id"])
+	require.Error(t, client.OpenView(context.Background(), "bad", view))
+}
@@ -0,0 +1,82 @@
+package slack
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Headers sent by Slack to sign requests.
+const (
+	HeaderSignature = "X-Slack-Signature"
+	HeaderTimestamp = "X-Slack-Request-Timestamp"
+)
+
+const (
+	signatureVersion = "v0"
+	maxClockSkew     = 5 * time.Minute
+	maxBodySize      = 64 * 1024
+)
+
+// Standard errors for error type checking
+var (
+	ErrInvalidSignature = errors.New("invalid slack request signature")
+	ErrStaleRequest     = errors.New("slack request timestamp is too old")
+)
+
+// Sign computes the Slack signature of the body at the specified timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signatureVersion + ":" + timestamp + ":"))
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks that the request was signed by Slack with the signing secret and that
+// the request is recent enough to prevent replay attacks.
+func Verify(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get(HeaderTimestamp)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if skew := now.Sub(time.Unix(ts, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+		return ErrStaleRequest
+	}
+
+	if !hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(header.Get(HeaderSignature))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Authenticate is gin middleware that verifies the Slack signature of the request body
+// and then restores the body so that it can be parsed by the handler.
+func Authenticate(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBodySize))
+		if err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		if err = Verify(secret, c.Request.Header, body, time.Now()); err != nil {
+			log.Debug().Err(err).Msg("could not verify slack request")
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
@@ -0,0 +1,124 @@
+package whisper_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/rotationalio/whisper/pkg"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/slack"
+)
+
+const testSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+func (s *WhisperTestSuite) TestSlackFlow() {
+	// Fake Slack API that records the modal and the ephemeral response message
+	var (
+		views    []map[string]interface{}
+		messages []*slack.Message
+	)
+	slackapi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/views.open":
+			view := make(map[string]interface{})
+			s.NoError(json.NewDecoder(r.Body).Decode(&view))
+			views = append(views, view)
+			w.Write([]byte(`{"ok": true}`))
+		case "/response":
+			msg := &slack.Message{}
+			s.NoError(json.NewDecoder(r.Body).Decode(msg))
+			messages = append(messages, msg)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer slackapi.Close()
+
+	conf := s.conf
+	conf.Slack = slack.Config{
+		SigningSecret: testSigningSecret,
+		BotToken:      "xoxb-test",
+		Endpoint:      slackapi.URL,
+		WebURL:        "https://whisper.example.com",
+	}
+
+	srv, err := New(conf)
+	s.NoError(err)
+	srv.SetStatus(true, true)
+	router := srv.Routes()
+
+	// Replace the recorded response url with the fake Slack API
+	fixture := func(name string) string {
+		data, err := os.ReadFile(filepath.Join("slack", "testdata", name))
+		s.NoError(err)
+		return strings.ReplaceAll(string(data), url.QueryEscape("https://hooks.slack.com/commands/1234/5678"), url.QueryEscape(slackapi.URL+"/response"))
+	}
+
+	// Requests that are not signed by Slack are rejected
+	command := fixture("command.txt")
+	w := s.sendSlack(router, "/v1/slack/commands", command, "invalid")
+	s.Equal(http.StatusUnauthorized, w.Code)
+	s.Len(views, 0)
+
+	// The slash command opens the create secret modal
+	w = s.sendSlack(router, "/v1/slack/commands", command, testSigningSecret)
+	s.Equal(http.StatusOK, w.Code)
+	s.Len(views, 1)
+	s.Equal("13345224609.738474920.8088930838d88f008e0", views[0]["trigger_id"])
+
+	// Submitting the modal creates the secret and posts an ephemeral link
+	payload := strings.ReplaceAll(fixture("view_submission.json"), "https://hooks.slack.com/commands/1234/5678", slackapi.URL+"/response")
+	w = s.sendSlack(router, "/v1/slack/interactions", url.Values{"payload": {payload}}.Encode(), testSigningSecret)
+	s.Equal(http.StatusOK, w.Code)
+	s.Len(messages, 1)
+	s.Equal("ephemeral", messages[0].ResponseType)
+
+	link := regexp.MustCompile(`https://whisper\.example\.com/secret/([\w-]+)`).FindStringSubmatch(messages[0].Text)
+	s.Len(link, 2)
+
+	// The secret can be fetched with the password from the modal
+	req := httptest.NewRequest(http.MethodGet, "/v1/secrets/"+link[1], nil)
+	req.Header.Set("Authorization", "Bearer "+base64.URLEncoding.EncodeToString([]byte("supersecret")))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+
+	rep := &api.FetchSecretReply{}
+	s.NoError(json.Unmarshal(w.Body.Bytes(), rep))
+	s.Equal("the eagle flies at midnight", rep.Secret)
+	s.Equal(1, rep.Accesses)
+	s.False(rep.Destroyed, "the secret should allow 3 accesses")
+
+	// Validation errors are displayed in the modal rather than creating a secret
+	payload = strings.ReplaceAll(payload, `"value": "the eagle flies at midnight"`, `"value": "  "`)
+	w = s.sendSlack(router, "/v1/slack/interactions", url.Values{"payload": {payload}}.Encode(), testSigningSecret)
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), `"response_action":"errors"`)
+	s.Len(messages, 1)
+
+	// Slack routes are not registered when the integration is disabled
+	w = s.sendSlack(s.router, "/v1/slack/commands", command, testSigningSecret)
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
+// Send a signed form request as Slack would to the specified router.
+func (s *WhisperTestSuite) sendSlack(router http.Handler, path, body, secret string) *httptest.ResponseRecorder {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(slack.HeaderTimestamp, timestamp)
+	req.Header.Set(slack.HeaderSignature, slack.Sign(secret, timestamp, []byte(body)))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
@@ -1,11 +1,16 @@
 package whisper
 
 import (
+	"context"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rotationalio/whisper/pkg/admin"
 	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/audit"
+	"github.com/rotationalio/whisper/pkg/health"
 )
 
 const (
@@ -13,6 +18,7 @@ const (
 	serverStatusNotReady    = "not ready"
 	serverStatusUnhealthy   = "unhealthy"
 	serverStatusMaintenance = "maintenance"
+	serverStatusUnknown     = "unknown"
 )
 
 // Status is an unauthenticated endpoint that returns the status of the api server and
@@ -20,25 +26,47 @@ const (
 // status method, meaning it returns the latest version of the whipser service, no
 // matter how many API versions are available.
 func (s *Server) Status(c *gin.Context) {
-	c.JSON(http.StatusOK, v1.StatusReply{
+	out := v1.StatusReply{
 		Status:  serverStatusOK,
 		Uptime:  time.Since(s.started).String(),
 		Version: Version(),
-	})
+	}
+
+	// The status is only reachable during maintenance if the server is read-only
+	if mode := s.Maintenance(); mode.Enabled {
+		out.Status = serverStatusMaintenance
+		out.Message = mode.Message
+		out.ETA = mode.ETA
+		out.ReadOnly = mode.ReadOnly
+	}
+
+	// The state of the dependencies may reveal internal details so it is admin-only
+	if verbose, _ := strconv.ParseBool(c.Query("verbose")); verbose {
+		if !admin.Verify(s.conf.Admin.Token, c.GetHeader("Authorization")) {
+			s.record(c, audit.AuthFailed, "", errAdminUnauthorized.Error())
+			c.JSON(http.StatusUnauthorized, ErrorReply(c, errAdminUnauthorized))
+			return
+		}
+		out.Components = components(s.health.States())
+	}
+
+	c.JSON(http.StatusOK, out)
 }
 
 // Available is middleware that uses the healthy boolean to return a service unavailable
-// http status code if the server is shutting down. It does this before all routes to
-// ensure that complex handling doesn't bog down the server.
+// http status code if the server is shutting down or in maintenance mode. It does this
+// before all routes to ensure that complex handling doesn't bog down the server. While
+// the server is draining before shutdown requests are still handled.
 func (s *Server) Available() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Check the health and ready status of the server
+		// Check the health, ready, and maintenance status of the server
 		s.RLock()
 		healthy := s.healthy
-		ready := s.ready
+		ready := s.ready || s.draining
+		mode := s.maintenance
 		s.RUnlock()
 
-		if s.conf.Maintenance || !healthy || !ready {
+		if !maintenanceAllows(mode, c) || !healthy || !ready {
 			out := v1.StatusReply{
 				Uptime:  time.Since(s.started).String(),
 				Version: Version(),
@@ -51,6 +79,10 @@ func (s *Server) Available() gin.HandlerFunc {
 				out.Status = serverStatusNotReady
 			default:
 				out.Status = serverStatusMaintenance
+				out.Message = mode.Message
+				out.ETA = mode.ETA
+				out.ReadOnly = mode.ReadOnly
+				retryAfter(c, mode.ETA)
 			}
 
 			// Write the 503 response and stop processing the request
@@ -77,12 +109,18 @@ func (s *Server) Healthz(c *gin.Context) {
 	c.Data(http.StatusOK, "text/plain", []byte(serverStatusOK))
 }
 
-// Readyz is used to alert k8s to the readiness status of the server.
+// Readyz is used to alert k8s to the readiness status of the server. The server is not
+// ready if any of its dependencies are unhealthy, e.g. if the vault credentials expired.
 func (s *Server) Readyz(c *gin.Context) {
 	s.RLock()
 	ready := s.ready
 	s.RUnlock()
 
+	if ready && !s.health.Healthy() {
+		c.Data(http.StatusServiceUnavailable, "text/plain", []byte(serverStatusUnhealthy))
+		return
+	}
+
 	if !ready {
 		c.Data(http.StatusServiceUnavailable, "text/plain", []byte(serverStatusNotReady))
 		return
@@ -90,3 +128,50 @@ func (s *Server) Readyz(c *gin.Context) {
 
 	c.Data(http.StatusOK, "text/plain", []byte(serverStatusOK))
 }
+
+// Health returns the monitor of the server dependencies and is primarily exposed for
+// testing purposes.
+func (s *Server) Health() *health.Monitor {
+	return s.health
+}
+
+// The name of a secret that never exists, used to check access to the vault.
+const healthSentinel = "whisper-health-check"
+
+// Check that the vault can be accessed by looking up a secret that does not exist.
+func (s *Server) checkVault(ctx context.Context) error {
+	_, err := s.vault.Check(ctx, healthSentinel)
+	return err
+}
+
+func components(states []health.State) []*v1.ComponentStatus {
+	out := make([]*v1.ComponentStatus, 0, len(states))
+	for _, state := range states {
+		component := &v1.ComponentStatus{
+			Name:      state.Name,
+			Status:    serverStatusOK,
+			Failures:  state.Failures,
+			LastError: state.LastError,
+		}
+
+		switch {
+		case !state.Healthy:
+			component.Status = serverStatusUnhealthy
+		case state.Checked.IsZero():
+			component.Status = serverStatusUnknown
+		}
+
+		if !state.Checked.IsZero() {
+			checked := state.Checked
+			component.Checked = &checked
+			component.Latency = v1.Duration(state.Latency)
+		}
+
+		if !state.LastErrorAt.IsZero() {
+			errorAt := state.LastErrorAt
+			component.LastErrorAt = &errorAt
+		}
+		out = append(out, component)
+	}
+	return out
+}
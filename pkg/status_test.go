@@ -1,11 +1,22 @@
 package whisper_test
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
 
 	whisper "github.com/rotationalio/whisper/pkg"
+	"github.com/rotationalio/whisper/pkg/admin"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"golang.org/x/net/http2"
 )
 
 func (s *WhisperTestSuite) TestStatus() {
@@ -29,3 +40,145 @@ func (s *WhisperTestSuite) TestStatus() {
 	s.Equal(whisper.Version(), data["version"])
 
 }
+
+func (s *WhisperTestSuite) TestDrain() {
+	// Serve a separate server with HTTP/2 on a unix socket so that it can be shutdown
+	dir, err := os.MkdirTemp("", "whisper")
+	s.Require().NoError(err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "whisper.sock")
+
+	conf := s.conf
+	conf.BindAddr = "unix://" + socket
+	conf.HTTP.H2C = true
+	conf.HTTP.DrainWindow = 500 * time.Millisecond
+	conf.Reaper.Enabled = false
+	srv, err := whisper.New(conf)
+	s.Require().NoError(err)
+
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve() }()
+
+	client := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, _, _ string, _ *tls.Config) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	get := func(path string) (int, int) {
+		rep, err := client.Get("http://whisper" + path)
+		s.Require().NoError(err)
+		rep.Body.Close()
+		return rep.StatusCode, rep.ProtoMajor
+	}
+
+	s.Eventually(func() bool {
+		_, err := os.Stat(socket)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	code, proto := get("/readyz")
+	s.Equal(http.StatusOK, code)
+	s.Equal(2, proto, "expected an h2c connection")
+
+	// While draining the server is not ready but still handles requests
+	stopped := make(chan error, 1)
+	go func() { stopped <- srv.Shutdown() }()
+
+	s.Eventually(func() bool {
+		code, _ := get("/readyz")
+		return code == http.StatusServiceUnavailable
+	}, 400*time.Millisecond, 10*time.Millisecond)
+
+	code, _ = get("/v1/status")
+	s.Equal(http.StatusOK, code)
+
+	s.NoError(<-stopped)
+	s.NoError(<-served)
+	s.NoFileExists(socket)
+}
+
+func (s *WhisperTestSuite) TestHealth() {
+	const token = "Wd8QaqkQ0pRL4hHvRAu5fiR79h3RU0W1XGrXH3qT"
+
+	// Use a separate server so that the failing dependency does not affect other tests
+	conf := s.conf
+	conf.Admin = admin.Config{Token: token}
+	conf.Health.Threshold = 1
+	srv, err := whisper.New(conf)
+	s.Require().NoError(err)
+	srv.SetStatus(true, true)
+
+	var failing atomic.Bool
+	srv.Health().Register("database", func(context.Context) error {
+		if failing.Load() {
+			return errors.New("credentials expired")
+		}
+		return nil
+	})
+
+	server := httptest.NewServer(srv.Routes())
+	defer server.Close()
+
+	readyz := func() int {
+		rep, err := http.Get(server.URL + "/readyz")
+		s.Require().NoError(err)
+		rep.Body.Close()
+		return rep.StatusCode
+	}
+
+	ctx := context.Background()
+	srv.Health().Run(ctx)
+	s.Equal(http.StatusOK, readyz())
+
+	// The server is not ready if a dependency fails but it is still alive
+	failing.Store(true)
+	srv.Health().Run(ctx)
+	s.Equal(http.StatusServiceUnavailable, readyz())
+
+	rep, err := http.Get(server.URL + "/healthz")
+	s.Require().NoError(err)
+	rep.Body.Close()
+	s.Equal(http.StatusOK, rep.StatusCode)
+
+	// The state of the dependencies is only available to admins
+	rep, err = http.Get(server.URL + "/v1/status?verbose=1")
+	s.Require().NoError(err)
+	rep.Body.Close()
+	s.Equal(http.StatusUnauthorized, rep.StatusCode)
+
+	client, err := api.NewAdmin(server.URL, token)
+	s.Require().NoError(err)
+
+	status, err := client.AdminStatus(ctx)
+	s.Require().NoError(err)
+	s.Equal("ok", status.Status)
+	s.Require().Len(status.Components, 3)
+	s.Equal("vault", status.Components[0].Name)
+	s.Equal("ok", status.Components[0].Status)
+	s.NotNil(status.Components[0].Checked)
+	s.Equal("prng", status.Components[1].Name)
+	s.Equal("ok", status.Components[1].Status)
+
+	database := status.Components[2]
+	s.Equal("database", database.Name)
+	s.Equal("unhealthy", database.Status)
+	s.Equal(1, database.Failures)
+	s.Equal("credentials expired", database.LastError)
+	s.NotNil(database.LastErrorAt)
+
+	// The status is not verbose by default
+	public, err := api.New(server.URL)
+	s.Require().NoError(err)
+	status, err = public.Status(ctx)
+	s.Require().NoError(err)
+	s.Empty(status.Components)
+
+	failing.Store(false)
+	srv.Health().Run(ctx)
+	s.Equal(http.StatusOK, readyz())
+}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// Middleware starts a server span for each request, continuing the trace from the W3C
+// traceparent header if present. The span is named by the route pattern and only the
+// method, route, and status code are recorded; the request URL is never recorded since
+// it contains the secret token.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := Propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		name := fmt.Sprintf("%s %s", c.Request.Method, route)
+		if route == "" {
+			name = c.Request.Method
+		}
+
+		ctx, span := Start(ctx, name,
+			semconv.HTTPMethod(c.Request.Method),
+			semconv.HTTPRoute(route),
+		)
+		defer span.End()
+
+		// Make the span available to the handlers via the request context
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int(string(semconv.HTTPStatusCodeKey), status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
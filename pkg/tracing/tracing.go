@@ -0,0 +1,108 @@
+/*
+Package tracing configures OpenTelemetry tracing for the whisper service, exporting
+spans to an OTLP collector. Spans must never carry secrets, passwords, or tokens as
+attributes; only route patterns, method names, and status codes are recorded.
+*/
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the tracer used to instrument whisper.
+const TracerName = "github.com/rotationalio/whisper"
+
+// Config enables exporting traces to an OTLP collector over HTTP.
+type Config struct {
+	Enabled     bool    `default:"false" yaml:"enabled"`
+	Endpoint    string  `required:"false" yaml:"endpoint"`                    // host:port of the OTLP HTTP collector
+	URLPath     string  `split_words:"true" required:"false" yaml:"url_path"` // override the default /v1/traces path
+	Insecure    bool    `default:"false" yaml:"insecure"`                     // export over http rather than https
+	SampleRate  float64 `split_words:"true" default:"1.0" yaml:"sample_rate"`
+	ServiceName string  `split_words:"true" default:"whisper" yaml:"service_name"`
+}
+
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Endpoint == "" {
+		return errors.New("invalid configuration: tracing requires an otlp endpoint")
+	}
+
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return errors.New("invalid configuration: tracing sample rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// Propagator is the W3C trace context propagator used by the server and the client.
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{}, propagation.Baggage{},
+)
+
+// Setup creates the OTLP exporter and registers the global tracer provider. The
+// returned function flushes and stops the exporter and should be called on shutdown.
+func Setup(conf Config, version string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(Propagator)
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(conf.Endpoint)}
+	if conf.URLPath != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(conf.URLPath))
+	}
+	if conf.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	var exporter *otlptrace.Exporter
+	if exporter, err = otlptracehttp.New(context.Background(), opts...); err != nil {
+		return nil, fmt.Errorf("could not create otlp exporter: %w", err)
+	}
+
+	var rsrc *resource.Resource
+	if rsrc, err = resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(conf.ServiceName),
+		semconv.ServiceVersion(version),
+	)); err != nil {
+		return nil, fmt.Errorf("could not create otel resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(rsrc),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRate))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start a span with the whisper tracer from the global tracer provider. If tracing is
+// not enabled the span is a no-op.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End the span, marking it as failed if an error occurred. The error message is not
+// recorded since errors may include resource names that contain secret tokens; callers
+// can add a safe description instead.
+func End(span trace.Span, err error, description string) {
+	if err != nil {
+		span.SetStatus(codes.Error, description)
+	}
+	span.End()
+}
@@ -0,0 +1,60 @@
+package tracing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rotationalio/whisper/pkg/tracing"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(tracing.Middleware())
+	router.GET("/v1/secrets/:token", func(c *gin.Context) {
+		// Handlers must be able to create child spans from the request context
+		_, span := tracing.Start(c.Request.Context(), "child")
+		span.End()
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/secrets/supersecrettoken", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	child, server := spans[0], spans[1]
+	require.Equal(t, "GET /v1/secrets/:token", server.Name())
+	require.Equal(t, server.SpanContext().SpanID(), child.Parent().SpanID())
+
+	// The trace is continued from the traceparent header
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.Equal(t, traceID, server.SpanContext().TraceID())
+
+	// The token in the URL must never be recorded on the span
+	for _, attr := range server.Attributes() {
+		require.False(t, strings.Contains(attr.Value.Emit(), "supersecrettoken"), "span attribute %s contains the token", attr.Key)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	require.NoError(t, tracing.Config{}.Validate())
+	require.Error(t, tracing.Config{Enabled: true, SampleRate: 1}.Validate())
+	require.Error(t, tracing.Config{Enabled: true, Endpoint: "localhost:4318", SampleRate: 2}.Validate())
+	require.NoError(t, tracing.Config{Enabled: true, Endpoint: "localhost:4318", SampleRate: 1}.Validate())
+}
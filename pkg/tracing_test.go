@@ -0,0 +1,68 @@
+package whisper_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/rotationalio/whisper/pkg"
+	"github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func (s *WhisperTestSuite) TestTracing() {
+	// Restore the global tracer provider that is replaced when tracing is enabled
+	prev := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prev)
+
+	conf := s.conf
+	conf.Tracing = tracing.Config{Enabled: true, Endpoint: "localhost:4318", Insecure: true, SampleRate: 1, ServiceName: "whisper"}
+	srv, err := New(conf)
+	s.NoError(err)
+	srv.SetStatus(true, true)
+
+	// Record spans in memory rather than exporting them to the collector
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	const (
+		secret   = "the eagle flies at midnight"
+		password = "supersecretpassword"
+	)
+
+	prevRouter := s.router
+	s.router = srv.Routes()
+	defer func() { s.router = prevRouter }()
+	rep := s.sendCreateSecret(&api.CreateSecretRequest{Secret: secret, Password: password}, http.StatusCreated)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/secrets/"+rep.Token, nil)
+	req.Header.Set("Authorization", "Bearer "+base64.URLEncoding.EncodeToString([]byte(password)))
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+
+	names := make(map[string]int)
+	for _, span := range recorder.Ended() {
+		names[span.Name()]++
+
+		// No span attribute or status may carry the secret, the password, or the token
+		for _, attr := range span.Attributes() {
+			for _, sensitive := range []string{secret, password, rep.Token} {
+				s.False(strings.Contains(attr.Value.Emit(), sensitive), "span %q attribute %s contains sensitive data", span.Name(), attr.Key)
+			}
+		}
+		s.NotContains(span.Status().Description, rep.Token)
+	}
+
+	s.Equal(1, names["POST /v1/secrets"])
+	s.Equal(1, names["GET /v1/secrets/:token"])
+	s.Equal(1, names["vault.New"])
+	s.Equal(1, names["vault.Fetch"])
+	s.Equal(1, names["passwd.CreateDerivedKey"])
+	s.Equal(1, names["passwd.VerifyDerivedKey"])
+	s.Greater(names["google.cloud.secretmanager.v1.SecretManagerService/AccessSecretVersion"], 0)
+}
@@ -0,0 +1,81 @@
+package vault
+
+import (
+	"context"
+	"time"
+
+	smpb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go"
+	"github.com/rotationalio/whisper/pkg/metrics"
+	"github.com/rotationalio/whisper/pkg/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
+)
+
+// instrumentedClient wraps a secretManagerClient to record the latency of each RPC and
+// to trace each RPC as a child span. The requests are not recorded on the span since
+// the resource names contain the secret tokens.
+type instrumentedClient struct {
+	client secretManagerClient
+}
+
+// Ensure the instrumented client implements the secretManagerClient interface
+var _ secretManagerClient = &instrumentedClient{}
+
+func (c *instrumentedClient) GetSecret(ctx context.Context, req *smpb.GetSecretRequest, opts ...gax.CallOption) (rep *smpb.Secret, err error) {
+	ctx, span := start(ctx, "GetSecret")
+	defer observe(span, "GetSecret", time.Now(), &err)
+	return c.client.GetSecret(ctx, req, opts...)
+}
+
+func (c *instrumentedClient) CreateSecret(ctx context.Context, req *smpb.CreateSecretRequest, opts ...gax.CallOption) (rep *smpb.Secret, err error) {
+	ctx, span := start(ctx, "CreateSecret")
+	defer observe(span, "CreateSecret", time.Now(), &err)
+	return c.client.CreateSecret(ctx, req, opts...)
+}
+
+func (c *instrumentedClient) AddSecretVersion(ctx context.Context, req *smpb.AddSecretVersionRequest, opts ...gax.CallOption) (rep *smpb.SecretVersion, err error) {
+	ctx, span := start(ctx, "AddSecretVersion")
+	defer observe(span, "AddSecretVersion", time.Now(), &err)
+	return c.client.AddSecretVersion(ctx, req, opts...)
+}
+
+func (c *instrumentedClient) AccessSecretVersion(ctx context.Context, req *smpb.AccessSecretVersionRequest, opts ...gax.CallOption) (rep *smpb.AccessSecretVersionResponse, err error) {
+	ctx, span := start(ctx, "AccessSecretVersion")
+	defer observe(span, "AccessSecretVersion", time.Now(), &err)
+	return c.client.AccessSecretVersion(ctx, req, opts...)
+}
+
+func (c *instrumentedClient) DeleteSecret(ctx context.Context, req *smpb.DeleteSecretRequest, opts ...gax.CallOption) (err error) {
+	ctx, span := start(ctx, "DeleteSecret")
+	defer observe(span, "DeleteSecret", time.Now(), &err)
+	return c.client.DeleteSecret(ctx, req, opts...)
+}
+
+func (c *instrumentedClient) ListSecrets(ctx context.Context, req *smpb.ListSecretsRequest, opts ...gax.CallOption) (rep []*smpb.Secret, err error) {
+	ctx, span := start(ctx, "ListSecrets")
+	defer observe(span, "ListSecrets", time.Now(), &err)
+	return c.client.ListSecrets(ctx, req, opts...)
+}
+
+const rpcService = "google.cloud.secretmanager.v1.SecretManagerService"
+
+// Start a client span for the RPC.
+func start(ctx context.Context, method string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, rpcService+"/"+method,
+		semconv.RPCSystemKey.String("grpc"),
+		semconv.RPCService(rpcService),
+		semconv.RPCMethod(method),
+	)
+}
+
+// Record the latency of the RPC labeled by the gRPC status code of the error and end
+// the span with the status code rather than the error message.
+func observe(span trace.Span, method string, started time.Time, err *error) {
+	code := status.Code(*err)
+	metrics.VaultLatency.WithLabelValues(method, code.String()).Observe(time.Since(started).Seconds())
+
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	tracing.End(span, *err, code.String())
+}
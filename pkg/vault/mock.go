@@ -3,8 +3,10 @@ package vault
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	smpb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
@@ -23,13 +25,16 @@ import (
 func NewMock(conf config.GoogleConfig) (*SecretManager, error) {
 	return &SecretManager{
 		parent: fmt.Sprintf("projects/%s", conf.Project),
-		client: &mockSecretManagerClient{
-			secrets: make(map[string]*mockSecret),
+		client: &instrumentedClient{
+			client: &mockSecretManagerClient{
+				secrets: make(map[string]*mockSecret),
+			},
 		},
 	}, nil
 }
 
 type mockSecretManagerClient struct {
+	sync.RWMutex
 	secrets map[string]*mockSecret
 }
 
@@ -42,6 +47,9 @@ type mockSecret struct {
 
 func (c *mockSecretManagerClient) GetSecret(ctx context.Context, req *smpb.GetSecretRequest, opts ...gax.CallOption) (*smpb.Secret, error) {
 	log.Warn().Str("method", "GetSecret").Msg("mock secret manager called")
+	c.RLock()
+	defer c.RUnlock()
+
 	// Check if secret is in the mock database
 	if secret, ok := c.secrets[req.Name]; ok && secret.Expires.After(time.Now()) {
 		return &smpb.Secret{
@@ -56,6 +64,9 @@ func (c *mockSecretManagerClient) GetSecret(ctx context.Context, req *smpb.GetSe
 
 func (c *mockSecretManagerClient) CreateSecret(ctx context.Context, req *smpb.CreateSecretRequest, opts ...gax.CallOption) (*smpb.Secret, error) {
 	log.Warn().Str("method", "CreateSecret").Msg("mock secret manager called")
+	c.Lock()
+	defer c.Unlock()
+
 	if req.Parent == "" || req.SecretId == "" {
 		return nil, status.Error(codes.InvalidArgument, "missing parent or secret id")
 	}
@@ -99,6 +110,9 @@ func (c *mockSecretManagerClient) CreateSecret(ctx context.Context, req *smpb.Cr
 
 func (c *mockSecretManagerClient) AddSecretVersion(ctx context.Context, req *smpb.AddSecretVersionRequest, opts ...gax.CallOption) (*smpb.SecretVersion, error) {
 	log.Warn().Str("method", "AddSecretVersion").Msg("mock secret manager called")
+	c.Lock()
+	defer c.Unlock()
+
 	if req.Parent == "" {
 		return nil, status.Error(codes.InvalidArgument, "missing parent")
 	}
@@ -127,6 +141,9 @@ func (c *mockSecretManagerClient) AddSecretVersion(ctx context.Context, req *smp
 
 func (c *mockSecretManagerClient) AccessSecretVersion(ctx context.Context, req *smpb.AccessSecretVersionRequest, opts ...gax.CallOption) (*smpb.AccessSecretVersionResponse, error) {
 	log.Warn().Str("method", "AccessSecretVersion").Msg("mock secret manager called")
+	c.Lock()
+	defer c.Unlock()
+
 	if req.Name == "" {
 		return nil, status.Error(codes.InvalidArgument, "missing secret version name")
 	}
@@ -157,7 +174,7 @@ func (c *mockSecretManagerClient) AccessSecretVersion(ctx context.Context, req *
 		}
 	}
 
-	if idx >= int64(len(secret.Versions)) {
+	if idx < 0 || idx >= int64(len(secret.Versions)) {
 		return nil, status.Error(codes.NotFound, "version not found")
 	}
 
@@ -171,6 +188,9 @@ func (c *mockSecretManagerClient) AccessSecretVersion(ctx context.Context, req *
 
 func (c *mockSecretManagerClient) DeleteSecret(ctx context.Context, req *smpb.DeleteSecretRequest, opts ...gax.CallOption) error {
 	log.Warn().Str("method", "DeleteSecret").Msg("mock secret manager called")
+	c.Lock()
+	defer c.Unlock()
+
 	if req.Name == "" {
 		return status.Error(codes.InvalidArgument, "missing secret name")
 	}
@@ -186,3 +206,28 @@ func (c *mockSecretManagerClient) DeleteSecret(ctx context.Context, req *smpb.De
 	}
 	return nil
 }
+
+func (c *mockSecretManagerClient) ListSecrets(ctx context.Context, req *smpb.ListSecretsRequest, opts ...gax.CallOption) ([]*smpb.Secret, error) {
+	log.Warn().Str("method", "ListSecrets").Msg("mock secret manager called")
+	c.RLock()
+	defer c.RUnlock()
+
+	if req.Parent == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing parent")
+	}
+
+	// Expired secrets are not listed since they are deleted by secret manager
+	secrets := make([]*smpb.Secret, 0, len(c.secrets))
+	for _, secret := range c.secrets {
+		if strings.HasPrefix(secret.Name, req.Parent+"/secrets/") && secret.Expires.After(time.Now()) {
+			secrets = append(secrets, &smpb.Secret{
+				Name:       secret.Name,
+				CreateTime: timestamppb.New(secret.Created),
+				Expiration: &smpb.Secret_ExpireTime{ExpireTime: timestamppb.New(secret.Expires)},
+			})
+		}
+	}
+
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].Name < secrets[j].Name })
+	return secrets, nil
+}
@@ -0,0 +1,270 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	smpb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go"
+	"github.com/rotationalio/whisper/pkg/tracing"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/api/iterator"
+)
+
+// Reasons that a secret is destroyed by Reap.
+type Reason string
+
+const (
+	Expired   Reason = "expired"   // the expiration time of the secret has passed
+	Exhausted Reason = "exhausted" // the secret has been fetched the allowed number of times
+	Orphaned  Reason = "orphaned"  // the secret or its metadata is missing or invalid
+)
+
+// Entry describes a whisper secret in the vault by its token and which of the secret and
+// its metadata are stored, so that incomplete secrets can be detected.
+type Entry struct {
+	Token    string
+	Secret   bool      // the secret payload is stored
+	Metadata bool      // the secret metadata is stored
+	Created  time.Time // the time the first part of the secret was stored
+	Expires  time.Time // the time the backend will delete the secret, may be zero
+}
+
+// ReapStats counts the secrets that were listed and destroyed by Reap.
+type ReapStats struct {
+	Listed    int
+	Expired   int
+	Exhausted int
+	Orphaned  int
+	Failed    int
+}
+
+// Reaped returns the total number of secrets that were destroyed.
+func (s *ReapStats) Reaped() int {
+	return s.Expired + s.Exhausted + s.Orphaned
+}
+
+// TokenLength is the number of random bytes in the tokens that whisper stores secrets
+// with before the bytes are URL safe base64 encoded.
+const TokenLength = 32
+
+// IsToken returns true if the token has the format of the tokens generated by whisper so
+// that other secrets in the same project are never listed, reaped, or purged.
+func IsToken(token string) bool {
+	if len(token) != base64.RawURLEncoding.EncodedLen(TokenLength) {
+		return false
+	}
+
+	_, err := base64.RawURLEncoding.DecodeString(token)
+	return err == nil
+}
+
+// List all of the whisper secrets in the vault. Secrets are identified by their name,
+// which is a token with the format generated by whisper and the secret or metadata
+// suffix; other secrets in the project are not listed.
+func (sm *SecretManager) List(ctx context.Context) (entries []*Entry, err error) {
+	sctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	var secrets []*smpb.Secret
+	if secrets, err = sm.client.ListSecrets(sctx, &smpb.ListSecretsRequest{Parent: sm.parent}); err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]*Entry)
+	prefix := sm.parent + "/secrets/"
+	for _, secret := range secrets {
+		if !strings.HasPrefix(secret.Name, prefix) {
+			continue
+		}
+
+		var (
+			token    string
+			isSecret bool
+		)
+		name := strings.TrimPrefix(secret.Name, prefix)
+		switch {
+		case strings.HasSuffix(name, "-"+SuffixSecret):
+			token, isSecret = strings.TrimSuffix(name, "-"+SuffixSecret), true
+		case strings.HasSuffix(name, "-"+SuffixMetadata):
+			token = strings.TrimSuffix(name, "-"+SuffixMetadata)
+		default:
+			continue
+		}
+
+		if !IsToken(token) {
+			continue
+		}
+
+		entry, ok := index[token]
+		if !ok {
+			entry = &Entry{Token: token}
+			index[token] = entry
+			entries = append(entries, entry)
+		}
+
+		if isSecret {
+			entry.Secret = true
+		} else {
+			entry.Metadata = true
+		}
+
+		if created := secret.CreateTime.AsTime(); entry.Created.IsZero() || created.Before(entry.Created) {
+			entry.Created = created
+		}
+
+		if expires := secret.GetExpireTime(); expires != nil && (entry.Expires.IsZero() || expires.AsTime().After(entry.Expires)) {
+			entry.Expires = expires.AsTime()
+		}
+	}
+	return entries, nil
+}
+
+// Reap lists the secrets in the vault and destroys any secrets that have expired, that
+// have been fetched the allowed number of times, or that are orphaned (e.g. a secret
+// without metadata). Secrets are usually destroyed when they are fetched or by the
+// expiration of the backend, but secrets that are never fetched or that failed to be
+// destroyed would otherwise accumulate. Incomplete secrets that were created within
+// the grace period are skipped since they may still be in the process of being created.
+// The reaped callback is called with the token and the metadata of each secret that is
+// destroyed; the metadata is not loaded if the secret is orphaned.
+func (sm *SecretManager) Reap(ctx context.Context, grace time.Duration, reaped func(token string, reason Reason, meta *SecretContext)) (stats *ReapStats, err error) {
+	ctx, span := tracing.Start(ctx, "vault.Reap")
+	defer func() { tracing.End(span, err, "could not reap secrets") }()
+
+	var entries []*Entry
+	if entries, err = sm.List(ctx); err != nil {
+		return nil, err
+	}
+
+	stats = &ReapStats{Listed: len(entries)}
+	for _, entry := range entries {
+		// Stop reaping if the server is shutting down
+		if err = ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		var (
+			reason Reason
+			meta   *SecretContext
+		)
+		if reason, meta, err = sm.reap(ctx, entry, grace); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("could not reap secret")
+			stats.Failed++
+			continue
+		}
+
+		switch reason {
+		case Expired:
+			stats.Expired++
+		case Exhausted:
+			stats.Exhausted++
+		case Orphaned:
+			stats.Orphaned++
+		default:
+			continue
+		}
+
+		if reaped != nil {
+			reaped(entry.Token, reason, meta)
+		}
+	}
+	return stats, nil
+}
+
+// Destroys the secret if it is no longer valid, returning the reason it was destroyed
+// and its metadata or an empty reason if the secret is valid (or too new to be
+// considered orphaned). The secret is locked so that it is not reaped while it is being
+// fetched or destroyed.
+func (sm *SecretManager) reap(ctx context.Context, entry *Entry, grace time.Duration) (_ Reason, meta *SecretContext, err error) {
+	unlock := sm.locks.lock(entry.Token)
+	defer unlock()
+
+	settled := time.Since(entry.Created) >= grace
+	meta = sm.With(entry.Token)
+
+	// A secret without metadata can never be fetched
+	if !entry.Metadata {
+		if !settled {
+			return "", nil, nil
+		}
+		return Orphaned, meta, ignoreNotFound(meta.Delete(ctx, SuffixSecret))
+	}
+
+	if err = meta.Load(ctx, false); err != nil {
+		if !errors.Is(err, ErrSecretNotFound) {
+			return "", nil, err
+		}
+
+		// The metadata exists but has no versions
+		if !settled {
+			return "", nil, nil
+		}
+		return Orphaned, meta, sm.purge(ctx, entry)
+	}
+
+	switch {
+	case meta.Expired():
+		return Expired, meta, sm.purge(ctx, entry)
+	case meta.Exhausted():
+		return Exhausted, meta, sm.purge(ctx, entry)
+	case !meta.Valid():
+		// The metadata is not initialized correctly
+		return Orphaned, meta, sm.purge(ctx, entry)
+	case !entry.Secret && !meta.Pending() && settled:
+		// Only an unfulfilled secret request has metadata without a secret
+		return Orphaned, meta, sm.purge(ctx, entry)
+	}
+	return "", nil, nil
+}
+
+// Purge deletes the parts of the secret that are stored without password verification.
+// It is used to destroy secrets that cannot be fetched and by operators. The secret is
+// locked so that it is not purged while it is being fetched or destroyed.
+func (sm *SecretManager) Purge(ctx context.Context, entry *Entry) (err error) {
+	unlock := sm.locks.lock(entry.Token)
+	defer unlock()
+	return sm.purge(ctx, entry)
+}
+
+func (sm *SecretManager) purge(ctx context.Context, entry *Entry) (err error) {
+	meta := sm.With(entry.Token)
+	if entry.Secret {
+		if err = ignoreNotFound(meta.Delete(ctx, SuffixSecret)); err != nil {
+			return err
+		}
+	}
+	return ignoreNotFound(meta.Delete(ctx, SuffixMetadata))
+}
+
+// The secret may have been destroyed by a fetch or expired while being reaped.
+func ignoreNotFound(err error) error {
+	if errors.Is(err, ErrSecretNotFound) {
+		return nil
+	}
+	return err
+}
+
+// gsmClient adapts the Google Secret Manager client to the secretManagerClient interface.
+type gsmClient struct {
+	*secretmanager.Client
+}
+
+// ListSecrets collects all of the pages of secrets from the iterator.
+func (c *gsmClient) ListSecrets(ctx context.Context, req *smpb.ListSecretsRequest, opts ...gax.CallOption) (secrets []*smpb.Secret, err error) {
+	iter := c.Client.ListSecrets(ctx, req, opts...)
+	for {
+		var secret *smpb.Secret
+		if secret, err = iter.Next(); err != nil {
+			if errors.Is(err, iterator.Done) {
+				return secrets, nil
+			}
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+}
@@ -0,0 +1,143 @@
+package vault_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rotationalio/whisper/pkg/config"
+	"github.com/rotationalio/whisper/pkg/vault"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *VaultTestSuite) TestReap() {
+	// Use a separate vault so that the secrets of the other tests are not reaped
+	sm, err := vault.NewMock(config.GoogleConfig{Project: "reaper-test-project"})
+	s.NoError(err)
+	ctx := context.Background()
+
+	// A valid secret and a pending secret request are never reaped
+	validToken, requestToken := createToken(), createToken()
+	valid := sm.With(validToken)
+	valid.Accesses = 2
+	valid.Retrievals = 1
+	valid.Created = time.Now()
+	valid.Expires = time.Now().Add(time.Hour)
+	s.NoError(valid.New(ctx, "the eagle flies at midnight"))
+
+	request := sm.With(requestToken)
+	request.Accesses = 1
+	request.Created = time.Now()
+	request.Expires = time.Now().Add(time.Hour)
+	s.NoError(request.NewRequest(ctx))
+
+	// The expiration in the metadata has passed but not the expiration of the backend
+	expired := s.store(sm, createToken(), true, func(meta *vault.SecretContext) {
+		meta.Expires = time.Now().Add(-time.Minute)
+		meta.Callback = "https://example.com/hooks"
+	})
+
+	// The secret has been fetched the allowed number of times but was not destroyed
+	exhausted := s.store(sm, createToken(), true, func(meta *vault.SecretContext) {
+		meta.Retrievals = 1
+	})
+
+	// A secret whose metadata was never stored and metadata whose secret was lost
+	noMetadata := createToken()
+	orphan := sm.With(noMetadata)
+	orphan.Expires = time.Now().Add(time.Hour)
+	s.NoError(orphan.Create(ctx, vault.SuffixSecret))
+	s.NoError(orphan.AddVersion(ctx, vault.SuffixSecret, []byte("stranded")))
+	noSecret := s.store(sm, createToken(), false, nil)
+
+	// Secrets in the project that were not created by whisper are never listed or reaped,
+	// even if they would be considered orphaned when they have a whisper suffix.
+	unrelated := map[string]string{"database-password": vault.SuffixSecret, "api": vault.SuffixMetadata}
+	for name, suffix := range unrelated {
+		other := sm.With(name)
+		other.Expires = time.Now().Add(time.Hour)
+		s.NoError(other.Create(ctx, suffix))
+		s.NoError(other.AddVersion(ctx, suffix, []byte("not a whisper secret")))
+	}
+
+	entries, err := sm.List(ctx)
+	s.NoError(err)
+	s.Len(entries, 6)
+
+	// Orphaned secrets are not reaped during the grace period
+	reaped := make(map[string]vault.Reason)
+	callbacks := make(map[string]string)
+	record := func(token string, reason vault.Reason, meta *vault.SecretContext) {
+		reaped[token] = reason
+		callbacks[token] = meta.Callback
+	}
+
+	stats, err := sm.Reap(ctx, time.Hour, record)
+	s.NoError(err)
+	s.Equal(&vault.ReapStats{Listed: 6, Expired: 1, Exhausted: 1}, stats)
+	s.Equal(map[string]vault.Reason{expired: vault.Expired, exhausted: vault.Exhausted}, reaped)
+
+	// The metadata is passed to the callback so that the creator can be notified
+	s.Equal("https://example.com/hooks", callbacks[expired])
+
+	stats, err = sm.Reap(ctx, 0, record)
+	s.NoError(err)
+	s.Equal(&vault.ReapStats{Listed: 4, Orphaned: 2}, stats)
+	s.Equal(vault.Orphaned, reaped[noMetadata])
+	s.Equal(vault.Orphaned, reaped[noSecret])
+	s.Equal(2, stats.Reaped())
+
+	// Only the valid secret and the request remain
+	entries, err = sm.List(ctx)
+	s.NoError(err)
+	s.Len(entries, 2)
+	for _, entry := range entries {
+		s.Contains([]string{validToken, requestToken}, entry.Token)
+	}
+
+	stats, err = sm.Reap(ctx, 0, nil)
+	s.NoError(err)
+	s.Equal(&vault.ReapStats{Listed: 2}, stats)
+
+	// The other secrets in the project have not been destroyed
+	for name, suffix := range unrelated {
+		other := sm.With(name)
+		other.Expires = time.Now().Add(time.Hour)
+		s.ErrorIs(other.Create(ctx, suffix), vault.ErrAlreadyExists, "%s was reaped", name)
+	}
+}
+
+func TestIsToken(t *testing.T) {
+	require.True(t, vault.IsToken(createToken()))
+	require.False(t, vault.IsToken("database-password"))
+	require.False(t, vault.IsToken(""))
+	require.False(t, vault.IsToken(strings.Repeat("a", 42)+"!"))
+	require.False(t, vault.IsToken(createToken()+"a"))
+}
+
+// Store a secret whose metadata is modified after the secret is created (e.g. so that
+// it is no longer valid), optionally without storing the secret itself.
+func (s *VaultTestSuite) store(sm *vault.SecretManager, token string, secret bool, modify func(*vault.SecretContext)) string {
+	ctx := context.Background()
+	meta := sm.With(token)
+	meta.Accesses = 1
+	meta.Created = time.Now()
+	meta.Expires = time.Now().Add(time.Hour)
+
+	s.NoError(meta.Create(ctx, vault.SuffixMetadata))
+	if secret {
+		s.NoError(meta.Create(ctx, vault.SuffixSecret))
+		s.NoError(meta.AddVersion(ctx, vault.SuffixSecret, []byte("the eagle flies at midnight")))
+	}
+
+	if modify != nil {
+		modify(meta)
+	}
+
+	data, err := json.Marshal(meta)
+	s.NoError(err)
+	s.NoError(meta.AddVersion(ctx, vault.SuffixMetadata, data))
+	return token
+}
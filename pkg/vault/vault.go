@@ -5,13 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	smpb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	"github.com/rotationalio/whisper/pkg/config"
+	"github.com/rotationalio/whisper/pkg/metrics"
 	"github.com/rotationalio/whisper/pkg/passwd"
+	"github.com/rotationalio/whisper/pkg/tracing"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -32,6 +36,14 @@ var (
 	ErrPermissionDenied = errors.New("secret manager permission denied")
 	ErrNotAuthorized    = errors.New("correct password required")
 	ErrNotLoaded        = errors.New("secret context needs to be loaded")
+	ErrNotRequest       = errors.New("secret context is not a secret request")
+	ErrRequestPending   = errors.New("secret request has not been fulfilled")
+	ErrRequestFulfilled = errors.New("secret request has already been fulfilled")
+	ErrPasswordAttempts = errors.New("too many incorrect password attempts, secret destroyed")
+
+	// ErrPasswordRequired is returned instead of ErrNotAuthorized if no password was
+	// supplied for a password protected secret; it is also an ErrNotAuthorized.
+	ErrPasswordRequired = fmt.Errorf("%w", ErrNotAuthorized)
 )
 
 // New creates and returns a client to access the Google Secret Manager.
@@ -49,10 +61,14 @@ func New(conf config.GoogleConfig) (sm *SecretManager, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if sm.client, err = secretmanager.NewClient(ctx); err != nil {
+	var client *secretmanager.Client
+	if client, err = secretmanager.NewClient(ctx); err != nil {
 		return nil, fmt.Errorf("could not connect to secret manager: %s", err)
 	}
 
+	// Record the latency of all RPCs to the secret manager
+	sm.client = &instrumentedClient{client: &gsmClient{client}}
+
 	return sm, nil
 }
 
@@ -62,6 +78,49 @@ func New(conf config.GoogleConfig) (sm *SecretManager, err error) {
 type SecretManager struct {
 	parent string
 	client secretManagerClient
+	locks  tokenLocks
+}
+
+// tokenLocks serializes the updates to the metadata of a secret, which are read, modified,
+// and written as a new version without any concurrency control from the secret manager.
+// Otherwise concurrent fetches could overwrite each other's accesses and failures, e.g.
+// so that incorrect password guesses sent in parallel are not counted. Updates are only
+// serialized within a server process, not across replicas of the server.
+type tokenLocks struct {
+	sync.Mutex
+	locks map[string]*tokenLock
+}
+
+type tokenLock struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock the token, returning the function that unlocks it. Locks are removed when they
+// are no longer held or waited on so that the locks do not grow with every secret.
+func (l *tokenLocks) lock(token string) (unlock func()) {
+	l.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*tokenLock)
+	}
+
+	tl, ok := l.locks[token]
+	if !ok {
+		tl = &tokenLock{}
+		l.locks[token] = tl
+	}
+	tl.refs++
+	l.Unlock()
+
+	tl.Lock()
+	return func() {
+		tl.Unlock()
+		l.Lock()
+		if tl.refs--; tl.refs == 0 {
+			delete(l.locks, token)
+		}
+		l.Unlock()
+	}
 }
 
 // With extracts a secret context with the information required to fetch a secret from
@@ -97,7 +156,7 @@ func (sm *SecretManager) Check(ctx context.Context, token string) (_ bool, err e
 		serr, ok := status.FromError(err)
 		if ok {
 			// Log the original message since it will be subsumed by the error check
-			log.Debug().Err(err).Msg("get secret rpc error")
+			log.Ctx(ctx).Debug().Err(err).Msg("get secret rpc error")
 
 			switch serr.Code() {
 			case codes.NotFound:
@@ -126,14 +185,26 @@ func (sm *SecretManager) Check(ctx context.Context, token string) (_ bool, err e
 // including using the derived key algorithm for password verification and checking.
 type SecretContext struct {
 	// External information that is serialized and stored in the secret manager.
-	Password     string    `json:"password,omitempty"` // the argon2 hashed password for comparision
-	Filename     string    `json:"filename,omitempty"` // if the secret is a file, the name of the file for download
-	IsBase64     bool      `json:"is_base64"`          // if the secret is base64 encoded or not
-	Accesses     int       `json:"accesses"`           // the number of allowed accesses for the secret
-	Retrievals   int       `json:"retrievals"`         // counts the number of times the secret has been accessed
-	Created      time.Time `json:"created"`            // the timestamp the secret was created
-	LastAccessed time.Time `json:"last_accessed"`      // the timestamp that the secret was last accessed
-	Expires      time.Time `json:"expires"`            // the timestamp when the secret will have expired
+	Password     string    `json:"password,omitempty"`     // the argon2 hashed password for comparision
+	Filename     string    `json:"filename,omitempty"`     // if the secret is a file, the name of the file for download
+	IsBase64     bool      `json:"is_base64"`              // if the secret is base64 encoded or not
+	Accesses     int       `json:"accesses"`               // the number of allowed accesses for the secret
+	Retrievals   int       `json:"retrievals"`             // counts the number of times the secret has been accessed
+	Created      time.Time `json:"created"`                // the timestamp the secret was created
+	LastAccessed time.Time `json:"last_accessed"`          // the timestamp that the secret was last accessed
+	Expires      time.Time `json:"expires"`                // the timestamp when the secret will have expired
+	Request      bool      `json:"request,omitempty"`      // if the context is a request for a secret rather than a secret
+	Fulfilled    bool      `json:"fulfilled,omitempty"`    // if the secret request has been responded to with a secret
+	Failures     int       `json:"failures,omitempty"`     // counts the number of incorrect password attempts
+	MaxFailures  int       `json:"max_failures,omitempty"` // the number of incorrect password attempts before the secret is destroyed
+	Callback     string    `json:"callback,omitempty"`     // a webhook URL to notify when the secret is accessed or destroyed
+	Email        string    `json:"email,omitempty"`        // an email address to notify when the secret is accessed or destroyed
+	Bundle       bool      `json:"bundle,omitempty"`       // if the secret is the JSON encoded items of a bundle
+	Archive      string    `json:"archive,omitempty"`      // the type of archive if the secret is several files packaged by the client
+	Files        []string  `json:"files,omitempty"`        // the files in the archive, listed by the server when the secret is created
+	ContentType  string    `json:"content_type,omitempty"` // the media type of the secret, detected when the secret is created
+	Size         int64     `json:"size,omitempty"`         // the size of the secret in bytes after it is base64 decoded
+	SHA256       string    `json:"sha256,omitempty"`       // the hex encoded SHA-256 digest of the decoded secret
 
 	// Internal information required to access secret manager api.
 	manager *SecretManager // client to make calls to the service
@@ -174,15 +245,17 @@ func (s *SecretContext) Valid() bool {
 		return false
 	}
 
-	if time.Now().After(s.Expires) {
-		return false
-	}
+	return !s.Expired() && !s.Exhausted()
+}
 
-	if s.Accesses > 0 && s.Retrievals >= s.Accesses {
-		return false
-	}
+// Expired returns true if the expiration time of the secret has passed.
+func (s *SecretContext) Expired() bool {
+	return !s.Expires.IsZero() && time.Now().After(s.Expires)
+}
 
-	return true
+// Exhausted returns true if the secret has been retrieved the allowed number of times.
+func (s *SecretContext) Exhausted() bool {
+	return s.Accesses > 0 && s.Retrievals >= s.Accesses
 }
 
 // Access updates the secret metadata on a fetch or other access to the secret.
@@ -194,6 +267,9 @@ func (s *SecretContext) Access() {
 // New creates a new secret and metadata in Google Secret Manager adding the first
 // version to actually store the data. Returns an error if the secret already exists.
 func (s *SecretContext) New(ctx context.Context, secret string) (err error) {
+	ctx, span := tracing.Start(ctx, "vault.New")
+	defer func() { tracing.End(span, err, "could not create secret") }()
+
 	// Marshal the context first so that if anything goes wrong we don't strand data in
 	// Google Secret Manager.
 	var data []byte
@@ -208,50 +284,168 @@ func (s *SecretContext) New(ctx context.Context, secret string) (err error) {
 
 	// Add a version for the metadata
 	if err = s.AddVersion(ctx, SuffixMetadata, data); err != nil {
-		log.Warn().Bool("metadata", true).Bool("secret", false).Msg("incomplete secret creation")
+		log.Ctx(ctx).Warn().Bool("metadata", true).Bool("secret", false).Msg("incomplete secret creation")
 		return fmt.Errorf("could not add metadata version: %s", err)
 	}
 
 	// Create the secret next
 	if err = s.Create(ctx, SuffixSecret); err != nil {
-		log.Warn().Bool("metadata version", true).Bool("secret", false).Msg("incomplete secret creation")
+		log.Ctx(ctx).Warn().Bool("metadata version", true).Bool("secret", false).Msg("incomplete secret creation")
 		return err
 	}
 
 	// Add a version for the secret
 	if err = s.AddVersion(ctx, SuffixSecret, []byte(secret)); err != nil {
-		log.Warn().Bool("metadata version", true).Bool("secret", true).Msg("incomplete secret creation")
+		log.Ctx(ctx).Warn().Bool("metadata version", true).Bool("secret", true).Msg("incomplete secret creation")
 		return fmt.Errorf("could not add secret actual version: %s", err)
 	}
 
 	return nil
 }
 
+// NewRequest creates a request for a secret, which is a secret context whose metadata is
+// stored in Google Secret Manager but without a secret. The secret is added later when
+// someone responds to the request. The password on the context should be set to the
+// owner token so that only the requester can fetch the response.
+func (s *SecretContext) NewRequest(ctx context.Context) (err error) {
+	s.Request = true
+	s.Fulfilled = false
+
+	var data []byte
+	if data, err = json.Marshal(s); err != nil {
+		return fmt.Errorf("could not marshal secret metadata: %s", err)
+	}
+
+	// Create the metadata secret
+	if err = s.Create(ctx, SuffixMetadata); err != nil {
+		return err
+	}
+
+	// Add a version for the metadata
+	if err = s.AddVersion(ctx, SuffixMetadata, data); err != nil {
+		log.Ctx(ctx).Warn().Bool("metadata", true).Bool("request", true).Msg("incomplete secret request creation")
+		return fmt.Errorf("could not add metadata version: %s", err)
+	}
+	return nil
+}
+
+// Respond fulfills a secret request by storing the secret in Google Secret Manager and
+// marking the metadata as fulfilled. The context must be loaded before Respond is called
+// so that the caller can update the file metadata of the secret. A request can only be
+// responded to once and only while it is still valid. Responses are serialized with
+// fetches and destroys of the request and the metadata is reloaded once the request is
+// locked, keeping only the file metadata set by the caller, so that the response does
+// not overwrite changes such as failed attempts to fetch the request by its owner.
+func (s *SecretContext) Respond(ctx context.Context, secret string) (err error) {
+	if !s.loaded {
+		return ErrNotLoaded
+	}
+
+	unlock := s.manager.locks.lock(s.token)
+	defer unlock()
+
+	latest := s.manager.With(s.token)
+	if err = latest.Load(ctx, false); err != nil {
+		return err
+	}
+
+	latest.Filename = s.Filename
+	latest.IsBase64 = s.IsBase64
+	latest.Archive = s.Archive
+	latest.Files = s.Files
+	latest.ContentType = s.ContentType
+	latest.Size = s.Size
+	latest.SHA256 = s.SHA256
+	*s = *latest
+
+	if !s.Request {
+		return ErrNotRequest
+	}
+
+	if s.Fulfilled {
+		return ErrRequestFulfilled
+	}
+
+	if !s.Valid() {
+		return ErrSecretNotFound
+	}
+
+	// Create the secret and add the version with the response
+	if err = s.Create(ctx, SuffixSecret); err != nil {
+		if errors.Is(err, ErrAlreadyExists) {
+			return ErrRequestFulfilled
+		}
+		return err
+	}
+
+	if err = s.AddVersion(ctx, SuffixSecret, []byte(secret)); err != nil {
+		log.Ctx(ctx).Warn().Bool("metadata version", true).Bool("secret", true).Msg("incomplete secret request response")
+		return err
+	}
+
+	// Mark the request as fulfilled so that the owner can fetch the secret
+	s.Fulfilled = true
+
+	var data []byte
+	if data, err = json.Marshal(s); err != nil {
+		return fmt.Errorf("could not marshal secret metadata: %s", err)
+	}
+
+	if err = s.AddVersion(ctx, SuffixMetadata, data); err != nil {
+		return fmt.Errorf("could not update metadata: %s", err)
+	}
+	return nil
+}
+
+// Pending returns true if the context is a secret request that has not been fulfilled.
+func (s *SecretContext) Pending() bool {
+	return s.Request && !s.Fulfilled
+}
+
 // Fetch loads the metadata into the context, then determines if a password is required
 // and validates the password using the derived key algorithm. If the secret metadata is
 // still valid then it returns the secret, updating the accesses, otherwise it returns
 // not found. If the secret is invalid after access, it is destroyed. In either case if
 // the secret is invalid before fetch or destroyed after fetch, the destroyed boolean
-// indicates what happened in the function.
+// indicates what happened in the function. Fetches of the same secret are serialized.
 func (s *SecretContext) Fetch(ctx context.Context, password string) (_ string, destroyed bool, err error) {
-	// First fetch the secret metadata
-	if err = s.Load(ctx, false); err != nil {
+	ctx, span := tracing.Start(ctx, "vault.Fetch")
+	defer func() {
+		span.SetAttributes(attribute.Bool("whisper.destroyed", destroyed))
+		tracing.End(span, err, "could not fetch secret")
+	}()
+
+	// Always reload the metadata once the secret is locked since it may have changed
+	unlock := s.manager.locks.lock(s.token)
+	defer unlock()
+
+	if err = s.Load(ctx, true); err != nil {
 		return "", destroyed, err
 	}
 
 	// Check the secret is valid prior to returning a response (in case a sidechannel
 	// retrieval or race condition failed to destroy the password).
 	if !s.Valid() {
-		log.Warn().Msg("race condition or invalid secret metadata fetched, destroying")
-		if err = s.Destroy(ctx, password); err != nil {
-			log.Error().Err(err).Msg("could not destroy invalid secret")
+		log.Ctx(ctx).Warn().Msg("race condition or invalid secret metadata fetched, destroying")
+		if s.Exhausted() {
+			metrics.Secret(metrics.Exhausted)
+		} else {
+			metrics.Secret(metrics.Expired)
+		}
+		if err = s.destroy(ctx); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("could not destroy invalid secret")
 		}
 		return "", true, ErrSecretNotFound
 	}
 
 	// Check if the password is required and if so, if it matches the derived key.
-	if err = s.VerifyPassword(password); err != nil {
-		return "", destroyed, err
+	if err = s.authorize(ctx, password); err != nil {
+		return "", errors.Is(err, ErrPasswordAttempts), err
+	}
+
+	// A secret request does not have a secret to fetch until it has been fulfilled.
+	if s.Pending() {
+		return "", destroyed, ErrRequestPending
 	}
 
 	// Fetch the latest version of the secret
@@ -276,9 +470,9 @@ func (s *SecretContext) Fetch(ctx context.Context, password string) (_ string, d
 		}
 	} else {
 		// Don't return the error in this case because the secret will eventually expire
-		log.Debug().Msg("destroying now invalid secret after access")
-		if err = s.Destroy(ctx, password); err != nil {
-			log.Error().Err(err).Msg("could not destroy invalid secret after access")
+		log.Ctx(ctx).Debug().Msg("destroying now invalid secret after access")
+		if err = s.destroy(ctx); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("could not destroy invalid secret after access")
 		}
 		destroyed = true
 	}
@@ -289,8 +483,14 @@ func (s *SecretContext) Fetch(ctx context.Context, password string) (_ string, d
 // Destroy both the secret metadata and the secret unless the password is incorrect
 // (returns not authorized) or the secret does not exist (returns not found).
 func (s *SecretContext) Destroy(ctx context.Context, password string) (err error) {
-	// First load the secret metadata - won't load if already loaded.
-	if err = s.Load(ctx, false); err != nil {
+	ctx, span := tracing.Start(ctx, "vault.Destroy")
+	defer func() { tracing.End(span, err, "could not destroy secret") }()
+
+	// Reload the secret metadata once the secret is locked so that failures are counted
+	unlock := s.manager.locks.lock(s.token)
+	defer unlock()
+
+	if err = s.Load(ctx, true); err != nil {
 		return err
 	}
 
@@ -298,14 +498,20 @@ func (s *SecretContext) Destroy(ctx context.Context, password string) (err error
 	// Otherwise anyone could destroy a secret. This only matters if the secret is still
 	// valid, if it's not valid; destroy no matter what the password is.
 	if s.Valid() {
-		if err = s.VerifyPassword(password); err != nil {
+		if err = s.authorize(ctx, password); err != nil {
 			return err
 		}
 	}
+	return s.destroy(ctx)
+}
 
-	// Delete the secret first
-	if err = s.Delete(ctx, SuffixSecret); err != nil {
-		return fmt.Errorf("could not delete secret actual: %s", err)
+// Delete the secret and the metadata without any password verification.
+func (s *SecretContext) destroy(ctx context.Context) (err error) {
+	// Delete the secret first; a pending secret request has no secret to delete.
+	if !s.Pending() {
+		if err = s.Delete(ctx, SuffixSecret); err != nil {
+			return fmt.Errorf("could not delete secret actual: %s", err)
+		}
 	}
 
 	// Delete the metadata last
@@ -377,7 +583,7 @@ func (s *SecretContext) Create(ctx context.Context, suffix string) (err error) {
 		// If the secret already exists, return an error that can be checked
 		serr, ok := status.FromError(err)
 		if ok {
-			log.Debug().Str("code", serr.Code().String()).Msg(serr.Message())
+			log.Ctx(ctx).Debug().Str("code", serr.Code().String()).Msg(serr.Message())
 			switch serr.Code() {
 			case codes.AlreadyExists:
 				return ErrAlreadyExists
@@ -422,13 +628,14 @@ func (s *SecretContext) AddVersion(ctx context.Context, suffix string, payload [
 		// If this is not a context error, attempt to parse the gRPC status error
 		serr, ok := status.FromError(err)
 		if ok {
-			log.Debug().Err(err).Msg("add secret version rpc error")
+			log.Ctx(ctx).Debug().Err(err).Msg("add secret version rpc error")
 			switch serr.Code() {
 			case codes.NotFound:
 				// If the secret doesn't exist (e.g. not created yet or deleted)
 				return ErrSecretNotFound
 			case codes.InvalidArgument:
 				// Maximum size limit of 65KiB for the payload
+				metrics.Secret(metrics.SizeLimit)
 				return ErrFileSizeLimit
 			case codes.PermissionDenied, codes.Unauthenticated:
 				// If we've given a wrong path, wrong project, or wrong service account
@@ -467,7 +674,7 @@ func (s *SecretContext) LatestVersion(ctx context.Context, suffix string) (_ []b
 		// If this is not a context error, attempt to parse the gRPC status error
 		serr, ok := status.FromError(err)
 		if ok {
-			log.Debug().Err(err).Msg("access secret version rpc error")
+			log.Ctx(ctx).Debug().Err(err).Msg("access secret version rpc error")
 			switch serr.Code() {
 			case codes.NotFound:
 				// If the secret doesn't exist (e.g. not created yet or deleted)
@@ -506,7 +713,7 @@ func (s *SecretContext) Delete(ctx context.Context, suffix string) (err error) {
 		// If this is not a context error, attempt to parse the gRPC status error
 		serr, ok := status.FromError(err)
 		if ok {
-			log.Debug().Err(err).Msg("delete secret rpc error")
+			log.Ctx(ctx).Debug().Err(err).Msg("delete secret rpc error")
 			switch serr.Code() {
 			case codes.NotFound:
 				// If the secret doesn't exist (e.g. not created yet or deleted)
@@ -524,6 +731,43 @@ func (s *SecretContext) Delete(ctx context.Context, suffix string) (err error) {
 	return nil
 }
 
+// authorize verifies the password and tracks the number of incorrect password attempts.
+// If the maximum number of failures is reached, the secret is destroyed and the error
+// ErrPasswordAttempts is returned. Missing passwords are not counted as failures since
+// clients usually attempt a fetch to discover if a password is required.
+func (s *SecretContext) authorize(ctx context.Context, password string) (err error) {
+	// Only whether the password was verified is recorded on the span, never the password
+	_, span := tracing.Start(ctx, "passwd.VerifyDerivedKey", attribute.Bool("whisper.password_required", s.Password != ""))
+	err = s.VerifyPassword(password)
+	span.SetAttributes(attribute.Bool("whisper.verified", err == nil))
+	tracing.End(span, err, "could not verify password")
+
+	if err == nil || !errors.Is(err, ErrNotAuthorized) || password == "" {
+		return err
+	}
+
+	metrics.Secret(metrics.WrongPassword)
+	s.Failures++
+	if s.MaxFailures > 0 && s.Failures >= s.MaxFailures {
+		log.Ctx(ctx).Debug().Int("failures", s.Failures).Msg("destroying secret after too many incorrect password attempts")
+		if err = s.destroy(ctx); err != nil {
+			return fmt.Errorf("could not destroy secret after incorrect password attempts: %s", err)
+		}
+		return ErrPasswordAttempts
+	}
+
+	// Record the failure so that the attempts are tracked across requests
+	var payload []byte
+	if payload, err = json.Marshal(s); err != nil {
+		return fmt.Errorf("could not marshal secret context: %s", err)
+	}
+
+	if err = s.AddVersion(ctx, SuffixMetadata, payload); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("could not record incorrect password attempt")
+	}
+	return ErrNotAuthorized
+}
+
 // VerifyPassword checks that the password matches the dervied password otherwise errors.
 func (s *SecretContext) VerifyPassword(password string) (err error) {
 	if !s.loaded {
@@ -533,11 +777,14 @@ func (s *SecretContext) VerifyPassword(password string) (err error) {
 	if s.Password != "" {
 		if password == "" {
 			log.Debug().Msg("password required but no password supplied")
-			return ErrNotAuthorized
+			return ErrPasswordRequired
 		}
 
 		var verified bool
-		if verified, err = passwd.VerifyDerivedKey(s.Password, password); err != nil {
+		started := time.Now()
+		verified, err = passwd.VerifyDerivedKey(s.Password, password)
+		metrics.PasswordVerification.Observe(time.Since(started).Seconds())
+		if err != nil {
 			return err
 		}
 		if !verified {
@@ -4,10 +4,14 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/rotationalio/whisper/pkg/config"
+	"github.com/rotationalio/whisper/pkg/metrics"
 	"github.com/rotationalio/whisper/pkg/vault"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
@@ -171,6 +175,188 @@ func (s *VaultTestSuite) TestDestroyPassword() {
 	s.ErrorIs(err, vault.ErrSecretNotFound)
 }
 
+func (s *VaultTestSuite) TestPasswordAttempts() {
+	token := createToken()
+	secret := s.vault.With(token)
+	secret.Accesses = -1
+	secret.MaxFailures = 2
+	secret.Created = time.Now()
+	secret.Expires = time.Now().Add(24 * time.Hour)
+	s.NoError(secret.SetPassword("theunlock"))
+	s.NoError(secret.New(context.TODO(), "the eagle flies at midnight"))
+
+	// A missing password does not count as a failure
+	for i := 0; i < 3; i++ {
+		_, _, err := s.vault.With(token).Fetch(context.TODO(), "")
+		s.ErrorIs(err, vault.ErrNotAuthorized)
+	}
+
+	// The first incorrect password is recorded
+	_, destroyed, err := s.vault.With(token).Fetch(context.TODO(), "opensaysme")
+	s.ErrorIs(err, vault.ErrNotAuthorized)
+	s.False(destroyed)
+
+	secret = s.vault.With(token)
+	s.NoError(secret.Load(context.TODO(), false))
+	s.Equal(1, secret.Failures)
+
+	// The second incorrect password destroys the secret
+	err = s.vault.With(token).Destroy(context.TODO(), "opensaysme")
+	s.ErrorIs(err, vault.ErrPasswordAttempts)
+
+	_, _, err = s.vault.With(token).Fetch(context.TODO(), "theunlock")
+	s.ErrorIs(err, vault.ErrSecretNotFound)
+}
+
+func (s *VaultTestSuite) TestConcurrentPasswordAttempts() {
+	token := createToken()
+	secret := s.vault.With(token)
+	secret.Accesses = -1
+	secret.MaxFailures = 3
+	secret.Created = time.Now()
+	secret.Expires = time.Now().Add(24 * time.Hour)
+	s.NoError(secret.SetPassword("theunlock"))
+	s.NoError(secret.New(context.TODO(), "the eagle flies at midnight"))
+
+	// Incorrect passwords guessed in parallel are all counted
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := s.vault.With(token).Fetch(context.TODO(), "opensaysme")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	counts := make(map[error]int)
+	for err := range errs {
+		switch {
+		case errors.Is(err, vault.ErrPasswordAttempts):
+			counts[vault.ErrPasswordAttempts]++
+		case errors.Is(err, vault.ErrNotAuthorized):
+			counts[vault.ErrNotAuthorized]++
+		case errors.Is(err, vault.ErrSecretNotFound):
+			counts[vault.ErrSecretNotFound]++
+		default:
+			s.Fail("unexpected error", "%v", err)
+		}
+	}
+
+	s.Equal(map[error]int{vault.ErrNotAuthorized: 2, vault.ErrPasswordAttempts: 1, vault.ErrSecretNotFound: 5}, counts)
+
+	_, _, err := s.vault.With(token).Fetch(context.TODO(), "theunlock")
+	s.ErrorIs(err, vault.ErrSecretNotFound)
+}
+
+func (s *VaultTestSuite) TestSecretRequestFlow() {
+	// Create the secret request with an owner token as the password
+	token := createToken()
+	request := s.vault.With(token)
+	request.Accesses = 1
+	request.Created = time.Now()
+	request.Expires = time.Now().Add(24 * time.Hour)
+	s.NoError(request.SetPassword("ownertoken"))
+	s.NoError(request.NewRequest(context.TODO()))
+
+	// The owner cannot fetch the request until it has been fulfilled
+	pending := s.vault.With(token)
+	_, _, err := pending.Fetch(context.TODO(), "ownertoken")
+	s.ErrorIs(err, vault.ErrRequestPending)
+
+	// Cannot respond to the request without loading it first
+	response := s.vault.With(token)
+	s.ErrorIs(response.Respond(context.TODO(), "the eagle flies at midnight"), vault.ErrNotLoaded)
+
+	// Respond to the request
+	stale := s.vault.With(token)
+	s.NoError(stale.Load(context.TODO(), false))
+	s.NoError(response.Load(context.TODO(), false))
+	s.True(response.Pending())
+	response.Filename = "eagle.txt"
+	s.NoError(response.Respond(context.TODO(), "the eagle flies at midnight"))
+	s.False(response.Pending())
+
+	// The request cannot be responded to twice, even by a context loaded before the response
+	s.ErrorIs(stale.Respond(context.TODO(), "a different secret"), vault.ErrRequestFulfilled)
+
+	again := s.vault.With(token)
+	s.NoError(again.Load(context.TODO(), false))
+	s.ErrorIs(again.Respond(context.TODO(), "a different secret"), vault.ErrRequestFulfilled)
+
+	// Only the owner can fetch the response
+	secret := s.vault.With(token)
+	_, _, err = secret.Fetch(context.TODO(), "")
+	s.ErrorIs(err, vault.ErrNotAuthorized)
+
+	secret = s.vault.With(token)
+	whisper, destroyed, err := secret.Fetch(context.TODO(), "ownertoken")
+	s.NoError(err)
+	s.True(destroyed)
+	s.Equal("the eagle flies at midnight", whisper)
+	s.Equal("eagle.txt", secret.Filename)
+}
+
+func (s *VaultTestSuite) TestRespondKeepsFailures() {
+	token := createToken()
+	request := s.vault.With(token)
+	request.Accesses = 1
+	request.MaxFailures = 3
+	request.Created = time.Now()
+	request.Expires = time.Now().Add(24 * time.Hour)
+	s.NoError(request.SetPassword("ownertoken"))
+	s.NoError(request.NewRequest(context.TODO()))
+
+	// The owner guesses the wrong token after the response is loaded
+	response := s.vault.With(token)
+	s.NoError(response.Load(context.TODO(), false))
+	_, _, err := s.vault.With(token).Fetch(context.TODO(), "wrongtoken")
+	s.ErrorIs(err, vault.ErrNotAuthorized)
+
+	// The response does not overwrite the failed attempt
+	response.Filename = "eagle.txt"
+	s.NoError(response.Respond(context.TODO(), "the eagle flies at midnight"))
+	s.Equal(1, response.Failures)
+
+	meta := s.vault.With(token)
+	s.NoError(meta.Load(context.TODO(), false))
+	s.Equal(1, meta.Failures)
+	s.True(meta.Fulfilled)
+	s.Equal("eagle.txt", meta.Filename)
+}
+
+func (s *VaultTestSuite) TestDestroyPendingRequest() {
+	token := createToken()
+	request := s.vault.With(token)
+	request.Created = time.Now()
+	request.Expires = time.Now().Add(24 * time.Hour)
+	s.NoError(request.SetPassword("ownertoken"))
+	s.NoError(request.NewRequest(context.TODO()))
+
+	// A pending request has no secret but should still be destroyed
+	request = s.vault.With(token)
+	s.NoError(request.Destroy(context.TODO(), "ownertoken"))
+
+	exists, err := s.vault.Check(context.TODO(), token)
+	s.NoError(err)
+	s.False(exists)
+}
+
+func (s *VaultTestSuite) TestRespondNotRequest() {
+	token := createToken()
+	secret := s.vault.With(token)
+	secret.Created = time.Now()
+	secret.Expires = time.Now().Add(24 * time.Hour)
+	s.NoError(secret.New(context.TODO(), "not a secret request"))
+
+	secret = s.vault.With(token)
+	s.NoError(secret.Load(context.TODO(), false))
+	s.ErrorIs(secret.Respond(context.TODO(), "overwrite"), vault.ErrNotRequest)
+}
+
 func (s *VaultTestSuite) TestCheckEmpty() {
 	token := createToken()
 	found, err := s.vault.Check(context.TODO(), token)
@@ -216,6 +402,27 @@ func (s *VaultTestSuite) TestSecretContextMethods() {
 	s.NotZero(secret.LastAccessed)
 }
 
+func (s *VaultTestSuite) TestFetchInvalidMetrics() {
+	expired := testutil.ToFloat64(metrics.SecretEvents.WithLabelValues(metrics.Expired))
+	exhausted := testutil.ToFloat64(metrics.SecretEvents.WithLabelValues(metrics.Exhausted))
+
+	// Store a secret that has already been retrieved the allowed number of times
+	token := createToken()
+	secret := s.vault.With(token)
+	secret.Accesses = 1
+	secret.Created = time.Now()
+	secret.Expires = time.Now().Add(24 * time.Hour)
+	secret.Access()
+	s.NoError(secret.New(context.TODO(), "the eagle flies at midnight"))
+
+	// Exhausted secrets are destroyed and are not counted as expired
+	_, destroyed, err := s.vault.With(token).Fetch(context.TODO(), "")
+	s.ErrorIs(err, vault.ErrSecretNotFound)
+	s.True(destroyed)
+	s.Equal(exhausted+1, testutil.ToFloat64(metrics.SecretEvents.WithLabelValues(metrics.Exhausted)))
+	s.Equal(expired, testutil.ToFloat64(metrics.SecretEvents.WithLabelValues(metrics.Expired)))
+}
+
 func (s *VaultTestSuite) TestSecretContextPassword() {
 	secret := s.vault.With(createToken())
 	s.Empty(secret.Password)
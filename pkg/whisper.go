@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,23 +16,33 @@ import (
 	sentrygin "github.com/getsentry/sentry-go/gin"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/rotationalio/whisper/pkg/admin"
+	v1 "github.com/rotationalio/whisper/pkg/api/v1"
+	"github.com/rotationalio/whisper/pkg/audit"
 	"github.com/rotationalio/whisper/pkg/config"
+	"github.com/rotationalio/whisper/pkg/health"
+	"github.com/rotationalio/whisper/pkg/listener"
 	"github.com/rotationalio/whisper/pkg/logger"
+	"github.com/rotationalio/whisper/pkg/metrics"
+	"github.com/rotationalio/whisper/pkg/mtls"
+	"github.com/rotationalio/whisper/pkg/notify"
 	"github.com/rotationalio/whisper/pkg/sentry"
+	"github.com/rotationalio/whisper/pkg/slack"
+	"github.com/rotationalio/whisper/pkg/tracing"
 	"github.com/rotationalio/whisper/pkg/vault"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
 )
 
 func init() {
-	// Initialize zerolog with GCP logging requirements
-	zerolog.TimeFieldFormat = time.RFC3339
-	zerolog.TimestampFieldName = logger.GCPFieldKeyTime
-	zerolog.MessageFieldName = logger.GCPFieldKeyMsg
-
-	// Add the severity hook for GCP logging
-	var gcpHook logger.SeverityHook
-	log.Logger = zerolog.New(os.Stdout).Hook(gcpHook).With().Timestamp().Logger()
+	// Log in the default GCP format until the server is configured
+	log.Logger, _, _ = logger.New(logger.Config{Format: logger.GCP})
+
+	// Use the global logger when a context does not have a request-scoped logger
+	zerolog.DefaultContextLogger = &log.Logger
 }
 
 const ServiceName = "whisper"
@@ -58,9 +70,13 @@ func New(conf config.Config) (s *Server, err error) {
 		}
 	}
 
-	// Set human readable logging if specified
-	if conf.ConsoleLog {
-		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	// Tokens are hashed in the logs with the redaction key
+	logger.SetRedactionKey(conf.RedactionKey)
+
+	// Configure the log format and output
+	var logs io.Closer
+	if logs, err = logger.Configure(conf.Log); err != nil {
+		return nil, err
 	}
 
 	// Check that a cryptographically secure PNRG is available
@@ -69,7 +85,7 @@ func New(conf config.Config) (s *Server, err error) {
 	}
 
 	// Create the server and prepare to serve
-	s = &Server{conf: conf, errc: make(chan error, 1), healthy: false}
+	s = &Server{conf: conf, live: conf, logs: logs, maintenance: maintenanceMode(conf), errc: make(chan error, 1), healthy: false}
 
 	// Create the vault to store secrets in (Google Secret Manager)
 	// Note that if conf.Google.Testing is true, a mock secret manager will be created
@@ -78,6 +94,54 @@ func New(conf config.Config) (s *Server, err error) {
 	}
 	log.Debug().Msg("connected to google secret manager")
 
+	// Monitor the dependencies of the server so that it is not ready if they fail
+	s.health = health.New(conf.Health)
+	s.health.Register("vault", s.checkVault)
+	s.health.Register("prng", func(context.Context) error { return checkAvailablePRNG() })
+
+	// Create the notifiers that deliver secret lifecycle events
+	if conf.Webhooks.Enabled {
+		var hooks *notify.Webhooks
+		if hooks, err = notify.NewWebhooks(conf.Webhooks); err != nil {
+			return nil, err
+		}
+		s.notifiers = append(s.notifiers, hooks)
+		log.Debug().Strs("domains", conf.Webhooks.AllowedDomains).Msg("webhook notifications enabled")
+	}
+
+	if conf.Email.Enabled {
+		var mailer *notify.Mailer
+		if mailer, err = notify.NewMailer(conf.Email); err != nil {
+			return nil, err
+		}
+		s.notifiers = append(s.notifiers, mailer)
+		log.Debug().Str("host", conf.Email.Host).Msg("email notifications enabled")
+	}
+
+	// Open the audit log to record security events
+	if conf.Audit.Enabled {
+		if s.auditor, err = audit.New(conf.Audit); err != nil {
+			return nil, err
+		}
+		log.Debug().Msg("audit log enabled")
+	}
+
+	// Create the Slack client if the slash command integration is enabled
+	if conf.Slack.Enabled() {
+		if s.slack, err = slack.New(conf.Slack); err != nil {
+			return nil, err
+		}
+		log.Debug().Msg("slack integration enabled")
+	}
+
+	// Export traces to the OpenTelemetry collector
+	if conf.Tracing.Enabled {
+		if s.tracing, err = tracing.Setup(conf.Tracing, Version()); err != nil {
+			return nil, err
+		}
+		log.Debug().Str("endpoint", conf.Tracing.Endpoint).Msg("opentelemetry tracing enabled")
+	}
+
 	// Create the Gin router and setup its routes
 	gin.SetMode(conf.Mode)
 	s.router = gin.New()
@@ -91,14 +155,50 @@ func New(conf config.Config) (s *Server, err error) {
 		return nil, err
 	}
 
+	// Serve HTTP/2 requests without TLS if enabled; HTTP/2 is always enabled with TLS
+	var handler http.Handler = s.router
+	if conf.HTTP.H2C {
+		handler = h2c.NewHandler(s.router, &http2.Server{IdleTimeout: conf.HTTP.IdleTimeout})
+	}
+
 	// Create the http server
 	s.srv = &http.Server{
-		Addr:         s.conf.BindAddr,
-		Handler:      s.router,
-		ErrorLog:     nil,
-		ReadTimeout:  20 * time.Second,
-		WriteTimeout: 20 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		Addr:              s.conf.BindAddr,
+		Handler:           handler,
+		ErrorLog:          nil,
+		ReadTimeout:       conf.HTTP.ReadTimeout,
+		ReadHeaderTimeout: conf.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      conf.HTTP.WriteTimeout,
+		IdleTimeout:       conf.HTTP.IdleTimeout,
+	}
+
+	// Terminate TLS in the server rather than relying on a load balancer if configured
+	if conf.TLS.Enabled() {
+		if s.certs, err = mtls.LoadCertificates(conf.TLS.CertFile, conf.TLS.KeyFile); err != nil {
+			return nil, err
+		}
+
+		if s.srv.TLSConfig, err = conf.TLS.TLSConfig(s.certs); err != nil {
+			return nil, err
+		}
+		log.Debug().Bool("verify_clients", conf.TLS.VerifyClients()).Msg("tls enabled")
+	}
+
+	// Serve the gRPC API on a separate address with the same TLS configuration
+	if conf.GRPC.Enabled() {
+		s.grpc = s.newGRPC()
+		log.Debug().Str("addr", conf.GRPC.BindAddr).Msg("grpc api enabled")
+	}
+
+	// Serve metrics on a separate admin server if a bind address is specified
+	if conf.Metrics.Enabled && conf.Metrics.BindAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle(conf.Metrics.Path, metrics.Handler())
+		s.metrics = &http.Server{
+			Addr:              conf.Metrics.BindAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
 	}
 
 	log.Debug().Msg("created http server with gin router")
@@ -107,29 +207,97 @@ func New(conf config.Config) (s *Server, err error) {
 
 type Server struct {
 	sync.RWMutex
-	conf    config.Config        // configuration of the API server
-	srv     *http.Server         // handle to a custom http server with specified API defaults
-	router  *gin.Engine          // the http handler and associated middlware
-	vault   *vault.SecretManager // storage for all secrets the whisper application manages
-	healthy bool                 // application state of the server for health checks
-	ready   bool                 // application state of the server for ready checks
-	started time.Time            // the timestamp when the server was started
-	errc    chan error           // synchronize shutdown gracefully
+	conf        config.Config               // configuration of the API server
+	live        config.Config               // configuration including the settings changed by a reload
+	cors        gin.HandlerFunc             // allows cross-origin requests from the allowed origins
+	srv         *http.Server                // handle to a custom http server with specified API defaults
+	certs       *mtls.Certificates          // the server certificate if tls is enabled, reloaded when it changes
+	unwatch     context.CancelFunc          // stops watching the certificate files for changes
+	metrics     *http.Server                // serves metrics on a separate admin port if configured
+	grpc        *grpc.Server                // serves the gRPC API on a separate address if configured
+	router      *gin.Engine                 // the http handler and associated middlware
+	vault       *vault.SecretManager        // storage for all secrets the whisper application manages
+	notifiers   []notify.Notifier           // deliver secret lifecycle events to secret creators
+	slack       *slack.Client               // post whisper links back to slack if the integration is enabled
+	tracing     func(context.Context) error // flush and stop the trace exporter on shutdown
+	logs        io.Closer                   // closes the log file if logging to a file
+	auditor     *audit.Logger               // tamper-evident log of security events; nil if disabled
+	reaper      *reaper                     // destroys expired and orphaned secrets in the background
+	health      *health.Monitor             // checks the dependencies of the server in the background
+	confirms    admin.Confirmations         // issues the confirmation tokens required to purge all secrets
+	maintenance v1.MaintenanceMode          // blocks requests to the api while the server is being maintained
+	healthy     bool                        // application state of the server for health checks
+	ready       bool                        // application state of the server for ready checks
+	draining    bool                        // not ready but requests are served until shutdown
+	started     time.Time                   // the timestamp when the server was started
+	errc        chan error                  // synchronize shutdown gracefully
 }
 
 func (s *Server) Serve() (err error) {
 	s.osSignals()
 	s.SetStatus(true, true)
 
-	if s.conf.Maintenance {
-		log.Warn().Msg("starting server in maintenance mode")
+	if mode := s.Maintenance(); mode.Enabled {
+		log.Warn().Bool("read_only", mode.ReadOnly).Msg("starting server in maintenance mode")
+	}
+
+	// Listen on a TCP address, a unix socket, or the socket passed by systemd
+	var sock net.Listener
+	if sock, err = listener.Listen(s.conf.BindAddr); err != nil {
+		return err
+	}
+
+	// The gRPC API is served on its own listener
+	var rpcsock net.Listener
+	if s.grpc != nil {
+		if rpcsock, err = listener.Listen(s.conf.GRPC.BindAddr); err != nil {
+			sock.Close()
+			return err
+		}
 	}
 
 	s.started = time.Now()
-	log.Info().Str("addr", s.conf.BindAddr).Msg("whisper server started")
+	log.Info().Str("addr", sock.Addr().String()).Str("network", sock.Addr().Network()).Bool("tls", s.certs != nil).Msg("whisper server started")
+
+	if s.metrics != nil {
+		go func() {
+			log.Info().Str("addr", s.metrics.Addr).Msg("metrics server started")
+			if err := s.metrics.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				sentry.Error(nil).Err(err).Msg("metrics server stopped")
+			}
+		}()
+	}
 
-	if err = s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		return err
+	if s.grpc != nil {
+		go func() {
+			log.Info().Str("addr", rpcsock.Addr().String()).Str("network", rpcsock.Addr().Network()).Msg("grpc server started")
+			if err := s.grpc.Serve(rpcsock); err != nil {
+				sentry.Error(nil).Err(err).Msg("grpc server stopped")
+			}
+		}()
+	}
+
+	if s.conf.Reaper.Enabled {
+		s.startReaper()
+	}
+
+	if s.conf.Health.Enabled {
+		s.health.Start()
+	}
+
+	if s.certs != nil {
+		var ctx context.Context
+		ctx, s.unwatch = context.WithCancel(context.Background())
+		go s.certs.Watch(ctx, s.conf.TLS.ReloadInterval)
+
+		// The certificate is provided by the TLS config so that it can be reloaded
+		if err = s.srv.ServeTLS(sock, "", ""); err != nil && err != http.ErrServerClosed {
+			return err
+		}
+	} else {
+		if err = s.srv.Serve(sock); err != nil && err != http.ErrServerClosed {
+			return err
+		}
 	}
 
 	if err = <-s.errc; err != nil {
@@ -140,10 +308,22 @@ func (s *Server) Serve() (err error) {
 
 func (s *Server) Shutdown() (err error) {
 	log.Info().Msg("gracefully shutting down whisper server")
+
+	// Report that the server is not ready while continuing to serve requests so that
+	// load balancers can stop routing requests to the server before it stops listening.
+	if drain := s.conf.HTTP.DrainWindow; drain > 0 {
+		s.Lock()
+		s.ready = false
+		s.draining = true
+		s.Unlock()
+
+		log.Info().Dur("window", drain).Msg("draining requests before shutdown")
+		time.Sleep(drain)
+	}
 	s.SetStatus(false, false)
 
 	errs := make([]error, 0)
-	ctx, cancel := context.WithTimeout(context.Background(), 35*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), s.conf.HTTP.ShutdownTimeout)
 	defer cancel()
 
 	s.srv.SetKeepAlivesEnabled(false)
@@ -152,9 +332,80 @@ func (s *Server) Shutdown() (err error) {
 		errs = append(errs, err)
 	}
 
+	// Allow in-flight gRPC requests to finish until the shutdown timeout
+	if s.grpc != nil {
+		stopped := make(chan struct{})
+		go func() {
+			s.grpc.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			s.grpc.Stop()
+			sentry.Error(nil).Err(ctx.Err()).Msg("could not gracefully stop grpc server")
+			errs = append(errs, ctx.Err())
+		}
+	}
+
+	if s.unwatch != nil {
+		s.unwatch()
+	}
+
+	if s.metrics != nil {
+		if err = s.metrics.Shutdown(ctx); err != nil {
+			sentry.Error(nil).Err(err).Msg("could not shutdown metrics server")
+			errs = append(errs, err)
+		}
+	}
+
+	// Flush any spans that have not been exported
+	if s.tracing != nil {
+		if err = s.tracing(ctx); err != nil {
+			sentry.Error(nil).Err(err).Msg("could not shutdown trace exporter")
+			errs = append(errs, err)
+		}
+	}
+
+	if err = s.health.Stop(ctx); err != nil {
+		sentry.Error(nil).Err(err).Msg("could not stop health monitor")
+		errs = append(errs, err)
+	}
+
+	// Stop the reaper before the vault connection is no longer needed
+	if s.reaper != nil {
+		if err = s.reaper.stop(ctx); err != nil {
+			sentry.Error(nil).Err(err).Msg("could not stop secret reaper")
+			errs = append(errs, err)
+		}
+	}
+
+	// Close the audit log after requests have stopped
+	if err = s.auditor.Close(); err != nil {
+		sentry.Error(nil).Err(err).Msg("could not close audit log")
+		errs = append(errs, err)
+	}
+
+	// Deliver any pending notifications after requests have stopped
+	for _, notifier := range s.notifiers {
+		if err = notifier.Shutdown(ctx); err != nil {
+			sentry.Error(nil).Err(err).Msg("could not shutdown notifier")
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		log.Debug().Msg("successful shutdown of whisper server")
+	}
+
+	// Close the log file last so that all shutdown messages are written
+	if err = s.logs.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
 	switch len(errs) {
 	case 0:
-		log.Debug().Msg("successful shutdown of whisper server")
 		close(s.errc)
 		return nil
 	case 1:
@@ -172,6 +423,12 @@ func (s *Server) Routes() http.Handler {
 }
 
 func (s *Server) setupRoutes() (err error) {
+	// OpenTelemetry tracing is a vendor-neutral alternative to Sentry performance tracking
+	var otel gin.HandlerFunc
+	if s.conf.Tracing.Enabled {
+		otel = tracing.Middleware()
+	}
+
 	// Instantiate Sentry handlers
 	var tags gin.HandlerFunc
 	if s.conf.Sentry.UseSentry() {
@@ -185,22 +442,37 @@ func (s *Server) setupRoutes() (err error) {
 		tracing = sentry.TrackPerformance(tagmap)
 	}
 
-	// Setup CORS configuration
-	corsConf := cors.Config{
-		AllowOrigins:     s.conf.AllowOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-CSRF-TOKEN", "sentry-trace", "baggage"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
+	// The CORS middleware is replaced when the allowed origins are reloaded
+	s.cors = cors.New(corsConfig(s.conf.AllowOrigins))
+
+	// Identify clients by their certificates if mutual TLS is enabled
+	var identify gin.HandlerFunc
+	if s.conf.TLS.Enabled() && s.conf.TLS.VerifyClients() {
+		identify = mtls.Identify()
+	}
+
+	// Record request metrics if enabled
+	var instrument gin.HandlerFunc
+	if s.conf.Metrics.Enabled {
+		instrument = metrics.Middleware()
 	}
 
 	// Application Middleware
 	// NOTE: ordering is important to how middleware is handled
 	middlewares := []gin.HandlerFunc{
+		// The request ID must be set before logging so that it is included in the logs
+		sentry.RequestID(),
+
+		// Verified client certificates identify the client for authorization
+		identify,
+
 		// Logging should be on the outside so we can record the correct latency of requests
 		// NOTE: logging panics will not recover
 		logger.GinLogger(ServiceName, Version()),
 
+		// Metrics are also recorded on the outside to include the latency of middleware
+		instrument,
+
 		// Panic recovery middleware
 		gin.Recovery(),
 		sentrygin.New(sentrygin.Options{
@@ -214,8 +486,11 @@ func (s *Server) setupRoutes() (err error) {
 		// Tracing helps us measure performance metrics with Sentry
 		tracing,
 
+		// Export spans to the OpenTelemetry collector
+		otel,
+
 		// CORS configuration allows the front-end to make cross-origin requests
-		cors.New(corsConf),
+		s.CORS(),
 
 		// Mainenance mode handling
 		s.Available(),
@@ -237,10 +512,45 @@ func (s *Server) setupRoutes() (err error) {
 		// Heartbeat route
 		v1.GET("/status", s.Status)
 
+		// Publish the policy so that clients can validate secrets before submitting them
+		v1.GET("/policy", s.Policy)
+
+		// Describe the API so that clients can be generated from the specification
+		v1.GET("/openapi.json", s.OpenAPI)
+
 		// Secrets REST resource
 		v1.POST("/secrets", s.CreateSecret)
+		v1.POST("/secrets/batch", s.CreateBatch)
 		v1.GET("/secrets/:token", s.FetchSecret)
 		v1.DELETE("/secrets/:token", s.DestroySecret)
+
+		// Secret requests REST resource
+		v1.POST("/requests", s.RequestSecret)
+		v1.POST("/requests/:token", s.RespondSecret)
+		v1.GET("/requests/:token", s.FetchResponse)
+
+		// Slack slash command and modal interactions, verified by the signing secret
+		if s.conf.Slack.Enabled() {
+			slackapi := v1.Group("/slack", slack.Authenticate(s.conf.Slack.SigningSecret))
+			slackapi.POST("/commands", s.SlackCommand)
+			slackapi.POST("/interactions", s.SlackInteraction)
+		}
+	}
+
+	// Operator routes to inspect and destroy secrets, authenticated by the admin token
+	if s.conf.Admin.Enabled() {
+		adminapi := s.router.Group("/admin", s.AdminAuthenticate())
+		adminapi.GET("/stats", s.AdminStats)
+		adminapi.GET("/secrets", s.AdminListSecrets)
+		adminapi.DELETE("/secrets/:token", s.AdminDestroySecret)
+		adminapi.POST("/purge", s.AdminPurge)
+		adminapi.GET("/maintenance", s.AdminMaintenance)
+		adminapi.PUT("/maintenance", s.AdminSetMaintenance)
+	}
+
+	// Serve metrics from the API unless they are served on a separate admin port
+	if s.conf.Metrics.Enabled && s.conf.Metrics.BindAddr == "" {
+		s.router.GET(s.conf.Metrics.Path, gin.WrapH(metrics.Handler()))
 	}
 
 	// Kubernetes liveness probes
@@ -272,5 +582,16 @@ func (s *Server) osSignals() {
 		<-quit
 		s.Shutdown()
 	}()
-	log.Debug().Msg("listening for OS signals SIGINT and SIGTERM")
+
+	// Reload the configuration without a restart on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := s.Reload(); err != nil {
+				sentry.Error(nil).Err(err).Msg("could not reload configuration")
+			}
+		}
+	}()
+	log.Debug().Msg("listening for OS signals SIGINT, SIGTERM, and SIGHUP")
 }
@@ -30,7 +30,7 @@ const ShowFile: React.FC<ShowFileProps> = ({ file, uploadedAt, onDelete, loading
 
 	const handleDownloadClick = () => {
 		if (file) {
-			const url = window.URL.createObjectURL(new Blob([file]));
+			const url = window.URL.createObjectURL(new Blob([file], { type: file.type }));
 			const link = document.createElement("a");
 			link.href = url;
 			link.target = "_blank";
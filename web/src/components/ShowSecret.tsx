@@ -67,7 +67,7 @@ const ShowSecret: React.FC<ShowSecretProps> = ({ secret, token }) => {
 
 	React.useEffect(() => {
 		if (secret?.is_base64) {
-			const _file = dataURLtoFile(secret.secret, secret.filename);
+			const _file = dataURLtoFile(secret.secret, secret.filename, secret.content_type);
 			setFile(_file);
 		}
 	}, []);
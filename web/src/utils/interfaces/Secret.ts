@@ -5,6 +5,9 @@ export interface Secret {
 	lifetime: string;
 	filename?: string;
 	is_base64: boolean;
+	content_type?: string;
+	size?: number;
+	sha256?: string;
 	destroyed?: boolean;
 	created?: Date;
 }
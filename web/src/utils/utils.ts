@@ -54,7 +54,7 @@ function encodeFileToBase64(file: File): Promise<string | ArrayBuffer | null> {
 	});
 }
 
-function dataURLtoFile(dataurl: string, filename?: string): File {
+function dataURLtoFile(dataurl: string, filename?: string, contentType?: string): File {
 	const fileName = filename || "";
 	const bstr = atob(dataurl);
 
@@ -65,7 +65,7 @@ function dataURLtoFile(dataurl: string, filename?: string): File {
 		u8arr[n] = bstr.charCodeAt(n);
 	}
 
-	return new File([u8arr], fileName);
+	return new File([u8arr], fileName, { type: contentType || "" });
 }
 
 function formatBytes(bytes: number, decimals = 2) {